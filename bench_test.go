@@ -0,0 +1,94 @@
+package gcp
+
+// The benchmarks are internal to the package to compare the Scanner
+// with parseTLVs, which backs Process.
+
+import "testing"
+
+// benchREX returns a REX Read Response with the identification of an
+// RPD and n IfEnet entries, like those a collector polls RPDs for.
+func benchREX(n int) []byte {
+	id := EncodeTLV(50, EncodeTLV(19,
+		EncodeTLV(1, []byte("Cisco")),
+		EncodeTLV(2, []byte{0, 9}),
+		EncodeTLV(3, []byte("RPHY-RPD")),
+		EncodeTLV(4, []byte{0xa0, 0xf8, 0x49, 0x6f, 0x43, 0x1c}),
+		EncodeTLV(9, []byte("CAT2133E0A5")),
+	))
+	var ifs [][]byte
+	for i := 1; i <= n; i++ {
+		ifs = append(ifs, EncodeTLV(8,
+			EncodeTLV(1, []byte{byte(i)}),
+			EncodeTLV(2, []byte("vbh0")),
+			EncodeTLV(6, []byte{0, 0, 5, 0xdc}),
+			EncodeTLV(7, []byte{0xa0, 0xf8, 0x49, 0x6f, 0x43, byte(i)}),
+			EncodeTLV(8, []byte{1}),
+			EncodeTLV(9, []byte{1}),
+			EncodeTLV(11, []byte{0, 0, 0x27, 0x10}),
+		))
+	}
+	return EncodeSequence(TypeREX, 1, OpReadResponse, EncodeTLV(19, []byte{0}), id, EncodeTLV(100, ifs...))
+}
+
+func BenchmarkParseTLVs(b *testing.B) {
+	v := benchREX(8)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(v)))
+	for i := 0; i < b.N; i++ {
+		if _, err := parseTLVs(v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkProcess(b *testing.B) {
+	res := &EDSRes{TransactionID: 1, VendorID: CableLabs, DataStr: benchREX(8)}
+	b.ReportAllocs()
+	b.SetBytes(int64(len(res.DataStr)))
+	for i := 0; i < b.N; i++ {
+		if _, g := res.Process(); g == nil {
+			b.Fatal("no data structures")
+		}
+	}
+}
+
+func BenchmarkScanner(b *testing.B) {
+	v := benchREX(8)
+	var s Scanner
+	b.ReportAllocs()
+	b.SetBytes(int64(len(v)))
+	for i := 0; i < b.N; i++ {
+		s.Reset(v)
+		for s.Next() {
+		}
+		if err := s.Err(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkScannerMatch pulls the OperStatus of every IfEnet entry,
+// skipping the identification.
+func BenchmarkScannerMatch(b *testing.B) {
+	v := benchREX(8)
+	var s Scanner
+	b.ReportAllocs()
+	b.SetBytes(int64(len(v)))
+	for i := 0; i < b.N; i++ {
+		s.Reset(v)
+		var up int
+		for s.Next() {
+			switch {
+			case s.Match("2.9.50"):
+				s.Skip()
+			case s.Match("2.9.100.8.9"):
+				if u, _ := s.Uint(); u == 1 {
+					up++
+				}
+			}
+		}
+		if up != 8 {
+			b.Fatalf("IfEnet entries up got: %d, want: 8", up)
+		}
+	}
+}
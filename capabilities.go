@@ -2,7 +2,6 @@ package gcp
 
 import (
 	"fmt"
-	"log"
 )
 
 // A RpdCap is a RpdCapabilities TLV (Complex TLV).
@@ -19,13 +18,14 @@ func (t *RpdCap) newTLV(b byte) RCP {
 	case 19:
 		r := new(RpdIdf)
 		r.parentMsg = t.parentMsg
+		keep(&t.parentMsg.NTF.Sequence.RpdCapabilities.kept, "RpdIdentification", true)
 		return r
 	case 24:
 		r := new(DevLoc)
 		r.parentMsg = t.parentMsg
+		keep(&t.parentMsg.NTF.Sequence.RpdCapabilities.kept, "Device Location", true)
 		return r
 	default:
-		log.Printf("RpdCapabilities TLV type: %d not supported", int(b))
 		return nil
 	}
 }
@@ -40,6 +40,9 @@ func (t *RpdCap) parseTLVs(b []byte) ([]RCP, error) {
 		}
 
 		tlv := t.newTLV(b[i])
+		if tlv == nil {
+			return nil, errUnsupported("RpdCapabilities", b[i])
+		}
 
 		// Unmarshal at the current offset, up to the expected length.
 		if err := tlv.unmarshal(b[i : i+3+l]); err != nil {
@@ -165,7 +168,6 @@ func (t *RpdIdf) newTLV(b byte) RCP {
 		r.parentMsg = t.parentMsg
 		return r
 	default:
-		log.Printf("RpdIdentification TLV type: %d not supported", int(b))
 		return nil
 	}
 }
@@ -180,6 +182,9 @@ func (t *RpdIdf) parseTLVs(b []byte) ([]RCP, error) {
 		}
 
 		tlv := t.newTLV(b[i])
+		if tlv == nil {
+			return nil, errUnsupported("RpdIdentification", b[i])
+		}
 
 		// Unmarshal at the current offset, up to the expected length.
 		if err := tlv.unmarshal(b[i : i+3+l]); err != nil {
@@ -211,11 +216,15 @@ type VendorName struct{ TLV }
 // Name returns the type name of a VendorName TLV.
 func (t *VendorName) Name() string { return "VendorName" }
 
+// Kind returns the encoding of the value of a VendorName TLV.
+func (t *VendorName) Kind() ValueKind { return KindString }
+
 // Val returns the value a VendorName TLV carries.
 // A string identifying the RPD's manufacturer.
 func (t *VendorName) Val() interface{} {
 	s := stringVal(t.Value)
 	t.parentMsg.NTF.Sequence.RpdCapabilities.RpdIdentification.VendorName = s
+	keep(&t.parentMsg.NTF.Sequence.RpdCapabilities.RpdIdentification.kept, "VendorName", s == "")
 	return s
 }
 
@@ -225,6 +234,9 @@ type VendorID struct{ TLV }
 // Name returns the type name of a VendorId TLV.
 func (t *VendorID) Name() string { return "VendorId" }
 
+// Kind returns the encoding of the value of a VendorId TLV.
+func (t *VendorID) Kind() ValueKind { return KindUint16 }
+
 // Val returns the value a VendorId TLV carries.
 // An unsigned short with Vendor Id of the RPD's manufacturer
 func (t *VendorID) Val() interface{} {
@@ -239,11 +251,15 @@ type ModelNbr struct{ TLV }
 // Name returns the type name of a ModelNumber TLV.
 func (t *ModelNbr) Name() string { return "ModelNumber" }
 
+// Kind returns the encoding of the value of a ModelNumber TLV.
+func (t *ModelNbr) Kind() ValueKind { return KindString }
+
 // Val returns the value a ModelNumber TLV carries.
 // A string identifying the RPD's model number.
 func (t *ModelNbr) Val() interface{} {
 	s := stringVal(t.Value)
 	t.parentMsg.NTF.Sequence.RpdCapabilities.RpdIdentification.ModelNumber = s
+	keep(&t.parentMsg.NTF.Sequence.RpdCapabilities.RpdIdentification.kept, "ModelNumber", s == "")
 	return s
 }
 
@@ -253,6 +269,9 @@ type DevMacAddr struct{ TLV }
 // Name returns the type name of a DeviceMacAddress TLV.
 func (t *DevMacAddr) Name() string { return "DeviceMacAddress" }
 
+// Kind returns the encoding of the value of a DeviceMacAddress TLV.
+func (t *DevMacAddr) Kind() ValueKind { return KindMAC }
+
 // Val returns the value a DeviceMacAddress TLV carries.
 // The MAC address used to uniquely identify the RPD.
 func (t *DevMacAddr) Val() interface{} {
@@ -267,11 +286,15 @@ type CurSwVer struct{ TLV }
 // Name returns the type name of a CurrentSwVersion TLV.
 func (t *CurSwVer) Name() string { return "CurrentSwVersion" }
 
+// Kind returns the encoding of the value of a CurrentSwVersion TLV.
+func (t *CurSwVer) Kind() ValueKind { return KindString }
+
 // Val returns the value a CurrentSwVersion TLV carries.
 // A string representing the SW version currently running on of the RPD.
 func (t *CurSwVer) Val() interface{} {
 	s := stringVal(t.Value)
 	t.parentMsg.NTF.Sequence.RpdCapabilities.RpdIdentification.CurrentSwVersion = s
+	keep(&t.parentMsg.NTF.Sequence.RpdCapabilities.RpdIdentification.kept, "CurrentSwVersion", s == "")
 	return s
 }
 
@@ -281,12 +304,16 @@ type BootVer struct{ TLV }
 // Name returns the type name of a BootRomVersion TLV.
 func (t *BootVer) Name() string { return "BootRomVersion" }
 
+// Kind returns the encoding of the value of a BootRomVersion TLV.
+func (t *BootVer) Kind() ValueKind { return KindString }
+
 // Val returns the value a BootRomVersion TLV carries.
 // A string representing the BootRom version currently installed
 // on of the RPD.
 func (t *BootVer) Val() interface{} {
 	s := stringVal(t.Value)
 	t.parentMsg.NTF.Sequence.RpdCapabilities.RpdIdentification.BootRomVersion = s
+	keep(&t.parentMsg.NTF.Sequence.RpdCapabilities.RpdIdentification.kept, "BootRomVersion", s == "")
 	return s
 }
 
@@ -296,11 +323,15 @@ type DevDesc struct{ TLV }
 // Name returns the type name of a DeviceDescription TLV.
 func (t *DevDesc) Name() string { return "DeviceDescription" }
 
+// Kind returns the encoding of the value of a DeviceDescription TLV.
+func (t *DevDesc) Kind() ValueKind { return KindString }
+
 // Val returns the value a DeviceDescription TLV carries.
 // A string selected by the RPD manufacturer.
 func (t *DevDesc) Val() interface{} {
 	s := stringVal(t.Value)
 	t.parentMsg.NTF.Sequence.RpdCapabilities.RpdIdentification.DeviceDescription = s
+	keep(&t.parentMsg.NTF.Sequence.RpdCapabilities.RpdIdentification.kept, "DeviceDescription", s == "")
 	return s
 }
 
@@ -310,11 +341,15 @@ type DevAlias struct{ TLV }
 // Name returns the type name of a DeviceAlias TLV.
 func (t *DevAlias) Name() string { return "DeviceAlias" }
 
+// Kind returns the encoding of the value of a DeviceAlias TLV.
+func (t *DevAlias) Kind() ValueKind { return KindString }
+
 // Val returns the value a DeviceAlias TLV carries.
 // A string communicating device's name assigned by the operator.
 func (t *DevAlias) Val() interface{} {
 	s := stringVal(t.Value)
 	t.parentMsg.NTF.Sequence.RpdCapabilities.RpdIdentification.DeviceAlias = s
+	keep(&t.parentMsg.NTF.Sequence.RpdCapabilities.RpdIdentification.kept, "DeviceAlias", s == "")
 	return s
 }
 
@@ -324,11 +359,15 @@ type SerialNum struct{ TLV }
 // Name returns the type name of a SerialNumber TLV.
 func (t *SerialNum) Name() string { return "SerialNumber" }
 
+// Kind returns the encoding of the value of a SerialNumber TLV.
+func (t *SerialNum) Kind() ValueKind { return KindString }
+
 // Val returns the value a SerialNumber TLV carries.
 // A string representing device's serial number.
 func (t *SerialNum) Val() interface{} {
 	s := stringVal(t.Value)
 	t.parentMsg.NTF.Sequence.RpdCapabilities.RpdIdentification.SerialNumber = s
+	keep(&t.parentMsg.NTF.Sequence.RpdCapabilities.RpdIdentification.kept, "SerialNumber", s == "")
 	return s
 }
 
@@ -338,6 +377,9 @@ type UsBurRecID struct{ TLV }
 // Name returns the type name of a UsBurstReceiverVendorId TLV.
 func (t *UsBurRecID) Name() string { return "UsBurstReceiverVendorId" }
 
+// Kind returns the encoding of the value of a UsBurstReceiverVendorId TLV.
+func (t *UsBurRecID) Kind() ValueKind { return KindUint16 }
+
 // Val returns the value a UsBurstReceiverVendorId TLV carries.
 // An unsigned 16-bit integer with the IANA Enterprise Code of
 // the manufacturer of the RPD's US burst receiver.
@@ -353,6 +395,9 @@ type UsBurRecMod struct{ TLV }
 // Name returns the type name of a UsBurstReceiverModelNumber TLV.
 func (t *UsBurRecMod) Name() string { return "UsBurstReceiverModelNumber" }
 
+// Kind returns the encoding of the value of a UsBurstReceiverModelNumber TLV.
+func (t *UsBurRecMod) Kind() ValueKind { return KindString }
+
 // Val returns the value a UsBurstReceiverModelNumber TLV carries.
 // A string with the identifier of the model number of the RPD's US
 // burst receiver. If not available from the vendor, report a zerolength string.
@@ -360,6 +405,7 @@ func (t *UsBurRecMod) Val() interface{} {
 	// The length of this one is 0-16, not 0-255. Do I create a new stringVal func for this?
 	s := stringVal(t.Value)
 	t.parentMsg.NTF.Sequence.RpdCapabilities.RpdIdentification.UsBurstReceiverModelNumber = s
+	keep(&t.parentMsg.NTF.Sequence.RpdCapabilities.RpdIdentification.kept, "UsBurstReceiverModelNumber", s == "")
 	return s
 }
 
@@ -369,6 +415,9 @@ type UsBurRecDrv struct{ TLV }
 // Name returns the type name of a UsBurstReceiverDriverVersion TLV.
 func (t *UsBurRecDrv) Name() string { return "UsBurstReceiverDriverVersion" }
 
+// Kind returns the encoding of the value of a UsBurstReceiverDriverVersion TLV.
+func (t *UsBurRecDrv) Kind() ValueKind { return KindString }
+
 // Val returns the value a UsBurstReceiverDriverVersion TLV carries.
 // A string identifying the version of the driver of the RPD's US
 // burst receiver. A zero-length string indicates the driver version
@@ -377,6 +426,7 @@ func (t *UsBurRecDrv) Val() interface{} {
 	// The length of this one is 0-16, not 0-255. Do I create a new stringVal func for this?
 	s := stringVal(t.Value)
 	t.parentMsg.NTF.Sequence.RpdCapabilities.RpdIdentification.UsBurstReceiverDriverVersion = s
+	keep(&t.parentMsg.NTF.Sequence.RpdCapabilities.RpdIdentification.kept, "UsBurstReceiverDriverVersion", s == "")
 	return s
 }
 
@@ -386,6 +436,9 @@ type UsBurRecSN struct{ TLV }
 // Name returns the type name of a UsBurstReceiverSerialNumber TLV.
 func (t *UsBurRecSN) Name() string { return "UsBurstReceiverSerialNumber" }
 
+// Kind returns the encoding of the value of a UsBurstReceiverSerialNumber TLV.
+func (t *UsBurRecSN) Kind() ValueKind { return KindString }
+
 // Val returns the value a UsBurstReceiverSerialNumber TLV carries.
 // A string identifying the serial number of the RPD's US burst
 // receiver. A zero-length string indicates the serial number is not
@@ -394,6 +447,7 @@ func (t *UsBurRecSN) Val() interface{} {
 	// The length of this one is 0-16, not 0-255. Do I create a new stringVal func for this?
 	s := stringVal(t.Value)
 	t.parentMsg.NTF.Sequence.RpdCapabilities.RpdIdentification.UsBurstReceiverSerialNumber = s
+	keep(&t.parentMsg.NTF.Sequence.RpdCapabilities.RpdIdentification.kept, "UsBurstReceiverSerialNumber", s == "")
 	return s
 }
 
@@ -403,12 +457,16 @@ type RpdRcpPrVer struct{ TLV }
 // Name returns the type name of a RpdRcpProtocolVersion TLV.
 func (t *RpdRcpPrVer) Name() string { return "RpdRcpProtocolVersion" }
 
+// Kind returns the encoding of the value of a RpdRcpProtocolVersion TLV.
+func (t *RpdRcpPrVer) Kind() ValueKind { return KindString }
+
 // Val returns the value a RpdRcpProtocolVersion TLV carries.
 // A string identifying the RCP protocol version supported by the RPD.
 func (t *RpdRcpPrVer) Val() interface{} {
 	// The length of this one is 3-32, not 0-255. Do I create a new stringVal func for this?
 	s := stringVal(t.Value)
 	t.parentMsg.NTF.Sequence.RpdCapabilities.RpdIdentification.RpdRcpProtocolVersion = s
+	keep(&t.parentMsg.NTF.Sequence.RpdCapabilities.RpdIdentification.kept, "RpdRcpProtocolVersion", s == "")
 	return s
 }
 
@@ -418,12 +476,16 @@ type RpdRcpSchVer struct{ TLV }
 // Name returns the type name of a RpdRcpSchemaVersion TLV.
 func (t *RpdRcpSchVer) Name() string { return "RpdRcpSchemaVersion" }
 
+// Kind returns the encoding of the value of a RpdRcpSchemaVersion TLV.
+func (t *RpdRcpSchVer) Kind() ValueKind { return KindString }
+
 // Val returns the value a RpdRcpSchemaVersion TLV carries.
 // A string identifying the RCP schema version supported by the RPD.
 func (t *RpdRcpSchVer) Val() interface{} {
 	// The length of this one is 5-32, not 0-255. Do I create a new stringVal func for this?
 	s := stringVal(t.Value)
 	t.parentMsg.NTF.Sequence.RpdCapabilities.RpdIdentification.RpdRcpSchemaVersion = s
+	keep(&t.parentMsg.NTF.Sequence.RpdCapabilities.RpdIdentification.kept, "RpdRcpSchemaVersion", s == "")
 	return s
 }
 
@@ -433,11 +495,15 @@ type HwRev struct{ TLV }
 // Name returns the type name of a HwRevision TLV.
 func (t *HwRev) Name() string { return "HwRevision" }
 
+// Kind returns the encoding of the value of a HwRevision TLV.
+func (t *HwRev) Kind() ValueKind { return KindString }
+
 // Val returns the value a HwRevision TLV carries.
 // A string identifying the revision of the RPD hardware.
 func (t *HwRev) Val() interface{} {
 	s := stringVal(t.Value)
 	t.parentMsg.NTF.Sequence.RpdCapabilities.RpdIdentification.HwRevision = s
+	keep(&t.parentMsg.NTF.Sequence.RpdCapabilities.RpdIdentification.kept, "HwRevision", s == "")
 	return s
 }
 
@@ -447,6 +513,9 @@ type AsID struct{ TLV }
 // Name returns the type name of a AssetId TLV.
 func (t *AsID) Name() string { return "AssetId" }
 
+// Kind returns the encoding of the value of a AssetId TLV.
+func (t *AsID) Kind() ValueKind { return KindString }
+
 // Val returns the value a AssetId TLV carries.
 // A string containing asset identification of the RPD.
 // The default value is the zero-length string or "".
@@ -454,6 +523,7 @@ func (t *AsID) Val() interface{} {
 	// The length of this one is 0-32, not 0-255. Do I create a new stringVal func for this?
 	s := stringVal(t.Value)
 	t.parentMsg.NTF.Sequence.RpdCapabilities.RpdIdentification.AssetID = s
+	keep(&t.parentMsg.NTF.Sequence.RpdCapabilities.RpdIdentification.kept, "AssetId", s == "")
 	return s
 }
 
@@ -463,6 +533,9 @@ type VspSel struct{ TLV }
 // Name returns the type name of a VspSelector TLV.
 func (t *VspSel) Name() string { return "VspSelector" }
 
+// Kind returns the encoding of the value of a VspSelector TLV.
+func (t *VspSel) Kind() ValueKind { return KindString }
+
 // Val returns the value a AssetId TLV carries.
 // A string containing a VSP Selector. If the RPD does not support
 // VSP the RPD communicates VSP as a zero-length string.
@@ -470,6 +543,7 @@ func (t *VspSel) Val() interface{} {
 	// The length of this one is 0-16, not 0-255. Do I create a new stringVal func for this?
 	s := stringVal(t.Value)
 	t.parentMsg.NTF.Sequence.RpdCapabilities.RpdIdentification.VspSelector = s
+	keep(&t.parentMsg.NTF.Sequence.RpdCapabilities.RpdIdentification.kept, "VspSelector", s == "")
 	return s
 }
 
@@ -479,6 +553,9 @@ type CurSwUpd struct{ TLV }
 // Name returns the type name of a CurrentSwImageLastUpdate TLV.
 func (t *CurSwUpd) Name() string { return "CurrentSwImageLastUpdate" }
 
+// Kind returns the encoding of the value of a CurrentSwImageLastUpdate TLV.
+func (t *CurSwUpd) Kind() ValueKind { return KindDateAndTime }
+
 // Val returns the value a CurrentSwImageLastUpdate TLV carries.
 // An octet string conforming to the definition of DateAndTime from [RFC 2578].
 func (t *CurSwUpd) Val() interface{} {
@@ -493,11 +570,15 @@ type CurSwName struct{ TLV }
 // Name returns the type name of a CurrentSwImageName TLV.
 func (t *CurSwName) Name() string { return "CurrentSwImageName" }
 
+// Kind returns the encoding of the value of a CurrentSwImageName TLV.
+func (t *CurSwName) Kind() ValueKind { return KindString }
+
 // Val returns the value a CurrentSwImageName TLV carries.
 // A string with the name of the current SW image.
 func (t *CurSwName) Val() interface{} {
 	s := stringVal(t.Value)
 	t.parentMsg.NTF.Sequence.RpdCapabilities.RpdIdentification.CurrentSwImageName = s
+	keep(&t.parentMsg.NTF.Sequence.RpdCapabilities.RpdIdentification.kept, "CurrentSwImageName", s == "")
 	return s
 }
 
@@ -507,6 +588,9 @@ type CurSwSer struct{ TLV }
 // Name returns the type name of a CurrentSwImageServer TLV.
 func (t *CurSwSer) Name() string { return "CurrentSwImageServer" }
 
+// Kind returns the encoding of the value of a CurrentSwImageServer TLV.
+func (t *CurSwSer) Kind() ValueKind { return KindIP }
+
 // Val returns the value a CurrentSwImageServer TLV carries.
 // The IP Address of the server from which the current SW image
 // was downloaded.
@@ -522,6 +606,9 @@ type CurSwIdx struct{ TLV }
 // Name returns the type name of a CurrrentSwImageIndex TLV.
 func (t *CurSwIdx) Name() string { return "CurrrentSwImageIndex" }
 
+// Kind returns the encoding of the value of a CurrrentSwImageIndex TLV.
+func (t *CurSwIdx) Kind() ValueKind { return KindUint8 }
+
 // Val returns the value a CurrrentSwImageIndex TLV carries.
 // An unsigned byte reporting which SW image is currently
 // running on the RPD. The following range of values are
@@ -561,7 +648,6 @@ func (t *DevLoc) newTLV(b byte) RCP {
 		r.parentMsg = t.parentMsg
 		return r
 	default:
-		log.Printf("DeviceLocation TLV type: %d not supported", int(b))
 		return nil
 	}
 }
@@ -576,6 +662,9 @@ func (t *DevLoc) parseTLVs(b []byte) ([]RCP, error) {
 		}
 
 		tlv := t.newTLV(b[i])
+		if tlv == nil {
+			return nil, errUnsupported("DeviceLocation", b[i])
+		}
 
 		// Unmarshal at the current offset, up to the expected length.
 		if err := tlv.unmarshal(b[i : i+3+l]); err != nil {
@@ -607,6 +696,9 @@ type DevLocDesc struct{ TLV }
 // Name returns the type name of a Device Location Description TLV.
 func (t *DevLocDesc) Name() string { return "Device Location Description" }
 
+// Kind returns the encoding of the value of a Device Location Description TLV.
+func (t *DevLocDesc) Kind() ValueKind { return KindString }
+
 // Val returns the value a Device Location Description TLV carries.
 // A string with a short text description of where the RPD has
 // been installed, such as a street address. The format is specific
@@ -614,6 +706,7 @@ func (t *DevLocDesc) Name() string { return "Device Location Description" }
 func (t *DevLocDesc) Val() interface{} {
 	s := stringVal(t.Value)
 	t.parentMsg.NTF.Sequence.RpdCapabilities.DeviceLocation.Description = s
+	keep(&t.parentMsg.NTF.Sequence.RpdCapabilities.DeviceLocation.kept, "Device Location Description", s == "")
 	return s
 }
 
@@ -623,6 +716,9 @@ type GeoLocLat struct{ TLV }
 // Name returns the type name of a Geographic Location Latitude TLV.
 func (t *GeoLocLat) Name() string { return "Geographic Location Latitude" }
 
+// Kind returns the encoding of the value of a Geographic Location Latitude TLV.
+func (t *GeoLocLat) Kind() ValueKind { return KindString }
+
 // Val returns the value a Geographic Location Latitude TLV carries.
 // A 9 byte long string with RPD's latitude formatted as in ISO
 // 6709-2008. The RPD uses "6 digit notation" in the format deg,
@@ -634,6 +730,7 @@ func (t *GeoLocLat) Val() interface{} {
 	s := stringVal(t.Value)
 	// TODO: Parse ISO 6709-2008
 	t.parentMsg.NTF.Sequence.RpdCapabilities.DeviceLocation.Latitude = s
+	keep(&t.parentMsg.NTF.Sequence.RpdCapabilities.DeviceLocation.kept, "Geographic Location Latitude", s == "")
 	return s
 }
 
@@ -643,6 +740,9 @@ type GeoLocLon struct{ TLV }
 // Name returns the type name of a Geographic Location Longitude TLV.
 func (t *GeoLocLon) Name() string { return "Geographic Location Longitude" }
 
+// Kind returns the encoding of the value of a Geographic Location Longitude TLV.
+func (t *GeoLocLon) Kind() ValueKind { return KindString }
+
 // Val returns the value a Geographic Location Longitude TLV carries.
 // A 10 byte long string with RPD's latitude formatted as in ISO
 // 6709-2008. The RPD uses "7 digit notation" in the format deg,
@@ -650,10 +750,11 @@ func (t *GeoLocLon) Name() string { return "Geographic Location Longitude" }
 // example: -0100015.1
 func (t *GeoLocLon) Val() interface{} {
 	if len(t.Value) != 10 {
-		return fmt.Sprintf("unexpected lenght: %v, want: 9", len(t.Value))
+		return fmt.Sprintf("unexpected lenght: %v, want: 10", len(t.Value))
 	}
 	s := stringVal(t.Value)
 	// TODO: Parse ISO 6709-2008
 	t.parentMsg.NTF.Sequence.RpdCapabilities.DeviceLocation.Longitude = s
+	keep(&t.parentMsg.NTF.Sequence.RpdCapabilities.DeviceLocation.kept, "Geographic Location Longitude", s == "")
 	return s
 }
@@ -0,0 +1,371 @@
+package gcp
+
+import (
+	"encoding/hex"
+)
+
+// A CcapCoreID is a CcapCoreIdentification TLV (Complex TLV).
+type CcapCoreID struct {
+	TLV
+	// Index of the entry in the CcapCoreIdentification slice
+	coreIndex int
+}
+
+// Name returns the type name of a CcapCoreIdentification TLV.
+func (t *CcapCoreID) Name() string { return "CcapCoreIdentification" }
+
+// IsComplex returns whether a CcapCoreIdentification TLV is Complex or not.
+func (t *CcapCoreID) IsComplex() bool { return true }
+
+func (t *CcapCoreID) newTLV(b byte) RCP {
+	switch int(b) {
+	case 1:
+		r := new(CoreIdx)
+		r.parentMsg = t.parentMsg
+		r.coreIndex = t.coreIndex
+		return r
+	case 2:
+		r := new(CoreID)
+		r.parentMsg = t.parentMsg
+		r.coreIndex = t.coreIndex
+		return r
+	case 3:
+		r := new(CoreIPAdd)
+		r.parentMsg = t.parentMsg
+		r.coreIndex = t.coreIndex
+		return r
+	case 4:
+		r := new(IsPrinc)
+		r.parentMsg = t.parentMsg
+		r.coreIndex = t.coreIndex
+		return r
+	case 5:
+		r := new(CoreName)
+		r.parentMsg = t.parentMsg
+		r.coreIndex = t.coreIndex
+		return r
+	case 6:
+		r := new(CoreVendorID)
+		r.parentMsg = t.parentMsg
+		r.coreIndex = t.coreIndex
+		return r
+	case 7:
+		r := new(CoreMode)
+		r.parentMsg = t.parentMsg
+		r.coreIndex = t.coreIndex
+		return r
+	case 8:
+		r := new(InitCfgDone)
+		r.parentMsg = t.parentMsg
+		r.coreIndex = t.coreIndex
+		return r
+	case 9:
+		r := new(MoveToOper)
+		r.parentMsg = t.parentMsg
+		r.coreIndex = t.coreIndex
+		return r
+	case 10:
+		r := new(CoreFunc)
+		r.parentMsg = t.parentMsg
+		r.coreIndex = t.coreIndex
+		return r
+	case 11:
+		r := new(ResSetIdx)
+		r.parentMsg = t.parentMsg
+		r.coreIndex = t.coreIndex
+		return r
+	default:
+		return nil
+	}
+}
+
+// parseTLVs parses CcapCoreIdentification TLVs.
+func (t *CcapCoreID) parseTLVs(b []byte) ([]RCP, error) {
+	var tlvs []RCP
+	for i := 0; len(b[i:]) != 0; {
+		l, err := boundsChk(i, b)
+		if err != nil {
+			return nil, err
+		}
+
+		tlv := t.newTLV(b[i])
+		if tlv == nil {
+			return nil, errUnsupported("CcapCoreIdentification", b[i])
+		}
+
+		// Unmarshal at the current offset, up to the expected length.
+		if err := tlv.unmarshal(b[i : i+3+l]); err != nil {
+			return nil, err
+		}
+
+		switch {
+		case l > 3 && tlv.IsComplex():
+			rectlv, err := tlv.parseTLVs(b[i+3 : i+3+l])
+			if err != nil {
+				return nil, err
+			}
+			tlvs = append(tlvs, tlv)
+			tlvs = append(tlvs, rectlv...)
+		case l <= 3 || !tlv.IsComplex():
+			tlvs = append(tlvs, tlv)
+		}
+		// Advance to the next TLV's type field.
+		i += (l + 3)
+	}
+
+	return tlvs, nil
+
+}
+
+// core returns the CcapCoreIdentification entry of index i in m.
+func core(m *GCP, i int) *CcapID {
+	return &m.REX.Sequence.CcapCoreIdentification[i]
+}
+
+// A CoreIdx is an Index TLV of a CcapCoreIdentification.
+type CoreIdx struct {
+	TLV
+	coreIndex int
+}
+
+// Name returns the type name of an Index TLV.
+func (t *CoreIdx) Name() string { return "Index" }
+
+// Kind returns the encoding of the value of an Index TLV.
+func (t *CoreIdx) Kind() ValueKind { return KindUint8 }
+
+// Val returns the value an Index TLV carries.
+// The key of the CcapCoreIdentification entry.
+func (t *CoreIdx) Val() interface{} {
+	s := u8Val(t.Value)
+	core(t.parentMsg, t.coreIndex).Index = s
+	return s
+}
+
+// A CoreID is a CoreId TLV.
+type CoreID struct {
+	TLV
+	coreIndex int
+}
+
+// Name returns the type name of a CoreId TLV.
+func (t *CoreID) Name() string { return "CoreId" }
+
+// Val returns the value a CoreId TLV carries.
+// A unique identifier of the CCAP Core, like its MAC address, in
+// hexadecimal.
+func (t *CoreID) Val() interface{} {
+	s := hex.EncodeToString(t.Value)
+	core(t.parentMsg, t.coreIndex).CoreID = s
+	return s
+}
+
+// A CoreIPAdd is a CoreIpAddress TLV.
+type CoreIPAdd struct {
+	TLV
+	coreIndex int
+}
+
+// Name returns the type name of a CoreIpAddress TLV.
+func (t *CoreIPAdd) Name() string { return "CoreIpAddress" }
+
+// Kind returns the encoding of the value of a CoreIpAddress TLV.
+func (t *CoreIPAdd) Kind() ValueKind { return KindIP }
+
+// Val returns the value a CoreIpAddress TLV carries.
+// The IP address of the CCAP Core.
+func (t *CoreIPAdd) Val() interface{} {
+	s := ipVal(t.Value)
+	core(t.parentMsg, t.coreIndex).CoreIPAddress = s
+	return s
+}
+
+// booleanNames are the names of the values of the Boolean TLVs of a
+// CcapCoreIdentification.
+var booleanNames = map[uint32]string{
+	0: "false",
+	1: "true",
+}
+
+// A IsPrinc is an IsPrincipal TLV.
+type IsPrinc struct {
+	TLV
+	coreIndex int
+}
+
+// Name returns the type name of an IsPrincipal TLV.
+func (t *IsPrinc) Name() string { return "IsPrincipal" }
+
+// Kind returns the encoding of the value of an IsPrincipal TLV.
+func (t *IsPrinc) Kind() ValueKind { return KindUint8 }
+
+// Enum returns the names of the values of an IsPrincipal TLV.
+func (t *IsPrinc) Enum() map[uint32]string { return booleanNames }
+
+// Val returns the value an IsPrincipal TLV carries.
+// Whether the CCAP Core is the Principal Core of the RPD.
+func (t *IsPrinc) Val() interface{} {
+	s := enumName(booleanNames, u8Val(t.Value))
+	core(t.parentMsg, t.coreIndex).IsPrincipal = s
+	return s
+}
+
+// A CoreName is a CoreName TLV.
+type CoreName struct {
+	TLV
+	coreIndex int
+}
+
+// Name returns the type name of a CoreName TLV.
+func (t *CoreName) Name() string { return "CoreName" }
+
+// Kind returns the encoding of the value of a CoreName TLV.
+func (t *CoreName) Kind() ValueKind { return KindString }
+
+// Val returns the value a CoreName TLV carries.
+// A name assigned to the CCAP Core by the operator.
+func (t *CoreName) Val() interface{} {
+	s := stringVal(t.Value)
+	c := core(t.parentMsg, t.coreIndex)
+	c.CoreName = s
+	keep(&c.kept, "CoreName", s == "")
+	return s
+}
+
+// A CoreVendorID is a VendorId TLV of a CcapCoreIdentification.
+type CoreVendorID struct {
+	TLV
+	coreIndex int
+}
+
+// Name returns the type name of a VendorId TLV.
+func (t *CoreVendorID) Name() string { return "VendorId" }
+
+// Kind returns the encoding of the value of a VendorId TLV.
+func (t *CoreVendorID) Kind() ValueKind { return KindUint16 }
+
+// Val returns the value a VendorId TLV carries.
+// The IANA-assigned enterprise number of the CCAP Core's manufacturer.
+func (t *CoreVendorID) Val() interface{} {
+	s := u16Val(t.Value)
+	core(t.parentMsg, t.coreIndex).VendorID = s
+	return s
+}
+
+// coreModeNames are the names of the values of a CoreMode TLV.
+var coreModeNames = map[uint32]string{
+	1: "Active",
+	2: "Backup",
+	3: "NotActing",
+	4: "DecisionPending",
+	5: "OutOfService",
+	6: "ContactPending",
+}
+
+// A CoreMode is a CoreMode TLV.
+type CoreMode struct {
+	TLV
+	coreIndex int
+}
+
+// Name returns the type name of a CoreMode TLV.
+func (t *CoreMode) Name() string { return "CoreMode" }
+
+// Kind returns the encoding of the value of a CoreMode TLV.
+func (t *CoreMode) Kind() ValueKind { return KindUint8 }
+
+// Enum returns the names of the values of a CoreMode TLV.
+func (t *CoreMode) Enum() map[uint32]string { return coreModeNames }
+
+// Val returns the value a CoreMode TLV carries.
+func (t *CoreMode) Val() interface{} {
+	s := enumName(coreModeNames, u8Val(t.Value))
+	core(t.parentMsg, t.coreIndex).CoreMode = s
+	return s
+}
+
+// A InitCfgDone is an InitialConfigurationComplete TLV.
+type InitCfgDone struct {
+	TLV
+	coreIndex int
+}
+
+// Name returns the type name of an InitialConfigurationComplete TLV.
+func (t *InitCfgDone) Name() string { return "InitialConfigurationComplete" }
+
+// Kind returns the encoding of the value of an InitialConfigurationComplete TLV.
+func (t *InitCfgDone) Kind() ValueKind { return KindUint8 }
+
+// Enum returns the names of the values of an InitialConfigurationComplete TLV.
+func (t *InitCfgDone) Enum() map[uint32]string { return booleanNames }
+
+// Val returns the value an InitialConfigurationComplete TLV carries.
+// Whether the CCAP Core has completed the initial configuration of the RPD.
+func (t *InitCfgDone) Val() interface{} {
+	s := enumName(booleanNames, u8Val(t.Value))
+	core(t.parentMsg, t.coreIndex).InitialConfigurationComplete = s
+	return s
+}
+
+// A MoveToOper is a MoveToOperational TLV.
+type MoveToOper struct {
+	TLV
+	coreIndex int
+}
+
+// Name returns the type name of a MoveToOperational TLV.
+func (t *MoveToOper) Name() string { return "MoveToOperational" }
+
+// Kind returns the encoding of the value of a MoveToOperational TLV.
+func (t *MoveToOper) Kind() ValueKind { return KindUint8 }
+
+// Enum returns the names of the values of a MoveToOperational TLV.
+func (t *MoveToOper) Enum() map[uint32]string { return booleanNames }
+
+// Val returns the value a MoveToOperational TLV carries.
+// Whether the RPD is to move to the operational state.
+func (t *MoveToOper) Val() interface{} {
+	s := enumName(booleanNames, u8Val(t.Value))
+	core(t.parentMsg, t.coreIndex).MoveToOperational = s
+	return s
+}
+
+// A CoreFunc is a CoreFunction TLV.
+type CoreFunc struct {
+	TLV
+	coreIndex int
+}
+
+// Name returns the type name of a CoreFunction TLV.
+func (t *CoreFunc) Name() string { return "CoreFunction" }
+
+// Kind returns the encoding of the value of a CoreFunction TLV.
+func (t *CoreFunc) Kind() ValueKind { return KindUint16 }
+
+// Val returns the value a CoreFunction TLV carries.
+// A bit mask of the functions the CCAP Core provides to the RPD.
+func (t *CoreFunc) Val() interface{} {
+	s := u16Val(t.Value)
+	core(t.parentMsg, t.coreIndex).CoreFunction = s
+	return s
+}
+
+// A ResSetIdx is a ResourceSetIndex TLV.
+type ResSetIdx struct {
+	TLV
+	coreIndex int
+}
+
+// Name returns the type name of a ResourceSetIndex TLV.
+func (t *ResSetIdx) Name() string { return "ResourceSetIndex" }
+
+// Kind returns the encoding of the value of a ResourceSetIndex TLV.
+func (t *ResSetIdx) Kind() ValueKind { return KindUint8 }
+
+// Val returns the value a ResourceSetIndex TLV carries.
+// The index of the set of RPD resources the CCAP Core manages.
+func (t *ResSetIdx) Val() interface{} {
+	s := u8Val(t.Value)
+	core(t.parentMsg, t.coreIndex).ResourceSetIndex = s
+	return s
+}
@@ -0,0 +1,116 @@
+// Command gcp-core-sim is a fake CCAP Core, for testing RPDs without a
+// core.
+//
+// It listens for RPDs and brings up each one that notifies its start up:
+// it reads its RpdCapabilities in an IRA exchange, to learn its MAC
+// address, then writes the RCP objects configured for that address, like
+// its CcapCoreIdentification, RfPort and RfChannel, in REX exchanges, and
+// logs the Response Code of each step. The configuration is a YAML or
+// JSON file like core.yaml, keyed by MAC address.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/coresim"
+	"github.com/nleiva/gcp-rphy/pcap"
+	"github.com/nleiva/gcp-rphy/transport"
+)
+
+func main() {
+	var (
+		addrFlag    = flag.String("a", ":8190", "address to listen on")
+		configFlag  = flag.String("c", "", "YAML or JSON file with the RCP objects to write to each RPD, by MAC address")
+		timeoutFlag = flag.Duration("timeout", 5*time.Second, "time to wait for each response")
+		recordFlag  = flag.String("r", "", "record the messages exchanged to a pcapng file")
+		verboseFlag = flag.Bool("v", false, "print the messages exchanged")
+	)
+	flag.Usage = func() {
+		fmt.Println("Usage: gcp-core-sim [flags]")
+		fmt.Println("  Accepts RPDs as a CCAP Core, identifies them and writes the RCP objects")
+		fmt.Println("  configured for their MAC addresses in -c, logging the Response Codes.")
+		fmt.Println("Flags:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	c := &coresim.Core{
+		Core: transport.Core{
+			Addr:    *addrFlag,
+			Timeout: *timeoutFlag,
+			OnEvent: logEvent,
+		},
+		OnResult: func(r coresim.Result) { log.Print(r) },
+	}
+	if *configFlag != "" {
+		doc, err := ioutil.ReadFile(*configFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if c.Config, err = coresim.ParseConfig(doc); err != nil {
+			log.Fatalf("%s: %v", *configFlag, err)
+		}
+	}
+	if *recordFlag != "" {
+		w, err := os.Create(*recordFlag)
+		if err != nil {
+			log.Fatalf("couldn't create the recording: %v", err)
+		}
+		defer w.Close()
+		if c.Recorder, err = pcap.NewRecorder(w); err != nil {
+			log.Fatalf("couldn't start recording: %v", err)
+		}
+	}
+	if *verboseFlag {
+		c.Print = printMessage
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		<-sig
+		c.Close()
+	}()
+	log.Printf("Listening on %s", *addrFlag)
+	if err := c.ListenAndServe(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// printMessage logs a message sent to or received from peer, decoded.
+func printMessage(sent bool, peer string, tid *uint16, m *gcp.Message) {
+	dir := "<-"
+	if sent {
+		dir = "->"
+	}
+	id := ""
+	if tid != nil {
+		id = fmt.Sprintf(", Transaction ID %d", *tid)
+	}
+	log.Printf("%s %s %s%s\n%s", dir, peer, gcp.MessageID(m.MessageID), id, decode(m))
+}
+
+// decode returns the decoded body of m.
+func decode(m *gcp.Message) string {
+	s, _ := m.Body.Process()
+	return s
+}
+
+func logEvent(e transport.Event) {
+	if e.Err != nil {
+		log.Printf("%v %s: %v", e.Type, e.Addr, e.Err)
+		return
+	}
+	log.Printf("%v %s", e.Type, e.Addr)
+}
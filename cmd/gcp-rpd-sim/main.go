@@ -0,0 +1,239 @@
+// Command gcp-rpd-sim is a configurable fake RPD, for testing CCAP Cores
+// without hardware.
+//
+// It loads the RCP objects of the RPD, like its RpdIdentification and
+// RpdInfo interfaces and addresses, from a YAML or JSON file, connects
+// to a core, announces its start up and answers the IRA and REX reads
+// and writes of the core, keeping the values written. Notifications are
+// raised on a schedule with -notify, or with commands read from the
+// standard input:
+//
+//	notify type         raise a GeneralNotification, by type name or number
+//	set path=value...   change RCP objects, like DeviceAlias
+//	stats               print the statistics of the RPDs
+//	quit                disconnect and exit
+//
+// With -n, it load tests the core with many RPDs sharing the file, with
+// MAC addresses from -mac up and serial numbers from -serial, starting
+// at random times within -ramp. Commands apply to all of them, and the
+// number of RPDs up, initialized (read by the core) and failed, and the
+// percentiles of the time to initialize are printed every -stats.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/compose"
+	"github.com/nleiva/gcp-rphy/pcap"
+	"github.com/nleiva/gcp-rphy/rpdsim"
+	"github.com/nleiva/gcp-rphy/transport"
+)
+
+func main() {
+	var (
+		configFlag  = flag.String("c", "", "YAML or JSON file with the RCP objects of the RPD")
+		recordFlag  = flag.String("r", "", "record the messages exchanged to a pcapng file")
+		verboseFlag = flag.Bool("v", false, "print the messages exchanged")
+		countFlag   = flag.Int("n", 1, "number of RPDs to simulate")
+		macFlag     = flag.String("mac", "", "MAC address of the first RPD, incremented for the rest (default from -c, or 02:00:00:00:00:01 with -n)")
+		serialFlag  = flag.String("serial", "", "fmt format of the serial numbers given the RPD number (default from -c, or SIM%07d with -n)")
+		rampFlag    = flag.Duration("ramp", 0, "start the RPDs at random times within this time")
+		jitterFlag  = flag.Duration("jitter", 0, "delay answers randomly up to this time")
+		strictFlag  = flag.Bool("strict", false, "answer requests that violate the GCP specification with Error Responses")
+		statsFlag   = flag.Duration("stats", 10*time.Second, "time between statistics with -n, 0 to disable")
+		notifyFlag  schedules
+	)
+	flag.Var(&notifyFlag, "notify", "raise a notification periodically, like TimeOutNotification@30s; can be repeated")
+	flag.Usage = func() {
+		fmt.Println("Usage: gcp-rpd-sim [flags] core-address")
+		fmt.Println("  Connects to a CCAP Core as an RPD with the RCP objects of -c, and answers")
+		fmt.Println("  its reads and writes. Type notify type, set path=value, stats or quit to act.")
+		fmt.Println("Flags:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 || *countFlag < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	st := &rpdsim.Store{}
+	if *configFlag != "" {
+		doc, err := ioutil.ReadFile(*configFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := st.Load(doc); err != nil {
+			log.Fatalf("%s: %v", *configFlag, err)
+		}
+	}
+	f := &rpdsim.Fleet{
+		Core:      flag.Arg(0),
+		Count:     *countFlag,
+		Template:  st,
+		Serial:    *serialFlag,
+		Ramp:      *rampFlag,
+		Jitter:    *jitterFlag,
+		Strict:    *strictFlag,
+		Schedules: notifyFlag,
+	}
+	if *countFlag > 1 {
+		if *macFlag == "" {
+			*macFlag = "02:00:00:00:00:01"
+		}
+		if f.Serial == "" {
+			f.Serial = "SIM%07d"
+		}
+	} else {
+		f.OnEvent = func(_ int, e transport.Event) { logEvent(e) }
+	}
+	if *macFlag != "" {
+		mac, err := net.ParseMAC(*macFlag)
+		if err != nil || len(mac) != 6 {
+			log.Fatalf("invalid MAC address %q", *macFlag)
+		}
+		f.MAC = mac
+	}
+	var recorder transport.Recorder
+	if *recordFlag != "" {
+		w, err := os.Create(*recordFlag)
+		if err != nil {
+			log.Fatalf("couldn't create the recording: %v", err)
+		}
+		defer w.Close()
+		if recorder, err = pcap.NewRecorder(w); err != nil {
+			log.Fatalf("couldn't start recording: %v", err)
+		}
+	}
+	if *verboseFlag || recorder != nil {
+		f.Setup = func(_ int, r *rpdsim.RPD) {
+			r.Recorder = recorder
+			if *verboseFlag {
+				r.Print = printMessage
+			}
+		}
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		<-sig
+		f.Close()
+	}()
+	if *countFlag > 1 && *statsFlag > 0 {
+		go func() {
+			for range time.Tick(*statsFlag) {
+				log.Print(f.Stats())
+			}
+		}()
+	}
+	go commands(f)
+	if err := f.Run(); err != nil {
+		log.Fatal(err)
+	}
+	if *countFlag > 1 {
+		log.Print(f.Stats())
+	}
+}
+
+// commands runs the commands read from the standard input on the RPDs of
+// f.
+func commands(f *rpdsim.Fleet) {
+	s := bufio.NewScanner(os.Stdin)
+	for s.Scan() {
+		args := strings.Fields(s.Text())
+		if len(args) == 0 {
+			continue
+		}
+		var err error
+		switch args[0] {
+		case "notify":
+			if len(args) != 2 {
+				err = fmt.Errorf("usage: notify type")
+				break
+			}
+			var nt gcp.NotificationType
+			if nt, err = rpdsim.ParseNotificationType(args[1]); err == nil {
+				f.Each(func(r *rpdsim.RPD) {
+					if e := r.Notify(nt); e != nil {
+						err = e
+					}
+				})
+			}
+		case "set":
+			var vs []compose.PathValue
+			for _, a := range args[1:] {
+				i := strings.Index(a, "=")
+				if i < 0 {
+					err = fmt.Errorf("usage: set path=value...")
+					break
+				}
+				vs = append(vs, compose.PathValue{Path: a[:i], Value: a[i+1:]})
+			}
+			if err == nil {
+				f.Each(func(r *rpdsim.RPD) {
+					if e := r.Store.Set(vs...); e != nil {
+						err = e
+					}
+				})
+			}
+		case "stats":
+			log.Print(f.Stats())
+		case "quit", "exit":
+			f.Close()
+			return
+		default:
+			err = fmt.Errorf("unknown command %q, want notify, set, stats or quit", args[0])
+		}
+		if err != nil {
+			log.Print(err)
+		}
+	}
+}
+
+// printMessage logs a message sent to or received from peer, decoded.
+func printMessage(sent bool, peer string, tid *uint16, m *gcp.Message) {
+	dir := "<-"
+	if sent {
+		dir = "->"
+	}
+	log.Printf("%s %s %s, Transaction ID %d\n%s", dir, peer, gcp.MessageID(m.MessageID), *tid, decode(m))
+}
+
+// decode returns the decoded body of m.
+func decode(m *gcp.Message) string {
+	s, _ := m.Body.Process()
+	return s
+}
+
+func logEvent(e transport.Event) {
+	if e.Err != nil {
+		log.Printf("%v %s: %v", e.Type, e.Addr, e.Err)
+		return
+	}
+	log.Printf("%v %s", e.Type, e.Addr)
+}
+
+// schedules is a flag.Value of notification schedules.
+type schedules []rpdsim.Schedule
+
+func (ss *schedules) String() string { return fmt.Sprint(*ss) }
+
+func (ss *schedules) Set(v string) error {
+	s, err := rpdsim.ParseSchedule(v)
+	if err != nil {
+		return err
+	}
+	*ss = append(*ss, s)
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/compose"
+	"github.com/nleiva/gcp-rphy/transport"
+)
+
+// encodeDoc runs the encode subcommand.
+func encodeDoc(args []string) error {
+	fs := flag.NewFlagSet("encode", flag.ExitOnError)
+	var (
+		outFlag    = fs.String("o", "", "file to write the binary message to")
+		formatFlag = fs.String("format", "", "print the message decoded in a format: text, json or hex, instead of in hexadecimal")
+	)
+	fs.Usage = func() {
+		fmt.Println("Usage: gcp encode [flags] message.yaml|message.json")
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	doc, err := ioutil.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	b, err := compose.Encode(doc)
+	if err != nil {
+		return err
+	}
+	switch {
+	case *outFlag != "":
+		return ioutil.WriteFile(*outFlag, b, 0644)
+	case *formatFlag != "":
+		p, err := newPrinter(*formatFlag)
+		if err != nil {
+			return err
+		}
+		p.print("", "", nil, b)
+	default:
+		fmt.Printf("%x\n", b)
+	}
+	return nil
+}
+
+// sendDoc runs the send subcommand.
+func sendDoc(args []string) error {
+	fs := flag.NewFlagSet("send", flag.ExitOnError)
+	var (
+		timeoutFlag = fs.Duration("timeout", 5*time.Second, "time to wait for the response to a request")
+		formatFlag  = formatFlag(fs)
+	)
+	fs.Usage = func() {
+		fmt.Println("Usage: gcp send [flags] message.yaml|message.json address")
+		fmt.Println("  Requests are answered by a response, which is printed out.")
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	p, err := newPrinter(*formatFlag)
+	if err != nil {
+		return err
+	}
+
+	doc, err := ioutil.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	m, err := compose.Message(doc)
+	if err != nil {
+		return err
+	}
+	s, err := transport.Dial(fs.Arg(1), nil)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	// Even Message IDs identify requests.
+	if m.MessageID&1 == 1 {
+		p.message(sent, fs.Arg(1), nil, m)
+		return s.Send(m)
+	}
+	return request(p, s, m, *timeoutFlag)
+}
+
+// request sends m over s and prints it along with its response.
+func request(p *printer, s *transport.Session, m *gcp.Message, timeout time.Duration) error {
+	p.message(sent, s.RemoteAddr().String(), nil, m)
+	r, err := s.Request(m, timeout)
+	if err != nil || r == nil {
+		return err
+	}
+	p.message(received, s.RemoteAddr().String(), &r.TranID, r.Msg)
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/conform"
+)
+
+// runConform runs the conform subcommand.
+func runConform(args []string) error {
+	fs := flag.NewFlagSet("conform", flag.ExitOnError)
+	var (
+		verboseFlag = fs.Bool("v", false, "print the messages exchanged")
+		timeoutFlag = fs.Duration("timeout", 5*time.Second, "time to wait for each response")
+		waitFlag    = fs.Duration("wait", time.Minute, "time to wait for the RPD to connect or reconnect")
+		runFlag     = fs.String("run", "", "run only the cases matching this regular expression")
+		listFlag    = fs.Bool("list", false, "list the cases and what they check, without running them")
+		jsonFlag    = fs.String("json", "", "write the report as JSON to this file")
+		junitFlag   = fs.String("junit", "", "write the report as JUnit XML to this file")
+		formatFlag  = formatFlag(fs)
+	)
+	fs.Usage = func() {
+		fmt.Println("Usage: gcp conform [flags] rpd|core address")
+		fmt.Println("  Tests that an RPD or a core answers well-formed and malformed requests")
+		fmt.Println("  as the GCP and RCP specifications require. An RPD is waited for on the")
+		fmt.Println("  address, like :8190; a core is connected to.")
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	role, err := conform.ParseRole(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	cases := conform.Cases(role)
+	if *runFlag != "" {
+		re, err := regexp.Compile(*runFlag)
+		if err != nil {
+			return fmt.Errorf("invalid -run: %v", err)
+		}
+		var sel []conform.Case
+		for _, c := range cases {
+			if re.MatchString(c.Name) {
+				sel = append(sel, c)
+			}
+		}
+		cases = sel
+	}
+	if *listFlag {
+		for _, c := range cases {
+			fmt.Printf("%s\n    %s\n", c.Name, c.Spec)
+		}
+		return nil
+	}
+	p, err := newPrinter(*formatFlag)
+	if err != nil {
+		return err
+	}
+
+	ru := &conform.Runner{Timeout: *timeoutFlag, Wait: *waitFlag}
+	if *verboseFlag {
+		ru.Print = func(s bool, peer string, tid *uint16, m *gcp.Message) {
+			dir := received
+			if s {
+				dir = sent
+			}
+			p.message(dir, peer, tid, m)
+		}
+	}
+	var rep *conform.Report
+	if role == conform.RPD {
+		l, err := net.Listen("tcp", fs.Arg(1))
+		if err != nil {
+			return err
+		}
+		rep = ru.Accept(l, cases)
+	} else {
+		rep = ru.Connect(fs.Arg(1), cases)
+	}
+	rep.Write(os.Stdout)
+	if err := writeReport(*jsonFlag, rep.WriteJSON); err != nil {
+		return err
+	}
+	if err := writeReport(*junitFlag, rep.WriteJUnit); err != nil {
+		return err
+	}
+	if rep.Failed() > 0 {
+		return errTestsFailed
+	}
+	return nil
+}
+
+// writeReport writes a report to the file name with write, if name is
+// set.
+func writeReport(name string, write func(w io.Writer) error) error {
+	if name == "" {
+		return nil
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/hexdump"
+	"github.com/nleiva/gcp-rphy/transport"
+)
+
+// decode runs the decode subcommand.
+func decode(args []string) error {
+	fs := flag.NewFlagSet("decode", flag.ExitOnError)
+	var (
+		tcpFlag      = fs.Bool("tcp", false, "the input is a TCP encapsulation unit")
+		validateFlag = fs.Bool("validate", false, "check the messages against the GCP and RCP specifications")
+		formatFlag   = formatFlag(fs)
+	)
+	fs.Usage = func() {
+		fmt.Println("Usage: gcp decode [flags] file|-")
+		fmt.Println("  Decodes GCP messages, in binary or hexadecimal.")
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	p, err := newPrinter(*formatFlag)
+	if err != nil {
+		return err
+	}
+
+	var b []byte
+	if name := fs.Arg(0); name == "-" {
+		b, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		b, err = ioutil.ReadFile(name)
+	}
+	if err != nil {
+		return err
+	}
+	if h, err := hex.DecodeString(strings.Join(strings.Fields(string(b)), "")); err == nil {
+		b = h
+	}
+
+	var tid *uint16
+	if *tcpFlag {
+		if p.format == "hex" {
+			fmt.Print(hexdump.TCP(b))
+			return nil
+		}
+		u, err := transport.UnMarshal(b)
+		if err != nil {
+			return err
+		}
+		b, tid = u.Msg, &u.TranID
+	}
+	var invalid bool
+	for len(b) > 0 {
+		n := len(b)
+		if n >= 3 {
+			if l := 3 + int(binary.BigEndian.Uint16(b[1:3])); l < n {
+				n = l
+			}
+		}
+		p.print("", "", tid, b[:n])
+		if *validateFlag {
+			for _, v := range violations(b[:n]) {
+				fmt.Printf("  violation: %v\n", v)
+				invalid = true
+			}
+		}
+		b = b[n:]
+	}
+	if invalid {
+		// The violations printed are the report.
+		os.Exit(1)
+	}
+	return nil
+}
+
+// violations returns the violations of the GCP message b, with offsets
+// from its start.
+func violations(b []byte) []gcp.Violation {
+	if len(b) < 3 {
+		return []gcp.Violation{{Rule: gcp.RuleLength, Name: "Message", Detail: fmt.Sprintf("%d bytes, want at least 3", len(b))}}
+	}
+	m := &gcp.Message{MessageID: b[0], Lenght: binary.BigEndian.Uint16(b[1:3]), Body: &gcp.RawBody{Data: b[3:]}}
+	return m.Validate()
+}
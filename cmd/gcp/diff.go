@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/compose"
+)
+
+// errManyMessages is returned by readLine for the JSON lines of several
+// messages.
+var errManyMessages = errors.New("more than one message, want one")
+
+// A jsonLine is the part of the JSON lines representation of a message,
+// as printed by decode or decode-pcap, that holds its RCP objects.
+type jsonLine struct {
+	MessageID *uint8          `json:"messageId"`
+	Data      json.RawMessage `json:"data"`
+	Raw       string          `json:"raw"`
+	Error     string          `json:"error"`
+}
+
+// A jsonChange is the JSON lines representation of a gcp.Change.
+type jsonChange struct {
+	Change string `json:"change"`
+	Path   string `json:"path"`
+	TLV    string `json:"tlv"`
+	Old    string `json:"old,omitempty"`
+	New    string `json:"new,omitempty"`
+}
+
+// diffStates runs the diff subcommand.
+func diffStates(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	format := formatFlag(fs)
+	fs.Usage = func() {
+		fmt.Println("Usage: gcp diff [flags] a b")
+		fmt.Println("  Compares the RCP objects of two messages, each a YAML or JSON document")
+		fmt.Println("  (see encode), a message in binary or hexadecimal, or one printed by")
+		fmt.Println("  decode -format json, or - for the standard input. Prints the TLVs added")
+		fmt.Println("  (+), removed (-) and changed (~) from a to b, one JSON object per change")
+		fmt.Println("  with -format json, and exits with status 1 if there are any.")
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	switch *format {
+	case "text", "json":
+	default:
+		return fmt.Errorf("unknown output format %q, want: text or json", *format)
+	}
+
+	a, err := readTree(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	b, err := readTree(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	cs := gcp.Diff(a, b)
+	enc := json.NewEncoder(os.Stdout)
+	for _, c := range cs {
+		if *format == "text" {
+			fmt.Println(c)
+			continue
+		}
+		j := jsonChange{Change: c.Type.String(), Path: c.Path}
+		if c.Old != nil {
+			j.TLV, j.Old = c.Old.Path, c.Old.String()
+		}
+		if c.New != nil {
+			j.TLV, j.New = c.New.Path, c.New.String()
+		}
+		enc.Encode(j)
+	}
+	if len(cs) > 0 {
+		// The changes printed are the report.
+		os.Exit(1)
+	}
+	return nil
+}
+
+// readTree returns the RCP tree of the message in file name.
+func readTree(name string) (*gcp.Tree, error) {
+	var (
+		b   []byte
+		err error
+	)
+	if name == "-" {
+		b, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		b, err = ioutil.ReadFile(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var m *gcp.Message
+	if h, herr := hex.DecodeString(strings.Join(strings.Fields(string(b)), "")); herr == nil {
+		m, err = gcp.ParseMessage(h)
+	} else if l, ok, lerr := readLine(b); ok {
+		m, err = l, lerr
+	} else if _, _, serr := compose.Split(b); serr == nil {
+		m, err = compose.Message(b)
+	} else {
+		m, err = gcp.ParseMessage(b)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	t, err := m.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return t, nil
+}
+
+// readLine returns the message of b if it is the JSON lines
+// representation of a message, as printed by decode or decode-pcap with
+// -format json, and whether it is. The message is composed back from
+// its RCP objects, or parsed from its raw bytes if it could not be
+// decoded.
+func readLine(b []byte) (*gcp.Message, bool, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	var l jsonLine
+	if err := dec.Decode(&l); err != nil || l.MessageID == nil && l.Error == "" {
+		return nil, false, nil
+	}
+	if dec.More() {
+		return nil, true, errManyMessages
+	}
+	switch {
+	case l.Raw != "":
+		h, err := hex.DecodeString(l.Raw)
+		if err != nil {
+			return nil, true, err
+		}
+		m, err := gcp.ParseMessage(h)
+		return m, true, err
+	case l.MessageID == nil:
+		// A unit of a capture that could not be decoded.
+		return nil, true, errors.New(l.Error)
+	}
+	doc := struct {
+		Message uint8                      `json:"message"`
+		Body    map[string]json.RawMessage `json:"body,omitempty"`
+	}{Message: *l.MessageID}
+	if len(l.Data) > 0 {
+		for _, f := range gcp.BodyLayout(gcp.MessageID(*l.MessageID)) {
+			if f.RCP {
+				doc.Body = map[string]json.RawMessage{f.Name: l.Data}
+			}
+		}
+	}
+	d, err := json.Marshal(doc)
+	if err != nil {
+		return nil, true, err
+	}
+	m, err := compose.Message(d)
+	return m, true, err
+}
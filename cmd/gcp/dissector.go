@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/nleiva/gcp-rphy/dissector"
+)
+
+// genDissector runs the gen-dissector subcommand.
+func genDissector(args []string) error {
+	fs := flag.NewFlagSet("gen-dissector", flag.ExitOnError)
+	var (
+		outFlag  = fs.String("o", "", "file to write the dissector to, standard output if empty")
+		portFlag = fs.Int("p", dissector.DefaultPort, "TCP port to decode as GCP")
+	)
+	fs.Usage = func() {
+		fmt.Println("Usage: gcp gen-dissector [flags]")
+		fmt.Println("  Writes a Wireshark Lua dissector for GCP and RCP.")
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	var w io.Writer = os.Stdout
+	if *outFlag != "" {
+		f, err := os.Create(*outFlag)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return dissector.WriteLua(w, *portFlag)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/nleiva/gcp-rphy/pcap"
+	"github.com/nleiva/gcp-rphy/transport"
+)
+
+// listen runs the listen subcommand.
+func listen(args []string) error {
+	fs := flag.NewFlagSet("listen", flag.ExitOnError)
+	var (
+		addrFlag   = fs.String("a", ":8190", "TCP address to listen on")
+		recordFlag = fs.String("r", "", "record the messages received to a pcapng file")
+		formatFlag = formatFlag(fs)
+	)
+	fs.Usage = func() {
+		fmt.Println("Usage: gcp listen [flags]")
+		fmt.Println("  Prints out the GCP messages received, without answering them.")
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	p, err := newPrinter(*formatFlag)
+	if err != nil {
+		return err
+	}
+	rec, done, err := recordTo(*recordFlag)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	c := &transport.Core{
+		Addr: *addrFlag,
+		Handler: transport.HandlerFunc(func(s *transport.Session, r *transport.Request) {
+			p.message(received, s.RemoteAddr().String(), &r.TranID, r.Msg)
+		}),
+		OnEvent:  logEvent,
+		Recorder: rec,
+	}
+	return c.ListenAndServe()
+}
+
+// logEvent logs the changes in the state of GCP associations.
+func logEvent(e transport.Event) {
+	if e.Err != nil {
+		log.Printf("%v %s: %v", e.Type, e.Addr, e.Err)
+		return
+	}
+	log.Printf("%v %s", e.Type, e.Addr)
+}
+
+// recordTo returns a Recorder writing to the pcapng file name, or nil if
+// name is empty, and a function that closes the file.
+func recordTo(name string) (transport.Recorder, func(), error) {
+	if name == "" {
+		return nil, func() {}, nil
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't create the recording: %v", err)
+	}
+	r, err := pcap.NewRecorder(f)
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("couldn't start recording: %v", err)
+	}
+	return r, func() { f.Close() }, nil
+}
@@ -2,113 +2,96 @@
 package main
 
 import (
-	"flag"
 	"fmt"
 	"log"
-	"math/rand"
-	"time"
-
-	gcp "github.com/nleiva/gcp-rphy"
-	"github.com/nleiva/gcp-rphy/transport"
+	"os"
+	"sort"
 )
 
 func main() {
-	var (
-		modeFlag   = flag.String("m", "", "connection mode: server or client")
-		targetFlag = flag.String("t", "", "target address for GCP connection")
-		wordFlag   = flag.String("w", "", "word to send over the GCP connection")
-		port       = "8190"
-	)
-
-	flag.Usage = func() {
-		fmt.Println(usage)
-		fmt.Println("Flags:")
-		flag.PrintDefaults()
+	if len(os.Args) < 2 {
+		printUsage()
+		os.Exit(2)
 	}
-
-	flag.Parse()
-	if flag.NArg() > 1 {
-		log.Fatalf("too many args on command line: %v", flag.Args()[1:])
-	}
-
-	if *modeFlag == "server" {
-		e := transport.TCPEnd{
-			Port: port,
-		}
-		err := e.Receive()
-		if err != nil {
-			log.Fatalf("Server failed: %v", err)
-		}
-
-	} else if *modeFlag == "client" {
-		e := transport.TCPEnd{
-			Host: *targetFlag,
-			Port: port,
-		}
-		// Create a Notify Message, Message ID: 2.
-		m, err := Encapsulate(2, 0, []byte(*wordFlag))
-		if err != nil {
-			log.Fatalf("couldn't create a Notify message: %v", err)
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		switch os.Args[1] {
+		case "help", "-h", "-help", "--help":
+			printUsage()
+			return
 		}
-		// Create the GCP packet.
-		b, err := m.Marshal()
-		if err != nil {
-			log.Fatalf("couldn't marshall a Notify message: %v", err)
-		}
-		err = e.Send(b)
-		if err != nil {
-			log.Fatalf("Client failed: %v", err)
-		}
-	} else {
-		log.Fatalf("unknown mode")
+		fmt.Printf("unknown command %q\n\n", os.Args[1])
+		printUsage()
+		os.Exit(2)
 	}
+	if err := cmd(os.Args[2:]); err != nil {
+		log.Fatalf("%s failed: %v", os.Args[1], err)
+	}
+}
+
+// commands are the subcommands, run with the arguments that follow them.
+var commands = map[string]func(args []string) error{
+	"conform":       runConform,
+	"decode":        decode,
+	"decode-pcap":   decodePcap,
+	"diff":          diffStates,
+	"encode":        encodeDoc,
+	"gen-dissector": genDissector,
+	"listen":        listen,
+	"read":          readRCP,
+	"replay":        replayCapture,
+	"run":           runScripts,
+	"send":          sendDoc,
+	"shell":         runShell,
+	"simulate-core": simulateCore,
+	"simulate-rpd":  simulateRPD,
+	"write":         writeRCP,
 }
 
-// Encapsulate is a temp function to encapsulate a GCP message
-func Encapsulate(id uint8, tid uint16, b []byte) (gcp.Message, error) {
-	m := gcp.Message{
-		MessageID: id,
-		Lenght:    uint16(len(b)),
+func printUsage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
 	}
-	rand.Seed(time.Now().UTC().UnixNano())
-	switch n := gcp.MessageID(id); n {
-	case gcp.MessageIDNotifyReq:
-		m.Body = &gcp.NotifyReq{
-			TransactionID: uint16(rand.Int()),
-			// TODO: Change this.
-			Mode: 0,
-			// TODO: Change this.
-			Status: 0,
-			// TODO: Change this.
-			EvntCode: 0,
-			EvntData: b,
-		}
-		return m, nil
-	case gcp.MessageIDNotifyRes:
-		m.Body = &gcp.NotifyRes{
-			TransactionID: tid,
-			// TODO: Change this.
-			Mode: 0,
-			// TODO: Change this.
-			EvntCode: 0,
-		}
-		return m, nil
-	case gcp.MessageIDNotifyErr:
-		m.Body = &gcp.NotifyErr{
-			TransactionID: tid,
-			// TODO: Change this.
-			RtrnCode: 0,
-		}
-		return m, nil
-	default:
-		return m, fmt.Errorf("unrecognized Message ID: %d", id)
+	sort.Strings(names)
+	fmt.Println(usage)
+	fmt.Println("Commands (run gcp <command> -h for their flags):")
+	for _, name := range names {
+		fmt.Printf("  %s\n", name)
 	}
 }
 
 const usage = `GCP: utility for working with the Generic Control Plane Protocol.
+Usage: gcp <command> [flags] [arguments]
+Most commands print messages as text, or with -format json (one JSON object
+per message) or -format hex (annotated hex dumps).
 Examples:
-  Listen for incoming GCP messages.
-    $ ./gcp -m server
-  Send a test message.
-    $ ./gcp -m client -t ::1 -w test1
-  `
+  Listen for incoming GCP messages, recording them to a pcapng file.
+    $ ./gcp listen -a :8190 -r session.pcapng
+  Encode a message described in YAML or JSON, or send it to a peer.
+    $ ./gcp encode -format hex read-rpdinfo.yaml
+    $ ./gcp send read-rpdinfo.yaml 192.0.2.2:8190
+  Decode a message in binary or hexadecimal.
+    $ ./gcp encode read-rpdinfo.yaml | ./gcp decode -format json -
+  Compare the RCP objects of two messages, like a write and a later read.
+    $ ./gcp diff written.yaml read-back.hex
+  Read or write RCP objects of an RPD, waiting for it to connect with -l.
+    $ ./gcp read 192.0.2.2:8190 RpdCapabilities.RpdIdentification
+    $ ./gcp write -l :8190 RpdCapabilities.RpdIdentification.DeviceAlias=node-1
+  Open an interactive shell to an RPD, with tab completion of RCP paths.
+    $ ./gcp shell -l :8190
+  Run the exchanges and checks of a script, reporting the tests that failed.
+    $ ./gcp run -v bring-up.gcp
+  Test an RPD or a core for conformance, with a JUnit report for CI.
+    $ ./gcp conform -junit conform.xml rpd :8190
+  Simulate an RPD or a CCAP Core.
+    $ ./gcp simulate-core -read RpdCapabilities,RpdInfo
+    $ ./gcp simulate-rpd -mac 02:00:00:00:00:02 192.0.2.1:8190
+  Decode the GCP messages in a packet capture.
+    $ ./gcp decode-pcap capture.pcapng
+    $ ./gcp decode-pcap -format json capture.pcap
+    $ ./gcp decode-pcap -format hex capture.pcap
+  Replay the RPD side of a capture against a core, comparing its responses.
+    $ ./gcp replay -side rpd capture.pcapng 192.0.2.1:8190
+  Generate a Wireshark dissector.
+    $ ./gcp gen-dissector -o ~/.local/lib/wireshark/plugins/gcp.lua`
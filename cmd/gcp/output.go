@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/hexdump"
+)
+
+// Directions of the messages printed.
+const (
+	sent     = "->"
+	received = "<-"
+)
+
+// A jsonMessage is the JSON lines representation of a GCP message.
+type jsonMessage struct {
+	Time      time.Time `json:"time"`
+	Direction string    `json:"direction,omitempty"`
+	Peer      string    `json:"peer,omitempty"`
+	TranID    *uint16   `json:"transactionId,omitempty"`
+	MessageID uint8     `json:"messageId"`
+	Message   string    `json:"message"`
+	Length    uint16    `json:"length"`
+	Data      *gcp.GCP  `json:"data,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Raw       string    `json:"raw,omitempty"` // Hexadecimal message, if it could not be decoded
+}
+
+// A printer writes GCP messages to the standard output in the text,
+// json or hex format. It can be shared by several sessions.
+type printer struct {
+	format string
+	mu     sync.Mutex
+	enc    *json.Encoder
+}
+
+// formatFlag defines the output format flag of a subcommand.
+func formatFlag(fs *flag.FlagSet) *string {
+	return fs.String("format", "text", "output format: text, json or hex")
+}
+
+func newPrinter(format string) (*printer, error) {
+	p := &printer{enc: json.NewEncoder(os.Stdout)}
+	if err := p.setFormat(format); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// setFormat changes the output format.
+func (p *printer) setFormat(format string) error {
+	switch format {
+	case "text", "json", "hex":
+	default:
+		return fmt.Errorf("unknown output format %q, want: text, json or hex", format)
+	}
+	p.mu.Lock()
+	p.format = format
+	p.mu.Unlock()
+	return nil
+}
+
+// message prints m, sent to or received from peer with the encapsulation
+// Transaction ID tid, if known.
+func (p *printer) message(dir, peer string, tid *uint16, m *gcp.Message) {
+	b, err := m.Marshal()
+	if err != nil {
+		p.mu.Lock()
+		fmt.Printf("%s %s Error: %v\n", dir, peer, err)
+		p.mu.Unlock()
+		return
+	}
+	p.print(dir, peer, tid, b)
+}
+
+// print prints the GCP message b. The direction, peer and Transaction
+// ID are left out when empty.
+func (p *printer) print(dir, peer string, tid *uint16, b []byte) {
+	j := jsonMessage{Time: time.Now(), Direction: dir, Peer: peer, TranID: tid}
+	m, err := gcp.ParseMessage(b)
+	var text string
+	if err == nil {
+		j.MessageID, j.Message, j.Length = m.MessageID, gcp.MessageID(m.MessageID).String(), m.Lenght
+		text, j.Data = m.Body.Process()
+	}
+	if err != nil {
+		j.Error, j.Raw = err.Error(), fmt.Sprintf("%x", b)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch p.format {
+	case "json":
+		switch dir {
+		case sent:
+			j.Direction = "sent"
+		case received:
+			j.Direction = "received"
+		}
+		p.enc.Encode(j)
+		return
+	}
+	if dir != "" {
+		fmt.Printf("%s %s\n", dir, peer)
+	}
+	if p.format == "hex" {
+		fmt.Print(hexdump.Message(b))
+		return
+	}
+	if tid != nil {
+		fmt.Printf("  Transaction ID: %d\n", *tid)
+	}
+	if m != nil {
+		fmt.Printf("  Message Identifier: %v\n  Length: %v\n", gcp.MessageID(m.MessageID), m.Lenght)
+		if err == nil {
+			fmt.Printf("  Body: %s\n", text)
+		}
+	}
+	if err != nil {
+		// Show what could not be decoded.
+		fmt.Printf("  Error: %v\n", err)
+		fmt.Print(hexdump.Message(b))
+	}
+}
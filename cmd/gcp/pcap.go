@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/hexdump"
+	"github.com/nleiva/gcp-rphy/pcap"
+)
+
+// A jsonFrame is the JSON lines representation of a pcap.Frame.
+type jsonFrame struct {
+	Time      time.Time `json:"time"`
+	Src       string    `json:"src"`
+	Dst       string    `json:"dst"`
+	TranID    uint16    `json:"transactionId"`
+	UnitID    uint8     `json:"unitId"`
+	MessageID uint8     `json:"messageId,omitempty"`
+	Length    uint16    `json:"length,omitempty"`
+	Data      *gcp.GCP  `json:"data,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// decodePcap runs the decode-pcap subcommand.
+func decodePcap(args []string) error {
+	fs := flag.NewFlagSet("decode-pcap", flag.ExitOnError)
+	var (
+		portFlag = fs.Int("p", pcap.DefaultPort, "TCP port of GCP sessions")
+		format   = formatFlag(fs)
+	)
+	fs.Usage = func() {
+		fmt.Println("Usage: gcp decode-pcap [flags] file.pcap|file.pcapng|-")
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	out := printFrame
+	switch *format {
+	case "text":
+	case "hex":
+		out = func(f *pcap.Frame) error {
+			printFrame(f)
+			// The unit is dumped along with the first of its messages.
+			if f.Index == 0 && f.TCP.Len != 0 {
+				b, _ := f.TCP.Marshal()
+				fmt.Print(hexdump.TCP(b))
+			}
+			return nil
+		}
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		out = func(f *pcap.Frame) error { return enc.Encode(toJSON(f)) }
+	default:
+		return fmt.Errorf("unknown output format %q, want: text, json or hex", *format)
+	}
+
+	var r io.Reader = os.Stdin
+	if name := fs.Arg(0); name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	return pcap.Decoder{Port: *portFlag}.Decode(r, out)
+}
+
+// printFrame prints a frame in the format used for live messages.
+func printFrame(f *pcap.Frame) error {
+	fmt.Printf("%s %s -> %s\n", f.Time.Format(time.RFC3339Nano), f.Src, f.Dst)
+	if f.Msg == nil {
+		fmt.Printf("  Error: %v\n", f.Err)
+		return nil
+	}
+	fmt.Printf("  Transaction ID: %d\n  Message Identifier: %v\n  Length: %v\n  Body: %s\n",
+		f.TCP.TranID, f.Msg.MessageID, f.Msg.Lenght, f.Text)
+	if f.Err != nil {
+		fmt.Printf("  Error: %v\n", f.Err)
+	}
+	return nil
+}
+
+func toJSON(f *pcap.Frame) jsonFrame {
+	j := jsonFrame{
+		Time:   f.Time,
+		Src:    f.Src.String(),
+		Dst:    f.Dst.String(),
+		TranID: f.TCP.TranID,
+		UnitID: f.TCP.UnitID,
+		Data:   f.Data,
+	}
+	if f.Msg != nil {
+		j.MessageID, j.Length = f.Msg.MessageID, f.Msg.Lenght
+	}
+	if f.Err != nil {
+		j.Error = f.Err.Error()
+	}
+	return j
+}
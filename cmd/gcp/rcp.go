@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/compose"
+	"github.com/nleiva/gcp-rphy/transport"
+)
+
+// errGone is returned by acceptRPD when the RPD disconnects right away.
+var errGone = errors.New("the RPD disconnected")
+
+// readRCP runs the read subcommand.
+func readRCP(args []string) error {
+	return exchange("read", gcp.OpRead, args)
+}
+
+// writeRCP runs the write subcommand.
+func writeRCP(args []string) error {
+	return exchange("write", gcp.OpWrite, args)
+}
+
+// exchange sends an EDS Request with an RCP operation op on the paths
+// given as arguments, and prints out the response.
+func exchange(name string, op gcp.Operation, args []string) error {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	var (
+		listenFlag  = fs.Bool("l", false, "listen on address for the RPD to connect, instead of connecting to it")
+		seqFlag     = fs.Uint("seq", 1, "RCP sequence number")
+		vendorFlag  = fs.Uint("vendor", uint(gcp.CableLabs), "Vendor ID of the EDS Request")
+		timeoutFlag = fs.Duration("timeout", 5*time.Second, "time to wait for the response")
+		formatFlag  = formatFlag(fs)
+	)
+	operand := "path"
+	if op == gcp.OpWrite {
+		operand = "path=value"
+	}
+	fs.Usage = func() {
+		fmt.Printf("Usage: gcp %s [flags] address %s...\n", name, operand)
+		fmt.Println("  Paths are RCP TLV names or types below a Sequence, separated by dots,")
+		fmt.Println("  like RpdCapabilities.RpdIdentification.VendorName or 50.19.1.")
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() < 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	p, err := newPrinter(*formatFlag)
+	if err != nil {
+		return err
+	}
+
+	var vs []compose.PathValue
+	for _, a := range fs.Args()[1:] {
+		v := compose.PathValue{Path: a}
+		if op == gcp.OpWrite {
+			i := strings.Index(a, "=")
+			if i < 0 {
+				return fmt.Errorf("missing value of %s", a)
+			}
+			v = compose.PathValue{Path: a[:i], Value: a[i+1:]}
+		}
+		vs = append(vs, v)
+	}
+	ds, err := compose.Sequence(gcp.TypeREX, uint16(*seqFlag), op, vs...)
+	if err != nil {
+		return err
+	}
+	m := gcp.NewMessage(gcp.MessageIDEDSReq, &gcp.EDSReq{
+		TransactionID: uint16(*seqFlag),
+		VendorID:      uint32(*vendorFlag),
+		DataStr:       ds,
+	})
+
+	var s *transport.Session
+	if *listenFlag {
+		s, err = acceptRPD(fs.Arg(0), transport.HandlerFunc(func(s *transport.Session, r *transport.Request) {
+			p.message(received, s.RemoteAddr().String(), &r.TranID, r.Msg)
+		}))
+	} else {
+		s, err = transport.Dial(fs.Arg(0), nil)
+	}
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	return request(p, s, m, *timeoutFlag)
+}
+
+// acceptRPD waits for an RPD to connect to addr and returns its session,
+// whose messages are handled by h. Only the first RPD is accepted.
+func acceptRPD(addr string, h transport.Handler) (*transport.Session, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	connected := make(chan struct{}, 1)
+	c := &transport.Core{
+		Handler: h,
+		OnEvent: func(e transport.Event) {
+			if e.Type == transport.EventConnected {
+				select {
+				case connected <- struct{}{}:
+				default:
+				}
+			}
+		},
+	}
+	served := make(chan error, 1)
+	go func() { served <- c.Serve(l) }()
+	select {
+	case <-connected:
+		// The session outlives the listener, and the Core with it.
+		l.Close()
+		ss := c.Sessions()
+		if len(ss) == 0 {
+			return nil, errGone
+		}
+		return ss[0], nil
+	case err := <-served:
+		c.Close()
+		return nil, err
+	}
+}
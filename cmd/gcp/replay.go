@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/pcap"
+	"github.com/nleiva/gcp-rphy/replay"
+	"github.com/nleiva/gcp-rphy/transport"
+)
+
+var errMismatches = errors.New("live messages differ from the capture")
+
+// replayCapture runs the replay subcommand.
+func replayCapture(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	var (
+		sideFlag    = fs.String("side", "rpd", "side of the conversation to replay: rpd or core")
+		portFlag    = fs.Int("p", pcap.DefaultPort, "TCP port of GCP sessions in the capture")
+		convFlag    = fs.Int("c", 1, "conversation to replay, in order of appearance in the capture")
+		timeoutFlag = fs.Duration("timeout", 5*time.Second, "time to wait for a message from the live endpoint")
+	)
+	fs.Usage = func() {
+		fmt.Println("Usage: gcp replay [flags] capture address")
+		fmt.Println("  Replaying the rpd side connects to the core at address.")
+		fmt.Println("  Replaying the core side listens on address for the rpd.")
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	convs, err := replay.Conversations(f, *portFlag)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	if *convFlag < 1 || *convFlag > len(convs) {
+		return fmt.Errorf("conversation %d not found, the capture has %d", *convFlag, len(convs))
+	}
+	c := convs[*convFlag-1]
+
+	var side replay.Side
+	switch *sideFlag {
+	case "rpd":
+		side = replay.RPD
+	case "core":
+		side = replay.Core
+	default:
+		return fmt.Errorf("unknown side: %s", *sideFlag)
+	}
+	rp := replay.NewReplayer(side)
+	rp.Timeout = *timeoutFlag
+
+	var s *transport.Session
+	if side == replay.RPD {
+		s, err = transport.Dial(fs.Arg(1), rp)
+		if err != nil {
+			return err
+		}
+	} else {
+		var core *transport.Core
+		core, s, err = accept(fs.Arg(1), rp)
+		if err != nil {
+			return err
+		}
+		defer core.Close()
+	}
+	defer s.Close()
+	fmt.Printf("Replaying %s side of %s -> %s (%d messages)\n", side, c.RPD, c.Core, len(c.Frames))
+
+	var n, failed int
+	err = rp.Replay(c, s, func(r *replay.Result) {
+		n++
+		printResult(r)
+		if r.Err != nil {
+			failed++
+		}
+	})
+	fmt.Printf("%d messages, %d failed\n", n, failed)
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return errMismatches
+	}
+	return nil
+}
+
+// accept waits for an RPD to connect on addr, returning the Core serving
+// its Session.
+func accept(addr string, h transport.Handler) (*transport.Core, *transport.Session, error) {
+	events := make(chan transport.Event, 1)
+	core := &transport.Core{
+		Addr:    addr,
+		Handler: h,
+		OnEvent: func(e transport.Event) {
+			if e.Type == transport.EventConnected {
+				select {
+				case events <- e:
+				default:
+				}
+			}
+		},
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- core.ListenAndServe() }()
+	fmt.Printf("Waiting for the RPD on %s\n", addr)
+	select {
+	case err := <-errc:
+		return nil, nil, err
+	case <-events:
+	}
+	return core, core.Sessions()[0], nil
+}
+
+// printResult prints the outcome of replaying a message.
+func printResult(r *replay.Result) {
+	f := r.Captured
+	dir := "<-"
+	if r.Sent {
+		dir = "->"
+	}
+	name := "undecoded message"
+	if f.Msg != nil {
+		name = gcp.MessageID(f.Msg.MessageID).String()
+	}
+	status := "ok"
+	if r.Err != nil {
+		status = r.Err.Error()
+	}
+	fmt.Printf("%s %s (Transaction ID %d): %s\n", dir, name, f.TCP.TranID, status)
+	for _, d := range r.Diff {
+		fmt.Printf("     %s\n", d)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/script"
+)
+
+// errTestsFailed is returned by runScripts when a test of a script failed.
+var errTestsFailed = errors.New("some tests failed")
+
+// runScripts runs the run subcommand.
+func runScripts(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	var (
+		verboseFlag = fs.Bool("v", false, "print the messages exchanged")
+		timeoutFlag = fs.Duration("timeout", 5*time.Second, "time to wait for responses")
+		formatFlag  = formatFlag(fs)
+	)
+	fs.Usage = func() {
+		fmt.Println("Usage: gcp run [flags] script...")
+		fmt.Println("  Runs the GCP exchanges and checks of the scripts, and reports the")
+		fmt.Println("  tests that passed or failed. See the script package for the syntax.")
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	p, err := newPrinter(*formatFlag)
+	if err != nil {
+		return err
+	}
+
+	ru := &script.Runner{Timeout: *timeoutFlag}
+	if *verboseFlag {
+		ru.Format = p.setFormat
+		ru.Print = func(s bool, peer string, tid *uint16, m *gcp.Message) {
+			dir := received
+			if s {
+				dir = sent
+			}
+			p.message(dir, peer, tid, m)
+		}
+	}
+	var failed bool
+	for _, name := range fs.Args() {
+		rep, err := ru.RunFile(name)
+		if err != nil {
+			return err
+		}
+		rep.Write(os.Stdout)
+		failed = failed || rep.Failed() > 0
+	}
+	if failed {
+		return errTestsFailed
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/shell"
+	"github.com/nleiva/gcp-rphy/transport"
+)
+
+// runShell runs the shell subcommand.
+func runShell(args []string) error {
+	fs := flag.NewFlagSet("shell", flag.ExitOnError)
+	var (
+		listenFlag  = fs.Bool("l", false, "listen on address for the RPD to connect, instead of connecting to it")
+		timeoutFlag = fs.Duration("timeout", 5*time.Second, "time to wait for responses")
+		formatFlag  = formatFlag(fs)
+	)
+	fs.Usage = func() {
+		fmt.Println("Usage: gcp shell [flags] address")
+		fmt.Println("  Opens a session to an RPD or a core and runs the commands typed,")
+		fmt.Println("  like read 50.19 or notify StartUpNotification. Type help for the list.")
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	p, err := newPrinter(*formatFlag)
+	if err != nil {
+		return err
+	}
+
+	addr := fs.Arg(0)
+	sh := &shell.Shell{Format: p.setFormat, Timeout: *timeoutFlag}
+	sh.Print = func(s bool, tid *uint16, m *gcp.Message) {
+		dir := received
+		if s {
+			dir = sent
+		}
+		p.message(dir, sh.Session.RemoteAddr().String(), tid, m)
+	}
+	// Messages received wait for the session to be set up, as an RPD
+	// notifies its start up as soon as it connects.
+	ready := make(chan struct{})
+	h := transport.HandlerFunc(func(_ *transport.Session, r *transport.Request) {
+		<-ready
+		sh.Received(r)
+	})
+	if *listenFlag {
+		fmt.Printf("Waiting for an RPD on %s\n", addr)
+		sh.Session, err = acceptRPD(addr, h)
+	} else {
+		sh.Session, err = transport.Dial(addr, h)
+	}
+	if err != nil {
+		return err
+	}
+	defer sh.Session.Close()
+	close(ready)
+	fmt.Printf("Connected to %s, type help for the list of commands.\n", sh.Session.RemoteAddr())
+	return sh.Run(os.Stdin)
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/compose"
+	"github.com/nleiva/gcp-rphy/rpdsim"
+	"github.com/nleiva/gcp-rphy/transport"
+)
+
+// simulateRPD runs the simulate-rpd subcommand.
+func simulateRPD(args []string) error {
+	fs := flag.NewFlagSet("simulate-rpd", flag.ExitOnError)
+	var (
+		configFlag = fs.String("c", "", "YAML or JSON file with the RCP objects of the RPD, overridden by the flags given")
+		recordFlag = fs.String("r", "", "record the messages exchanged to a pcapng file")
+		formatFlag = formatFlag(fs)
+		notifyFlag schedules
+	)
+	fs.String("vendor", "gcp-rphy", "vendor name of the RPD")
+	fs.String("model", "simulator", "model number of the RPD")
+	fs.String("mac", "02:00:00:00:00:01", "MAC address of the RPD")
+	fs.String("serial", "0000000001", "serial number of the RPD")
+	fs.Var(&notifyFlag, "notify", "raise a notification periodically, like TimeOutNotification@30s; can be repeated")
+	fs.Usage = func() {
+		fmt.Println("Usage: gcp simulate-rpd [flags] core-address")
+		fmt.Println("  Connects to a CCAP Core and answers its RCP reads and writes,")
+		fmt.Println("  keeping the values written. See also gcp-rpd-sim.")
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	p, err := newPrinter(*formatFlag)
+	if err != nil {
+		return err
+	}
+	rec, done, err := recordTo(*recordFlag)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	st := &rpdsim.Store{}
+	if *configFlag != "" {
+		doc, err := ioutil.ReadFile(*configFlag)
+		if err != nil {
+			return err
+		}
+		if err := st.Load(doc); err != nil {
+			return fmt.Errorf("%s: %v", *configFlag, err)
+		}
+	}
+	// The identification flags given apply, and all of them without a
+	// configuration file.
+	visit := fs.Visit
+	var vs []compose.PathValue
+	if *configFlag == "" {
+		visit = fs.VisitAll
+		vs = append(vs, compose.PathValue{Path: identity + "VendorId", Value: fmt.Sprint(gcp.CableLabs)})
+	}
+	visit(func(f *flag.Flag) {
+		if name, ok := identification[f.Name]; ok {
+			vs = append(vs, compose.PathValue{Path: identity + name, Value: f.Value.String()})
+		}
+	})
+	if err := st.Set(vs...); err != nil {
+		return err
+	}
+
+	r := &rpdsim.RPD{
+		Core:      fs.Arg(0),
+		Store:     st,
+		Schedules: notifyFlag,
+		OnEvent:   logEvent,
+		Recorder:  rec,
+		Print: func(s bool, peer string, tid *uint16, m *gcp.Message) {
+			dir := received
+			if s {
+				dir = sent
+			}
+			p.message(dir, peer, tid, m)
+		},
+	}
+	return r.Run()
+}
+
+// identity is the path of the RpdIdentification TLVs, set by the flags of
+// simulate-rpd named in identification.
+const identity = "RpdCapabilities.RpdIdentification."
+
+var identification = map[string]string{
+	"vendor": "VendorName",
+	"model":  "ModelNumber",
+	"mac":    "DeviceMacAddress",
+	"serial": "SerialNumber",
+}
+
+// schedules is a flag.Value of notification schedules.
+type schedules []rpdsim.Schedule
+
+func (ss *schedules) String() string { return fmt.Sprint(*ss) }
+
+func (ss *schedules) Set(v string) error {
+	s, err := rpdsim.ParseSchedule(v)
+	if err != nil {
+		return err
+	}
+	*ss = append(*ss, s)
+	return nil
+}
+
+// simulateCore runs the simulate-core subcommand.
+func simulateCore(args []string) error {
+	fs := flag.NewFlagSet("simulate-core", flag.ExitOnError)
+	var (
+		addrFlag    = fs.String("a", ":8190", "TCP address to listen on")
+		readFlag    = fs.String("read", "RpdCapabilities", "comma separated RCP paths to read from RPDs once they notify their start up")
+		timeoutFlag = fs.Duration("timeout", 5*time.Second, "time to wait for the response of an RPD")
+		recordFlag  = fs.String("r", "", "record the messages exchanged to a pcapng file")
+		formatFlag  = formatFlag(fs)
+	)
+	fs.Usage = func() {
+		fmt.Println("Usage: gcp simulate-core [flags]")
+		fmt.Println("  Accepts RPDs and reads RCP objects from them.")
+		fmt.Println("Flags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	p, err := newPrinter(*formatFlag)
+	if err != nil {
+		return err
+	}
+	rec, done, err := recordTo(*recordFlag)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	var vs []compose.PathValue
+	for _, path := range strings.Split(*readFlag, ",") {
+		if path != "" {
+			vs = append(vs, compose.PathValue{Path: path})
+		}
+	}
+	if _, err := compose.Sequence(gcp.TypeREX, 0, gcp.OpRead, vs...); err != nil {
+		return err
+	}
+
+	var (
+		mu  sync.Mutex
+		seq uint16
+	)
+	c := &transport.Core{
+		Addr: *addrFlag,
+		Handler: transport.HandlerFunc(func(s *transport.Session, r *transport.Request) {
+			p.message(received, s.RemoteAddr().String(), &r.TranID, r.Msg)
+			if _, ok := r.Msg.Body.(*gcp.NotifyReq); !ok || len(vs) == 0 {
+				return
+			}
+			mu.Lock()
+			seq++
+			n := seq
+			mu.Unlock()
+			ds, _ := compose.Sequence(gcp.TypeREX, n, gcp.OpRead, vs...)
+			m := gcp.NewMessage(gcp.MessageIDEDSReq, &gcp.EDSReq{
+				TransactionID: n,
+				VendorID:      gcp.CableLabs,
+				DataStr:       ds,
+			})
+			// Handlers must not wait for responses on their own session.
+			go func() {
+				if err := request(p, s, m, *timeoutFlag); err != nil {
+					log.Printf("%s: %v", s.RemoteAddr(), err)
+				}
+			}()
+		}),
+		OnEvent:  logEvent,
+		Recorder: rec,
+	}
+	return c.ListenAndServe()
+}
@@ -0,0 +1,498 @@
+// Package compose builds GCP messages from JSON or YAML documents, so
+// RCP scenarios can be kept as readable files rather than Go code.
+//
+// A document describes the GCP header, the body fields and the RCP TLVs
+// of a message:
+//
+//	# Read the RpdInfo of an RPD.
+//	message: EDS Request     # Message ID, by name or number
+//	body:
+//	  TransactionID: 1
+//	  VendorID: 4491
+//	  DataStr:
+//	    REX:
+//	      Sequence:
+//	        SequenceNumber: 1
+//	        Operation: Read
+//	        RpdInfo: {}
+//
+// Body fields and TLVs are named as in the gcp package, ignoring case,
+// spaces and punctuation, or, for TLVs, by their type number. TLVs can
+// also be named as in the JSON output of Process, so it can be composed
+// back into the message it came from. Missing body fields are zero and
+// the Message Length is computed unless given as length. TLVs are
+// encoded in document order; a list encodes a TLV once per item, as do
+// repeated keys in JSON.
+//
+// Values are encoded according to the TLV they belong to: numbers or
+// enumerated value names for integers, hundredths of a second for time
+// ticks, text for strings, "a0:f8:49:6f:43:1c" for MAC addresses, IPv4
+// or IPv6 addresses, "2019-04-02 18:50:42.5 +08:00" for dates, and
+// hexadecimal bytes for opaque values. The values Process renders, like
+// "100 Mbps" or dates in the format of time.Time.String, are accepted
+// too. Any value written as 0x followed by hexadecimal digits is used
+// as is, and an empty value encodes an empty TLV, which allows building
+// malformed messages on purpose. So does a whole body given as 0x
+// followed by hexadecimal digits.
+//
+// YAML documents are limited to block mappings and sequences of plain
+// or quoted scalars, with {} and [] as the only flow collections.
+package compose
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+)
+
+// Error messages
+var (
+	errTrailingData = errors.New("unexpected data after the document")
+	errTabIndent    = errors.New("tabs cannot indent YAML")
+	errManyDocs     = errors.New("only one document is supported")
+	errIndent       = errors.New("unexpected indentation")
+	errNoKey        = errors.New("expected a key followed by ':'")
+	errFlow         = errors.New("flow collections other than {} and [] are not supported")
+	errQuote        = errors.New("malformed quoted string")
+	errNoMessage    = errors.New("missing message")
+	errNotMapping   = errors.New("expected a mapping")
+	errNotScalar    = errors.New("expected a value")
+	errNoLayout     = errors.New("body must be given as 0x and hexadecimal digits for this message")
+	errUnknownKey   = errors.New("unknown key")
+	errUnknownTLV   = errors.New("TLV unknown to the decoder needs a mapping or 0x and hexadecimal digits")
+)
+
+// Encode returns the GCP message described by the JSON or YAML document
+// doc.
+func Encode(doc []byte) ([]byte, error) {
+	n, err := parse(doc)
+	if err != nil {
+		return nil, err
+	}
+	return encodeMessage(n)
+}
+
+// Message returns the GCP message described by the JSON or YAML document
+// doc, parsed by gcp.ParseMessage when possible. Messages that do not
+// parse, like malformed ones, have a gcp.RawBody with their exact body.
+func Message(doc []byte) (*gcp.Message, error) {
+	b, err := Encode(doc)
+	if err != nil {
+		return nil, err
+	}
+	if m, err := gcp.ParseMessage(b); err == nil {
+		return m, nil
+	}
+	m := &gcp.Message{MessageID: b[0], Lenght: binary.BigEndian.Uint16(b[1:3])}
+	m.Body = &gcp.RawBody{Data: b[3:]}
+	return m, nil
+}
+
+// Split returns the keys of the mapping document doc and their values,
+// as JSON documents, so a document can group others, like the RCP TLVs
+// to write to each RPD.
+func Split(doc []byte) (keys []string, values [][]byte, err error) {
+	n, err := parse(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+	if n.kind == nullNode {
+		return nil, nil, nil
+	}
+	if n.kind != mapNode {
+		return nil, nil, errNotMapping
+	}
+	for i, k := range n.keys {
+		var b bytes.Buffer
+		n.vals[i].writeJSON(&b)
+		keys = append(keys, k)
+		values = append(values, b.Bytes())
+	}
+	return keys, values, nil
+}
+
+// encodeMessage encodes the message document n.
+func encodeMessage(n *node) ([]byte, error) {
+	if n.kind != mapNode {
+		return nil, errNotMapping
+	}
+	var (
+		id     gcp.MessageID
+		hasID  bool
+		length *node
+		body   = &node{kind: nullNode}
+	)
+	for i, k := range n.keys {
+		v := n.vals[i]
+		switch norm(k) {
+		case "message", "messageid":
+			m, err := messageID(v)
+			if err != nil {
+				return nil, &keyError{k, err}
+			}
+			id, hasID = m, true
+		case "length", "messagelength":
+			length = v
+		case "body":
+			body = v
+		default:
+			return nil, &keyError{k, errUnknownKey}
+		}
+	}
+	if !hasID {
+		return nil, errNoMessage
+	}
+	bd, err := encodeBody(id, body)
+	if err != nil {
+		return nil, &keyError{"body", err}
+	}
+	b := make([]byte, 3, 3+len(bd))
+	b[0] = byte(id)
+	l := uint64(len(bd))
+	if length != nil {
+		if l, err = number(length, 16); err != nil {
+			return nil, &keyError{"length", err}
+		}
+	}
+	binary.BigEndian.PutUint16(b[1:3], uint16(l))
+	return append(b, bd...), nil
+}
+
+// messageID returns the Message ID named or numbered n.
+func messageID(n *node) (gcp.MessageID, error) {
+	if n.kind != scalarNode {
+		return 0, errNotScalar
+	}
+	for _, id := range gcp.MessageIDs() {
+		if norm(id.String()) == norm(n.scalar) {
+			return id, nil
+		}
+	}
+	v, err := number(n, 8)
+	if err != nil {
+		return 0, fmt.Errorf("unknown message %q", n.scalar)
+	}
+	return gcp.MessageID(v), nil
+}
+
+// encodeBody encodes the body document n of a message with id.
+func encodeBody(id gcp.MessageID, n *node) ([]byte, error) {
+	if b, ok, err := raw(n); ok || err != nil {
+		return b, err
+	}
+	layout := gcp.BodyLayout(id)
+	if layout == nil {
+		if n.kind == nullNode {
+			return nil, nil
+		}
+		return nil, errNoLayout
+	}
+	if n.kind == nullNode {
+		n = &node{kind: mapNode}
+	}
+	if n.kind != mapNode {
+		return nil, errNotMapping
+	}
+	vals := make([]*node, len(layout))
+	for i, k := range n.keys {
+		found := false
+		for j, f := range layout {
+			if norm(f.Name) == norm(k) {
+				vals[j], found = n.vals[i], true
+			}
+		}
+		if !found {
+			return nil, &keyError{k, errUnknownKey}
+		}
+	}
+	var b []byte
+	for i, f := range layout {
+		v := vals[i]
+		if v == nil {
+			b = append(b, make([]byte, f.Size)...)
+			continue
+		}
+		var fb []byte
+		var err error
+		if f.RCP {
+			fb, err = encodeTLVs(v, rootSchema)
+		} else {
+			fb, err = encodeUint(v, f.Size, nil)
+		}
+		if err != nil {
+			return nil, &keyError{f.Name, err}
+		}
+		b = append(b, fb...)
+	}
+	return b, nil
+}
+
+// rootSchema describes the Top Level TLVs of the RCP.
+var rootSchema = &gcp.TLVSchema{Kind: gcp.KindComplex, Sub: gcp.Schema()}
+
+// encodeTLVs encodes the TLVs in the document n, the value of the
+// Complex TLV described by parent, or identified by type number if
+// parent is nil.
+func encodeTLVs(n *node, parent *gcp.TLVSchema) ([]byte, error) {
+	if b, ok, err := raw(n); ok || err != nil {
+		return b, err
+	}
+	switch n.kind {
+	case nullNode:
+		return nil, nil
+	case mapNode:
+	default:
+		return nil, errNotMapping
+	}
+	var b []byte
+	for i, k := range n.keys {
+		typ, s, err := lookup(k, parent)
+		if err != nil {
+			return nil, &keyError{k, err}
+		}
+		items := []*node{n.vals[i]}
+		if n.vals[i].kind == seqNode {
+			items = n.vals[i].items
+		}
+		for _, item := range items {
+			v, err := encodeValue(item, s)
+			if err != nil {
+				return nil, &keyError{k, err}
+			}
+			if len(v) > 0xffff {
+				return nil, &keyError{k, fmt.Errorf("value of %d bytes too long", len(v))}
+			}
+			b = append(b, gcp.EncodeTLV(typ, v)...)
+		}
+	}
+	return b, nil
+}
+
+// processNames are the types of the TLVs Process names other than the
+// decoder does, by the name of the Complex TLV they belong to.
+var processNames = map[string]map[string]uint8{
+	"":                    {"ntf": 3},
+	"RpdRedirect":         {"ipaddress": 1},
+	"GeneralNotification": {"type": 1},
+	"IfEnet":              {"portindex": 1, "physicaladdress": 7, "adminstate": 8, "operationalstate": 9, "bandwidth": 11},
+	"IpAddress":           {"addresstype": 1, "portindex": 3, "prefixlength": 5, "origin": 6},
+}
+
+// lookup returns the type and schema of the TLV below parent named or
+// numbered k. The schema is nil for TLVs unknown to the decoder.
+func lookup(k string, parent *gcp.TLVSchema) (uint8, *gcp.TLVSchema, error) {
+	var ss []*gcp.TLVSchema
+	if parent != nil {
+		ss = parent.Sub
+	}
+	for _, s := range ss {
+		if norm(s.Name) == norm(k) {
+			return s.Type, s, nil
+		}
+	}
+	t, err := strconv.ParseUint(k, 10, 8)
+	if err != nil {
+		if parent == nil || processNames[parent.Name][norm(k)] == 0 {
+			return 0, nil, errUnknownKey
+		}
+		t = uint64(processNames[parent.Name][norm(k)])
+	}
+	for _, s := range ss {
+		if s.Type == uint8(t) {
+			return s.Type, s, nil
+		}
+	}
+	return uint8(t), nil, nil
+}
+
+// encodeValue encodes n, the value of a TLV described by s.
+func encodeValue(n *node, s *gcp.TLVSchema) ([]byte, error) {
+	if b, ok, err := raw(n); ok || err != nil {
+		return b, err
+	}
+	if n.kind == nullNode {
+		return nil, nil
+	}
+	if s == nil {
+		if n.kind == mapNode {
+			return encodeTLVs(n, nil)
+		}
+		return nil, errUnknownTLV
+	}
+	if s.Kind == gcp.KindComplex {
+		return encodeTLVs(n, s)
+	}
+	if n.kind != scalarNode {
+		return nil, errNotScalar
+	}
+	v := n.scalar
+	switch s.Kind {
+	case gcp.KindUint8, gcp.KindUint16, gcp.KindUint32:
+		return encodeUint(n, s.Kind.Size(), s.Enum)
+	case gcp.KindTimeTicks:
+		return timeTicks(n)
+	case gcp.KindString:
+		if len(v) > 255 {
+			return nil, fmt.Errorf("string of %d bytes too long", len(v))
+		}
+		return []byte(v), nil
+	case gcp.KindMAC:
+		mac, err := net.ParseMAC(v)
+		if err != nil || len(mac) != 6 {
+			return nil, fmt.Errorf("invalid MAC address %q", v)
+		}
+		return mac, nil
+	case gcp.KindIP:
+		ip := net.ParseIP(v)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", v)
+		}
+		if ip4 := ip.To4(); ip4 != nil && !strings.Contains(v, ":") {
+			return ip4, nil
+		}
+		return ip.To16(), nil
+	case gcp.KindDateAndTime:
+		return dateAndTime(v)
+	}
+	return hexBytes(v)
+}
+
+// encodeUint encodes the integer n in size bytes, looking up names in
+// enum.
+func encodeUint(n *node, size int, enum map[uint32]string) ([]byte, error) {
+	if n.kind != scalarNode {
+		return nil, errNotScalar
+	}
+	v, err := number(n, 8*size)
+	if f := strings.Fields(n.scalar); err != nil && len(f) == 2 && f[1] == "Mbps" {
+		v, err = number(&node{kind: scalarNode, scalar: f[0]}, 8*size)
+	}
+	if err != nil {
+		for e, name := range enum {
+			if norm(name) == norm(n.scalar) {
+				v, err = uint64(e), nil
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b[8-size:], nil
+}
+
+// timeTicks encodes the time ticks n, given in hundredths of a second
+// or as rendered by Process.
+func timeTicks(n *node) ([]byte, error) {
+	if n.kind == scalarNode {
+		if t, err := time.Parse("2006-01-02 15:04:05 -0700 MST", n.scalar); err == nil && t.Unix()%100 == 0 {
+			n = &node{kind: scalarNode, scalar: strconv.FormatInt(t.Unix()/100, 10)}
+		}
+	}
+	return encodeUint(n, 4, nil)
+}
+
+// number parses the scalar n as an unsigned integer of bits bits.
+func number(n *node, bits int) (uint64, error) {
+	if n.kind != scalarNode {
+		return 0, errNotScalar
+	}
+	v, err := strconv.ParseUint(n.scalar, 10, bits)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %d bit unsigned integer %q", bits, n.scalar)
+	}
+	return v, nil
+}
+
+// raw returns the bytes of n if it is written as 0x followed by
+// hexadecimal digits.
+func raw(n *node) ([]byte, bool, error) {
+	if n.kind != scalarNode || !strings.HasPrefix(n.scalar, "0x") {
+		return nil, false, nil
+	}
+	b, err := hexBytes(n.scalar[2:])
+	return b, true, err
+}
+
+// hexBytes decodes hexadecimal bytes, optionally separated by spaces or
+// colons.
+func hexBytes(s string) ([]byte, error) {
+	b, err := hex.DecodeString(strings.NewReplacer(" ", "", ":", "").Replace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid hexadecimal bytes %q", s)
+	}
+	return b, nil
+}
+
+// dateLayouts are the accepted formats of DateAndTime values.
+var dateLayouts = []string{
+	"2006-01-02 15:04:05.9 -07:00",
+	"2006-01-02 15:04:05 -07:00",
+	time.RFC3339,
+	"2006-01-02 15:04:05.9 -0700 -0700", // time.Time.String, as Process renders
+
+	"2006-01-02 15:04:05.9",
+	"2006-01-02 15:04:05",
+}
+
+// dateAndTime encodes s as a DateAndTime from RFC 2579, with the UTC
+// offset if s has one. Process renders dates without one as UTC, so
+// those have none.
+func dateAndTime(s string) ([]byte, error) {
+	for i, layout := range dateLayouts {
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			continue
+		}
+		b := make([]byte, 8, 11)
+		binary.BigEndian.PutUint16(b[0:2], uint16(t.Year()))
+		b[2], b[3], b[4] = byte(t.Month()), byte(t.Day()), byte(t.Hour())
+		b[5], b[6], b[7] = byte(t.Minute()), byte(t.Second()), byte(t.Nanosecond()/1e8)
+		_, off := t.Zone()
+		if i < 3 || i == 3 && off != 0 {
+			dir := byte('+')
+			if off < 0 {
+				dir, off = '-', -off
+			}
+			b = append(b, dir, byte(off/3600), byte(off%3600/60))
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("invalid date and time %q", s)
+}
+
+// norm returns s in lower case without anything other than letters and
+// digits, to compare names.
+func norm(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return -1
+	}, s)
+}
+
+// A keyError is an error in the value of a key of a document.
+type keyError struct {
+	key string
+	err error
+}
+
+func (e *keyError) Error() string {
+	if k, ok := e.err.(*keyError); ok {
+		return e.key + "." + k.Error()
+	}
+	return e.key + ": " + e.err.Error()
+}
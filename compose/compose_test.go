@@ -0,0 +1,276 @@
+package compose_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/compose"
+	"github.com/nleiva/gcp-rphy/hexdump"
+)
+
+var (
+	// Pre-generated GCP Notify message for testing
+	ntf = "AgFqAAHAAQAAAAEDAV8JAVwKAAIAAQsAAQIyAUkTASUBAAVDaXNjbwIAAgAJAwAIUlBIWS1SUEQEAAag+ElvQxwFAAR2Ni40BgBvUHJpbWFyeTogVS1Cb290IDIwMTYuMDEgKEp1bCAzMSAyMDE3IC0gMDk6NTQ6NTEgKzA4MDApICo7R29sZGVuOiBVLUJvb3QgMjAxNi4wMSAoQXByIDEyIDIwMTcgLSAwOToxMzoyOCArMDgwMCk7BwADUlBECAADUlBECQALQ0FUMjEzM0UwQTUKAAIRPQsACEJDTTMxNjEwDAADVjExDQAIMDAwMDAwMDAOAAMxLjAPAAYxLjAuMTAQAAMxLjARAAASAAATAAgH4wQCEjIqBRQAEFJQRC1WNi00Lml0Yi5TU0EVABAgAQV4EAAREQAAAAAAAAJFFgABABgAHgEAAk5BAgAJKzAwMDAwMC4wAwAKKzAwMDAwMDAuMFYABAEAAQE="
+	// Pre-generated GCP RCP Object Exchange message for testing
+	rex = "BwMgCEQAAAAAAAAAEYsBAgMRCQMOCgACAAoLAAEEEwABAGQC/ggAbgEAAQICAAR2YmgxAwAmVmlydHVhbCBCYWNraGF1bCBUZW4gR2lnYWJpdCBJbnRlcmZhY2UEAAIABgUAAAYABAAABdwHAAag+ElvQx0IAAEBCQABBwoABAAANV0LAAQAACcQDAABAg0AAQIOAAECCABuAQABAQIABHZiaDADACZWaXJ0dWFsIEJhY2toYXVsIFRlbiBHaWdhYml0IEludGVyZmFjZQQAAgAGBQAABgAEAAAF3AcABqD4SW9DHAgAAQEJAAEBCgAEAAA1uwsABAAAJxAMAAECDQABAg4AAQEPADEBAAQAAAABAgAECgAB/gMAAQQEAAEBBQACABgGAAEEBwABAQgABAAAAAAJAAQAAAAADwAxAQAEAAAAAQIABH8AAAEDAAEHBAABAQUAAgAIBgABBAcAAQEIAAQAAAAACQAEAAAAAA8AMQEABAAAAAECAATAqAEBAwABAwQAAQEFAAIAGAYAAQQHAAEBCAAEAAAAAAkABAAAAAAPAD0BAAQAAAACAgAQAAAAAAAAAAAAAAAAAAAAAQMAAQcEAAEBBQACAIAGAAEBBwABAQgABAAAAAAJAAQAAAAADwA9AQAEAAAAAgIAECABBXgQAAESAAAAAAAAAwEDAAEBBAABAQUAAgBABgABBAcAAQEIAAQAAFyfCQAEAABcnw8APQEABAAAAAICABD+gAAAAAAAAKL4Sf/+b0McAwABAQQAAQEFAAIAQAYAAQEHAAEBCAAEAABcnwkABAAAXJ8PAD0BAAQAAAACAgAQ/oAAAAAAAACi+En//m9DHQMAAQIEAAEBBQACAEAGAAEBBwABAQgABAAAAAAJAAQAAAAADwA9AQAEAAAAAgIAEP6AAAAAAAAAovhJ//5vQx4DAAEDBAABAQUAAgBABgABAQcAAQEIAAQAAAAACQAEAAAAAA8APQEABAAAAAICABD+gAAAAAAAAKgzEf/+ZgAAAwABBAQAAQEFAAIAQAYAAQEHAAEBCAAEAAAAAAkABAAAAAA="
+)
+
+const edsYAML = `
+# Read the RpdInfo of an RPD.
+message: EDS Request   # by name
+body:
+  TransactionID: 7
+  VendorID: 4491
+  DataStr:
+    REX:
+      Sequence:
+        SequenceNumber: 7
+        Operation: Read
+        RpdInfo: {}
+`
+
+const edsJSON = `{
+  "message": 6,
+  "body": {
+    "Transaction ID": 7,
+    "VendorID": 4491,
+    "DataStr": {"2": {"Sequence": {"Sequence Number": 7, "operation": "read", "RpdInfo": null}}}
+  }
+}`
+
+const notifyYAML = `
+message: Notify Request
+body:
+  TransactionID: 1
+  EvntData:
+    Notify:
+      Sequence:
+        SequenceNumber: 1
+        Operation: Write
+        RpdCapabilities:
+          RpdIdentification:
+            VendorName: "Cisco # 1"
+            DeviceMacAddress: a0:f8:49:6f:43:1c
+            CurrentSwImageLastUpdate: 2019-04-02 18:50:42.5 +08:00
+            CurrentSwImageServer: 2001:578:1000:1111::245
+        RpdRedirect:
+          - RedirectIpAddress: 10.0.0.1
+          - RedirectIpAddress: 10.0.0.2
+        77: 0x0102
+`
+
+func TestEncode(t *testing.T) {
+	eds, _ := gcp.NewMessage(gcp.MessageIDEDSReq, &gcp.EDSReq{
+		TransactionID: 7,
+		VendorID:      gcp.CableLabs,
+		DataStr:       gcp.EncodeSequence(gcp.TypeREX, 7, gcp.OpRead, gcp.EncodeTLV(100)),
+	}).Marshal()
+	ntf, _ := gcp.NewMessage(gcp.MessageIDNotifyReq, &gcp.NotifyReq{
+		TransactionID: 1,
+		EvntData: gcp.EncodeSequence(gcp.TypeNTF, 1, gcp.OpWrite,
+			gcp.EncodeTLV(50, gcp.EncodeTLV(19,
+				gcp.EncodeTLV(1, []byte("Cisco # 1")),
+				gcp.EncodeTLV(4, []byte{0xa0, 0xf8, 0x49, 0x6f, 0x43, 0x1c}),
+				gcp.EncodeTLV(19, []byte{0x07, 0xe3, 4, 2, 18, 50, 42, 5, '+', 8, 0}),
+				gcp.EncodeTLV(21, []byte{0x20, 0x01, 0x05, 0x78, 0x10, 0, 0x11, 0x11, 0, 0, 0, 0, 0, 0, 0x02, 0x45}),
+			)),
+			gcp.EncodeTLV(25, gcp.EncodeTLV(1, []byte{10, 0, 0, 1})),
+			gcp.EncodeTLV(25, gcp.EncodeTLV(1, []byte{10, 0, 0, 2})),
+			gcp.EncodeTLV(77, []byte{1, 2}),
+		),
+	}).Marshal()
+
+	tt := []struct {
+		name string
+		doc  string
+		want []byte
+	}{
+		{name: "YAML", doc: edsYAML, want: eds},
+		{name: "JSON", doc: edsJSON, want: eds},
+		{name: "Value types", doc: notifyYAML, want: ntf},
+		{name: "Malformed", doc: "message: 2\nlength: 99\nbody: 0x0001", want: []byte{2, 0, 99, 0, 1}},
+		{name: "Unknown message", doc: `{"message": 200, "body": "0xff"}`, want: []byte{200, 0, 1, 0xff}},
+		{name: "Empty TLV", doc: "message: 2\nbody:\n  EvntData:\n    Notify:\n      Sequence:\n        Operation:\n",
+			want: append([]byte{2, 0, 17, 0, 0, 0, 0, 0, 0, 0, 0}, gcp.EncodeTLV(3, gcp.EncodeTLV(9, gcp.EncodeTLV(11)))...)},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := compose.Encode([]byte(tc.doc))
+			if err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+			if !bytes.Equal(b, tc.want) {
+				t.Errorf("Encode got:\n%s\nwant:\n%s", hexdump.Message(b), hexdump.Message(tc.want))
+			}
+		})
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	tt := []struct {
+		name string
+		doc  string
+		err  string
+	}{
+		{name: "No message", doc: "body: {}", err: "missing message"},
+		{name: "Unknown value", doc: strings.Replace(edsYAML, "Operation: Read", "Operation: Reed", 1),
+			err: `body.DataStr.REX.Sequence.Operation: invalid 8 bit unsigned integer "Reed"`},
+		{name: "Unknown TLV", doc: strings.Replace(edsYAML, "RpdInfo: {}", "RpdInformation: {}", 1),
+			err: "body.DataStr.REX.Sequence.RpdInformation: unknown key"},
+		{name: "Bad MAC", doc: "message: 2\nbody:\n  EvntData:\n    3:\n      9:\n        50:\n          19:\n            4: a0:f8",
+			err: `body.EvntData.3.9.50.19.4: invalid MAC address "a0:f8"`},
+		{name: "Tabs", doc: "message: 2\nbody:\n\tTransactionID: 1", err: "line 3: tabs cannot indent YAML"},
+		{name: "Indentation", doc: "message: 2\n  body: {}", err: "line 2: unexpected indentation"},
+		{name: "Flow", doc: "message: 2\nbody: {TransactionID: 1}", err: "line 2: flow collections other than {} and [] are not supported"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := compose.Encode([]byte(tc.doc))
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("Encode got: %v, want: %s", err, tc.err)
+			}
+		})
+	}
+}
+
+func TestMessage(t *testing.T) {
+	m, err := compose.Message([]byte(edsYAML))
+	if err != nil {
+		t.Fatalf("Message failed: %v", err)
+	}
+	req, ok := m.Body.(*gcp.EDSReq)
+	if !ok || req.TransactionID != 7 || req.VendorID != gcp.CableLabs {
+		t.Errorf("Message got: %+v", m.Body)
+	}
+}
+
+func TestSequence(t *testing.T) {
+	got, err := compose.Sequence(gcp.TypeREX, 1, gcp.OpWrite,
+		compose.PathValue{Path: "RpdCapabilities.RpdIdentification.DeviceAlias", Value: "node-1"},
+		compose.PathValue{Path: "RpdCapabilities.19.8", Value: "node-2"},
+		compose.PathValue{Path: "RpdInfo"},
+	)
+	if err != nil {
+		t.Fatalf("Sequence failed: %v", err)
+	}
+	want := gcp.EncodeSequence(gcp.TypeREX, 1, gcp.OpWrite,
+		gcp.EncodeTLV(50,
+			gcp.EncodeTLV(19, gcp.EncodeTLV(8, []byte("node-1"))),
+			gcp.EncodeTLV(19, gcp.EncodeTLV(8, []byte("node-2")))),
+		gcp.EncodeTLV(100))
+	if !bytes.Equal(got, want) {
+		t.Errorf("Sequence got: %x, want: %x", got, want)
+	}
+
+	_, err = compose.Sequence(gcp.TypeREX, 1, gcp.OpRead, compose.PathValue{Path: "RpdCapabilities.Foo"})
+	if err == nil || err.Error() != "RpdCapabilities.Foo: unknown key" {
+		t.Errorf("Sequence got: %v, want an unknown key error", err)
+	}
+}
+
+func TestTLVs(t *testing.T) {
+	doc := []byte(`
+RpdCapabilities:
+  RpdIdentification:
+    VendorName: acme
+RpdInfo:
+  IfEnet:
+    - EnetPortIndex: 1
+      Name: eth0
+    - EnetPortIndex: 2
+`)
+	got, err := compose.TLVs(doc, gcp.TypeREX)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append(gcp.EncodeTLV(50, gcp.EncodeTLV(19, gcp.EncodeTLV(1, []byte("acme")))),
+		gcp.EncodeTLV(100,
+			gcp.EncodeTLV(8, gcp.EncodeTLV(1, []byte{1}), gcp.EncodeTLV(2, []byte("eth0"))),
+			gcp.EncodeTLV(8, gcp.EncodeTLV(1, []byte{2})))...)
+	if !bytes.Equal(got, want) {
+		t.Errorf("TLVs got: %x, want: %x", got, want)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	doc := []byte(`
+# Configuration by RPD.
+02:00:00:00:00:01:
+  RpdCapabilities:
+    RpdIdentification:
+      DeviceAlias: "node: 1"
+default: {}
+`)
+	keys, values, err := compose.Split(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0] != "02:00:00:00:00:01" || keys[1] != "default" {
+		t.Fatalf("Split got keys: %q", keys)
+	}
+	if want := `{"RpdCapabilities":{"RpdIdentification":{"DeviceAlias":"node: 1"}}}`; string(values[0]) != want {
+		t.Errorf("Split got: %s, want: %s", values[0], want)
+	}
+	got, err := compose.TLVs(values[0], gcp.TypeREX)
+	if want := gcp.EncodeTLV(50, gcp.EncodeTLV(19, gcp.EncodeTLV(8, []byte("node: 1")))); err != nil || !bytes.Equal(got, want) {
+		t.Errorf("TLVs got: %x, %v, want: %x", got, err, want)
+	}
+}
+
+func TestProcessRoundTrip(t *testing.T) {
+	tt := []struct {
+		name    string
+		message string
+	}{
+		{name: "Notify", message: ntf},
+		{name: "RCP Object Exchange", message: rex},
+		// A write of the DeviceAlias alone, with no DeviceLocation.
+		{name: "RpdIdentification write", message: "BgAqAAEAAAAAAAAAEYsAAgAbCQAYCgACAAELAAECMgAMEwAJCAAGbm9kZS0x"},
+		// Process lists the addresses of all the RpdRedirect TLVs of
+		// ira under one, so it does not compose back to the same bytes.
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := base64.StdEncoding.DecodeString(tc.message)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := gcp.ParseMessage(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// A document with the body fields of m and the JSON
+			// output of Process as its RCP TLVs.
+			_, g := m.Body.Process()
+			rcp, err := json.Marshal(g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var body map[string]interface{}
+			bb, _ := json.Marshal(m.Body)
+			json.Unmarshal(bb, &body)
+			for _, f := range gcp.BodyLayout(gcp.MessageID(m.MessageID)) {
+				if f.RCP {
+					body[f.Name] = json.RawMessage(rcp)
+				}
+			}
+			doc, _ := json.Marshal(map[string]interface{}{"message": m.MessageID, "body": body})
+
+			got, err := compose.Message(doc)
+			if err != nil {
+				t.Fatalf("Message failed: %v\n%s", err, doc)
+			}
+			gb, err := got.Marshal()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(gb, b) {
+				t.Errorf("Message got:\n%s\nwant:\n%s", hexdump.Message(gb), hexdump.Message(b))
+			}
+		})
+	}
+}
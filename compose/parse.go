@@ -0,0 +1,379 @@
+package compose
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type nodeKind int
+
+const (
+	nullNode nodeKind = iota
+	scalarNode
+	mapNode
+	seqNode
+)
+
+// A node is a value of a document. Mappings keep their keys in document
+// order, repeated keys included, as the order of TLVs matters.
+type node struct {
+	kind   nodeKind
+	scalar string
+	keys   []string
+	vals   []*node
+	items  []*node
+}
+
+// parse parses doc as JSON if it starts with '{' or '[', or as YAML
+// otherwise.
+func parse(doc []byte) (*node, error) {
+	t := bytes.TrimSpace(doc)
+	if len(t) > 0 && (t[0] == '{' || t[0] == '[') {
+		return parseJSON(t)
+	}
+	return parseYAML(doc)
+}
+
+// writeJSON writes n as JSON, keeping repeated keys.
+func (n *node) writeJSON(b *bytes.Buffer) {
+	switch n.kind {
+	case nullNode:
+		b.WriteString("null")
+	case scalarNode:
+		q, _ := json.Marshal(n.scalar)
+		b.Write(q)
+	case mapNode:
+		b.WriteByte('{')
+		for i, k := range n.keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			q, _ := json.Marshal(k)
+			b.Write(q)
+			b.WriteByte(':')
+			n.vals[i].writeJSON(b)
+		}
+		b.WriteByte('}')
+	case seqNode:
+		b.WriteByte('[')
+		for i, item := range n.items {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			item.writeJSON(b)
+		}
+		b.WriteByte(']')
+	}
+}
+
+// parseJSON parses the JSON document b.
+func parseJSON(b []byte) (*node, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	n, err := jsonValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errTrailingData
+	}
+	return n, nil
+}
+
+func jsonValue(dec *json.Decoder) (*node, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch v := tok.(type) {
+	case json.Delim:
+		switch v {
+		case '{':
+			n := &node{kind: mapNode}
+			for dec.More() {
+				k, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				val, err := jsonValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				n.keys = append(n.keys, k.(string))
+				n.vals = append(n.vals, val)
+			}
+			_, err := dec.Token()
+			return n, err
+		case '[':
+			n := &node{kind: seqNode}
+			for dec.More() {
+				val, err := jsonValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				n.items = append(n.items, val)
+			}
+			_, err := dec.Token()
+			return n, err
+		}
+	case nil:
+		return &node{kind: nullNode}, nil
+	case string:
+		return &node{kind: scalarNode, scalar: v}, nil
+	case json.Number:
+		return &node{kind: scalarNode, scalar: v.String()}, nil
+	case bool:
+		return &node{kind: scalarNode, scalar: strconv.FormatBool(v)}, nil
+	}
+	return nil, fmt.Errorf("unexpected JSON token: %v", tok)
+}
+
+// A line is a line of a YAML document, without its indentation and
+// comment.
+type line struct {
+	no     int
+	indent int
+	text   string
+}
+
+// parseYAML parses doc, a YAML document made of block mappings, block
+// sequences and scalars. Flow collections are limited to {} and [].
+func parseYAML(doc []byte) (*node, error) {
+	var lines []line
+	for i, l := range strings.Split(string(doc), "\n") {
+		l = strings.TrimRight(l, " \r")
+		text := strings.TrimLeft(l, " ")
+		if strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("line %d: %v", i+1, errTabIndent)
+		}
+		text = stripComment(text)
+		switch {
+		case text == "":
+			continue
+		case text == "---" || text == "...":
+			if len(lines) > 0 {
+				return nil, fmt.Errorf("line %d: %v", i+1, errManyDocs)
+			}
+			continue
+		}
+		lines = append(lines, line{no: i + 1, indent: len(l) - len(strings.TrimLeft(l, " ")), text: text})
+	}
+	if len(lines) == 0 {
+		return &node{kind: nullNode}, nil
+	}
+	p := &yamlParser{lines: lines}
+	n, err := p.block(lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.i < len(p.lines) {
+		return nil, fmt.Errorf("line %d: %v", p.lines[p.i].no, errIndent)
+	}
+	return n, nil
+}
+
+type yamlParser struct {
+	lines []line
+	i     int
+}
+
+// block parses the mapping or sequence whose entries are indented by
+// indent.
+func (p *yamlParser) block(indent int) (*node, error) {
+	if l := p.lines[p.i]; l.text == "-" || strings.HasPrefix(l.text, "- ") {
+		return p.sequence(indent)
+	}
+	return p.mapping(indent)
+}
+
+func (p *yamlParser) sequence(indent int) (*node, error) {
+	n := &node{kind: seqNode}
+	for p.i < len(p.lines) {
+		l := p.lines[p.i]
+		if l.indent != indent || !(l.text == "-" || strings.HasPrefix(l.text, "- ")) {
+			break
+		}
+		rest := strings.TrimLeft(strings.TrimPrefix(l.text, "-"), " ")
+		if rest == "" {
+			p.i++
+			item, err := p.nested(indent, true)
+			if err != nil {
+				return nil, err
+			}
+			n.items = append(n.items, item)
+			continue
+		}
+		if _, _, ok := splitKey(rest); ok || strings.HasPrefix(rest, "- ") {
+			// The item is a collection starting on the same line; treat
+			// its first entry as a line of its own.
+			p.lines[p.i] = line{no: l.no, indent: indent + len(l.text) - len(rest), text: rest}
+			item, err := p.block(p.lines[p.i].indent)
+			if err != nil {
+				return nil, err
+			}
+			n.items = append(n.items, item)
+			continue
+		}
+		item, err := scalar(rest)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", l.no, err)
+		}
+		n.items = append(n.items, item)
+		p.i++
+	}
+	return n, nil
+}
+
+func (p *yamlParser) mapping(indent int) (*node, error) {
+	n := &node{kind: mapNode}
+	for p.i < len(p.lines) {
+		l := p.lines[p.i]
+		if l.indent < indent {
+			break
+		}
+		if l.indent > indent {
+			return nil, fmt.Errorf("line %d: %v", l.no, errIndent)
+		}
+		k, rest, ok := splitKey(l.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: %v", l.no, errNoKey)
+		}
+		p.i++
+		var val *node
+		var err error
+		if rest == "" {
+			val, err = p.nested(indent, false)
+		} else {
+			val, err = scalar(rest)
+			if err != nil {
+				err = fmt.Errorf("line %d: %v", l.no, err)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		n.keys = append(n.keys, k)
+		n.vals = append(n.vals, val)
+	}
+	return n, nil
+}
+
+// nested parses the block that follows an entry indented by indent, or
+// returns a null node if there is none. Sequences may be indented as
+// much as the key they belong to.
+func (p *yamlParser) nested(indent int, item bool) (*node, error) {
+	if p.i >= len(p.lines) {
+		return &node{kind: nullNode}, nil
+	}
+	l := p.lines[p.i]
+	seq := l.text == "-" || strings.HasPrefix(l.text, "- ")
+	if l.indent > indent || !item && seq && l.indent == indent {
+		return p.block(l.indent)
+	}
+	return &node{kind: nullNode}, nil
+}
+
+// splitKey splits the mapping entry s into its key and value.
+func splitKey(s string) (key, rest string, ok bool) {
+	if s == "" {
+		return "", "", false
+	}
+	if q := s[0]; q == '"' || q == '\'' {
+		end := closingQuote(s)
+		if end < 0 {
+			return "", "", false
+		}
+		k, err := unquote(s[:end+1])
+		if err != nil {
+			return "", "", false
+		}
+		rest = s[end+1:]
+		if rest != ":" && !strings.HasPrefix(rest, ": ") {
+			return "", "", false
+		}
+		return k, strings.TrimSpace(rest[1:]), true
+	}
+	if strings.HasSuffix(s, ":") && !strings.Contains(s[:len(s)-1], ": ") {
+		return s[:len(s)-1], "", true
+	}
+	i := strings.Index(s, ": ")
+	if i < 0 {
+		return "", "", false
+	}
+	return s[:i], strings.TrimSpace(s[i+2:]), true
+}
+
+// scalar parses the inline value s.
+func scalar(s string) (*node, error) {
+	switch {
+	case s == "{}":
+		return &node{kind: mapNode}, nil
+	case s == "[]":
+		return &node{kind: seqNode}, nil
+	case s == "~" || s == "null":
+		return &node{kind: nullNode}, nil
+	case s[0] == '{' || s[0] == '[':
+		return nil, errFlow
+	case s[0] == '"' || s[0] == '\'':
+		if closingQuote(s) != len(s)-1 {
+			return nil, errQuote
+		}
+		v, err := unquote(s)
+		if err != nil {
+			return nil, errQuote
+		}
+		return &node{kind: scalarNode, scalar: v}, nil
+	}
+	return &node{kind: scalarNode, scalar: s}, nil
+}
+
+// closingQuote returns the index of the quote that closes the string s
+// starts, or -1.
+func closingQuote(s string) int {
+	q := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case q == '"' && s[i] == '\\':
+			i++
+		case q == '\'' && s[i] == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == q:
+			return i
+		}
+	}
+	return -1
+}
+
+func unquote(s string) (string, error) {
+	if s[0] == '\'' {
+		return strings.Replace(s[1:len(s)-1], "''", "'", -1), nil
+	}
+	return strconv.Unquote(s)
+}
+
+// stripComment removes a comment from the end of s, outside quotes.
+func stripComment(s string) string {
+	var q byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case q != 0:
+			if c == '\\' && q == '"' {
+				i++
+			} else if c == q {
+				q = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || s[i-1] == ' ' {
+				q = c
+			}
+		case c == '#' && (i == 0 || s[i-1] == ' '):
+			return strings.TrimRight(s[:i], " ")
+		}
+	}
+	return s
+}
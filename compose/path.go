@@ -0,0 +1,80 @@
+package compose
+
+import (
+	"fmt"
+	"strings"
+
+	gcp "github.com/nleiva/gcp-rphy"
+)
+
+// A PathValue is the value of the RCP TLV at Path, a dot separated list
+// of TLV names or type numbers below a Sequence TLV, like
+// "RpdCapabilities.RpdIdentification.VendorName" or "50.19.1".
+type PathValue struct {
+	Path  string
+	Value string // Written as in documents, empty for an empty TLV
+}
+
+// Sequence returns the RCP message of Top Level type top carrying a
+// Sequence TLV with the given sequence number and operation, and the
+// TLVs at the paths of vs. Consecutive paths share the Complex TLVs
+// they have in common, so reading "RpdInfo" or writing two values of
+// the same object takes a single request.
+func Sequence(top uint8, seq uint16, op gcp.Operation, vs ...PathValue) ([]byte, error) {
+	s := gcp.LookupTLV(top, 9)
+	if s == nil {
+		return nil, fmt.Errorf("unknown top level TLV %d", top)
+	}
+	root := &node{kind: mapNode}
+	for _, v := range vs {
+		keys := strings.Split(v.Path, ".")
+		n := root
+		for _, k := range keys[:len(keys)-1] {
+			last := len(n.keys) - 1
+			if last >= 0 && n.keys[last] == k && n.vals[last].kind == mapNode {
+				n = n.vals[last]
+				continue
+			}
+			c := &node{kind: mapNode}
+			n.keys = append(n.keys, k)
+			n.vals = append(n.vals, c)
+			n = c
+		}
+		val := &node{kind: nullNode}
+		if v.Value != "" {
+			val = &node{kind: scalarNode, scalar: v.Value}
+		}
+		n.keys = append(n.keys, keys[len(keys)-1])
+		n.vals = append(n.vals, val)
+	}
+	b, err := encodeTLVs(root, s)
+	if err != nil {
+		return nil, err
+	}
+	return gcp.EncodeSequence(top, seq, op, b), nil
+}
+
+// TLVs returns the RCP TLVs described by the JSON or YAML document doc, a
+// mapping of the TLVs below a Sequence TLV of Top Level type top to their
+// values, like:
+//
+//	RpdCapabilities:
+//	  RpdIdentification:
+//	    VendorName: acme
+//	RpdInfo:
+//	  IfEnet:
+//	    - EnetPortIndex: 1
+//	      Name: eth0
+//	    - EnetPortIndex: 2
+//	      Name: eth1
+func TLVs(doc []byte, top uint8) ([]byte, error) {
+	s := gcp.LookupTLV(top, 9)
+	if s == nil {
+		return nil, fmt.Errorf("unknown top level TLV %d", top)
+	}
+	n, err := parse(doc)
+	if err != nil {
+		return nil, err
+	}
+	return encodeTLVs(n, s)
+}
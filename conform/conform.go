@@ -0,0 +1,341 @@
+// Package conform tests GCP endpoints for conformance to the protocol.
+//
+// A Runner sends the endpoint a battery of requests derived from the GCP
+// and RCP specifications, well-formed and malformed: wrong message
+// lengths, truncated messages, unsupported message identifiers,
+// responses to requests never sent, reserved Mode bits, invalid Vendor
+// IDs, and TLVs that overflow or are unknown. It checks that the
+// endpoint answers each with the Normal Response or the Error Response
+// and Return Code the specification requires, and, for RCP requests, the
+// ResponseCode, that the response itself is valid, and reports the cases
+// that passed or failed.
+//
+// RPDs are sent EDS Requests and cores Notify Requests. Since RPDs
+// connect to their cores, the Runner accepts an RPD, and connects to a
+// core. When an endpoint drops the session after a case, the next case
+// runs on a new one.
+package conform
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	gcp "github.com/nleiva/gcp-rphy"
+)
+
+// Error messages
+var (
+	errNoCode = errors.New("RCP response without a ResponseCode")
+)
+
+// A Role is the role of the endpoint tested.
+type Role int
+
+// Roles.
+const (
+	RPD Role = 1 << iota
+	Core
+)
+
+func (r Role) String() string {
+	switch r {
+	case RPD:
+		return "rpd"
+	case Core:
+		return "core"
+	}
+	return fmt.Sprintf("Role(%d)", int(r))
+}
+
+// ParseRole returns the Role named s, rpd or core.
+func ParseRole(s string) (Role, error) {
+	switch strings.ToLower(s) {
+	case "rpd":
+		return RPD, nil
+	case "core":
+		return Core, nil
+	}
+	return 0, fmt.Errorf("unknown role %q, want rpd or core", s)
+}
+
+// A Case is a conformance test case.
+type Case struct {
+	Name string
+	Spec string // What the specification requires
+
+	request func(tid uint16) *gcp.Message
+	check   func(req, res *gcp.Message) error
+}
+
+// A spec describes a case for the roles it applies to.
+type spec struct {
+	name    string
+	roles   Role
+	spec    string
+	request func(r Role, tid uint16) *gcp.Message
+	check   func(req, res *gcp.Message) error
+}
+
+// unsupportedID is a Message Identifier no GCP message has.
+const unsupportedID = 100
+
+// specs are the cases, in the order they run.
+var specs = []spec{
+	{
+		name:    "valid-request",
+		roles:   RPD | Core,
+		spec:    "A well-formed request gets a Normal Response with its Transaction ID, and a ResponseCode of NoError for RCP reads",
+		request: valid,
+		check:   wantResponse(gcp.RespNoError),
+	},
+	{
+		name:  "length-too-long",
+		roles: RPD | Core,
+		spec:  "A Message Length beyond the end of the message gets an Error Response with IllegalMsgLen",
+		request: func(r Role, tid uint16) *gcp.Message {
+			m := raw(valid(r, tid))
+			m.Lenght += 4
+			return m
+		},
+		check: wantError(gcp.IllegalMsgLen),
+	},
+	{
+		name:  "length-too-short",
+		roles: RPD | Core,
+		spec:  "A Message Length short of the end of the message gets an Error Response with IllegalMsgLen",
+		request: func(r Role, tid uint16) *gcp.Message {
+			m := raw(valid(r, tid))
+			m.Lenght -= 4
+			return m
+		},
+		check: wantError(gcp.IllegalMsgLen),
+	},
+	{
+		name:  "truncated-message",
+		roles: RPD | Core,
+		spec:  "A message shorter than the fixed fields of its body gets an Error Response with IllegalMsgLen",
+		request: func(r Role, tid uint16) *gcp.Message {
+			m := raw(valid(r, tid))
+			body := m.Body.(*gcp.RawBody)
+			body.Data = body.Data[:5]
+			m.Lenght = 5
+			return m
+		},
+		check: wantError(gcp.IllegalMsgLen),
+	},
+	{
+		name:  "unsupported-message",
+		roles: RPD | Core,
+		spec:  "A request with an unknown Message Identifier gets an Error Response with UnsupportedMsg",
+		request: func(r Role, tid uint16) *gcp.Message {
+			m := raw(valid(r, tid))
+			m.MessageID = unsupportedID
+			return m
+		},
+		check: wantError(gcp.UnsupportedMsg),
+	},
+	{
+		name:  "unsolicited-response",
+		roles: RPD | Core,
+		spec:  "A Normal Response whose Transaction ID matches no request gets an Error Response with IllegalTransID",
+		request: func(r Role, tid uint16) *gcp.Message {
+			m := raw(valid(r, tid))
+			m.MessageID++
+			return m
+		},
+		check: wantError(gcp.IllegalTransID),
+	},
+	{
+		name:  "reserved-mode",
+		roles: RPD | Core,
+		spec:  "A request with reserved Mode bits set gets an Error Response with IllegalMode",
+		request: func(r Role, tid uint16) *gcp.Message {
+			m := raw(valid(r, tid))
+			m.Body.(*gcp.RawBody).Data[2] = 0x7f
+			return m
+		},
+		check: wantError(gcp.IllegalMode),
+	},
+	{
+		name:  "invalid-vendor-id",
+		roles: RPD,
+		spec:  "An EDS Request with the reserved Vendor ID 0 gets an Error Response with IllegalVendorID",
+		request: func(r Role, tid uint16) *gcp.Message {
+			m := raw(valid(r, tid))
+			binary.BigEndian.PutUint32(m.Body.(*gcp.RawBody).Data[7:11], 0)
+			return m
+		},
+		check: wantError(gcp.IllegalVendorID),
+	},
+	{
+		name:  "oversized-tlv",
+		roles: RPD,
+		spec:  "An RCP TLV whose length runs past the end of its parent gets a ResponseCode of WrongLength",
+		request: func(_ Role, tid uint16) *gcp.Message {
+			return eds(tid, []byte{50, 0x00, 0xff, 19, 0, 0})
+		},
+		check: wantResponse(gcp.RespWrongLength),
+	},
+	{
+		name:  "unknown-tlv",
+		roles: RPD,
+		spec:  "An RCP read of an unknown attribute gets a ResponseCode of AttributeNotFound",
+		request: func(_ Role, tid uint16) *gcp.Message {
+			return eds(tid, gcp.EncodeTLV(50, gcp.EncodeTLV(250)))
+		},
+		check: wantResponse(gcp.RespAttributeNotFound),
+	},
+}
+
+// Cases returns the cases for endpoints with role r.
+func Cases(r Role) []Case {
+	var cs []Case
+	for _, s := range specs {
+		if s.roles&r == 0 {
+			continue
+		}
+		s := s
+		cs = append(cs, Case{
+			Name:    s.name,
+			Spec:    s.spec,
+			request: func(tid uint16) *gcp.Message { return s.request(r, tid) },
+			check:   s.check,
+		})
+	}
+	return cs
+}
+
+// valid returns a well-formed request for an endpoint with role r: an
+// EDS Request reading the VendorName of an RPD, or a Notify Request
+// announcing the start up of an RPD to a core.
+func valid(r Role, tid uint16) *gcp.Message {
+	if r == Core {
+		return gcp.NewMessage(gcp.MessageIDNotifyReq, &gcp.NotifyReq{
+			TransactionID: tid,
+			EvntData:      gcp.GeneralNotification(tid, gcp.StartUpNotification),
+		})
+	}
+	return eds(tid, gcp.EncodeTLV(50, gcp.EncodeTLV(19, gcp.EncodeTLV(1))))
+}
+
+// eds returns an EDS Request with an IRA read of tlvs.
+func eds(tid uint16, tlvs []byte) *gcp.Message {
+	return gcp.NewMessage(gcp.MessageIDEDSReq, &gcp.EDSReq{
+		TransactionID: tid,
+		VendorID:      gcp.CableLabs,
+		DataStr:       gcp.EncodeSequence(gcp.TypeIRA, tid, gcp.OpRead, tlvs),
+	})
+}
+
+// raw returns m with a RawBody, to be altered.
+func raw(m *gcp.Message) *gcp.Message {
+	b, _ := m.Body.Marshal()
+	return &gcp.Message{MessageID: m.MessageID, Lenght: m.Lenght, Body: &gcp.RawBody{Data: b}}
+}
+
+// errorID returns the Message Identifier of the Error Response to
+// messages with identifier id: that of the Normal Response with the most
+// significant bit set.
+func errorID(id uint8) uint8 { return (id | 1) + 128 }
+
+// wantResponse checks for a Normal Response with the Transaction ID of
+// the request and, if it carries RCP TLVs, a ResponseCode of code.
+func wantResponse(code gcp.ResponseCode) func(req, res *gcp.Message) error {
+	return func(req, res *gcp.Message) error {
+		if want := req.MessageID + 1; res.MessageID != want {
+			return fmt.Errorf("got %s, want %s", describe(res), gcp.MessageID(want))
+		}
+		b, err := checkTID(req, res)
+		if err != nil {
+			return err
+		}
+		if gcp.MessageID(res.MessageID) != gcp.MessageIDEDSRes {
+			return nil
+		}
+		got, err := responseCode(b[12:])
+		if err != nil {
+			return err
+		}
+		if got != code {
+			return fmt.Errorf("got ResponseCode %s, want %s", codeName(got), codeName(code))
+		}
+		return nil
+	}
+}
+
+// wantError checks for an Error Response with the Transaction ID of the
+// request and the Return Code rc.
+func wantError(rc gcp.RtrnCode) func(req, res *gcp.Message) error {
+	return func(req, res *gcp.Message) error {
+		want := errorID(req.MessageID)
+		if res.MessageID != want {
+			return fmt.Errorf("got %s, want %s with %v", describe(res), gcp.MessageID(want), rc)
+		}
+		b, err := checkTID(req, res)
+		if err != nil {
+			return err
+		}
+		if got := gcp.RtrnCode(b[2]); got != rc {
+			return fmt.Errorf("got Return Code %v, want %v", got, rc)
+		}
+		return nil
+	}
+}
+
+// checkTID checks that res carries the Transaction ID of req, and
+// returns the body of res.
+func checkTID(req, res *gcp.Message) ([]byte, error) {
+	q, _ := req.Body.Marshal()
+	b, err := res.Body.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	min := 3
+	if gcp.MessageID(res.MessageID) == gcp.MessageIDEDSRes {
+		min = 12
+	}
+	if len(b) < min {
+		return nil, fmt.Errorf("%s body of %d bytes, want at least %d", gcp.MessageID(res.MessageID), len(b), min)
+	}
+	if got, want := binary.BigEndian.Uint16(b), binary.BigEndian.Uint16(q); got != want {
+		return nil, fmt.Errorf("got Transaction ID %d, want %d", got, want)
+	}
+	return b, nil
+}
+
+// describe names a response, with its Return Code if it is an Error
+// Response.
+func describe(m *gcp.Message) string {
+	s := gcp.MessageID(m.MessageID).String()
+	if b, _ := m.Body.Marshal(); m.MessageID >= 128 && len(b) >= 3 {
+		s += fmt.Sprintf(" with %v", gcp.RtrnCode(b[2]))
+	}
+	return s
+}
+
+// responseCode returns the ResponseCode of the RCP Data Structures ds.
+func responseCode(ds []byte) (gcp.ResponseCode, error) {
+	if len(ds) == 0 {
+		return 0, errNoCode
+	}
+	v, err := gcp.FindTLV(ds, ds[0], 9, 19)
+	if err != nil {
+		return 0, err
+	}
+	if len(v) != 1 {
+		return 0, errNoCode
+	}
+	return gcp.ResponseCode(v[0]), nil
+}
+
+// codeName returns the name of the ResponseCode c.
+func codeName(c gcp.ResponseCode) string {
+	if s := gcp.LookupTLV(gcp.TypeREX, 9, 19); s != nil {
+		if name, ok := s.Enum[uint32(c)]; ok {
+			return name
+		}
+	}
+	return fmt.Sprintf("%d", c)
+}
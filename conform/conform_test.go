@@ -0,0 +1,188 @@
+package conform_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/conform"
+	"github.com/nleiva/gcp-rphy/transport"
+)
+
+// serveConformant answers the messages read from conn as the GCP and RCP
+// specifications require, until conn fails.
+func serveConformant(conn net.Conn) {
+	defer conn.Close()
+	for {
+		u, err := transport.ReadMessage(conn)
+		if err != nil {
+			return
+		}
+		b := u.Msg
+		id, body := b[0], b[3:]
+		tid := body[:2]
+		res := func(id uint8, body ...[]byte) {
+			m := bytes.Join(body, nil)
+			msg := append([]byte{id, 0, 0}, m...)
+			binary.BigEndian.PutUint16(msg[1:3], uint16(len(m)))
+			t, _ := transport.TCPmessage{TranID: u.TranID, ProtID: 1, Len: uint16(1 + len(msg)), Msg: msg}.Marshal()
+			conn.Write(t)
+		}
+		fail := func(rc gcp.RtrnCode) { res((id|1)+128, tid, []byte{byte(rc)}) }
+		min := 8
+		if id == 6 {
+			min = 12
+		}
+		switch {
+		case id&1 == 1:
+			fail(gcp.IllegalTransID)
+		case id != 2 && id != 6:
+			fail(gcp.UnsupportedMsg)
+		case int(binary.BigEndian.Uint16(b[1:3])) != len(body) || len(body) < min:
+			fail(gcp.IllegalMsgLen)
+		case body[2] != 0:
+			fail(gcp.IllegalMode)
+		case id == 6 && binary.BigEndian.Uint32(body[7:11]) == 0:
+			fail(gcp.IllegalVendorID)
+		case id == 2:
+			res(3, body[:3], body[4:8])
+		default:
+			ds := body[12:]
+			rc := gcp.RespNoError
+			types, ok := walk(ds)
+			switch {
+			case !ok:
+				rc = gcp.RespWrongLength
+			case bytes.IndexByte(types, 250) >= 0:
+				rc = gcp.RespAttributeNotFound
+			}
+			seq := binary.BigEndian.Uint16(tid)
+			res(7, body[:12], gcp.EncodeSequence(ds[0], seq, gcp.OpReadResponse, gcp.EncodeTLV(19, []byte{byte(rc)})))
+		}
+	}
+}
+
+// walk returns the types of the TLVs of b, descending into the complex
+// TLVs of the requests, and whether they are well-formed.
+func walk(b []byte) ([]uint8, bool) {
+	var types []uint8
+	for len(b) > 0 {
+		if len(b) < 3 {
+			return nil, false
+		}
+		l := 3 + int(binary.BigEndian.Uint16(b[1:3]))
+		if l > len(b) {
+			return nil, false
+		}
+		types = append(types, b[0])
+		switch b[0] {
+		case gcp.TypeIRA, gcp.TypeREX, 9, 50, 19:
+			ts, ok := walk(b[3:l])
+			if !ok {
+				return nil, false
+			}
+			types = append(types, ts...)
+		}
+		b = b[l:]
+	}
+	return types, true
+}
+
+func TestConformant(t *testing.T) {
+	for _, role := range []conform.Role{conform.RPD, conform.Core} {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ru := &conform.Runner{Timeout: time.Second, Wait: 5 * time.Second}
+		var rep *conform.Report
+		if role == conform.RPD {
+			go func() {
+				conn, err := net.Dial("tcp", l.Addr().String())
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				serveConformant(conn)
+			}()
+			rep = ru.Accept(l, conform.Cases(role))
+		} else {
+			go func() {
+				for {
+					conn, err := l.Accept()
+					if err != nil {
+						return
+					}
+					go serveConformant(conn)
+				}
+			}()
+			rep = ru.Connect(l.Addr().String(), conform.Cases(role))
+			l.Close()
+		}
+		if rep.Failed() != 0 || len(rep.Results) < 7 {
+			var b strings.Builder
+			rep.Write(&b)
+			t.Errorf("%v report got:\n%s", role, &b)
+		}
+	}
+}
+
+func TestNonConformant(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			// Read, but never answer.
+			go io.Copy(ioutil.Discard, conn)
+		}
+	}()
+	ru := &conform.Runner{Timeout: 20 * time.Millisecond}
+	cases := conform.Cases(conform.Core)
+	rep := ru.Connect(l.Addr().String(), cases)
+	if rep.Failed() != len(cases) {
+		t.Errorf("Failed got: %d, want %d", rep.Failed(), len(cases))
+	}
+	if err := rep.Results[0].Err; err == nil || !strings.Contains(err.Error(), "no response within") {
+		t.Errorf("first error got: %v, want no response", err)
+	}
+
+	var b bytes.Buffer
+	if err := rep.WriteJUnit(&b); err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Suites []struct {
+			Tests    int `xml:"tests,attr"`
+			Failures int `xml:"failures,attr"`
+			Cases    []struct {
+				Name    string `xml:"name,attr"`
+				Failure *struct {
+					Message string `xml:"message,attr"`
+				} `xml:"failure"`
+			} `xml:"testcase"`
+		} `xml:"testsuite"`
+	}
+	if err := xml.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatalf("WriteJUnit wrote invalid XML: %v\n%s", err, &b)
+	}
+	if len(got.Suites) != 1 || got.Suites[0].Tests != len(cases) || got.Suites[0].Failures != len(cases) {
+		t.Fatalf("WriteJUnit got:\n%s", &b)
+	}
+	if c := got.Suites[0].Cases[0]; c.Name != "valid-request" || c.Failure == nil || c.Failure.Message == "" {
+		t.Errorf("first test case got: %+v", c)
+	}
+}
@@ -0,0 +1,140 @@
+package conform
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// A Result is the outcome of a case.
+type Result struct {
+	Name     string
+	Spec     string
+	Err      error // Nil if the case passed
+	Duration time.Duration
+}
+
+// A Report holds the results of the cases run against an endpoint.
+type Report struct {
+	Target  string // Address of the endpoint
+	Results []Result
+}
+
+// Failed returns the number of cases that failed.
+func (r *Report) Failed() int {
+	var n int
+	for _, res := range r.Results {
+		if res.Err != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// Write writes the report to w, a line per case followed by a summary.
+func (r *Report) Write(w io.Writer) error {
+	var b strings.Builder
+	for _, res := range r.Results {
+		status := "PASS"
+		if res.Err != nil {
+			status = "FAIL"
+		}
+		fmt.Fprintf(&b, "--- %s: %s (%.2fs)\n", status, res.Name, res.Duration.Seconds())
+		if res.Err != nil {
+			fmt.Fprintf(&b, "    %s\n    %v\n", res.Spec, res.Err)
+		}
+	}
+	if n := r.Failed(); n > 0 {
+		fmt.Fprintf(&b, "FAIL\t%s\t%d of %d cases failed\n", r.Target, n, len(r.Results))
+	} else {
+		fmt.Fprintf(&b, "ok\t%s\t%d cases passed\n", r.Target, len(r.Results))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+type jsonReport struct {
+	Target   string       `json:"target"`
+	Cases    int          `json:"cases"`
+	Failures int          `json:"failures"`
+	Results  []jsonResult `json:"results"`
+}
+
+type jsonResult struct {
+	Name     string  `json:"name"`
+	Spec     string  `json:"spec"`
+	Passed   bool    `json:"passed"`
+	Error    string  `json:"error,omitempty"`
+	Duration float64 `json:"duration"` // In seconds
+}
+
+// WriteJSON writes the report to w as a JSON object.
+func (r *Report) WriteJSON(w io.Writer) error {
+	j := jsonReport{Target: r.Target, Cases: len(r.Results), Failures: r.Failed()}
+	for _, res := range r.Results {
+		jr := jsonResult{Name: res.Name, Spec: res.Spec, Passed: res.Err == nil, Duration: res.Duration.Seconds()}
+		if res.Err != nil {
+			jr.Error = res.Err.Error()
+		}
+		j.Results = append(j.Results, jr)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(j)
+}
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the report to w in the JUnit XML format of CI
+// systems, as a test suite named after the endpoint.
+func (r *Report) WriteJUnit(w io.Writer) error {
+	s := junitSuite{Name: r.Target, Tests: len(r.Results), Failures: r.Failed()}
+	var total time.Duration
+	for _, res := range r.Results {
+		total += res.Duration
+		c := junitCase{Name: res.Name, Classname: "conform", Time: seconds(res.Duration)}
+		if res.Err != nil {
+			c.Failure = &junitFailure{Message: res.Err.Error(), Text: res.Spec}
+		}
+		s.Cases = append(s.Cases, c)
+	}
+	s.Time = seconds(total)
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitSuites{Suites: []junitSuite{s}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func seconds(d time.Duration) string { return fmt.Sprintf("%.3f", d.Seconds()) }
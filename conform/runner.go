@@ -0,0 +1,140 @@
+package conform
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/transport"
+)
+
+// defaultWait is how long Accept waits for an RPD to connect by default.
+const defaultWait = time.Minute
+
+// A Runner runs cases against an endpoint.
+type Runner struct {
+	// Print, if set, prints the messages sent to and received from peer.
+	// The encapsulation Transaction ID of messages sent is nil.
+	Print   func(sent bool, peer string, tid *uint16, m *gcp.Message)
+	Timeout time.Duration // Time to wait for each response, 5s if zero
+	Wait    time.Duration // Time Accept waits for the RPD to connect or reconnect, one minute if zero
+}
+
+// Connect runs cases against the core at addr, connecting to it again
+// when it drops the session.
+func (ru *Runner) Connect(addr string, cases []Case) *Report {
+	d := &transport.Dialer{Timeout: ru.timeout()}
+	return ru.run(addr, cases, func() (*transport.Session, error) {
+		return d.Dial(addr, ru.handler())
+	})
+}
+
+// Accept runs cases against the RPD that connects to l, waiting for it
+// to reconnect when it drops the session. Other RPDs are disconnected.
+func (ru *Runner) Accept(l net.Listener, cases []Case) *Report {
+	wait := ru.Wait
+	if wait == 0 {
+		wait = defaultWait
+	}
+	accepted := make(chan *transport.Session, 1)
+	c := &transport.Core{Handler: ru.handler()}
+	c.OnEvent = func(e transport.Event) {
+		if e.Type != transport.EventConnected {
+			return
+		}
+		for _, s := range c.Sessions() {
+			if s.RemoteAddr().String() != e.Addr {
+				continue
+			}
+			select {
+			case accepted <- s:
+			default:
+				s.Close()
+			}
+		}
+	}
+	go c.Serve(l)
+	defer c.Close()
+
+	var err error
+	return ru.run(l.Addr().String(), cases, func() (*transport.Session, error) {
+		if err != nil {
+			return nil, err
+		}
+		select {
+		case s := <-accepted:
+			return s, nil
+		case <-time.After(wait):
+			err = fmt.Errorf("no RPD connected within %v", wait)
+			return nil, err
+		}
+	})
+}
+
+// run runs cases over the sessions returned by session.
+func (ru *Runner) run(target string, cases []Case, session func() (*transport.Session, error)) *Report {
+	rep := &Report{Target: target}
+	var s *transport.Session
+	for i, c := range cases {
+		res := Result{Name: c.Name, Spec: c.Spec}
+		if s == nil || s.Err() != nil {
+			s, res.Err = session()
+		}
+		if res.Err == nil {
+			start := time.Now()
+			res.Err = ru.exchange(s, c, uint16(i+1))
+			res.Duration = time.Since(start)
+		}
+		rep.Results = append(rep.Results, res)
+	}
+	if s != nil {
+		s.Close()
+	}
+	return rep
+}
+
+// exchange sends the request of c over s and checks the response, which
+// must also be valid itself.
+func (ru *Runner) exchange(s *transport.Session, c Case, tid uint16) error {
+	req := c.request(tid)
+	peer := s.RemoteAddr().String()
+	if ru.Print != nil {
+		ru.Print(true, peer, nil, req)
+	}
+	r, err := s.Request(req, ru.timeout())
+	switch {
+	case err == nil && r != nil:
+	case err == nil, err == transport.ErrRequestTimeout:
+		return fmt.Errorf("no response within %v", ru.timeout())
+	default:
+		return fmt.Errorf("no response: %v", err)
+	}
+	if ru.Print != nil {
+		ru.Print(false, peer, &r.TranID, r.Msg)
+	}
+	if err := c.check(req, r.Msg); err != nil {
+		return err
+	}
+	if vs := r.Msg.Validate(); len(vs) > 0 {
+		return fmt.Errorf("malformed %s: %v", gcp.MessageID(r.Msg.MessageID), vs[0])
+	}
+	return nil
+}
+
+// handler returns the Handler of the sessions, which prints the messages
+// the endpoint sends on its own, like notifications, and ignores them.
+func (ru *Runner) handler() transport.Handler {
+	return transport.HandlerFunc(func(s *transport.Session, r *transport.Request) {
+		if ru.Print != nil {
+			ru.Print(false, s.RemoteAddr().String(), &r.TranID, r.Msg)
+		}
+	})
+}
+
+func (ru *Runner) timeout() time.Duration {
+	if ru.Timeout == 0 {
+		return 5 * time.Second
+	}
+	return ru.Timeout
+}
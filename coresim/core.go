@@ -0,0 +1,272 @@
+// Package coresim simulates a CCAP Core, for testing RPDs. A simulated
+// core accepts RPDs, and brings each one up when it notifies its start
+// up: it reads the RpdCapabilities of the RPD in an IRA exchange, to
+// learn its MAC address, then writes the RCP objects configured for that
+// address in REX exchanges, one object per request, reporting the
+// Response Code of each step.
+package coresim
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/compose"
+	"github.com/nleiva/gcp-rphy/transport"
+)
+
+// Error messages
+var (
+	errNoCode = errors.New("response without a Response Code")
+	errNoMAC  = errors.New("response without a DeviceMacAddress")
+)
+
+// defaultKey is the key of the configuration of RPDs not listed.
+const defaultKey = "default"
+
+// A Config is the configuration of RPDs, by MAC address.
+type Config struct {
+	rpds map[string][]byte
+}
+
+// ParseConfig parses the JSON or YAML document doc, a mapping of MAC
+// addresses of RPDs, or default for the others, to the RCP objects to
+// write to them, described as in compose.TLVs:
+//
+//	02:00:00:00:00:01:
+//	  RpdCapabilities:
+//	    RpdIdentification:
+//	      DeviceAlias: node-1
+//	default:
+//	  RpdCapabilities:
+//	    RpdIdentification:
+//	      DeviceAlias: spare
+//
+// Objects unknown to the decoder are written by type number, with their
+// values in hexadecimal.
+func ParseConfig(doc []byte) (*Config, error) {
+	keys, values, err := compose.Split(doc)
+	if err != nil {
+		return nil, err
+	}
+	c := &Config{rpds: make(map[string][]byte)}
+	for i, k := range keys {
+		if k != defaultKey {
+			mac, err := net.ParseMAC(k)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid MAC address", k)
+			}
+			k = mac.String()
+		}
+		b, err := compose.TLVs(values[i], gcp.TypeREX)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%v", k, err)
+		}
+		c.rpds[k] = b
+	}
+	return c, nil
+}
+
+// TLVs returns the RCP TLVs configured for the RPD with MAC address mac.
+func (c *Config) TLVs(mac net.HardwareAddr) []byte {
+	if c == nil {
+		return nil
+	}
+	if b, ok := c.rpds[mac.String()]; ok {
+		return b
+	}
+	return c.rpds[defaultKey]
+}
+
+// A Result is the outcome of a step of the bring-up of an RPD.
+type Result struct {
+	Peer string
+	MAC  net.HardwareAddr // Nil until the IRA step succeeds
+	Step string           // IRA, or the name of the object written
+	Code gcp.ResponseCode
+	Err  error // Why the step failed, if it did not get a Response Code
+}
+
+func (r Result) String() string {
+	id := r.Peer
+	if r.MAC != nil {
+		id += " " + r.MAC.String()
+	}
+	if r.Err != nil {
+		return fmt.Sprintf("%s %s: %v", id, r.Step, r.Err)
+	}
+	return fmt.Sprintf("%s %s: %s", id, r.Step, responseCode(r.Code))
+}
+
+// responseCode returns the name of the Response Code c.
+func responseCode(c gcp.ResponseCode) string {
+	if s := gcp.LookupTLV(gcp.TypeREX, 9, 19); s != nil {
+		if name, ok := s.Enum[uint32(c)]; ok {
+			return name
+		}
+	}
+	return fmt.Sprintf("ResponseCode %d", c)
+}
+
+// A Core is a simulated CCAP Core. Its Handler is set by ListenAndServe
+// and Serve.
+type Core struct {
+	transport.Core
+	Config *Config
+	// Print, if set, prints the messages sent to and received from peer.
+	// The encapsulation Transaction ID of messages sent is nil.
+	Print    func(sent bool, peer string, tid *uint16, m *gcp.Message)
+	OnResult func(Result) // Optional, called after each step of a bring-up
+
+	mu  sync.Mutex
+	seq uint16
+}
+
+// ListenAndServe listens on Addr and brings up the RPDs that connect.
+func (c *Core) ListenAndServe() error {
+	c.Handler = transport.HandlerFunc(c.serve)
+	return c.Core.ListenAndServe()
+}
+
+// Serve brings up the RPDs that connect to l.
+func (c *Core) Serve(l net.Listener) error {
+	c.Handler = transport.HandlerFunc(c.serve)
+	return c.Core.Serve(l)
+}
+
+// serve answers Notify Requests, and brings up the RPDs that notify
+// their start up.
+func (c *Core) serve(s *transport.Session, r *transport.Request) {
+	if c.Print != nil {
+		c.Print(false, s.RemoteAddr().String(), &r.TranID, r.Msg)
+	}
+	n, ok := r.Msg.Body.(*gcp.NotifyReq)
+	if !ok {
+		return
+	}
+	if n.Mode.ResponseRequired() {
+		m := gcp.NewMessage(gcp.MessageIDNotifyRes, &gcp.NotifyRes{
+			TransactionID: n.TransactionID,
+			Mode:          n.Mode,
+			EvntCode:      n.EvntCode,
+		})
+		if c.Print != nil {
+			c.Print(true, s.RemoteAddr().String(), &r.TranID, m)
+		}
+		s.Reply(r, m)
+	}
+	if notificationType(n.EvntData) == gcp.StartUpNotification {
+		// Handlers must not wait for responses on their own session.
+		go c.bringUp(s)
+	}
+}
+
+// bringUp identifies the RPD of s and writes its configuration.
+func (c *Core) bringUp(s *transport.Session) {
+	res := Result{Peer: s.RemoteAddr().String(), Step: "IRA"}
+	ds, err := c.exchange(s, gcp.TypeIRA, gcp.OpRead, gcp.EncodeTLV(50, gcp.EncodeTLV(19, gcp.EncodeTLV(4))))
+	if err == nil {
+		res.Code, err = code(ds)
+	}
+	if err == nil && res.Code == gcp.RespNoError {
+		mac, _ := gcp.FindTLV(ds, gcp.TypeIRA, 9, 50, 19, 4)
+		if len(mac) != 6 {
+			err = errNoMAC
+		}
+		res.MAC = net.HardwareAddr(mac)
+	}
+	res.Err = err
+	c.result(res)
+	if res.Err != nil || res.Code != gcp.RespNoError {
+		return
+	}
+
+	gcp.EachTLV(c.Config.TLVs(res.MAC), func(t uint8, v []byte) error {
+		res.Step, res.Code, res.Err = objectName(t), 0, nil
+		ds, err := c.exchange(s, gcp.TypeREX, gcp.OpWrite, gcp.EncodeTLV(t, v))
+		if err == nil {
+			res.Code, err = code(ds)
+		}
+		res.Err = err
+		c.result(res)
+		return err
+	})
+}
+
+func (c *Core) result(r Result) {
+	if c.OnResult != nil {
+		c.OnResult(r)
+	}
+}
+
+// exchange sends an EDS Request with the RCP operation op on tlvs, and
+// returns the Data Structures of the response.
+func (c *Core) exchange(s *transport.Session, top uint8, op gcp.Operation, tlvs []byte) ([]byte, error) {
+	c.mu.Lock()
+	c.seq++
+	seq := c.seq
+	c.mu.Unlock()
+	m := gcp.NewMessage(gcp.MessageIDEDSReq, &gcp.EDSReq{
+		TransactionID: seq,
+		VendorID:      gcp.CableLabs,
+		DataStr:       gcp.EncodeSequence(top, seq, op, tlvs),
+	})
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	peer := s.RemoteAddr().String()
+	if c.Print != nil {
+		c.Print(true, peer, nil, m)
+	}
+	r, err := s.Request(m, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if c.Print != nil {
+		c.Print(false, peer, &r.TranID, r.Msg)
+	}
+	res, ok := r.Msg.Body.(*gcp.EDSRes)
+	if !ok {
+		return nil, fmt.Errorf("unexpected %s", gcp.MessageID(r.Msg.MessageID))
+	}
+	return res.DataStr, nil
+}
+
+// code returns the Response Code of the Data Structures ds.
+func code(ds []byte) (gcp.ResponseCode, error) {
+	if err := gcp.EachTLV(ds, func(uint8, []byte) error { return nil }); err != nil {
+		return 0, err
+	}
+	if len(ds) == 0 {
+		return 0, errNoCode
+	}
+	v, err := gcp.FindTLV(ds, ds[0], 9, 19)
+	if err != nil {
+		return 0, err
+	}
+	if len(v) != 1 {
+		return 0, errNoCode
+	}
+	return gcp.ResponseCode(v[0]), nil
+}
+
+// notificationType returns the type of the GeneralNotification in the
+// Event Data b, or zero.
+func notificationType(b []byte) gcp.NotificationType {
+	if v, _ := gcp.FindTLV(b, gcp.TypeNTF, 9, 86, 1); len(v) == 1 {
+		return gcp.NotificationType(v[0])
+	}
+	return 0
+}
+
+// objectName returns the name of the RCP object of type t.
+func objectName(t uint8) string {
+	if s := gcp.LookupTLV(gcp.TypeREX, 9, t); s != nil {
+		return s.Name
+	}
+	return fmt.Sprintf("TLV %d", t)
+}
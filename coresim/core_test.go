@@ -0,0 +1,180 @@
+package coresim_test
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/coresim"
+	"github.com/nleiva/gcp-rphy/rpdsim"
+	"github.com/nleiva/gcp-rphy/transport"
+)
+
+const config = `
+02:00:00:00:00:0A:
+  RpdCapabilities:
+    RpdIdentification:
+      DeviceAlias: node-1
+  CcapCoreIdentification:
+    CoreName: ccap
+  RpdInfo:
+    IfEnet:
+      Name: eth0
+default:
+  RpdCapabilities:
+    RpdIdentification:
+      DeviceAlias: spare
+`
+
+func TestParseConfig(t *testing.T) {
+	c, err := coresim.ParseConfig([]byte(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	alias := func(s string) []byte {
+		return gcp.EncodeTLV(50, gcp.EncodeTLV(19, gcp.EncodeTLV(8, []byte(s))))
+	}
+	want := bytes.Join([][]byte{
+		alias("node-1"),
+		gcp.EncodeTLV(60, gcp.EncodeTLV(5, []byte("ccap"))),
+		gcp.EncodeTLV(100, gcp.EncodeTLV(8, gcp.EncodeTLV(2, []byte("eth0")))),
+	}, nil)
+	if got := c.TLVs(net.HardwareAddr{0x02, 0, 0, 0, 0, 0x0a}); !bytes.Equal(got, want) {
+		t.Errorf("TLVs of 02:00:00:00:00:0a got: %x, want: %x", got, want)
+	}
+	if got, want := c.TLVs(net.HardwareAddr{0x02, 0, 0, 0, 0, 0x0b}), alias("spare"); !bytes.Equal(got, want) {
+		t.Errorf("TLVs of 02:00:00:00:00:0b got: %x, want: %x", got, want)
+	}
+
+	for _, doc := range []string{
+		"rpd-1:\n  RpdInfo: {}\n",
+		"default:\n  NoSuchObject: 1\n",
+		"- default\n",
+	} {
+		if _, err := coresim.ParseConfig([]byte(doc)); err == nil {
+			t.Errorf("ParseConfig(%q) got no error", doc)
+		}
+	}
+}
+
+func TestBringUp(t *testing.T) {
+	cfg, err := coresim.ParseConfig([]byte(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		mu      sync.Mutex
+		results []string
+		done    = make(chan struct{})
+	)
+	c := &coresim.Core{
+		Config: cfg,
+		OnResult: func(r coresim.Result) {
+			mu.Lock()
+			defer mu.Unlock()
+			results = append(results, fmt.Sprintf("%v %s: %d %v", r.MAC, r.Step, r.Code, r.Err))
+			if len(results) == 4 {
+				close(done)
+			}
+		},
+	}
+	go c.Serve(l)
+	defer c.Close()
+
+	st := &rpdsim.Store{}
+	if err := st.Load([]byte("RpdCapabilities:\n  RpdIdentification:\n    DeviceMacAddress: 02:00:00:00:00:0a\n")); err != nil {
+		t.Fatal(err)
+	}
+	r := &rpdsim.RPD{Core: l.Addr().String(), Store: st}
+	go r.Run()
+	defer r.Close()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		mu.Lock()
+		defer mu.Unlock()
+		t.Fatalf("bring-up did not finish, results: %q", results)
+	}
+	want := []string{
+		"02:00:00:00:00:0a IRA: 0 <nil>",
+		"02:00:00:00:00:0a RpdCapabilities: 0 <nil>",
+		"02:00:00:00:00:0a CcapCoreIdentification: 0 <nil>",
+		// The RPD rejects IfEnet entries without their EnetPortIndex.
+		"02:00:00:00:00:0a RpdInfo: 1 <nil>",
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for i := range want {
+		if results[i] != want[i] {
+			t.Errorf("result %d got: %q, want: %q", i, results[i], want[i])
+		}
+	}
+}
+
+func TestNotifyResponse(t *testing.T) {
+	cfg, err := coresim.ParseConfig([]byte(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &coresim.Core{Config: cfg}
+	go c.Serve(l)
+	defer c.Close()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	req, _ := gcp.NewMessage(gcp.MessageIDNotifyReq, &gcp.NotifyReq{
+		TransactionID: 3,
+		EvntData:      gcp.GeneralNotification(1, gcp.StartUpNotification),
+	}).Marshal()
+	u, _ := transport.TCPmessage{TranID: 9, ProtID: 1, Len: uint16(1 + len(req)), Msg: req}.Marshal()
+	if _, err := conn.Write(u); err != nil {
+		t.Fatal(err)
+	}
+	// The Notify Response comes before the bring-up starts.
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	res, err := transport.ReadMessage(conn)
+	if err != nil {
+		t.Fatalf("no response: %v", err)
+	}
+	if res.TranID != 9 {
+		t.Errorf("encapsulation Transaction ID got: %d, want: 9", res.TranID)
+	}
+	m, err := gcp.ParseMessage(res.Msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := gcp.MessageID(m.MessageID); got != gcp.MessageIDNotifyRes {
+		t.Errorf("response got: %v, want: %v", got, gcp.MessageIDNotifyRes)
+	}
+	if got := m.TransactionID(); got != 3 {
+		t.Errorf("Transaction ID got: %d, want: 3", got)
+	}
+}
+
+func TestResultString(t *testing.T) {
+	r := coresim.Result{
+		Peer: "192.0.2.2:50000",
+		MAC:  net.HardwareAddr{0x02, 0, 0, 0, 0, 0x0a},
+		Step: "RfPort",
+		Code: gcp.RespGeneralError,
+	}
+	if got, want := r.String(), "192.0.2.2:50000 02:00:00:00:00:0a RfPort: GeneralError"; got != want {
+		t.Errorf("String got: %q, want: %q", got, want)
+	}
+}
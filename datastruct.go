@@ -1,5 +1,12 @@
 package gcp
 
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
 // A GCP represents a GCP data structure.
 // TODO: These are all just Requests for now, need to add Responses (Normal and Error)
 type GCP struct {
@@ -29,6 +36,10 @@ type Sequence struct {
 	RpdRedirect     *RpdR  `json:"RPD Redirect,omitempty"`
 	GeneralNtf      *GNtf  `json:"General Notification,omitempty"`
 	RpdInfo         *RpdI  `json:"RPD Info,omitempty"`
+	// Written by the CCAP Core to configure the RPD.
+	RfChannel              []RfCh   `json:"RfChannel,omitempty"`
+	RfPort                 []RfPt   `json:"RfPort,omitempty"`
+	CcapCoreIdentification []CcapID `json:"CcapCoreIdentification,omitempty"`
 }
 
 // A RpdC represents a RpdCapabilities data structure.
@@ -53,6 +64,8 @@ type RpdC struct {
 
 	// This TLV allows the RPD to inform the CCAP Core about it its location.
 	DeviceLocation DeLoc `json:"Device Location,omitempty"`
+
+	kept []string
 }
 
 // A RpdIden represents a RpdCapabilities data structure.
@@ -132,6 +145,10 @@ type RpdIden struct {
 	// This attribute reports which software image is currently running on the RPD.
 	// An RPD which supports only one SW image always reports 0.
 	CurrrentSwImageIndex string `json:"CurrrentSwImageIndex,omitempty"`
+
+	// kept holds the JSON names of the fields decoded from TLVs with
+	// empty or false values, which are rendered despite omitempty.
+	kept []string
 }
 
 // A DeLoc represents a Device Location data structure.
@@ -145,6 +162,76 @@ type DeLoc struct {
 	// This object allows the RPD to inform the CCAP Core about the longitude
 	// portion of its geographic location.
 	Longitude string `json:"Geographic Location Longitude,omitempty"`
+
+	// kept holds the JSON names of the fields decoded from TLVs with
+	// empty or false values, which are rendered despite omitempty.
+	kept []string
+}
+
+// A CcapID represents a CcapCoreIdentification data structure.
+type CcapID struct {
+	// This key attribute reports the index of the CCAP Core entry.
+	Index string `json:"Index,omitempty"`
+	// This attribute reports a unique identifier of the CCAP Core, like
+	// its MAC address.
+	CoreID string `json:"CoreId,omitempty"`
+	// This attribute reports the IP address of the CCAP Core.
+	CoreIPAddress string `json:"CoreIpAddress,omitempty"`
+	// This attribute reports whether the CCAP Core is the Principal Core.
+	IsPrincipal string `json:"IsPrincipal,omitempty"`
+	// This attribute reports a name assigned to the CCAP Core.
+	CoreName string `json:"CoreName,omitempty"`
+	// This attribute reports the IANA-assigned enterprise number of the
+	// CCAP Core's manufacturer.
+	VendorID string `json:"VendorId,omitempty"`
+	// This attribute reports the mode of the CCAP Core, like Active or Backup.
+	CoreMode string `json:"CoreMode,omitempty"`
+	// This attribute reports whether the CCAP Core has completed the initial
+	// configuration of the RPD.
+	InitialConfigurationComplete string `json:"InitialConfigurationComplete,omitempty"`
+	// This attribute instructs the RPD to move to the operational state.
+	MoveToOperational string `json:"MoveToOperational,omitempty"`
+	// This attribute reports a bit mask of the functions the CCAP Core
+	// provides to the RPD.
+	CoreFunction string `json:"CoreFunction,omitempty"`
+	// This attribute reports the index of the set of RPD resources the
+	// CCAP Core manages.
+	ResourceSetIndex string `json:"ResourceSetIndex,omitempty"`
+
+	// kept holds the JSON names of the fields decoded from TLVs with
+	// empty or false values, which are rendered despite omitempty.
+	kept []string
+}
+
+// A RfCh represents a RfChannel data structure.
+type RfCh struct {
+	// This object identifies the RF channel the RfChannel TLV refers to.
+	RfChannelSelector RfChSel `json:"RfChannelSelector"`
+}
+
+// A RfChSel represents a RfChannelSelector data structure.
+type RfChSel struct {
+	// This attribute reports the index of the RF port of the channel.
+	RfPortIndex string `json:"RfPortIndex,omitempty"`
+	// This attribute reports the type of the channel, like DsScQam.
+	RfChannelType string `json:"RfChannelType,omitempty"`
+	// This attribute reports the index of the channel among those of its
+	// type on its RF port.
+	RfChannelIndex string `json:"RfChannelIndex,omitempty"`
+}
+
+// A RfPt represents a RfPort data structure.
+type RfPt struct {
+	// This object identifies the RF port the RfPort TLV refers to.
+	RfPortSelector RfPtSel `json:"RfPortSelector"`
+}
+
+// A RfPtSel represents a RfPortSelector data structure.
+type RfPtSel struct {
+	// This attribute reports the index of the RF port among those of its type.
+	RfPortIndex string `json:"RfPortIndex,omitempty"`
+	// This attribute reports the type of the RF port, like DsRfPort.
+	RfPortType string `json:"RfPortType,omitempty"`
 }
 
 // A RpdR represents a RpdRedirect data structure.
@@ -227,6 +314,10 @@ type IfEn struct {
 	ConnectorPresent bool `json:"Connector Present,omitempty"`
 	// This attribute reports the network authentication status of this interface.
 	NetworkAuthStatus string `json:"Network Auth Status,omitempty"`
+
+	// kept holds the JSON names of the fields decoded from TLVs with
+	// empty or false values, which are rendered despite omitempty.
+	kept []string
 }
 
 // A IPAdd represents an IPAddress data structure.
@@ -258,3 +349,74 @@ type IPAdd struct {
 	// of the local network management subsystem, then this attribute contains a zero value.
 	LastChanged string `json:"Last Changed,omitempty"`
 }
+
+// MarshalJSON encodes an RpdC, leaving out the Complex TLVs it did not
+// carry.
+func (c RpdC) MarshalJSON() ([]byte, error) { return marshalKept(c, c.kept) }
+
+// MarshalJSON encodes an RpdIden, rendering the TLVs it carried empty.
+func (r RpdIden) MarshalJSON() ([]byte, error) { return marshalKept(r, r.kept) }
+
+// MarshalJSON encodes a DeLoc, rendering the TLVs it carried empty.
+func (d DeLoc) MarshalJSON() ([]byte, error) { return marshalKept(d, d.kept) }
+
+// MarshalJSON encodes a CcapID, rendering the TLVs it carried empty.
+func (c CcapID) MarshalJSON() ([]byte, error) { return marshalKept(c, c.kept) }
+
+// MarshalJSON encodes an IfEn, rendering the TLVs it carried empty or
+// false.
+func (i IfEn) MarshalJSON() ([]byte, error) { return marshalKept(i, i.kept) }
+
+// keep records that the field named name in JSON was decoded from a TLV
+// if its value is zero, so it is rendered rather than omitted.
+func keep(kept *[]string, name string, zero bool) {
+	if zero {
+		*kept = append(*kept, name)
+	}
+}
+
+// marshalKept encodes the struct v as encoding/json does, except that
+// the fields named in kept are rendered even if omitempty and zero, so
+// the JSON output of Process shows every TLV decoded.
+func marshalKept(v interface{}, kept []string) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i := 0; i < rv.NumField(); i++ {
+		f := rv.Type().Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		tag := strings.Split(f.Tag.Get("json"), ",")
+		name := tag[0]
+		if name == "" {
+			name = f.Name
+		}
+		if len(tag) > 1 && tag[1] == "omitempty" && rv.Field(i).IsZero() && !contains(kept, name) {
+			continue
+		}
+		k, _ := json.Marshal(name)
+		val, err := json.Marshal(rv.Field(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(val)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// contains reports whether ss holds s.
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -10,7 +10,7 @@ import (
 // An DMReq represents a GCP Device Management (GDM) Request message body.
 type DMReq struct {
 	TransactionID uint16 // Transaction ID: 2 bytes
-	Mode          uint8  // Mode: 1 byte
+	Mode          Mode   // Mode: 1 byte
 	Port          uint16 // Port: 2 bytes
 	Channel       uint16 // Channel: 2 bytes
 	Command       uint8  // Command: 1 byte
@@ -65,7 +65,7 @@ func parseDMReq(_ MessageID, b []byte) (MessageBody, error) {
 	}
 	p := &DMReq{
 		TransactionID: binary.BigEndian.Uint16(b[:2]),
-		Mode:          uint8(b[2]),
+		Mode:          Mode(b[2]),
 		Port:          binary.BigEndian.Uint16(b[3:5]),
 		Channel:       binary.BigEndian.Uint16(b[5:7]),
 		Command:       uint8(b[7]),
@@ -0,0 +1,257 @@
+package gcp
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// A ChangeType tells how an RCP TLV differs between two trees.
+type ChangeType int
+
+// Change types.
+const (
+	Added   ChangeType = iota + 1 // The TLV is only in the second tree
+	Removed                       // The TLV is only in the first tree
+	Changed                       // The value of the TLV differs
+)
+
+var changeNames = map[ChangeType]string{
+	Added:   "Added",
+	Removed: "Removed",
+	Changed: "Changed",
+}
+
+func (c ChangeType) String() string {
+	if s, ok := changeNames[c]; ok {
+		return s
+	}
+	return fmt.Sprintf("ChangeType(%d)", int(c))
+}
+
+// A Change is a difference between two RCP trees.
+type Change struct {
+	Type ChangeType
+	// Path is the query of the TLV in either tree, relative to the
+	// Sequences, like "RpdInfo/IfEnet[EnetPortIndex=1]/OperStatus".
+	Path string
+	Old  *Node // TLV in the first tree, nil if Added
+	New  *Node // TLV in the second tree, nil if Removed
+}
+
+// String renders c like "~ path: old -> new", "+ path: new" or
+// "- path: old". Complex TLVs added or removed have no value.
+func (c Change) String() string {
+	switch {
+	case c.Type == Changed:
+		return fmt.Sprintf("~ %s: %s -> %s", c.Path, c.Old, c.New)
+	case c.Type == Added && c.New.Kind == KindComplex:
+		return "+ " + c.Path
+	case c.Type == Added:
+		return fmt.Sprintf("+ %s: %s", c.Path, c.New)
+	case c.Old.Kind == KindComplex:
+		return "- " + c.Path
+	}
+	return fmt.Sprintf("- %s: %s", c.Path, c.Old)
+}
+
+// Diff returns the differences between the RCP objects of a and b: the
+// TLVs nested in their Sequences, other than SequenceNumber, Operation
+// and ResponseCode. A Complex TLV only in one of the trees is reported
+// as a whole.
+//
+// Repeated TLVs, like the entries of a table, are paired by the first
+// nested TLV whose value tells them apart in both trees, like the
+// EnetPortIndex of IfEnet entries, and by position otherwise.
+func Diff(a, b *Tree) []Change {
+	var cs []Change
+	diffNodes("", objects(a), objects(b), &cs)
+	return cs
+}
+
+// objects returns the RCP objects in the Sequences of t.
+func objects(t *Tree) []*Node {
+	var ns []*Node
+	for _, top := range t.TLVs {
+		for _, s := range top.Sub {
+			if s.Type != 9 {
+				continue
+			}
+			for _, n := range s.Sub {
+				switch n.Type {
+				case 10, 11, 19:
+				default:
+					ns = append(ns, n)
+				}
+			}
+		}
+	}
+	return ns
+}
+
+// diffNodes adds to cs the differences between the TLVs a and b, nested
+// in the TLV at path.
+func diffNodes(path string, a, b []*Node, cs *[]Change) {
+	var types []uint8
+	seen := make(map[uint8]bool)
+	for _, ns := range [][]*Node{a, b} {
+		for _, n := range ns {
+			if !seen[n.Type] {
+				seen[n.Type] = true
+				types = append(types, n.Type)
+			}
+		}
+	}
+	for _, t := range types {
+		for _, p := range pair(ofType(a, t), ofType(b, t)) {
+			diffNode(join(path, p.step), p.old, p.new, cs)
+		}
+	}
+}
+
+// diffNode adds to cs the differences between the TLVs x and y at path,
+// either of which may be nil.
+func diffNode(path string, x, y *Node, cs *[]Change) {
+	switch {
+	case x == nil:
+		*cs = append(*cs, Change{Type: Added, Path: path, New: y})
+	case y == nil:
+		*cs = append(*cs, Change{Type: Removed, Path: path, Old: x})
+	case x.Kind == KindComplex && y.Kind == KindComplex:
+		diffNodes(path, x.Sub, y.Sub, cs)
+	case !bytes.Equal(x.Raw, y.Raw):
+		*cs = append(*cs, Change{Type: Changed, Path: path, Old: x, New: y})
+	}
+}
+
+// A nodePair is a TLV of each tree at the same query step. Either may
+// be nil.
+type nodePair struct {
+	step     string
+	old, new *Node
+}
+
+// pair pairs the TLVs of the same type a and b.
+func pair(a, b []*Node) []nodePair {
+	var n *Node
+	if len(a) > 0 {
+		n = a[0]
+	} else {
+		n = b[0]
+	}
+	name := stepName(n)
+	if len(a) <= 1 && len(b) <= 1 {
+		p := nodePair{step: name}
+		if len(a) == 1 {
+			p.old = a[0]
+		}
+		if len(b) == 1 {
+			p.new = b[0]
+		}
+		return []nodePair{p}
+	}
+	if k, ok := key(a, b); ok {
+		return pairBy(name, k, a, b)
+	}
+	var ps []nodePair
+	for i := 0; i < len(a) || i < len(b); i++ {
+		p := nodePair{step: fmt.Sprintf("%s[%d]", name, i)}
+		if i < len(a) {
+			p.old = a[i]
+		}
+		if i < len(b) {
+			p.new = b[i]
+		}
+		ps = append(ps, p)
+	}
+	return ps
+}
+
+// key returns the type of the first nested TLV that every TLV of a and
+// b has once, with values unique in a and in b.
+func key(a, b []*Node) (uint8, bool) {
+	var first *Node
+	if len(a) > 0 {
+		first = a[0]
+	} else {
+		first = b[0]
+	}
+	if first.Kind != KindComplex {
+		return 0, false
+	}
+	for _, c := range first.Sub {
+		if unique(a, c.Type) && unique(b, c.Type) {
+			return c.Type, true
+		}
+	}
+	return 0, false
+}
+
+// unique reports whether each TLV of ns has a single nested TLV of type
+// t, whose value no other TLV of ns shares.
+func unique(ns []*Node, t uint8) bool {
+	vals := make(map[string]bool)
+	for _, n := range ns {
+		cs := ofType(n.Sub, t)
+		if len(cs) != 1 || vals[string(cs[0].Raw)] {
+			return false
+		}
+		vals[string(cs[0].Raw)] = true
+	}
+	return true
+}
+
+// pairBy pairs the TLVs of a and b named name by the value of their
+// nested TLV of type k.
+func pairBy(name string, k uint8, a, b []*Node) []nodePair {
+	var ps []nodePair
+	used := make(map[*Node]bool)
+	step := func(n *Node) string {
+		c := ofType(n.Sub, k)[0]
+		return fmt.Sprintf("%s[%s=%s]", name, stepName(c), c)
+	}
+	for _, x := range a {
+		p := nodePair{step: step(x), old: x}
+		kx := ofType(x.Sub, k)[0].Raw
+		for _, y := range b {
+			if bytes.Equal(kx, ofType(y.Sub, k)[0].Raw) {
+				p.new, used[y] = y, true
+				break
+			}
+		}
+		ps = append(ps, p)
+	}
+	for _, y := range b {
+		if !used[y] {
+			ps = append(ps, nodePair{step: step(y), new: y})
+		}
+	}
+	return ps
+}
+
+// ofType returns the TLVs of ns of type t.
+func ofType(ns []*Node, t uint8) []*Node {
+	var m []*Node
+	for _, n := range ns {
+		if n.Type == t {
+			m = append(m, n)
+		}
+	}
+	return m
+}
+
+// stepName returns the name of n in a query, or its type if it is
+// unknown to the decoder.
+func stepName(n *Node) string {
+	if n.Name == "" {
+		return strconv.Itoa(int(n.Type))
+	}
+	return n.Name
+}
+
+func join(path, step string) string {
+	if path == "" {
+		return step
+	}
+	return path + "/" + step
+}
@@ -0,0 +1,109 @@
+package gcp_test
+
+import (
+	"reflect"
+	"testing"
+
+	gcp "github.com/nleiva/gcp-rphy"
+)
+
+func TestDiff(t *testing.T) {
+	ifEnet := func(port, oper byte, name string) []byte {
+		return gcp.EncodeTLV(8,
+			gcp.EncodeTLV(1, []byte{port}),
+			gcp.EncodeTLV(2, []byte(name)),
+			gcp.EncodeTLV(9, []byte{oper}),
+		)
+	}
+	ipAddr := func(addr byte, port byte) []byte {
+		return gcp.EncodeTLV(15,
+			gcp.EncodeTLV(1, []byte{0, 0, 0, 1}),
+			gcp.EncodeTLV(2, []byte{10, 0, 0, addr}),
+			gcp.EncodeTLV(3, []byte{port}),
+		)
+	}
+	// The configuration written.
+	a, err := gcp.DecodeTree(gcp.EncodeSequence(gcp.TypeREX, 1, gcp.OpWrite,
+		gcp.EncodeTLV(50, gcp.EncodeTLV(19, gcp.EncodeTLV(8, []byte("node-1")))),
+		gcp.EncodeTLV(100,
+			ifEnet(1, 1, "vbh0"),
+			ifEnet(2, 1, "vbh1"),
+			ipAddr(1, 1),
+			ipAddr(2, 2),
+			gcp.EncodeTLV(200, []byte{1}),
+		),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The state read back, with entries in another order.
+	b, err := gcp.DecodeTree(gcp.EncodeSequence(gcp.TypeREX, 7, gcp.OpReadResponse,
+		gcp.EncodeTLV(19, []byte{0}),
+		gcp.EncodeTLV(50, gcp.EncodeTLV(19, gcp.EncodeTLV(8, []byte("node-2")))),
+		gcp.EncodeTLV(100,
+			ifEnet(2, 2, "vbh1"),
+			ifEnet(1, 1, "vbh0"),
+			ifEnet(3, 1, "vbh2"),
+			ipAddr(2, 3),
+			gcp.EncodeTLV(200, []byte{1}),
+		),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"~ RpdCapabilities/RpdIdentification/DeviceAlias: node-1 -> node-2",
+		"~ RpdInfo/IfEnet[EnetPortIndex=2]/OperStatus: up -> down",
+		"+ RpdInfo/IfEnet[EnetPortIndex=3]",
+		"- RpdInfo/IpAddress[IpAddress=10.0.0.1]",
+		"~ RpdInfo/IpAddress[IpAddress=10.0.0.2]/EnetPortIndex: 2 -> 3",
+	}
+	cs := gcp.Diff(a, b)
+	var got []string
+	for _, c := range cs {
+		got = append(got, c.String())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Diff got:\n%q\nwant:\n%q", got, want)
+	}
+
+	// The paths of the changes are queries of the TLVs.
+	for _, c := range cs {
+		for _, side := range []struct {
+			tree *gcp.Tree
+			node *gcp.Node
+		}{{a, c.Old}, {b, c.New}} {
+			if side.node == nil {
+				continue
+			}
+			n, err := side.tree.Get(c.Path)
+			if err != nil || n != side.node {
+				t.Errorf("%s: Get got: %v (%v), want: %s", c.Path, n, err, side.node.Path)
+			}
+		}
+	}
+
+	if cs := gcp.Diff(b, b); len(cs) != 0 {
+		t.Errorf("Diff of a tree with itself got: %v", cs)
+	}
+}
+
+func TestDiffPositional(t *testing.T) {
+	// Entries without a nested TLV that tells them apart are paired by
+	// position.
+	entry := func(v byte) []byte { return gcp.EncodeTLV(15, gcp.EncodeTLV(1, []byte{0, 0, 0, v})) }
+	a, _ := gcp.DecodeTree(gcp.EncodeSequence(gcp.TypeREX, 1, gcp.OpRead, gcp.EncodeTLV(100, entry(1), entry(1))))
+	b, _ := gcp.DecodeTree(gcp.EncodeSequence(gcp.TypeREX, 1, gcp.OpRead, gcp.EncodeTLV(100, entry(1), entry(2), entry(2))))
+	want := []string{
+		"~ RpdInfo/IpAddress[1]/AddrType: ipv4 -> ipv6",
+		"+ RpdInfo/IpAddress[2]",
+	}
+	var got []string
+	for _, c := range gcp.Diff(a, b) {
+		got = append(got, c.String())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff got: %q, want: %q", got, want)
+	}
+}
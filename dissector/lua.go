@@ -0,0 +1,417 @@
+// Package dissector generates a Wireshark dissector for GCP from the
+// message and RCP TLV definitions of the gcp package.
+//
+// The dissector is a Lua plugin. It decodes the TCP encapsulation, the GCP
+// header and message body fields, and the RCP TLVs carried in them, with
+// their names and the names of enumerated values, flagging malformed
+// TLVs. Install it by copying it to the Wireshark personal plugins folder.
+package dissector
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	gcp "github.com/nleiva/gcp-rphy"
+)
+
+// DefaultPort is the TCP port the dissector registers for when none is
+// given.
+const DefaultPort = 8190
+
+// luaKinds map the encoding of RCP TLV values to Wireshark field types.
+var luaKinds = map[gcp.ValueKind]string{
+	gcp.KindBytes:       "bytes",
+	gcp.KindComplex:     "none",
+	gcp.KindString:      "string",
+	gcp.KindUint8:       "uint8",
+	gcp.KindUint16:      "uint16",
+	gcp.KindUint32:      "uint32",
+	gcp.KindTimeTicks:   "uint32",
+	gcp.KindMAC:         "ether",
+	gcp.KindIP:          "ipv4",
+	gcp.KindDateAndTime: "bytes",
+}
+
+// WriteLua writes to w a Lua dissector for GCP sessions on TCP port,
+// DefaultPort if zero.
+func WriteLua(w io.Writer, port int) error {
+	if port == 0 {
+		port = DefaultPort
+	}
+	g := &generator{
+		w:     bufio.NewWriter(w),
+		ids:   make(map[*gcp.TLVSchema]int),
+		names: make(map[*gcp.TLVSchema]string),
+		owner: make(map[*gcp.TLVSchema]*gcp.TLVSchema),
+	}
+	g.collect(gcp.Schema(), nil)
+	g.abbrevs()
+
+	g.printf("%s", header)
+	g.messages()
+	g.tlvs()
+	g.printf(body, port)
+	return g.w.Flush()
+}
+
+// A generator writes the Lua dissector.
+type generator struct {
+	w     *bufio.Writer
+	nodes []*gcp.TLVSchema                  // TLVs, in order of discovery
+	ids   map[*gcp.TLVSchema]int            // Index of TLVs in nodes
+	names map[*gcp.TLVSchema]string         // Display filter name of TLVs
+	owner map[*gcp.TLVSchema]*gcp.TLVSchema // TLV each TLV was first found in
+}
+
+func (g *generator) printf(format string, a ...interface{}) {
+	fmt.Fprintf(g.w, format, a...)
+}
+
+// collect numbers the TLVs in ss, nested in parent, and in their children.
+func (g *generator) collect(ss []*gcp.TLVSchema, parent *gcp.TLVSchema) {
+	for _, s := range ss {
+		if _, ok := g.ids[s]; ok {
+			continue
+		}
+		g.ids[s] = len(g.nodes)
+		g.nodes = append(g.nodes, s)
+		g.owner[s] = parent
+	}
+	for _, s := range ss {
+		if g.owner[s] == parent {
+			g.collect(s.Sub, s)
+		}
+	}
+}
+
+// abbrevs names the TLVs for display filters after themselves, or after
+// the TLV they were found in when several TLVs have the same name.
+func (g *generator) abbrevs() {
+	// Names of the fields common to all TLVs.
+	count := map[string]int{"tlv": 1, "type": 1, "length": 1}
+	for _, s := range g.nodes {
+		count[ident(s.Name)]++
+	}
+	used := make(map[string]bool)
+	for _, s := range g.nodes {
+		n := ident(s.Name)
+		if p := g.owner[s]; count[n] > 1 && p != nil {
+			n = ident(p.Name) + "." + n
+		}
+		if used[n] {
+			n = fmt.Sprintf("%s_%d", n, s.Type)
+		}
+		used[n] = true
+		g.names[s] = "gcp.rcp." + n
+	}
+}
+
+// messages writes the fields of GCP message bodies.
+func (g *generator) messages() {
+	g.printf("local msg_names = {\n")
+	for _, id := range gcp.MessageIDs() {
+		g.printf("  [%d] = %s,\n", id, quote(id.String()))
+	}
+	g.printf("}\n")
+	g.printf("f.msg_id = ProtoField.uint8(\"gcp.message.id\", \"Message Identifier\", base.DEC, msg_names)\n\n")
+
+	// Fields of the same name share a field definition.
+	defined := make(map[string]bool)
+	type layout struct {
+		id     gcp.MessageID
+		fields []gcp.BodyField
+	}
+	var layouts []layout
+	for _, id := range gcp.MessageIDs() {
+		fs := gcp.BodyLayout(id)
+		if fs == nil {
+			continue
+		}
+		layouts = append(layouts, layout{id, fs})
+		for _, f := range fs {
+			n := ident(f.Name)
+			if defined[n] {
+				continue
+			}
+			defined[n] = true
+			typ := "bytes"
+			switch f.Size {
+			case 1, 2, 4:
+				typ = fmt.Sprintf("uint%d", 8*f.Size)
+			}
+			base := ""
+			if typ != "bytes" {
+				base = ", base.DEC"
+			}
+			g.printf("f.body_%s = ProtoField.%s(%s, %s%s)\n", n, typ, quote("gcp.body."+n), quote(f.Name), base)
+		}
+	}
+	g.printf("\nlocal bodies = {\n")
+	for _, l := range layouts {
+		g.printf("  [%d] = {\n", l.id)
+		for _, f := range l.fields {
+			g.printf("    { field = f.body_%s, size = %d, rcp = %t },\n", ident(f.Name), f.Size, f.RCP)
+		}
+		g.printf("  },\n")
+	}
+	g.printf("}\n\n")
+}
+
+// tlvs writes the fields of RCP TLVs and the tree they form.
+func (g *generator) tlvs() {
+	for i, s := range g.nodes {
+		abbr := g.names[s]
+		typ := luaKinds[s.Kind]
+		switch {
+		case len(s.Enum) > 0:
+			g.printf("f.tlv%d = ProtoField.%s(%s, %s, base.DEC, %s)\n", i, typ, quote(abbr), quote(s.Name), enum(s.Enum))
+		case strings.HasPrefix(typ, "uint"):
+			g.printf("f.tlv%d = ProtoField.%s(%s, %s, base.DEC)\n", i, typ, quote(abbr), quote(s.Name))
+		default:
+			g.printf("f.tlv%d = ProtoField.%s(%s, %s)\n", i, typ, quote(abbr), quote(s.Name))
+		}
+		if s.Kind == gcp.KindIP {
+			g.printf("f.tlv%d_v6 = ProtoField.ipv6(%s, %s)\n", i, quote(abbr+"_v6"), quote(s.Name))
+		}
+	}
+
+	g.printf("\nlocal nodes = {\n")
+	for i, s := range g.nodes {
+		g.printf("  [%d] = { name = %s, kind = %s, field = f.tlv%d", i, quote(s.Name), quote(s.Kind.String()), i)
+		if s.Kind == gcp.KindIP {
+			g.printf(", field_v6 = f.tlv%d_v6", i)
+		}
+		if n := s.Kind.Size(); n > 0 {
+			g.printf(", size = %d", n)
+		}
+		g.printf(" },\n")
+	}
+	g.printf("}\n")
+	for i, s := range g.nodes {
+		if s.Kind != gcp.KindComplex {
+			continue
+		}
+		g.printf("nodes[%d].sub = {%s}\n", i, g.children(s.Sub))
+	}
+	g.printf("local top = {%s}\n", g.children(gcp.Schema()))
+}
+
+// children returns a Lua table of the nodes of ss, by TLV type.
+func (g *generator) children(ss []*gcp.TLVSchema) string {
+	var b strings.Builder
+	for i, s := range ss {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, " [%d] = nodes[%d]", s.Type, g.ids[s])
+	}
+	b.WriteString(" ")
+	return b.String()
+}
+
+// enum returns a Lua table of the value names in e.
+func enum(e map[uint32]string) string {
+	vs := make([]uint32, 0, len(e))
+	for v := range e {
+		vs = append(vs, v)
+	}
+	sort.Slice(vs, func(i, j int) bool { return vs[i] < vs[j] })
+	var b strings.Builder
+	b.WriteString("{")
+	for i, v := range vs {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, " [%d] = %s", v, quote(e[v]))
+	}
+	b.WriteString(" }")
+	return b.String()
+}
+
+// ident returns name in lower case, with anything other than letters
+// and digits replaced by underscores.
+func ident(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return '_'
+	}, name)
+}
+
+// quote returns s as a Lua string literal.
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < ' ' || c > '~':
+			fmt.Fprintf(&b, "\\%03d", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+const header = `-- Wireshark dissector for the Generic Control Plane (GCP) protocol and
+-- the Remote PHY Control Protocol (RCP) TLVs it carries.
+--
+-- Generated by "gcp gen-dissector" from github.com/nleiva/gcp-rphy.
+-- DO NOT EDIT.
+
+local gcp = Proto("gcp", "Generic Control Plane")
+local f = gcp.fields
+
+local ef_malformed = ProtoExpert.new("gcp.malformed", "Malformed GCP message",
+  expert.group.MALFORMED, expert.severity.ERROR)
+local ef_unknown = ProtoExpert.new("gcp.unknown_tlv", "TLV not known to the decoder",
+  expert.group.UNDECODED, expert.severity.WARN)
+gcp.experts = { ef_malformed, ef_unknown }
+
+f.tran_id = ProtoField.uint16("gcp.tcp.transaction_id", "Transaction Identifier", base.DEC)
+f.prot_id = ProtoField.uint16("gcp.tcp.protocol_id", "Protocol Identifier", base.DEC)
+f.length = ProtoField.uint16("gcp.tcp.length", "Length", base.DEC)
+f.unit_id = ProtoField.uint8("gcp.tcp.unit_id", "Unit Identifier", base.DEC)
+f.message = ProtoField.none("gcp.message", "GCP Message")
+f.msg_len = ProtoField.uint16("gcp.message.length", "Message Length", base.DEC)
+f.tlv = ProtoField.bytes("gcp.rcp.tlv", "TLV")
+f.tlv_type = ProtoField.uint8("gcp.rcp.type", "Type", base.DEC)
+f.tlv_len = ProtoField.uint16("gcp.rcp.length", "Length", base.DEC)
+
+`
+
+const body = `
+-- dissect_tlvs adds the RCP TLVs in buf(off, stop - off), described by
+-- nodes, to tree.
+local function dissect_tlvs(buf, off, stop, tree, nodes)
+  while off < stop do
+    if stop - off < 3 then
+      tree:add_proto_expert_info(ef_malformed, "Truncated TLV header at offset " .. off)
+      return
+    end
+    local t = buf(off, 1):uint()
+    local l = buf(off + 1, 2):uint()
+    if off + 3 + l > stop then
+      local item = tree:add(f.tlv, buf(off, stop - off))
+      item:set_text("TLV " .. t .. ": length " .. l .. " exceeds the " .. (stop - off - 3) .. " bytes left")
+      item:add_proto_expert_info(ef_malformed)
+      return
+    end
+    local n = nodes and nodes[t]
+    local whole = buf(off, 3 + l)
+    local item
+    if n == nil then
+      item = tree:add(f.tlv, whole)
+      item:set_text("Unknown TLV " .. t .. " (" .. l .. " bytes)")
+      item:add_proto_expert_info(ef_unknown)
+    elseif n.kind == "complex" then
+      item = tree:add(n.field, whole)
+      item:append_text(" (" .. t .. ")")
+    elseif l == 0 then
+      -- A Read or Delete names a leaf without a value.
+      item = tree:add(f.tlv, whole)
+      item:set_text(n.name .. " (" .. t .. ")")
+    elseif (n.size and n.size ~= l) or (n.field_v6 and l ~= 4 and l ~= 16) then
+      item = tree:add(f.tlv, whole)
+      item:set_text(n.name .. " (" .. t .. "): unexpected length " .. l)
+      item:add_proto_expert_info(ef_malformed)
+    elseif n.field_v6 and l == 16 then
+      item = tree:add(n.field_v6, buf(off + 3, l))
+    else
+      item = tree:add(n.field, buf(off + 3, l))
+    end
+    item:add(f.tlv_type, buf(off, 1))
+    item:add(f.tlv_len, buf(off + 1, 2))
+    if n and n.kind == "complex" then
+      dissect_tlvs(buf, off + 3, off + 3 + l, item, n.sub)
+    end
+    off = off + 3 + l
+  end
+end
+
+-- dissect_message adds the GCP message in buf(off, stop - off) to tree,
+-- returning its name.
+local function dissect_message(buf, off, stop, tree)
+  local id = buf(off, 1):uint()
+  local name = msg_names[id] or ("Message " .. id)
+  local item = tree:add(f.message, buf(off, stop - off))
+  item:append_text(": " .. name)
+  item:add(f.msg_id, buf(off, 1))
+  if stop - off < 3 then
+    item:add_proto_expert_info(ef_malformed, "Truncated GCP header")
+    return name
+  end
+  item:add(f.msg_len, buf(off + 1, 2))
+  local l = buf(off + 1, 2):uint()
+  local last = off + 3 + l
+  if last > stop then
+    item:add_proto_expert_info(ef_malformed, "Message length exceeds the encapsulation")
+    last = stop
+  end
+  local b = off + 3
+  for _, fl in ipairs(bodies[id] or {}) do
+    local size = fl.size
+    if size == 0 then
+      size = last - b
+    end
+    if b + size > last then
+      item:add_proto_expert_info(ef_malformed, "Truncated message body")
+      break
+    end
+    if size > 0 then
+      local fi = item:add(fl.field, buf(b, size))
+      if fl.rcp then
+        dissect_tlvs(buf, b, b + size, fi, top)
+      end
+    end
+    b = b + size
+  end
+  return name
+end
+
+-- dissect_pdu dissects a GCP TCP encapsulation unit.
+local function dissect_pdu(buf, pinfo, tree)
+  pinfo.cols.protocol = "GCP"
+  local item = tree:add(gcp, buf())
+  item:add(f.tran_id, buf(0, 2))
+  item:add(f.prot_id, buf(2, 2))
+  item:add(f.length, buf(4, 2))
+  local stop = math.min(6 + buf(4, 2):uint(), buf:len())
+  if stop < 7 then
+    item:add_proto_expert_info(ef_malformed, "Encapsulation length too short")
+    return
+  end
+  item:add(f.unit_id, buf(6, 1))
+  local names = {}
+  local off = 7
+  while off < stop do
+    table.insert(names, dissect_message(buf, off, stop, item))
+    if stop - off < 3 then
+      break
+    end
+    off = off + 3 + buf(off + 1, 2):uint()
+  end
+  pinfo.cols.info:set(table.concat(names, ", "))
+end
+
+local function pdu_len(buf, pinfo, off)
+  return 6 + buf(off + 4, 2):uint()
+end
+
+function gcp.dissector(buf, pinfo, tree)
+  dissect_tcp_pdus(buf, tree, 6, pdu_len, dissect_pdu)
+end
+
+DissectorTable.get("tcp.port"):add(%d, gcp)
+`
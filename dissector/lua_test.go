@@ -0,0 +1,84 @@
+package dissector_test
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/dissector"
+)
+
+func TestWriteLua(t *testing.T) {
+	var b bytes.Buffer
+	if err := dissector.WriteLua(&b, 0); err != nil {
+		t.Fatalf("WriteLua failed: %v", err)
+	}
+	lua := b.String()
+	for _, want := range []string{
+		`Proto("gcp", "Generic Control Plane")`,
+		`[6] = "EDS Request"`,
+		`ProtoField.uint32("gcp.body.vendorid", "VendorID", base.DEC)`,
+		`ProtoField.string("gcp.rcp.vendorname", "VendorName")`,
+		`ProtoField.ether("gcp.rcp.devicemacaddress", "DeviceMacAddress")`,
+		`ProtoField.uint8("gcp.rcp.operation", "Operation", base.DEC, { [1] = "Read",`,
+		`ProtoField.uint8("gcp.rcp.adminstatus", "AdminStatus", base.DEC, { [1] = "up", [2] = "down", [3] = "testing" })`,
+		`local top = { [1] = nodes[0], [2] = nodes[1], [3] = nodes[2] }`,
+		`DissectorTable.get("tcp.port"):add(8190, gcp)`,
+	} {
+		if !strings.Contains(lua, want) {
+			t.Errorf("dissector lacks %s", want)
+		}
+	}
+
+	// Display filter names must be unique, and every field used defined.
+	seen := make(map[string]bool)
+	for _, m := range regexp.MustCompile(`ProtoField\.\w+\("([^"]+)"`).FindAllStringSubmatch(lua, -1) {
+		if seen[m[1]] {
+			t.Errorf("field %s defined twice", m[1])
+		}
+		seen[m[1]] = true
+	}
+	defined := make(map[string]bool)
+	for _, m := range regexp.MustCompile(`(?m)^(f\.\w+) = `).FindAllStringSubmatch(lua, -1) {
+		defined[m[1]] = true
+	}
+	for _, m := range regexp.MustCompile(`field(?:_v6)? = (f\.\w+)`).FindAllStringSubmatch(lua, -1) {
+		if !defined[m[1]] {
+			t.Errorf("field %s used but not defined", m[1])
+		}
+	}
+
+	// A Read request names leaves without values: whatever their size,
+	// they must be dissected before their length is checked.
+	read := gcp.EncodeSequence(gcp.TypeREX, 1, gcp.OpRead, gcp.EncodeTLV(100,
+		gcp.EncodeTLV(8, gcp.EncodeTLV(1), gcp.EncodeTLV(6), gcp.EncodeTLV(7))))
+	empty, check := strings.Index(lua, "elseif l == 0 then"), strings.Index(lua, "n.size ~= l")
+	var sized int
+	for s := gcp.NewScanner(read); s.Next(); {
+		n := s.Schema()
+		if n == nil || n.Kind == gcp.KindComplex || len(s.Value()) != 0 || n.Kind.Size() == 0 {
+			continue
+		}
+		sized++
+		if !strings.Contains(lua, fmt.Sprintf("name = %q, kind = %q, field = ", n.Name, n.Kind.String())) {
+			t.Errorf("dissector lacks leaf %s", n.Name)
+		}
+		if empty < 0 || check < 0 || empty > check {
+			t.Errorf("empty %s in a Read request dissected as malformed", n.Name)
+		}
+	}
+	if sized == 0 {
+		t.Errorf("Read request carries no empty fixed-size leaf")
+	}
+
+	b.Reset()
+	if err := dissector.WriteLua(&b, 18190); err != nil {
+		t.Fatalf("WriteLua failed: %v", err)
+	}
+	if !strings.Contains(b.String(), `add(18190, gcp)`) {
+		t.Errorf("dissector not registered on port 18190")
+	}
+}
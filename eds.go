@@ -9,7 +9,7 @@ import (
 // An EDSReq represents a GCP Exchange Data Structures Request message body.
 type EDSReq struct {
 	TransactionID uint16 // Transaction ID: 2 bytes
-	Mode          uint8  // Mode: 1 byte
+	Mode          Mode   // Mode: 1 byte
 	Port          uint16 // Port: 2 bytes
 	Channel       uint16 // Channel: 2 bytes
 	VendorID      uint32 // Vendor ID: 4 bytes
@@ -90,13 +90,13 @@ func parseEDSReq(_ MessageID, b []byte) (MessageBody, error) {
 	}
 	p := &EDSReq{
 		TransactionID: binary.BigEndian.Uint16(b[:2]),
-		Mode:          uint8(b[2]),
+		Mode:          Mode(b[2]),
 		Port:          binary.BigEndian.Uint16(b[3:5]),
 		Channel:       binary.BigEndian.Uint16(b[5:7]),
 		VendorID:      binary.BigEndian.Uint32(b[7:11]),
 		VendorIdx:     uint8(b[11]),
 	}
-	if bodyLen > 8 {
+	if bodyLen > 12 {
 		p.DataStr = make([]byte, bodyLen-12)
 		copy(p.DataStr, b[12:])
 	}
@@ -106,7 +106,7 @@ func parseEDSReq(_ MessageID, b []byte) (MessageBody, error) {
 // An EDSRes represents a GCP Exchange Data Structures Normal Response message body.
 type EDSRes struct {
 	TransactionID uint16 // Transaction ID: 2 bytes
-	Mode          uint8  // Mode: 1 byte
+	Mode          Mode   // Mode: 1 byte
 	Port          uint16 // Port: 2 bytes
 	Channel       uint16 // Channel: 2 bytes
 	VendorID      uint32 // Vendor ID: 4 bytes
@@ -179,13 +179,13 @@ func parseEDSRes(_ MessageID, b []byte) (MessageBody, error) {
 	}
 	p := &EDSRes{
 		TransactionID: binary.BigEndian.Uint16(b[:2]),
-		Mode:          uint8(b[2]),
+		Mode:          Mode(b[2]),
 		Port:          binary.BigEndian.Uint16(b[3:5]),
 		Channel:       binary.BigEndian.Uint16(b[5:7]),
 		VendorID:      binary.BigEndian.Uint32(b[7:11]),
 		VendorIdx:     uint8(b[11]),
 	}
-	if bodyLen > 8 {
+	if bodyLen > 12 {
 		p.DataStr = make([]byte, bodyLen-12)
 		copy(p.DataStr, b[12:])
 	}
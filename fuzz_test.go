@@ -0,0 +1,76 @@
+package gcp
+
+// The fuzz targets are internal to the package to reach the parsers of
+// message bodies and RCP TLVs. Their corpora in testdata/fuzz are seeded
+// with the messages of message_test.go, and keep the inputs that once
+// made a parser panic.
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+// bodyParsers are the parsers of message bodies, by the message they
+// parse.
+var bodyParsers = map[MessageID]func(MessageID, []byte) (MessageBody, error){
+	MessageIDNotifyReq: parseNotifyReq,
+	MessageIDNotifyRes: parseNotifyRes,
+	MessageIDNotifyErr: parseNotifyErr,
+	MessageIDGDMReq:    parseDMReq,
+	MessageIDEDSReq:    parseEDSReq,
+	MessageIDEDSRes:    parseEDSRes,
+}
+
+func FuzzParseMessage(f *testing.F) {
+	f.Add([]byte{4, 0, 8, 0, 1, 0, 0, 0, 0, 0, 0})
+	f.Fuzz(func(t *testing.T, b []byte) {
+		m, err := ParseMessage(b)
+		if err != nil {
+			return
+		}
+		m.Body.Process()
+		if _, err := m.Marshal(); err != nil {
+			t.Errorf("Marshal of a parsed message got: %v", err)
+		}
+	})
+}
+
+func FuzzBody(f *testing.F) {
+	f.Add([]byte{0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0x11, 0x8b})
+	f.Fuzz(func(t *testing.T, b []byte) {
+		for id, parse := range bodyParsers {
+			p, err := parse(id, b)
+			if err != nil {
+				continue
+			}
+			p.Process()
+			got, err := p.Marshal()
+			if err != nil {
+				t.Fatalf("%v: Marshal of a parsed body got: %v", id, err)
+			}
+			if len(got) != p.Len() {
+				t.Fatalf("%v: Len got: %d, want the %d bytes of Marshal", id, p.Len(), len(got))
+			}
+			// Bodies are parsed from their leading bytes.
+			if !bytes.HasPrefix(b, got) {
+				t.Fatalf("%v: Marshal got: %x, want a prefix of %x", id, got, b)
+			}
+		}
+	})
+}
+
+func FuzzParseTLVs(f *testing.F) {
+	f.Add(EncodeSequence(TypeREX, 1, OpRead, EncodeTLV(100)))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		parseTLVs(b)
+		(&EDSReq{DataStr: b}).Process()
+	})
+}
+
+func FuzzParseRedirect(f *testing.F) {
+	f.Add(Redirect(1, net.IPv4(192, 0, 2, 1)))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		ParseRedirect(b)
+	})
+}
@@ -1,10 +1,29 @@
 package gcp
 
-import (
-	"fmt"
-	"log"
+// A NotificationType represents the value of a NotificationType TLV.
+type NotificationType uint8
+
+// RCP Notification Types.
+const (
+	StartUpNotification          NotificationType = 1
+	RedirectResultNotification   NotificationType = 2
+	PtpResultNotification        NotificationType = 3
+	AuxCoreResultNotification    NotificationType = 4
+	TimeOutNotification          NotificationType = 5
+	ReconnectNotification        NotificationType = 7
+	AuxCoreGcpStatusNotification NotificationType = 8
+	ChannelUcdRefreshRequest     NotificationType = 9
+	HandoverNotification         NotificationType = 10
+	SsdFailureNotification       NotificationType = 11
 )
 
+// GeneralNotification returns an RCP Notify message reporting an event
+// of type nt. It is meant to be carried as the Event Data of a Notify
+// Request.
+func GeneralNotification(seq uint16, nt NotificationType) []byte {
+	return EncodeSequence(TypeNTF, seq, OpWrite, EncodeTLV(86, EncodeTLV(1, []byte{byte(nt)})))
+}
+
 // A GenrlNtf is a GeneralNotification TLV (Complex TLV).
 type GenrlNtf struct{ TLV }
 
@@ -21,7 +40,6 @@ func (t *GenrlNtf) newTLV(b byte) RCP {
 		r.parentMsg = t.parentMsg
 		return r
 	default:
-		log.Printf("GeneralNotification TLV type: %d not supported", int(b))
 		return nil
 	}
 }
@@ -36,6 +54,9 @@ func (t *GenrlNtf) parseTLVs(b []byte) ([]RCP, error) {
 		}
 
 		tlv := t.newTLV(b[i])
+		if tlv == nil {
+			return nil, errUnsupported("GeneralNotification", b[i])
+		}
 
 		// Unmarshal at the current offset, up to the expected length.
 		if err := tlv.unmarshal(b[i : i+3+l]); err != nil {
@@ -61,41 +82,35 @@ func (t *GenrlNtf) parseTLVs(b []byte) ([]RCP, error) {
 
 }
 
+// notificationTypeNames are the names of the values of a NotificationType TLV.
+var notificationTypeNames = map[uint32]string{
+	1:  "StartUpNotification",
+	2:  "RedirectResultNotification",
+	3:  "PtpResultNotification",
+	4:  "AuxCoreResultNotification",
+	5:  "TimeOutNotification",
+	7:  "ReconnectNotification",
+	8:  "AuxCoreGcpStatusNotification",
+	9:  "ChannelUcdRefreshRequest",
+	10: "HandoverNotification",
+	11: "SsdFailureNotification",
+}
+
 // A NtfType is a NotificationType TLV.
 type NtfType struct{ TLV }
 
 // Name returns the type name of a NotificationType TLV.
 func (t *NtfType) Name() string { return "NotificationType" }
 
+// Kind returns the encoding of the value of a NotificationType TLV.
+func (t *NtfType) Kind() ValueKind { return KindUint8 }
+
+// Enum returns the names of the values of a NotificationType TLV.
+func (t *NtfType) Enum() map[uint32]string { return notificationTypeNames }
+
 // Val returns the value a NotificationType TLV carries.
 func (t *NtfType) Val() interface{} {
-	if len(t.Value) != 1 {
-		return fmt.Errorf("unexpected lenght: %v, want: 1", len(t.Value))
-	}
-	var s string
-	switch int(t.Value[0]) {
-	case 1:
-		s = "StartUpNotification"
-	case 2:
-		s = "RedirectResultNotification"
-	case 3:
-		s = "PtpResultNotification"
-	case 4:
-		s = "AuxCoreResultNotification"
-	case 5:
-		s = "TimeOutNotification"
-	case 7:
-		s = "ReconnectNotification"
-	case 8:
-		s = "AuxCoreGcpStatusNotification"
-	case 9:
-		s = "ChannelUcdRefreshRequest"
-	case 10:
-		s = "HandoverNotification"
-	case 11:
-		s = "SsdFailureNotification"
-	default:
-	}
+	s := enumName(notificationTypeNames, u8Val(t.Value))
 	t.parentMsg.NTF.Sequence.GeneralNtf.NotificationType = s
 	return s
 }
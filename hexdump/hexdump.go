@@ -0,0 +1,321 @@
+// Package hexdump renders GCP messages as hex dumps annotated with the
+// field every byte belongs to: the TCP encapsulation header, the GCP
+// header, the message body fields and each RCP TLV, with its path and
+// name. Bytes that cannot be decoded are highlighted along with the
+// reason.
+//
+// A Notify Request with a truncated SequenceNumber TLV is dumped as:
+//
+//	000000  02                                               Message ID: Notify Request (2)
+//	000001  00 11                                            Message Length: 17
+//	000003  00 01                                            TransactionID: 1
+//	000005  00                                               Mode: 0 (response required)
+//	000006  00                                               Status: 0
+//	000007  00 00 00 00                                      EvntCode: 0
+//	00000b                                                   EvntData: 9 bytes of RCP TLVs
+//	00000b  03 00 06                                           3 Notify
+//	00000e  09 00 03                                             3.9 Sequence
+//	000011  0a 01 2c                                               3.9.10 SequenceNumber
+//	        ^^ ^^ ^^                                               error: TLV length 300 exceeds the 0 bytes left
+package hexdump
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	gcp "github.com/nleiva/gcp-rphy"
+)
+
+// Error messages
+var (
+	ErrUnknownTLV = errors.New("TLV type not known to the decoder")
+	errTrailing   = errors.New("bytes beyond the message length")
+)
+
+// bytesPerRow is the number of bytes in a row of a dump.
+const bytesPerRow = 16
+
+// A Field is a range of bytes of a message and what they encode.
+type Field struct {
+	Offset int
+	Len    int    // Zero for fields that only group the ones that follow
+	Depth  int    // Nesting level of RCP TLVs, zero outside them
+	Path   string // Types of the RCP TLVs leading to this one, dot separated
+	Name   string
+	Value  string // Decoded value, empty if not decoded
+	Err    error  // Why decoding failed at this field, nil if it did not
+}
+
+// Fields returns the fields of the GCP message b.
+func Fields(b []byte) []Field {
+	var d dumper
+	if len(b) == 0 {
+		d.add(Field{Name: "Message ID", Err: gcp.ErrMessageTooShort})
+		return d.fields
+	}
+	if next := d.message(b, 0, len(b)); next < len(b) {
+		d.add(Field{Offset: next, Len: len(b) - next, Name: "Trailing bytes", Err: errTrailing})
+	}
+	return d.fields
+}
+
+// TCPFields returns the fields of b, a GCP TCP encapsulation unit with
+// one or more GCP messages.
+func TCPFields(b []byte) []Field {
+	var d dumper
+	if len(b) < 7 {
+		d.add(Field{Len: len(b), Name: "TCP encapsulation header", Err: gcp.ErrMessageTooShort})
+		return d.fields
+	}
+	d.add(Field{Offset: 0, Len: 2, Name: "TCP Transaction ID", Value: u(b[0:2])})
+	prot := Field{Offset: 2, Len: 2, Name: "TCP Protocol ID", Value: u(b[2:4])}
+	if id := binary.BigEndian.Uint16(b[2:4]); id != 1 {
+		prot.Err = fmt.Errorf("protocol identifier %d, want: 1", id)
+	}
+	d.add(prot)
+	l := int(binary.BigEndian.Uint16(b[4:6]))
+	stop := 6 + l
+	length := Field{Offset: 4, Len: 2, Name: "TCP Length", Value: strconv.Itoa(l)}
+	switch {
+	case l < 1:
+		length.Err = fmt.Errorf("length %d leaves no room for the unit identifier", l)
+		stop = 7
+	case stop > len(b):
+		length.Err = fmt.Errorf("length %d exceeds the %d bytes left", l, len(b)-6)
+		stop = len(b)
+	}
+	d.add(length)
+	d.add(Field{Offset: 6, Len: 1, Name: "TCP Unit ID", Value: u(b[6:7])})
+	for off := 7; off < stop; {
+		off = d.message(b, off, stop)
+	}
+	if stop < len(b) {
+		d.add(Field{Offset: stop, Len: len(b) - stop, Name: "Trailing bytes", Err: errTrailing})
+	}
+	return d.fields
+}
+
+// Fprint writes to w a dump of b annotated with fs, the fields of b.
+func Fprint(w io.Writer, b []byte, fs []Field) error {
+	var buf bytes.Buffer
+	for _, f := range fs {
+		label := strings.Repeat("  ", f.Depth)
+		if f.Path != "" {
+			label += f.Path + " "
+		}
+		label += f.Name
+		if f.Value != "" {
+			label += ": " + f.Value
+		}
+		end := f.Offset + f.Len
+		if end > len(b) {
+			end = len(b)
+		}
+		v := b[f.Offset:end]
+		first := v
+		if len(first) > bytesPerRow {
+			first = first[:bytesPerRow]
+		}
+		fmt.Fprintf(&buf, "%06x  %-*s  %s\n", f.Offset, 3*bytesPerRow-1, hex(first), label)
+		for i := bytesPerRow; i < len(v); i += bytesPerRow {
+			j := i + bytesPerRow
+			if j > len(v) {
+				j = len(v)
+			}
+			fmt.Fprintf(&buf, "%6s  %s\n", "", hex(v[i:j]))
+		}
+		if f.Err != nil {
+			marks := strings.TrimSpace(strings.Repeat("^^ ", len(first)))
+			if marks == "" {
+				marks = "^"
+			}
+			fmt.Fprintf(&buf, "%6s  %-*s  %serror: %v\n", "", 3*bytesPerRow-1, marks, strings.Repeat("  ", f.Depth), f.Err)
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Message returns an annotated dump of the GCP message b.
+func Message(b []byte) string {
+	var s strings.Builder
+	Fprint(&s, b, Fields(b))
+	return s.String()
+}
+
+// TCP returns an annotated dump of the GCP TCP encapsulation unit b.
+func TCP(b []byte) string {
+	var s strings.Builder
+	Fprint(&s, b, TCPFields(b))
+	return s.String()
+}
+
+// Err returns the first decoding error in fs, or nil if there is none.
+func Err(fs []Field) error {
+	for _, f := range fs {
+		if f.Err != nil {
+			return f.Err
+		}
+	}
+	return nil
+}
+
+// A dumper collects the fields of a message.
+type dumper struct {
+	fields []Field
+}
+
+func (d *dumper) add(f Field) { d.fields = append(d.fields, f) }
+
+// message adds the fields of the GCP message at b[off:stop], returning
+// the offset that follows it.
+func (d *dumper) message(b []byte, off, stop int) int {
+	id := gcp.MessageID(b[off])
+	d.add(Field{Offset: off, Len: 1, Name: "Message ID", Value: fmt.Sprintf("%v (%d)", id, id)})
+	if stop-off < 3 {
+		d.add(Field{Offset: off + 1, Len: stop - off - 1, Name: "Message Length", Err: gcp.ErrMessageTooShort})
+		return stop
+	}
+	l := int(binary.BigEndian.Uint16(b[off+1 : off+3]))
+	end := off + 3 + l
+	length := Field{Offset: off + 1, Len: 2, Name: "Message Length", Value: strconv.Itoa(l)}
+	if end > stop {
+		length.Err = fmt.Errorf("message length %d exceeds the %d bytes left", l, stop-off-3)
+		end = stop
+	}
+	d.add(length)
+
+	layout := gcp.BodyLayout(id)
+	if layout == nil {
+		if end > off+3 {
+			d.add(Field{Offset: off + 3, Len: end - off - 3, Name: "Message Body"})
+		}
+		return end
+	}
+	i := off + 3
+	for _, f := range layout {
+		size := f.Size
+		if size == 0 {
+			size = end - i
+		}
+		if i+size > end {
+			d.add(Field{Offset: i, Len: end - i, Name: f.Name,
+				Err: fmt.Errorf("field needs %d bytes, %d left", size, end-i)})
+			return end
+		}
+		if f.RCP {
+			d.add(Field{Offset: i, Name: f.Name, Value: fmt.Sprintf("%d bytes of RCP TLVs", size)})
+			d.tlvs(b, i, i+size, "", gcp.Schema(), 1)
+		} else if f.Name == "Mode" {
+			d.add(Field{Offset: i, Len: size, Name: f.Name, Value: gcp.Mode(b[i]).String()})
+		} else {
+			d.add(Field{Offset: i, Len: size, Name: f.Name, Value: u(b[i : i+size])})
+		}
+		i += size
+	}
+	return end
+}
+
+// tlvs adds the fields of the RCP TLVs at b[off:stop], nested in the TLV
+// at path and described by ss.
+func (d *dumper) tlvs(b []byte, off, stop int, path string, ss []*gcp.TLVSchema, depth int) {
+	for off < stop {
+		if stop-off < 3 {
+			d.add(Field{Offset: off, Len: stop - off, Depth: depth, Path: path, Name: "TLV header",
+				Err: fmt.Errorf("TLV header needs 3 bytes, %d left", stop-off)})
+			return
+		}
+		t := b[off]
+		l := int(binary.BigEndian.Uint16(b[off+1 : off+3]))
+		p := strconv.Itoa(int(t))
+		if path != "" {
+			p = path + "." + p
+		}
+		var s *gcp.TLVSchema
+		for _, c := range ss {
+			if c.Type == t {
+				s = c
+			}
+		}
+		f := Field{Offset: off, Len: 3 + l, Depth: depth, Path: p, Name: "TLV"}
+		if s != nil {
+			f.Name = s.Name
+		}
+		switch {
+		case off+3+l > stop:
+			f.Len = stop - off
+			f.Err = fmt.Errorf("TLV length %d exceeds the %d bytes left", l, stop-off-3)
+			d.add(f)
+			return
+		case s == nil:
+			f.Err = ErrUnknownTLV
+			d.add(f)
+		case s.Kind == gcp.KindComplex:
+			f.Len = 3
+			d.add(f)
+			d.tlvs(b, off+3, off+3+l, p, s.Sub, depth+1)
+		default:
+			f.Value, f.Err = value(s, b[off+3:off+3+l])
+			d.add(f)
+		}
+		off += 3 + l
+	}
+}
+
+// value renders v, the value of a TLV described by s.
+func value(s *gcp.TLVSchema, v []byte) (string, error) {
+	if n := s.Kind.Size(); n > 0 && len(v) != n {
+		return "", fmt.Errorf("unexpected length: %d, want: %d", len(v), n)
+	}
+	switch s.Kind {
+	case gcp.KindUint8, gcp.KindUint16, gcp.KindUint32:
+		n := binary.BigEndian.Uint32(append(make([]byte, 4-len(v)), v...))
+		if name, ok := s.Enum[n]; ok {
+			return fmt.Sprintf("%s (%d)", name, n), nil
+		}
+		return strconv.FormatUint(uint64(n), 10), nil
+	case gcp.KindTimeTicks:
+		n := binary.BigEndian.Uint32(v)
+		return fmt.Sprintf("%d.%02ds", n/100, n%100), nil
+	case gcp.KindString:
+		return strconv.Quote(string(v)), nil
+	case gcp.KindMAC:
+		return net.HardwareAddr(v).String(), nil
+	case gcp.KindIP:
+		if len(v) != 4 && len(v) != 16 {
+			return "", fmt.Errorf("unexpected length: %d, want: 4 or 16", len(v))
+		}
+		return net.IP(v).String(), nil
+	case gcp.KindDateAndTime:
+		if len(v) != 8 && len(v) != 11 {
+			return "", fmt.Errorf("unexpected length: %d, want: 8 or 11", len(v))
+		}
+		t := fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d.%d",
+			binary.BigEndian.Uint16(v[0:2]), v[2], v[3], v[4], v[5], v[6], v[7])
+		if len(v) == 11 {
+			t += fmt.Sprintf(" %c%02d:%02d", v[8], v[9], v[10])
+		}
+		return t, nil
+	}
+	return "", nil
+}
+
+// u renders the big endian unsigned integer b.
+func u(b []byte) string {
+	var n uint64
+	for _, c := range b {
+		n = n<<8 | uint64(c)
+	}
+	return strconv.FormatUint(n, 10)
+}
+
+// hex returns b as space separated hexadecimal bytes.
+func hex(b []byte) string {
+	return fmt.Sprintf("% x", b)
+}
@@ -0,0 +1,111 @@
+package hexdump_test
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/hexdump"
+)
+
+var ntf = "AgFqAAHAAQAAAAEDAV8JAVwKAAIAAQsAAQIyAUkTASUBAAVDaXNjbwIAAgAJAwAIUlBIWS1SUEQEAAag+ElvQxwFAAR2Ni40BgBvUHJpbWFyeTogVS1Cb290IDIwMTYuMDEgKEp1bCAzMSAyMDE3IC0gMDk6NTQ6NTEgKzA4MDApICo7R29sZGVuOiBVLUJvb3QgMjAxNi4wMSAoQXByIDEyIDIwMTcgLSAwOToxMzoyOCArMDgwMCk7BwADUlBECAADUlBECQALQ0FUMjEzM0UwQTUKAAIRPQsACEJDTTMxNjEwDAADVjExDQAIMDAwMDAwMDAOAAMxLjAPAAYxLjAuMTAQAAMxLjARAAASAAATAAgH4wQCEjIqBRQAEFJQRC1WNi00Lml0Yi5TU0EVABAgAQV4EAAREQAAAAAAAAJFFgABABgAHgEAAk5BAgAJKzAwMDAwMC4wAwAKKzAwMDAwMDAuMFYABAEAAQE="
+
+// notify returns a Notify Request carrying the RCP TLVs b.
+func notify(t *testing.T, b ...byte) []byte {
+	t.Helper()
+	m, err := gcp.NewMessage(gcp.MessageIDNotifyReq, &gcp.NotifyReq{TransactionID: 1, EvntData: b}).Marshal()
+	if err != nil {
+		t.Fatalf("could not encode message: %v", err)
+	}
+	return m
+}
+
+// find returns the field at path, or the first field named name.
+func find(fs []hexdump.Field, s string) *hexdump.Field {
+	for i, f := range fs {
+		if f.Path == s || f.Path == "" && f.Name == s {
+			return &fs[i]
+		}
+	}
+	return nil
+}
+
+func TestTCPFields(t *testing.T) {
+	m, err := base64.StdEncoding.DecodeString(ntf)
+	if err != nil {
+		t.Fatalf("could not decode base64 message: %v", err)
+	}
+	b := append([]byte{0, 7, 0, 1, byte((len(m) + 1) >> 8), byte(len(m) + 1), 0}, m...)
+	fs := hexdump.TCPFields(b)
+	if err := hexdump.Err(fs); err != nil {
+		t.Fatalf("Err got: %v, want: nil\n%s", err, hexdump.TCP(b))
+	}
+	tt := []struct {
+		field  string
+		offset int
+		name   string
+		value  string
+	}{
+		{field: "TCP Transaction ID", offset: 0, name: "TCP Transaction ID", value: "7"},
+		{field: "Message ID", offset: 7, name: "Message ID", value: "Notify Request (2)"},
+		{field: "EvntCode", offset: 14, name: "EvntCode", value: "1"},
+		{field: "3.9.11", offset: 29, name: "Operation", value: "Write (2)"},
+		{field: "3.9.50.19.1", offset: 39, name: "VendorName", value: `"Cisco"`},
+		{field: "3.9.50.19.4", offset: 63, name: "DeviceMacAddress", value: "a0:f8:49:6f:43:1c"},
+		{field: "3.9.50.19.21", offset: 309, name: "CurrentSwImageServer", value: "2001:578:1000:1111::245"},
+		{field: "3.9.86.1", offset: 368, name: "NotificationType", value: "StartUpNotification (1)"},
+	}
+	for _, tc := range tt {
+		f := find(fs, tc.field)
+		if f == nil {
+			t.Errorf("field %s not found", tc.field)
+			continue
+		}
+		if f.Offset != tc.offset || f.Name != tc.name || f.Value != tc.value {
+			t.Errorf("field %s got: %d %s %s, want: %d %s %s", tc.field, f.Offset, f.Name, f.Value, tc.offset, tc.name, tc.value)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tt := []struct {
+		name   string
+		b      []byte
+		tcp    bool
+		field  string
+		offset int
+		err    string
+	}{
+		{name: "TLV too long", b: notify(t, 3, 0, 6, 9, 0, 3, 10, 1, 44), field: "3.9.10", offset: 17,
+			err: "TLV length 300 exceeds the 0 bytes left"},
+		{name: "Unknown TLV", b: notify(t, 3, 0, 4, 200, 0, 1, 0), field: "3.200", offset: 14,
+			err: hexdump.ErrUnknownTLV.Error()},
+		{name: "Wrong length", b: notify(t, 3, 0, 8, 9, 0, 5, 11, 0, 2, 0, 1), field: "3.9.11", offset: 17,
+			err: "unexpected length: 2, want: 1"},
+		{name: "Message too long", b: []byte{3, 0, 9, 0, 1, 0}, field: "Message Length", offset: 1,
+			err: "message length 9 exceeds the 3 bytes left"},
+		{name: "Body too short", b: []byte{3, 0, 3, 0, 1, 0}, field: "EvntCode", offset: 6,
+			err: "field needs 4 bytes, 0 left"},
+		{name: "Protocol ID", b: []byte{0, 1, 0, 2, 0, 1, 0}, tcp: true, field: "TCP Protocol ID", offset: 2,
+			err: "protocol identifier 2, want: 1"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			fs, dump := hexdump.Fields(tc.b), hexdump.Message(tc.b)
+			if tc.tcp {
+				fs, dump = hexdump.TCPFields(tc.b), hexdump.TCP(tc.b)
+			}
+			f := find(fs, tc.field)
+			if f == nil {
+				t.Fatalf("field %s not found\n%s", tc.field, dump)
+			}
+			if f.Offset != tc.offset || f.Err == nil || f.Err.Error() != tc.err {
+				t.Errorf("field %s got: %d %v, want: %d %s\n%s", tc.field, f.Offset, f.Err, tc.offset, tc.err, dump)
+			}
+			if !strings.Contains(dump, "^") || !strings.Contains(dump, "error: "+tc.err) {
+				t.Errorf("error not highlighted\n%s", dump)
+			}
+		})
+	}
+}
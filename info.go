@@ -1,15 +1,15 @@
 package gcp
 
 import (
+	"encoding/binary"
 	"fmt"
-	"log"
 )
 
 // A RpdInfo is a RpdInfo TLV (Complex TLV).
 type RpdInfo struct {
 	TLV
-	Ifindex int8
-	IPindex int8
+	Ifindex int
+	IPindex int
 }
 
 // Name returns the type name of a RpdInfo TLV.
@@ -25,15 +25,20 @@ func (t *RpdInfo) newTLV(b byte) RCP {
 		t.Ifindex++
 		r.parentMsg = t.parentMsg
 		r.portIndex = t.Ifindex
+		t.parentMsg.REX.Sequence.RpdInfo.IfEnet = append(t.parentMsg.REX.Sequence.RpdInfo.IfEnet,
+			IfEn{},
+		)
 		return r
 	case 15:
 		r := new(IPAddress)
 		t.IPindex++
 		r.parentMsg = t.parentMsg
 		r.portIndex = t.IPindex
+		t.parentMsg.REX.Sequence.RpdInfo.IPAddress = append(t.parentMsg.REX.Sequence.RpdInfo.IPAddress,
+			IPAdd{},
+		)
 		return r
 	default:
-		log.Printf("RpdInfo TLV type: %d not supported", int(b))
 		return nil
 	}
 }
@@ -48,6 +53,9 @@ func (t *RpdInfo) parseTLVs(b []byte) ([]RCP, error) {
 		}
 
 		tlv := t.newTLV(b[i])
+		if tlv == nil {
+			return nil, errUnsupported("RpdInfo", b[i])
+		}
 
 		// Unmarshal at the current offset, up to the expected length.
 		if err := tlv.unmarshal(b[i : i+3+l]); err != nil {
@@ -77,7 +85,7 @@ func (t *RpdInfo) parseTLVs(b []byte) ([]RCP, error) {
 type IfEnet struct {
 	TLV
 	// Fake port index to creat an array of interfaces
-	portIndex int8
+	portIndex int
 }
 
 // Name returns the type name of an IfEnet TLV.
@@ -92,9 +100,6 @@ func (t *IfEnet) newTLV(b byte) RCP {
 		r := new(EnPortIdx)
 		r.parentMsg = t.parentMsg
 		r.portIndex = t.portIndex
-		t.parentMsg.REX.Sequence.RpdInfo.IfEnet = append(t.parentMsg.REX.Sequence.RpdInfo.IfEnet,
-			IfEn{},
-		)
 		return r
 	case 2:
 		r := new(IfName)
@@ -162,7 +167,6 @@ func (t *IfEnet) newTLV(b byte) RCP {
 		r.portIndex = t.portIndex
 		return r
 	default:
-		log.Printf("IfEnet TLV type: %d not supported", int(b))
 		return nil
 	}
 }
@@ -176,6 +180,9 @@ func (t *IfEnet) parseTLVs(b []byte) ([]RCP, error) {
 			return nil, err
 		}
 		tlv := t.newTLV(b[i])
+		if tlv == nil {
+			return nil, errUnsupported("IfEnet", b[i])
+		}
 
 		// Unmarshal at the current offset, up to the expected length.
 		if err := tlv.unmarshal(b[i : i+3+l]); err != nil {
@@ -205,7 +212,7 @@ func (t *IfEnet) parseTLVs(b []byte) ([]RCP, error) {
 type IPAddress struct {
 	TLV
 	// Fake port index to creat an array of interfaces
-	portIndex int8
+	portIndex int
 }
 
 // Name returns the type name of an IpAddress TLV.
@@ -220,9 +227,6 @@ func (t *IPAddress) newTLV(b byte) RCP {
 		r := new(AddrType)
 		r.parentMsg = t.parentMsg
 		r.portIndex = t.portIndex
-		t.parentMsg.REX.Sequence.RpdInfo.IPAddress = append(t.parentMsg.REX.Sequence.RpdInfo.IPAddress,
-			IPAdd{},
-		)
 		return r
 	case 2:
 		r := new(IPAddr)
@@ -265,7 +269,6 @@ func (t *IPAddress) newTLV(b byte) RCP {
 		r.portIndex = t.portIndex
 		return r
 	default:
-		log.Printf("IPAddress TLV type: %d not supported", int(b))
 		return nil
 	}
 }
@@ -279,6 +282,9 @@ func (t *IPAddress) parseTLVs(b []byte) ([]RCP, error) {
 			return nil, err
 		}
 		tlv := t.newTLV(b[i])
+		if tlv == nil {
+			return nil, errUnsupported("IPAddress", b[i])
+		}
 
 		// Unmarshal at the current offset, up to the expected length.
 		if err := tlv.unmarshal(b[i : i+3+l]); err != nil {
@@ -307,12 +313,15 @@ func (t *IPAddress) parseTLVs(b []byte) ([]RCP, error) {
 // A EnPortIdx is a EnetPortIndex TLV.
 type EnPortIdx struct {
 	TLV
-	portIndex int8
+	portIndex int
 }
 
 // Name returns the type name of a EnetPortIndex TLV.
 func (t *EnPortIdx) Name() string { return "EnetPortIndex" }
 
+// Kind returns the encoding of the value of a EnetPortIndex TLV.
+func (t *EnPortIdx) Kind() ValueKind { return KindUint8 }
+
 // Val returns the value a EnetPortIndex TLV carries.
 func (t *EnPortIdx) Val() interface{} {
 	if len(t.Value) != 1 {
@@ -326,54 +335,67 @@ func (t *EnPortIdx) Val() interface{} {
 // A IfName is a Name TLV.
 type IfName struct {
 	TLV
-	portIndex int8
+	portIndex int
 }
 
 // Name returns the type name of a Name TLV.
 func (t *IfName) Name() string { return "Name" }
 
+// Kind returns the encoding of the value of a Name TLV.
+func (t *IfName) Kind() ValueKind { return KindString }
+
 // Val returns the value a Name TLV carries.
 func (t *IfName) Val() interface{} {
 	s := stringVal(t.Value)
 	t.parentMsg.REX.Sequence.RpdInfo.IfEnet[t.portIndex].Name = s
+	keep(&t.parentMsg.REX.Sequence.RpdInfo.IfEnet[t.portIndex].kept, "Name", s == "")
 	return s
 }
 
 // A Descr is a Descr TLV.
 type Descr struct {
 	TLV
-	portIndex int8
+	portIndex int
 }
 
 // Name returns the type name of a Descr TLV.
 func (t *Descr) Name() string { return "Description" }
 
+// Kind returns the encoding of the value of a Descr TLV.
+func (t *Descr) Kind() ValueKind { return KindString }
+
 // Val returns the value a Descr TLV carries.
 func (t *Descr) Val() interface{} {
 	s := stringVal(t.Value)
 	t.parentMsg.REX.Sequence.RpdInfo.IfEnet[t.portIndex].Descr = s
+	keep(&t.parentMsg.REX.Sequence.RpdInfo.IfEnet[t.portIndex].kept, "Description", s == "")
 	return s
 }
 
+// ianaIfTypeNames are the names of the values of a Type TLV of an IfEnet (IANAifType).
+var ianaIfTypeNames = map[uint32]string{
+	1: "other",
+	6: "ethernetCsmacd",
+}
+
 // A IifType is a Type TLV (IANAifType).
 type IifType struct {
 	TLV
-	portIndex int8
+	portIndex int
 }
 
 // Name returns the type name of a Type TLV.
 func (t *IifType) Name() string { return "Type" }
 
+// Kind returns the encoding of the value of a Type TLV.
+func (t *IifType) Kind() ValueKind { return KindUint16 }
+
+// Enum returns the names of the values of a Type TLV.
+func (t *IifType) Enum() map[uint32]string { return ianaIfTypeNames }
+
 // Val returns the value a Type TLV carries.
 func (t *IifType) Val() interface{} {
-	s := u16Val(t.Value)
-	switch s {
-	case "1":
-		s = "other"
-	case "6":
-		s = "ethernetCsmacd"
-	default:
-	}
+	s := enumName(ianaIfTypeNames, u16Val(t.Value))
 	t.parentMsg.REX.Sequence.RpdInfo.IfEnet[t.portIndex].Type = s
 	return s
 }
@@ -381,28 +403,35 @@ func (t *IifType) Val() interface{} {
 // A Alias is a Alias TLV.
 type Alias struct {
 	TLV
-	portIndex int8
+	portIndex int
 }
 
 // Name returns the type name of a Alias TLV.
 func (t *Alias) Name() string { return "Alias" }
 
+// Kind returns the encoding of the value of a Alias TLV.
+func (t *Alias) Kind() ValueKind { return KindString }
+
 // Val returns the value a Alias TLV carries.
 func (t *Alias) Val() interface{} {
 	s := stringVal(t.Value)
 	t.parentMsg.REX.Sequence.RpdInfo.IfEnet[t.portIndex].Alias = s
+	keep(&t.parentMsg.REX.Sequence.RpdInfo.IfEnet[t.portIndex].kept, "Alias", s == "")
 	return s
 }
 
 // A Mtu is a Mtu TLV.
 type Mtu struct {
 	TLV
-	portIndex int8
+	portIndex int
 }
 
 // Name returns the type name of a Mtu TLV.
 func (t *Mtu) Name() string { return "Mtu" }
 
+// Kind returns the encoding of the value of a Mtu TLV.
+func (t *Mtu) Kind() ValueKind { return KindUint32 }
+
 // Val returns the value a Mtu TLV carries.
 func (t *Mtu) Val() interface{} {
 	s := u32Val(t.Value)
@@ -413,12 +442,15 @@ func (t *Mtu) Val() interface{} {
 // A PhyAddr is a PhysAddress TLV.
 type PhyAddr struct {
 	TLV
-	portIndex int8
+	portIndex int
 }
 
 // Name returns the type name of a PhysAddress TLV.
 func (t *PhyAddr) Name() string { return "PhysAddress" }
 
+// Kind returns the encoding of the value of a PhysAddress TLV.
+func (t *PhyAddr) Kind() ValueKind { return KindMAC }
+
 // Val returns the value a PhysAddress TLV carries.
 func (t *PhyAddr) Val() interface{} {
 	s := macVal(t.Value)
@@ -426,66 +458,74 @@ func (t *PhyAddr) Val() interface{} {
 	return s
 }
 
+// adminStatusNames are the names of the values of an AdminStatus TLV.
+var adminStatusNames = map[uint32]string{
+	1: "up",
+	2: "down",
+	3: "testing",
+}
+
 // A AdmStatus is a AdminStatus TLV.
 type AdmStatus struct {
 	TLV
-	portIndex int8
+	portIndex int
 }
 
 // Name returns the type name of a AdminStatus TLV.
 func (t *AdmStatus) Name() string { return "AdminStatus" }
 
+// Kind returns the encoding of the value of a AdminStatus TLV.
+func (t *AdmStatus) Kind() ValueKind { return KindUint8 }
+
+// Enum returns the names of the values of a AdminStatus TLV.
+func (t *AdmStatus) Enum() map[uint32]string { return adminStatusNames }
+
 // Val returns the value a AdminStatus TLV carries.
 func (t *AdmStatus) Val() interface{} {
 	if len(t.Value) != 1 {
 		return fmt.Sprintf("unexpected lenght: %v, want: 1", len(t.Value))
 	}
-	var s string
-	switch int(t.Value[0]) {
-	case 1:
-		s = "up"
-	case 2:
-		s = "down"
-	case 3:
-		s = "testing"
-	default:
+	s, ok := adminStatusNames[uint32(t.Value[0])]
+	if !ok {
 		s = "Unknown AdminStatus"
 	}
 	t.parentMsg.REX.Sequence.RpdInfo.IfEnet[t.portIndex].AdminStatus = s
 	return s
 }
 
+// operStatusNames are the names of the values of an OperStatus TLV.
+var operStatusNames = map[uint32]string{
+	1: "up",
+	2: "down",
+	3: "testing",
+	4: "unknown",
+	5: "dormant",
+	6: "notPresent",
+	7: "lowerLayerDown",
+}
+
 // A OperStatus is a OperStatus TLV.
 type OperStatus struct {
 	TLV
-	portIndex int8
+	portIndex int
 }
 
 // Name returns the type name of a OperStatus TLV.
 func (t *OperStatus) Name() string { return "OperStatus" }
 
+// Kind returns the encoding of the value of a OperStatus TLV.
+func (t *OperStatus) Kind() ValueKind { return KindUint8 }
+
+// Enum returns the names of the values of a OperStatus TLV.
+func (t *OperStatus) Enum() map[uint32]string { return operStatusNames }
+
 // Val returns the value a OperStatus TLV carries.
 func (t *OperStatus) Val() interface{} {
 	if len(t.Value) != 1 {
 		return fmt.Errorf("unexpected lenght: %v, want: 1", len(t.Value))
 	}
-	var s string
-	switch int(t.Value[0]) {
-	case 1:
-		s = "up"
-	case 2:
-		s = "down"
-	case 3:
-		s = "testing"
-	case 4:
-		s = "unknown"
-	case 5:
-		s = "dormant"
-	case 6:
-		s = "notPresent"
-	case 7:
-		s = "lowerLayerDown"
-	default:
+	s, ok := operStatusNames[uint32(t.Value[0])]
+	if !ok {
 		s = "Unknown OperStatus"
 	}
 	t.parentMsg.REX.Sequence.RpdInfo.IfEnet[t.portIndex].OperStatus = s
@@ -495,12 +535,15 @@ func (t *OperStatus) Val() interface{} {
 // A LastChange is a LastChange TLV.
 type LastChange struct {
 	TLV
-	portIndex int8
+	portIndex int
 }
 
 // Name returns the type name of a LastChange TLV.
 func (t *LastChange) Name() string { return "LastChange" }
 
+// Kind returns the encoding of the value of a LastChange TLV.
+func (t *LastChange) Kind() ValueKind { return KindTimeTicks }
+
 // Val returns the value a LastChange TLV carries.
 func (t *LastChange) Val() interface{} {
 	s := timeVal(t.Value)
@@ -511,12 +554,15 @@ func (t *LastChange) Val() interface{} {
 // A HighSpeed is a HighSpeed TLV.
 type HighSpeed struct {
 	TLV
-	portIndex int8
+	portIndex int
 }
 
 // Name returns the type name of a HighSpeed TLV.
 func (t *HighSpeed) Name() string { return "HighSpeed" }
 
+// Kind returns the encoding of the value of a HighSpeed TLV.
+func (t *HighSpeed) Kind() ValueKind { return KindUint32 }
+
 // Val returns the value a HighSpeed TLV carries.
 func (t *HighSpeed) Val() interface{} {
 	// Speed in units of 1,000,000 bits per second.
@@ -525,25 +571,30 @@ func (t *HighSpeed) Val() interface{} {
 	return s
 }
 
+// truthValueNames are the names of the values of the TruthValue TLVs of an IfEnet.
+var truthValueNames = map[uint32]string{
+	1: "true",
+	2: "false",
+}
+
 // A LinkTrap is a Type LinkUpDownTrapEnable.
 type LinkTrap struct {
 	TLV
-	portIndex int8
+	portIndex int
 }
 
 // Name returns the type name of a LinkUpDownTrapEnable TLV.
 func (t *LinkTrap) Name() string { return "LinkUpDownTrapEnable" }
 
+// Kind returns the encoding of the value of a LinkUpDownTrapEnable TLV.
+func (t *LinkTrap) Kind() ValueKind { return KindUint8 }
+
+// Enum returns the names of the values of a LinkUpDownTrapEnable TLV.
+func (t *LinkTrap) Enum() map[uint32]string { return truthValueNames }
+
 // Val returns the value a LinkUpDownTrapEnable TLV carries.
 func (t *LinkTrap) Val() interface{} {
-	s := u8Val(t.Value)
-	switch s {
-	case "1":
-		s = "true"
-	case "2":
-		s = "false"
-	default:
-	}
+	s := enumName(truthValueNames, u8Val(t.Value))
 	t.parentMsg.REX.Sequence.RpdInfo.IfEnet[t.portIndex].LinkUpDownTrapEnable = s
 	return s
 }
@@ -551,71 +602,76 @@ func (t *LinkTrap) Val() interface{} {
 // A PromMode is a Type PromiscuousMode.
 type PromMode struct {
 	TLV
-	portIndex int8
+	portIndex int
 }
 
 // Name returns the type name of a PromiscuousMode TLV.
 func (t *PromMode) Name() string { return "PromiscuousMode" }
 
+// Kind returns the encoding of the value of a PromiscuousMode TLV.
+func (t *PromMode) Kind() ValueKind { return KindUint8 }
+
+// Enum returns the names of the values of a PromiscuousMode TLV.
+func (t *PromMode) Enum() map[uint32]string { return truthValueNames }
+
 // Val returns the value a PromiscuousMode TLV carries.
 func (t *PromMode) Val() interface{} {
-	s := u8Val(t.Value)
-	switch s {
-	case "1":
-		s = "true"
-	case "2":
-		s = "false"
-	default:
-	}
+	s := enumName(truthValueNames, u8Val(t.Value))
 	t.parentMsg.REX.Sequence.RpdInfo.IfEnet[t.portIndex].PromiscuousMode = s
 	return s
 }
 
-// A ConPres is a Type ConnectorPresent .
+// A ConPres is a Type ConnectorPresent.
 type ConPres struct {
 	TLV
-	portIndex int8
+	portIndex int
 }
 
-// Name returns the type name of a ConnectorPresent  TLV.
-func (t *ConPres) Name() string { return "PromiscuousMode" }
+// Name returns the type name of a ConnectorPresent TLV.
+func (t *ConPres) Name() string { return "ConnectorPresent" }
 
-// Val returns the value a ConnectorPresent  TLV carries.
+// Kind returns the encoding of the value of a ConnectorPresent TLV.
+func (t *ConPres) Kind() ValueKind { return KindUint8 }
+
+// Enum returns the names of the values of a ConnectorPresent TLV.
+func (t *ConPres) Enum() map[uint32]string { return truthValueNames }
+
+// Val returns the value a ConnectorPresent TLV carries.
 func (t *ConPres) Val() interface{} {
-	s := u8Val(t.Value)
-	var b bool
-	switch s {
-	case "1":
-		b = true
-	case "2":
-		b = false
-	default:
-	}
+	b := enumName(truthValueNames, u8Val(t.Value)) == "true"
 	t.parentMsg.REX.Sequence.RpdInfo.IfEnet[t.portIndex].ConnectorPresent = b
+	keep(&t.parentMsg.REX.Sequence.RpdInfo.IfEnet[t.portIndex].kept, "Connector Present", !b)
 	return b
 }
 
+// inetAddressTypeNames are the names of the values of an AddrType TLV (InetAddressType).
+var inetAddressTypeNames = map[uint32]string{
+	1: "ipv4",
+	2: "ipv6",
+}
+
 // A AddrType is an AddrType TLV.
 type AddrType struct {
 	TLV
-	portIndex int8
+	portIndex int
 }
 
 // Name returns the type name of an AddrType TLV.
 func (t *AddrType) Name() string { return "AddrType" }
 
+// Kind returns the encoding of the value of an AddrType TLV.
+func (t *AddrType) Kind() ValueKind { return KindUint32 }
+
+// Enum returns the names of the values of an AddrType TLV.
+func (t *AddrType) Enum() map[uint32]string { return inetAddressTypeNames }
+
 // Val returns the value an AddrType carries.
 func (t *AddrType) Val() interface{} {
 	if len(t.Value) != 4 {
 		return fmt.Errorf("unexpected lenght: %v, want: 4", len(t.Value))
 	}
-	var s string
-	switch int(t.Value[3]) {
-	case 1:
-		s = "ipv4"
-	case 2:
-		s = "ipv6"
-	default:
+	s, ok := inetAddressTypeNames[binary.BigEndian.Uint32(t.Value)]
+	if !ok {
 		s = "Unknown InetAddressType"
 	}
 	t.parentMsg.REX.Sequence.RpdInfo.IPAddress[t.portIndex].AddrType = s
@@ -625,12 +681,15 @@ func (t *AddrType) Val() interface{} {
 // A IPAddr is an IpAddress TLV.
 type IPAddr struct {
 	TLV
-	portIndex int8
+	portIndex int
 }
 
 // Name returns the type name of an IpAddress TLV.
 func (t *IPAddr) Name() string { return "IpAddress" }
 
+// Kind returns the encoding of the value of an IpAddress TLV.
+func (t *IPAddr) Kind() ValueKind { return KindIP }
+
 // Val returns the value an IpAddress TLV carries.
 func (t *IPAddr) Val() interface{} {
 	s := ipVal(t.Value)
@@ -641,12 +700,15 @@ func (t *IPAddr) Val() interface{} {
 // A PortIdx is an EnetPortIndex TLV.
 type PortIdx struct {
 	TLV
-	portIndex int8
+	portIndex int
 }
 
 // Name returns the type name of an EnetPortIndex TLV.
 func (t *PortIdx) Name() string { return "EnetPortIndex" }
 
+// Kind returns the encoding of the value of an EnetPortIndex TLV.
+func (t *PortIdx) Kind() ValueKind { return KindUint8 }
+
 // Val returns the value an EnetPortIndex TLV carries.
 func (t *PortIdx) Val() interface{} {
 	s := u8Val(t.Value)
@@ -654,29 +716,35 @@ func (t *PortIdx) Val() interface{} {
 	return s
 }
 
+// ipAddressTypeNames are the names of the values of a Type TLV of an IpAddress.
+var ipAddressTypeNames = map[uint32]string{
+	1: "unicast",
+	2: "anycast",
+	3: "broadcast",
+}
+
 // A IntType is an Type TLV.
 type IntType struct {
 	TLV
-	portIndex int8
+	portIndex int
 }
 
 // Name returns the type name of a Type TLV.
 func (t *IntType) Name() string { return "Type" }
 
+// Kind returns the encoding of the value of a Type TLV.
+func (t *IntType) Kind() ValueKind { return KindUint8 }
+
+// Enum returns the names of the values of a Type TLV.
+func (t *IntType) Enum() map[uint32]string { return ipAddressTypeNames }
+
 // Val returns the value a Type carries.
 func (t *IntType) Val() interface{} {
 	if len(t.Value) != 1 {
 		return fmt.Errorf("unexpected lenght: %v, want: 1", len(t.Value))
 	}
-	var s string
-	switch int(t.Value[0]) {
-	case 1:
-		s = "unicast"
-	case 2:
-		s = "anycast"
-	case 3:
-		s = "broadcast"
-	default:
+	s, ok := ipAddressTypeNames[uint32(t.Value[0])]
+	if !ok {
 		s = "Unknown Type"
 	}
 	t.parentMsg.REX.Sequence.RpdInfo.IPAddress[t.portIndex].Type = s
@@ -686,12 +754,15 @@ func (t *IntType) Val() interface{} {
 // A PrefixLen is a PrefixLen TLV.
 type PrefixLen struct {
 	TLV
-	portIndex int8
+	portIndex int
 }
 
 // Name returns the type name of a PrefixLen TLV.
 func (t *PrefixLen) Name() string { return "PrefixLen" }
 
+// Kind returns the encoding of the value of a PrefixLen TLV.
+func (t *PrefixLen) Kind() ValueKind { return KindUint16 }
+
 // Val returns the value a PrefixLen TLV carries.
 func (t *PrefixLen) Val() interface{} {
 	s := u16Val(t.Value)
@@ -699,72 +770,77 @@ func (t *PrefixLen) Val() interface{} {
 	return s
 }
 
+// ipAddressOriginNames are the names of the values of an Origin TLV.
+var ipAddressOriginNames = map[uint32]string{
+	1: "other",
+	2: "manual",
+	3: "wellKnown",
+	4: "dhcp",
+	5: "routerAdv",
+}
+
 // A Origin is an OriginTLV.
 type Origin struct {
 	TLV
-	portIndex int8
+	portIndex int
 }
 
 // Name returns the type name of an Origin TLV.
 func (t *Origin) Name() string { return "Type" }
 
+// Kind returns the encoding of the value of an Origin TLV.
+func (t *Origin) Kind() ValueKind { return KindUint8 }
+
+// Enum returns the names of the values of an Origin TLV.
+func (t *Origin) Enum() map[uint32]string { return ipAddressOriginNames }
+
 // Val returns the value an Origin TLV carries.
 func (t *Origin) Val() interface{} {
 	if len(t.Value) != 1 {
 		return fmt.Errorf("unexpected lenght: %v, want: 1", len(t.Value))
 	}
-	var s string
-	switch int(t.Value[0]) {
-	case 1:
-		s = "other"
-	case 2:
-		s = "manual"
-	case 3:
-		s = "wellKnown"
-	case 4:
-		s = "dhcp"
-	case 5:
-		s = "routerAdv"
-	default:
+	s, ok := ipAddressOriginNames[uint32(t.Value[0])]
+	if !ok {
 		s = "Unknown Origin"
 	}
 	t.parentMsg.REX.Sequence.RpdInfo.IPAddress[t.portIndex].Origin = s
 	return s
 }
 
+// ipAddressStatusNames are the names of the values of a Status TLV of an IpAddress.
+var ipAddressStatusNames = map[uint32]string{
+	1: "preferred",
+	2: "deprecated",
+	3: "invalid",
+	4: "inaccessible",
+	5: "unknown",
+	6: "tentative",
+	7: "duplicate",
+	8: "optimistic",
+}
+
 // An IntStatus is a Status TLV.
 type IntStatus struct {
 	TLV
-	portIndex int8
+	portIndex int
 }
 
 // Name returns the type name of a Status TLV.
 func (t *IntStatus) Name() string { return "Status" }
 
+// Kind returns the encoding of the value of a Status TLV.
+func (t *IntStatus) Kind() ValueKind { return KindUint8 }
+
+// Enum returns the names of the values of a Status TLV.
+func (t *IntStatus) Enum() map[uint32]string { return ipAddressStatusNames }
+
 // Val returns the value a Status carries.
 func (t *IntStatus) Val() interface{} {
 	if len(t.Value) != 1 {
 		return fmt.Errorf("unexpected lenght: %v, want: 1", len(t.Value))
 	}
-	var s string
-	switch int(t.Value[0]) {
-	case 1:
-		s = "preferred"
-	case 2:
-		s = "deprecated"
-	case 3:
-		s = "invalid"
-	case 4:
-		s = "inaccessible"
-	case 5:
-		s = "unknown"
-	case 6:
-		s = "tentative"
-	case 7:
-		s = "duplicate"
-	case 8:
-		s = "optimistic"
-	default:
+	s, ok := ipAddressStatusNames[uint32(t.Value[0])]
+	if !ok {
 		s = "Unknown Status"
 	}
 	t.parentMsg.REX.Sequence.RpdInfo.IPAddress[t.portIndex].Status = s
@@ -774,12 +850,15 @@ func (t *IntStatus) Val() interface{} {
 // A Created is a Created TLV.
 type Created struct {
 	TLV
-	portIndex int8
+	portIndex int
 }
 
 // Name returns the type name of a Created TLV.
 func (t *Created) Name() string { return "Created" }
 
+// Kind returns the encoding of the value of a Created TLV.
+func (t *Created) Kind() ValueKind { return KindTimeTicks }
+
 // Val returns the value a Created TLV carries.
 func (t *Created) Val() interface{} {
 	s := timeVal(t.Value)
@@ -790,12 +869,15 @@ func (t *Created) Val() interface{} {
 // A LastChanged is a LastChanged TLV.
 type LastChanged struct {
 	TLV
-	portIndex int8
+	portIndex int
 }
 
 // Name returns the type name of a LastChanged TLV.
 func (t *LastChanged) Name() string { return "LastChanged" }
 
+// Kind returns the encoding of the value of a LastChanged TLV.
+func (t *LastChanged) Kind() ValueKind { return KindTimeTicks }
+
 // Val returns the value a LastChanged TLV carries.
 func (t *LastChanged) Val() interface{} {
 	s := timeVal(t.Value)
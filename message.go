@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"sort"
 )
 
 // A Message represents a GCP message.
@@ -26,6 +27,7 @@ type MessageID uint8
 // Error messages
 var (
 	ErrMessageTooShort = errors.New("message too short")
+	ErrMessageOverflow = errors.New("message length exceeds the data left")
 	errMessageID       = errors.New("invalid message id")
 )
 
@@ -48,6 +50,42 @@ const (
 	MessageIDMWRErr    MessageID = 147 // Mask Write Register (MWR) Error Response
 )
 
+var messageNames = map[MessageID]string{
+	MessageIDNotifyReq: "Notify Request",
+	MessageIDNotifyRes: "Notify Response",
+	MessageIDGDMReq:    "GDM Request",
+	MessageIDGDMRes:    "GDM Response",
+	MessageIDEDSReq:    "EDS Request",
+	MessageIDEDSRes:    "EDS Response",
+	MessageIDEDRReq:    "EDR Request",
+	MessageIDEDRRes:    "EDR Response",
+	MessageIDMWRReq:    "MWR Request",
+	MessageIDMWRRes:    "MWR Response",
+	MessageIDNotifyErr: "Notify Error Response",
+	MessageIDGDMErr:    "GDM Error Response",
+	MessageIDEDSErr:    "EDS Error Response",
+	MessageIDEDRErr:    "EDR Error Response",
+	MessageIDMWRErr:    "MWR Error Response",
+}
+
+func (id MessageID) String() string {
+	if n, ok := messageNames[id]; ok {
+		return n
+	}
+	return fmt.Sprintf("MessageID(%d)", uint8(id))
+}
+
+// MessageIDs returns the Message Identifiers with a name, in increasing
+// order.
+func MessageIDs() []MessageID {
+	ids := make([]MessageID, 0, len(messageNames))
+	for id := range messageNames {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
 // A RtrnCode represents a Return Code of a GCP message.
 type RtrnCode int
 
@@ -69,6 +107,30 @@ const (
 	SlaveDevFail       RtrnCode = 255 // SLAVE DEVICE FAILURE
 )
 
+var rtrnCodeNames = map[RtrnCode]string{
+	MsgSuccess:         "MsgSuccess",
+	UnsupportedMsg:     "UnsupportedMsg",
+	IllegalMsgLen:      "IllegalMsgLen",
+	IllegalTransID:     "IllegalTransID",
+	IllegalMode:        "IllegalMode",
+	IllegalPort:        "IllegalPort",
+	IllegalChannel:     "IllegalChannel",
+	IllegalCmd:         "IllegalCmd",
+	IllegalVendorID:    "IllegalVendorID",
+	IllegalVendorIndex: "IllegalVendorIndex",
+	IllegalAddr:        "IllegalAddr",
+	IllegalDataValue:   "IllegalDataValue",
+	MsgFail:            "MsgFail",
+	SlaveDevFail:       "SlaveDevFail",
+}
+
+func (c RtrnCode) String() string {
+	if n, ok := rtrnCodeNames[c]; ok {
+		return n
+	}
+	return fmt.Sprintf("RtrnCode(%d)", int(c))
+}
+
 var parseFns = map[MessageID]func(MessageID, []byte) (MessageBody, error){
 	MessageIDNotifyReq: parseNotifyReq,
 	MessageIDGDMReq:    parseDMReq,
@@ -114,19 +176,37 @@ func parseRawBody(b []byte) (MessageBody, error) {
 	return p, nil
 }
 
-// ParseMessage parses b as a GCP message.
+// NewMessage returns a GCP message with identifier id carrying body.
+func NewMessage(id MessageID, body MessageBody) *Message {
+	return &Message{MessageID: uint8(id), Lenght: uint16(body.Len()), Body: body}
+}
+
+// ErrorResponse returns the Error Response to the request m with Return
+// Code rc: that of the Normal Response with the most significant bit set,
+// carrying the Transaction ID of m.
+func ErrorResponse(m *Message, rc RtrnCode) *Message {
+	return NewMessage((MessageID(m.MessageID)|1)+128, &NotifyErr{TransactionID: m.TransactionID(), RtrnCode: uint8(rc)})
+}
+
+// ParseMessage parses b as a GCP message. The body ends where its
+// Message Length says, or at the end of b if that is sooner; the bytes
+// of b that follow it are ignored.
 func ParseMessage(b []byte) (*Message, error) {
 	if len(b) < 3 {
 		return nil, ErrMessageTooShort
 	}
 	id := uint8(b[0])
 	mlen := binary.BigEndian.Uint16(b[1:3])
+	body := b[3:]
+	if end := 3 + int(mlen); end < len(b) {
+		body = b[3:end]
+	}
 	var err error
 	m := &Message{MessageID: id, Lenght: mlen}
 	if fn, ok := parseFns[MessageID(id)]; !ok {
-		m.Body, err = parseRawBody(b[3:])
+		m.Body, err = parseRawBody(body)
 	} else {
-		m.Body, err = fn(MessageID(id), b[3:])
+		m.Body, err = fn(MessageID(id), body)
 	}
 	if err != nil {
 		return nil, err
@@ -134,6 +214,31 @@ func ParseMessage(b []byte) (*Message, error) {
 	return m, nil
 }
 
+// ParseMessages parses b as one or more GCP messages laid back to back,
+// like the Message Field of a TCP encapsulation unit, using the Message
+// Length of each to find where the next one starts. It returns the
+// messages parsed before any error.
+func ParseMessages(b []byte) ([]*Message, error) {
+	var ms []*Message
+	for {
+		if len(b) < 3 {
+			return ms, ErrMessageTooShort
+		}
+		end := 3 + int(binary.BigEndian.Uint16(b[1:3]))
+		if end > len(b) {
+			return ms, ErrMessageOverflow
+		}
+		m, err := ParseMessage(b[:end])
+		if err != nil {
+			return ms, err
+		}
+		ms = append(ms, m)
+		if b = b[end:]; len(b) == 0 {
+			return ms, nil
+		}
+	}
+}
+
 // Marshal converts a GCP message into a byte array.
 func (p *Message) Marshal() ([]byte, error) {
 	bd, err := p.Body.Marshal()
@@ -1,11 +1,13 @@
 package gcp_test
 
 import (
+	"bytes"
 	"encoding/base64"
 	"fmt"
 	"testing"
 
 	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/hexdump"
 )
 
 var (
@@ -40,7 +42,7 @@ func TestParseMessage(t *testing.T) {
 			}
 			msg, err := gcp.ParseMessage(data)
 			if err != nil {
-				t.Fatalf("could not parse %s message: %v", tc.name, err)
+				t.Fatalf("could not parse %s message: %v\n%s", tc.name, err, hexdump.Message(data))
 			}
 			// output, g := msg.Body.Process()
 			_, g := msg.Body.Process()
@@ -325,3 +327,91 @@ func parseDM(g *gcp.GCP) error {
 	}
 	return nil
 }
+
+func TestMode(t *testing.T) {
+	tt := []struct {
+		name     string
+		msg      *gcp.Message
+		mode     gcp.Mode
+		ok       bool
+		required bool
+		str      string
+	}{
+		{name: "Response required", msg: gcp.NewMessage(gcp.MessageIDNotifyReq, &gcp.NotifyReq{Mode: 0}),
+			ok: true, required: true, str: "0 (response required)"},
+		{name: "No response required", msg: gcp.NewMessage(gcp.MessageIDEDSReq, &gcp.EDSReq{Mode: 0x80}),
+			mode: 0x80, ok: true, str: "128 (no response required)"},
+		{name: "Vendor specific", msg: gcp.NewMessage(gcp.MessageIDGDMReq, &gcp.DMReq{Mode: 0x41}),
+			mode: 0x41, ok: true, required: true, str: "65 (response required, vendor specific, reserved bits 0x01)"},
+		{name: "Raw body", msg: gcp.NewMessage(gcp.MessageIDEDSReq, &gcp.RawBody{Data: []byte{0, 1, 0xc0}}),
+			mode: 0xc0, ok: true, str: "192 (no response required, vendor specific)"},
+		{name: "No Mode", msg: gcp.NewMessage(gcp.MessageIDNotifyErr, &gcp.NotifyErr{}),
+			required: true, str: "0 (response required)"},
+	}
+	for _, tc := range tt {
+		m, ok := tc.msg.Mode()
+		if m != tc.mode || ok != tc.ok {
+			t.Errorf("%s: Mode got: %v, %v, want: %v, %v", tc.name, m, ok, tc.mode, tc.ok)
+		}
+		if got := m.ResponseRequired(); got != tc.required {
+			t.Errorf("%s: ResponseRequired got: %v, want: %v", tc.name, got, tc.required)
+		}
+		if got := m.String(); got != tc.str {
+			t.Errorf("%s: String got: %q, want: %q", tc.name, got, tc.str)
+		}
+	}
+}
+
+func TestParseMessages(t *testing.T) {
+	n, _ := base64.StdEncoding.DecodeString(ntf)
+	d, _ := base64.StdEncoding.DecodeString(dm)
+	join := func(bs ...[]byte) []byte {
+		var b []byte
+		for _, m := range bs {
+			b = append(b, m...)
+		}
+		return b
+	}
+	tt := []struct {
+		name string
+		b    []byte
+		ids  []gcp.MessageID
+		err  error
+	}{
+		{name: "Single message", b: n, ids: []gcp.MessageID{gcp.MessageIDNotifyReq}},
+		{name: "Two messages", b: join(n, d), ids: []gcp.MessageID{gcp.MessageIDNotifyReq, gcp.MessageIDGDMReq}},
+		{name: "Trailing bytes", b: join(d, n[:2]), ids: []gcp.MessageID{gcp.MessageIDGDMReq}, err: gcp.ErrMessageTooShort},
+		{name: "Truncated message", b: join(d, n[:len(n)-1]), ids: []gcp.MessageID{gcp.MessageIDGDMReq}, err: gcp.ErrMessageOverflow},
+		{name: "Empty", err: gcp.ErrMessageTooShort},
+	}
+	for _, tc := range tt {
+		ms, err := gcp.ParseMessages(tc.b)
+		if err != tc.err {
+			t.Errorf("%s: error got: %v, want: %v", tc.name, err, tc.err)
+		}
+		if len(ms) != len(tc.ids) {
+			t.Fatalf("%s: messages got: %d, want: %d", tc.name, len(ms), len(tc.ids))
+		}
+		for i, m := range ms {
+			if got := gcp.MessageID(m.MessageID); got != tc.ids[i] {
+				t.Errorf("%s: message %d got: %v, want: %v", tc.name, i, got, tc.ids[i])
+			}
+		}
+	}
+}
+
+func TestParseMessageTrailingBytes(t *testing.T) {
+	n, _ := base64.StdEncoding.DecodeString(ntf)
+	d, _ := base64.StdEncoding.DecodeString(dm)
+	m, err := gcp.ParseMessage(append(append([]byte(nil), n...), d...))
+	if err != nil {
+		t.Fatalf("ParseMessage failed: %v", err)
+	}
+	got, err := m.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if !bytes.Equal(got, n) {
+		t.Errorf("ParseMessage kept %d bytes, want the %d of the first message", len(got), len(n))
+	}
+}
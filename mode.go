@@ -0,0 +1,64 @@
+package gcp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// A Mode is the Mode field of a GCP message body.
+type Mode uint8
+
+// Mode bits.
+const (
+	ModeNoResponse Mode = 0x80 // The sender does not require a Normal Response
+	ModeVendor     Mode = 0x40 // The message uses a vendor specific mode
+	ModeReserved   Mode = 0x3f // Reserved bits, zero
+)
+
+// ResponseRequired reports whether the receiver of a request with Mode m
+// must answer it with a Normal Response. Error Responses are sent
+// regardless.
+func (m Mode) ResponseRequired() bool { return m&ModeNoResponse == 0 }
+
+// VendorSpecific reports whether Mode m is vendor specific.
+func (m Mode) VendorSpecific() bool { return m&ModeVendor != 0 }
+
+func (m Mode) String() string {
+	s := []string{"response required"}
+	if !m.ResponseRequired() {
+		s[0] = "no response required"
+	}
+	if m.VendorSpecific() {
+		s = append(s, "vendor specific")
+	}
+	if r := m & ModeReserved; r != 0 {
+		s = append(s, fmt.Sprintf("reserved bits %#02x", uint8(r)))
+	}
+	return fmt.Sprintf("%d (%s)", uint8(m), strings.Join(s, ", "))
+}
+
+// Mode returns the Mode of the body of m, and whether it has one.
+func (p *Message) Mode() (Mode, bool) {
+	for _, f := range BodyLayout(MessageID(p.MessageID)) {
+		if f.Name != "Mode" {
+			continue
+		}
+		b, err := p.Body.Marshal()
+		if err != nil || len(b) <= f.Offset {
+			return 0, false
+		}
+		return Mode(b[f.Offset]), true
+	}
+	return 0, false
+}
+
+// TransactionID returns the Transaction ID of the body of m, which all
+// GCP messages start with, or zero if the body is too short to carry one.
+func (p *Message) TransactionID() uint16 {
+	b, _ := p.Body.Marshal()
+	if len(b) < 2 {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b)
+}
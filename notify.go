@@ -9,7 +9,7 @@ import (
 // An NotifyReq represents a GCP Notify Request message body.
 type NotifyReq struct {
 	TransactionID uint16 // Transaction ID: 2 bytes
-	Mode          uint8  // Mode: 1 byte
+	Mode          Mode   // Mode: 1 byte
 	Status        uint8  // Status: 1 byte
 	EvntCode      uint32 // Event Code: 4 bytes
 	EvntData      []byte // Event Data: N bytes
@@ -76,7 +76,7 @@ func parseNotifyReq(_ MessageID, b []byte) (MessageBody, error) {
 	}
 	p := &NotifyReq{
 		TransactionID: binary.BigEndian.Uint16(b[:2]),
-		Mode:          uint8(b[2]),
+		Mode:          Mode(b[2]),
 		Status:        uint8(b[3]),
 		EvntCode:      binary.BigEndian.Uint32(b[4:8]),
 	}
@@ -90,7 +90,7 @@ func parseNotifyReq(_ MessageID, b []byte) (MessageBody, error) {
 // An NotifyRes represents a GCP Notify Response message body.
 type NotifyRes struct {
 	TransactionID uint16 // Transaction ID: 2 bytes
-	Mode          uint8  // Mode: 1 byte
+	Mode          Mode   // Mode: 1 byte
 	EvntCode      uint32 // Event Code: 4 bytes
 }
 
@@ -132,7 +132,7 @@ func parseNotifyRes(_ MessageID, b []byte) (MessageBody, error) {
 	}
 	p := &NotifyRes{
 		TransactionID: binary.BigEndian.Uint16(b[:2]),
-		Mode:          uint8(b[2]),
+		Mode:          Mode(b[2]),
 		EvntCode:      binary.BigEndian.Uint32(b[3:7]),
 	}
 	return p, nil
@@ -0,0 +1,225 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/transport"
+)
+
+// DefaultPort is the TCP port of GCP sessions.
+const DefaultPort = 8190
+
+// maxPending bounds the out-of-order segments buffered for a stream.
+const maxPending = 1024
+
+// Error messages
+var (
+	ErrTruncated  = errors.New("stream ends in the middle of a message")
+	errProtocolID = errors.New("unknown protocol identifier, skipping to the next segment")
+)
+
+// A Frame is a GCP message found in a capture, along with the TCP
+// encapsulation unit that carried it. A unit that batches several
+// messages yields a Frame for each.
+type Frame struct {
+	Time  time.Time            // Timestamp of the packet that completed the unit
+	Src   *net.TCPAddr         // Sender
+	Dst   *net.TCPAddr         // Receiver
+	TCP   transport.TCPmessage // Encapsulation unit
+	Index int                  // Position of the message in the unit
+	Raw   []byte               // GCP message, out of the Message Field of the unit
+	Msg   *gcp.Message         // GCP message, nil if it could not be parsed
+	Text  string               // Decoded message body, as printed by Process
+	Data  *gcp.GCP             // Decoded RCP TLVs
+	Err   error                // Reason the message could not be decoded
+}
+
+// A Decoder extracts the GCP messages exchanged in a capture.
+type Decoder struct {
+	Port int // TCP port of GCP sessions, DefaultPort if zero
+}
+
+// Decode calls fn for each GCP message found in the capture read from r,
+// in the order in which the messages were completed on the wire. It
+// stops at the first error returned by fn.
+func Decode(r io.Reader, fn func(*Frame) error) error {
+	return Decoder{}.Decode(r, fn)
+}
+
+// Decode calls fn for each GCP message found in the capture read from r,
+// in the order in which the messages were completed on the wire. It
+// stops at the first error returned by fn.
+func (d Decoder) Decode(r io.Reader, fn func(*Frame) error) error {
+	rd, err := NewReader(r)
+	if err != nil {
+		return err
+	}
+	port := d.Port
+	if port == 0 {
+		port = DefaultPort
+	}
+	flows := make(map[string]*stream)
+	for {
+		p, err := rd.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// Keep what was decoded from a capture cut short.
+			if ferr := flush(flows, fn); ferr != nil {
+				return ferr
+			}
+			return err
+		}
+		seg, ok := decodePacket(p)
+		if !ok || (seg.src.Port != port && seg.dst.Port != port) {
+			continue
+		}
+		key := seg.src.String() + ">" + seg.dst.String()
+		s := flows[key]
+		if s == nil || seg.flags&tcpSYN != 0 {
+			s = &stream{src: seg.src, dst: seg.dst}
+			flows[key] = s
+		}
+		if err := s.add(p.Time, seg, fn); err != nil {
+			return err
+		}
+		if seg.flags&(tcpFIN|tcpRST) != 0 {
+			delete(flows, key)
+			if err := s.close(p.Time, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return flush(flows, fn)
+}
+
+// flush reports the messages left incomplete in flows.
+func flush(flows map[string]*stream, fn func(*Frame) error) error {
+	for k, s := range flows {
+		delete(flows, k)
+		if err := s.close(s.last, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A stream reassembles one direction of a TCP connection.
+type stream struct {
+	src, dst *net.TCPAddr
+	synced   bool
+	next     uint32            // Next sequence number expected
+	pending  map[uint32][]byte // Out-of-order segments by sequence number
+	buf      []byte            // Bytes received and not yet framed
+	last     time.Time
+}
+
+// add adds a segment to the stream, calling fn for each GCP message it
+// completes.
+func (s *stream) add(t time.Time, seg segment, fn func(*Frame) error) error {
+	s.last = t
+	if seg.flags&tcpSYN != 0 {
+		s.synced, s.next = true, seg.seq+1
+		return nil
+	}
+	if len(seg.payload) == 0 {
+		return nil
+	}
+	if !s.synced {
+		// Capture started after the handshake.
+		s.synced, s.next = true, seg.seq
+	}
+	if d := int32(seg.seq - s.next); d > 0 {
+		if s.pending == nil {
+			s.pending = make(map[uint32][]byte)
+		}
+		if len(s.pending) < maxPending {
+			s.pending[seg.seq] = seg.payload
+		}
+		return nil
+	}
+	s.append(seg.seq, seg.payload)
+	// Segments that were waiting for this one.
+	for found := true; found; {
+		found = false
+		for seq, b := range s.pending {
+			if int32(seq-s.next) <= 0 {
+				delete(s.pending, seq)
+				s.append(seq, b)
+				found = true
+			}
+		}
+	}
+	return s.frames(t, fn)
+}
+
+// append adds the bytes of b, which starts at sequence number seq, that
+// are beyond the next expected one.
+func (s *stream) append(seq uint32, b []byte) {
+	// Retransmitted bytes.
+	d := int(s.next - seq)
+	if d >= len(b) {
+		return
+	}
+	s.buf = append(s.buf, b[d:]...)
+	s.next += uint32(len(b) - d)
+}
+
+// frames calls fn for each GCP message of the complete encapsulation
+// units in the buffer.
+func (s *stream) frames(t time.Time, fn func(*Frame) error) error {
+	for len(s.buf) >= 6 {
+		l := int(binary.BigEndian.Uint16(s.buf[4:6]))
+		if binary.BigEndian.Uint16(s.buf[2:4]) != 1 || l < 1 {
+			// Not a unit boundary, most likely because the capture
+			// started in the middle of a message.
+			s.buf = s.buf[:0]
+			return fn(&Frame{Time: t, Src: s.src, Dst: s.dst, Err: errProtocolID})
+		}
+		if len(s.buf) < 6+l {
+			return nil
+		}
+		u, _ := transport.UnMarshal(s.buf[:6+l])
+		s.buf = append(s.buf[:0], s.buf[6+l:]...)
+		for _, f := range s.messages(t, u) {
+			if err := fn(f); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// messages returns a Frame for each GCP message in the unit u, and one
+// for the bytes that follow the last message that could be parsed.
+func (s *stream) messages(t time.Time, u transport.TCPmessage) []*Frame {
+	ms, err := gcp.ParseMessages(u.Msg)
+	fs := make([]*Frame, 0, len(ms)+1)
+	b := u.Msg
+	for i, m := range ms {
+		n := 3 + int(m.Lenght)
+		f := &Frame{Time: t, Src: s.src, Dst: s.dst, TCP: u, Index: i, Raw: b[:n], Msg: m}
+		f.Text, f.Data = m.Body.Process()
+		fs = append(fs, f)
+		b = b[n:]
+	}
+	if err != nil {
+		fs = append(fs, &Frame{Time: t, Src: s.src, Dst: s.dst, TCP: u, Index: len(ms), Raw: b, Err: err})
+	}
+	return fs
+}
+
+// close reports a message left incomplete when the stream ends.
+func (s *stream) close(t time.Time, fn func(*Frame) error) error {
+	if len(s.buf) == 0 && len(s.pending) == 0 {
+		return nil
+	}
+	s.buf, s.pending = nil, nil
+	return fn(&Frame{Time: t, Src: s.src, Dst: s.dst, Err: ErrTruncated})
+}
@@ -0,0 +1,216 @@
+package pcap_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/pcap"
+)
+
+var (
+	ntf = "AgFqAAHAAQAAAAEDAV8JAVwKAAIAAQsAAQIyAUkTASUBAAVDaXNjbwIAAgAJAwAIUlBIWS1SUEQEAAag+ElvQxwFAAR2Ni40BgBvUHJpbWFyeTogVS1Cb290IDIwMTYuMDEgKEp1bCAzMSAyMDE3IC0gMDk6NTQ6NTEgKzA4MDApICo7R29sZGVuOiBVLUJvb3QgMjAxNi4wMSAoQXByIDEyIDIwMTcgLSAwOToxMzoyOCArMDgwMCk7BwADUlBECAADUlBECQALQ0FUMjEzM0UwQTUKAAIRPQsACEJDTTMxNjEwDAADVjExDQAIMDAwMDAwMDAOAAMxLjAPAAYxLjAuMTAQAAMxLjARAAASAAATAAgH4wQCEjIqBRQAEFJQRC1WNi00Lml0Yi5TU0EVABAgAQV4EAAREQAAAAAAAAJFFgABABgAHgEAAk5BAgAJKzAwMDAwMC4wAwAKKzAwMDAwMDAuMFYABAEAAQE="
+	dm  = "BAAIAAEAAAAAAAA="
+)
+
+// A capture is the list of packets written to a test file.
+type capture struct {
+	v6    bool
+	start time.Time
+	pkts  [][]byte
+}
+
+func decode64(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatalf("could not decode base64 message: %v", err)
+	}
+	return b
+}
+
+// unit encapsulates a GCP message for TCP.
+func unit(tid uint16, m []byte) []byte {
+	b := make([]byte, 7, 7+len(m))
+	binary.BigEndian.PutUint16(b[0:2], tid)
+	binary.BigEndian.PutUint16(b[2:4], 1)
+	binary.BigEndian.PutUint16(b[4:6], uint16(1+len(m)))
+	return append(b, m...)
+}
+
+// segment adds a TCP segment from the RPD (port 50000) to the Core (port
+// 8190), or the other way around.
+func (c *capture) segment(toCore bool, seq uint32, flags byte, payload []byte) {
+	tcp := make([]byte, 20, 20+len(payload))
+	sp, dp := uint16(50000), uint16(8190)
+	if !toCore {
+		sp, dp = dp, sp
+	}
+	binary.BigEndian.PutUint16(tcp[0:2], sp)
+	binary.BigEndian.PutUint16(tcp[2:4], dp)
+	binary.BigEndian.PutUint32(tcp[4:8], seq)
+	tcp[12], tcp[13] = 5<<4, flags
+	tcp = append(tcp, payload...)
+
+	src, dst := []byte{10, 0, 0, 1}, []byte{10, 0, 0, 2}
+	var ip []byte
+	if c.v6 {
+		src, dst = net.ParseIP("2001::1"), net.ParseIP("2001::2")
+		ip = make([]byte, 40)
+		ip[0] = 6 << 4
+		binary.BigEndian.PutUint16(ip[4:6], uint16(len(tcp)))
+		ip[6], ip[7] = 6, 64
+		copy(ip[8:24], src)
+		copy(ip[24:40], dst)
+	} else {
+		ip = make([]byte, 20)
+		ip[0], ip[8], ip[9] = 0x45, 64, 6
+		binary.BigEndian.PutUint16(ip[2:4], uint16(20+len(tcp)))
+		copy(ip[12:16], src)
+		copy(ip[16:20], dst)
+	}
+	if !toCore {
+		copy(ip[len(ip)-2*len(src):], dst)
+		copy(ip[len(ip)-len(src):], src)
+	}
+	c.pkts = append(c.pkts, append(ip, tcp...))
+}
+
+func (c *capture) ts(i int) time.Time {
+	return c.start.Add(time.Duration(i) * 1500 * time.Microsecond)
+}
+
+// pcap returns the capture as a little-endian pcap file of Ethernet frames.
+func (c *capture) pcap() []byte {
+	var b bytes.Buffer
+	h := []uint32{0xa1b2c3d4, 0x00040002, 0, 0, 65535, pcap.LinkTypeEthernet}
+	binary.Write(&b, binary.LittleEndian, h)
+	for i, p := range c.pkts {
+		eth := make([]byte, 14)
+		// Add a VLAN tag to test tag skipping.
+		binary.BigEndian.PutUint16(eth[12:14], 0x8100)
+		eth = append(eth, 0, 10, 0x08, 0x00)
+		frame := append(eth, p...)
+		ts := c.ts(i)
+		binary.Write(&b, binary.LittleEndian, []uint32{
+			uint32(ts.Unix()), uint32(ts.Nanosecond() / 1000), uint32(len(frame)), uint32(len(frame)),
+		})
+		b.Write(frame)
+	}
+	return b.Bytes()
+}
+
+// pcapng returns the capture as a big-endian pcapng file of raw IP
+// packets with nanosecond timestamps.
+func (c *capture) pcapng() []byte {
+	var b bytes.Buffer
+	block := func(typ uint32, body []byte) {
+		for len(body)%4 != 0 {
+			body = append(body, 0)
+		}
+		binary.Write(&b, binary.BigEndian, []uint32{typ, uint32(12 + len(body))})
+		b.Write(body)
+		binary.Write(&b, binary.BigEndian, uint32(12+len(body)))
+	}
+	block(0x0a0d0d0a, []byte{0x1a, 0x2b, 0x3c, 0x4d, 0, 1, 0, 0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	// Interface with if_tsresol = 9.
+	block(1, []byte{0, pcap.LinkTypeRaw, 0, 0, 0, 0, 0xff, 0xff, 0, 9, 0, 1, 9, 0, 0, 0, 0, 0, 0, 0})
+	for i, p := range c.pkts {
+		ts := uint64(c.ts(i).UnixNano())
+		body := make([]byte, 20, 20+len(p))
+		binary.BigEndian.PutUint32(body[4:8], uint32(ts>>32))
+		binary.BigEndian.PutUint32(body[8:12], uint32(ts))
+		binary.BigEndian.PutUint32(body[12:16], uint32(len(p)))
+		binary.BigEndian.PutUint32(body[16:20], uint32(len(p)))
+		block(6, append(body, p...))
+	}
+	return b.Bytes()
+}
+
+// session returns a capture of an RPD sending a Notify split across two
+// segments, the Core answering with a GDM, and the RPD starting another
+// Notify it never completes. The RPD retransmits the first half of the
+// Notify, and the second half of the Notify is captured before the first.
+func session(t *testing.T, v6 bool) *capture {
+	n, d := unit(1, decode64(t, ntf)), unit(2, decode64(t, dm))
+	c := &capture{v6: v6, start: time.Date(2019, 4, 2, 18, 50, 42, 0, time.UTC)}
+	c.segment(true, 999, 0x02, nil)
+	c.segment(false, 4999, 0x12, nil)
+	c.segment(true, 1000+100, 0x18, n[100:])
+	c.segment(true, 1000, 0x18, n[:100])
+	c.segment(true, 1000, 0x18, n[:100])
+	c.segment(false, 5000, 0x18, d)
+	c.segment(true, 1000+uint32(len(n)), 0x18, n[:50])
+	return c
+}
+
+func TestDecode(t *testing.T) {
+	tt := []struct {
+		name string
+		v6   bool
+		file func(*capture) []byte
+	}{
+		{name: "pcap", file: (*capture).pcap},
+		{name: "pcapng", v6: true, file: (*capture).pcapng},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			c := session(t, tc.v6)
+			var frames []*pcap.Frame
+			err := pcap.Decode(bytes.NewReader(tc.file(c)), func(f *pcap.Frame) error {
+				frames = append(frames, f)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("could not decode capture: %v", err)
+			}
+			if len(frames) != 3 {
+				t.Fatalf("Frames got: %d, want: 3", len(frames))
+			}
+
+			f := frames[0]
+			if f.Err != nil || f.Msg == nil || gcp.MessageID(f.Msg.MessageID) != gcp.MessageIDNotifyReq {
+				t.Fatalf("Notify got: %+v", f)
+			}
+			if !f.Time.Equal(c.ts(3)) {
+				t.Errorf("Time got: %v, want: %v", f.Time, c.ts(3))
+			}
+			if f.Dst.Port != pcap.DefaultPort || f.TCP.TranID != 1 {
+				t.Errorf("Frame got: %v -> %v (Transaction ID %d)", f.Src, f.Dst, f.TCP.TranID)
+			}
+			if f.Data == nil || f.Data.NTF == nil {
+				t.Errorf("Notify RCP data missing")
+			}
+
+			f = frames[1]
+			if f.Err != nil || gcp.MessageID(f.Msg.MessageID) != gcp.MessageIDGDMReq || f.Src.Port != pcap.DefaultPort {
+				t.Errorf("GDM got: %+v", f)
+			}
+
+			if f = frames[2]; f.Err != pcap.ErrTruncated {
+				t.Errorf("Error got: %v, want: %v", f.Err, pcap.ErrTruncated)
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	if err := pcap.Decode(bytes.NewReader([]byte("not a capture file")), nil); err != pcap.ErrUnknownFormat {
+		t.Errorf("Decode got: %v, want: %v", err, pcap.ErrUnknownFormat)
+	}
+
+	// Traffic on another port is ignored.
+	c := session(t, false)
+	n := 0
+	err := pcap.Decoder{Port: 8191}.Decode(bytes.NewReader(c.pcap()), func(*pcap.Frame) error {
+		n++
+		return nil
+	})
+	if err != nil || n != 0 {
+		t.Errorf("Decode got: %d frames (%v), want: 0", n, err)
+	}
+}
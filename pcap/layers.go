@@ -0,0 +1,157 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"net"
+)
+
+// EtherTypes and IP protocol numbers of interest.
+const (
+	etherTypeIPv4 = 0x0800
+	etherTypeIPv6 = 0x86dd
+	etherTypeVLAN = 0x8100
+	etherTypeQinQ = 0x88a8
+	ipProtoTCP    = 6
+)
+
+// TCP flags.
+const (
+	tcpFIN = 0x01
+	tcpSYN = 0x02
+	tcpRST = 0x04
+)
+
+// A segment is a TCP segment decoded from a packet.
+type segment struct {
+	src, dst *net.TCPAddr
+	seq      uint32
+	flags    uint8
+	payload  []byte
+}
+
+// decodePacket extracts the TCP segment carried in p. It reports false
+// for packets that are not unfragmented TCP over IPv4 or IPv6.
+func decodePacket(p Packet) (segment, bool) {
+	b := p.Data
+	var etype uint16
+	switch p.LinkType {
+	case LinkTypeEthernet:
+		if len(b) < 14 {
+			return segment{}, false
+		}
+		etype, b = binary.BigEndian.Uint16(b[12:14]), b[14:]
+		// 802.1Q and 802.1ad tags.
+		for (etype == etherTypeVLAN || etype == etherTypeQinQ) && len(b) >= 4 {
+			etype, b = binary.BigEndian.Uint16(b[2:4]), b[4:]
+		}
+	case LinkTypeNull:
+		if len(b) < 4 {
+			return segment{}, false
+		}
+		// Address family in host byte order; take the IP version from
+		// the packet instead.
+		b = b[4:]
+	case LinkTypeLinuxSLL:
+		if len(b) < 16 {
+			return segment{}, false
+		}
+		etype, b = binary.BigEndian.Uint16(b[14:16]), b[16:]
+	case LinkTypeSLL2:
+		if len(b) < 20 {
+			return segment{}, false
+		}
+		etype, b = binary.BigEndian.Uint16(b[0:2]), b[20:]
+	case LinkTypeRaw, linkTypeRawAlt, LinkTypeIPv4, LinkTypeIPv6:
+	default:
+		return segment{}, false
+	}
+	if etype == 0 && len(b) > 0 {
+		switch b[0] >> 4 {
+		case 4:
+			etype = etherTypeIPv4
+		case 6:
+			etype = etherTypeIPv6
+		}
+	}
+	var (
+		src, dst net.IP
+		ok       bool
+	)
+	switch etype {
+	case etherTypeIPv4:
+		src, dst, b, ok = decodeIPv4(b)
+	case etherTypeIPv6:
+		src, dst, b, ok = decodeIPv6(b)
+	}
+	if !ok {
+		return segment{}, false
+	}
+	return decodeTCP(src, dst, b)
+}
+
+// decodeIPv4 returns the addresses and the TCP payload of an IPv4 packet.
+func decodeIPv4(b []byte) (src, dst net.IP, payload []byte, ok bool) {
+	if len(b) < 20 || b[0]>>4 != 4 {
+		return nil, nil, nil, false
+	}
+	hl := int(b[0]&0x0f) * 4
+	tl := int(binary.BigEndian.Uint16(b[2:4]))
+	if hl < 20 || tl < hl || tl > len(b) {
+		return nil, nil, nil, false
+	}
+	// Fragments are not reassembled: MF set or a non-zero offset.
+	if binary.BigEndian.Uint16(b[6:8])&0x3fff != 0 || b[9] != ipProtoTCP {
+		return nil, nil, nil, false
+	}
+	return net.IP(b[12:16]), net.IP(b[16:20]), b[hl:tl], true
+}
+
+// decodeIPv6 returns the addresses and the TCP payload of an IPv6 packet,
+// skipping extension headers.
+func decodeIPv6(b []byte) (src, dst net.IP, payload []byte, ok bool) {
+	if len(b) < 40 || b[0]>>4 != 6 {
+		return nil, nil, nil, false
+	}
+	pl := int(binary.BigEndian.Uint16(b[4:6]))
+	if 40+pl > len(b) {
+		return nil, nil, nil, false
+	}
+	next, p := b[6], b[40:40+pl]
+	for {
+		switch next {
+		case ipProtoTCP:
+			return net.IP(b[8:24]), net.IP(b[24:40]), p, true
+		// Hop-by-Hop, Routing and Destination Options.
+		case 0, 43, 60:
+			if len(p) < 8 {
+				return nil, nil, nil, false
+			}
+			l := (int(p[1]) + 1) * 8
+			if l > len(p) {
+				return nil, nil, nil, false
+			}
+			next, p = p[0], p[l:]
+		default:
+			// Fragments included.
+			return nil, nil, nil, false
+		}
+	}
+}
+
+// decodeTCP decodes the TCP segment in b.
+func decodeTCP(src, dst net.IP, b []byte) (segment, bool) {
+	if len(b) < 20 {
+		return segment{}, false
+	}
+	off := int(b[12]>>4) * 4
+	if off < 20 || off > len(b) {
+		return segment{}, false
+	}
+	return segment{
+		src:     &net.TCPAddr{IP: src, Port: int(binary.BigEndian.Uint16(b[0:2]))},
+		dst:     &net.TCPAddr{IP: dst, Port: int(binary.BigEndian.Uint16(b[2:4]))},
+		seq:     binary.BigEndian.Uint32(b[4:8]),
+		flags:   b[13],
+		payload: b[off:],
+	}, true
+}
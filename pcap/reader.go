@@ -0,0 +1,282 @@
+// Package pcap reads and writes packet captures of GCP traffic.
+//
+// It understands the classic pcap and the pcapng file formats, decodes
+// the Ethernet, IP and TCP layers of each packet, reassembles the TCP
+// streams of GCP sessions and extracts the GCP messages they carry. A
+// Recorder writes the messages exchanged by the transport package to a
+// pcapng file. It is written in pure Go and does not depend on libpcap.
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"time"
+)
+
+// Link types, as assigned by tcpdump.org.
+const (
+	LinkTypeNull     = 0   // BSD loopback
+	LinkTypeEthernet = 1   // IEEE 802.3 Ethernet
+	LinkTypeRaw      = 101 // Raw IPv4 or IPv6
+	LinkTypeLinuxSLL = 113 // Linux cooked capture v1
+	LinkTypeIPv4     = 228 // Raw IPv4
+	LinkTypeIPv6     = 229 // Raw IPv6
+	LinkTypeSLL2     = 276 // Linux cooked capture v2
+
+	linkTypeRawAlt = 12 // Raw IP on some BSDs
+)
+
+// File format magic numbers.
+const (
+	magicMicro  = 0xa1b2c3d4 // pcap, microsecond timestamps
+	magicNano   = 0xa1b23c4d // pcap, nanosecond timestamps
+	blockSHB    = 0x0a0d0d0a // pcapng Section Header Block
+	blockIDB    = 0x00000001 // pcapng Interface Description Block
+	blockOPB    = 0x00000002 // pcapng Packet Block (obsolete)
+	blockSPB    = 0x00000003 // pcapng Simple Packet Block
+	blockEPB    = 0x00000006 // pcapng Enhanced Packet Block
+	byteOrderNG = 0x1a2b3c4d // pcapng Byte-Order Magic
+)
+
+// maxBlock bounds the size of records and blocks accepted from a file.
+const maxBlock = 1 << 24
+
+// Error messages
+var (
+	ErrUnknownFormat = errors.New("not a pcap or pcapng file")
+	errBadBlock      = errors.New("malformed pcapng block")
+	errBadInterface  = errors.New("packet references an unknown interface")
+	errRecordTooLong = errors.New("record too long")
+)
+
+// A Packet is a packet read from a capture file.
+type Packet struct {
+	Time     time.Time // Capture timestamp
+	LinkType int       // Link type of the capturing interface
+	Data     []byte    // Captured bytes, starting at the link layer
+}
+
+// An iface is a pcapng capturing interface.
+type iface struct {
+	linkType int
+	// Timestamp units per second.
+	tsres uint64
+}
+
+// A Reader reads packets from a pcap or a pcapng file.
+type Reader struct {
+	r  io.Reader
+	bo binary.ByteOrder
+	ng bool
+
+	// pcap
+	linkType int
+	nano     bool
+
+	// pcapng
+	ifaces []iface
+}
+
+// NewReader returns a Reader for the capture in r, detecting its format.
+func NewReader(r io.Reader) (*Reader, error) {
+	h := make([]byte, 4)
+	if _, err := io.ReadFull(r, h); err != nil {
+		return nil, ErrUnknownFormat
+	}
+	rd := &Reader{r: r}
+	switch {
+	case binary.BigEndian.Uint32(h) == blockSHB:
+		rd.ng = true
+		if err := rd.readSHB(); err != nil {
+			return nil, err
+		}
+		return rd, nil
+	case binary.LittleEndian.Uint32(h) == magicMicro:
+		rd.bo = binary.LittleEndian
+	case binary.BigEndian.Uint32(h) == magicMicro:
+		rd.bo = binary.BigEndian
+	case binary.LittleEndian.Uint32(h) == magicNano:
+		rd.bo, rd.nano = binary.LittleEndian, true
+	case binary.BigEndian.Uint32(h) == magicNano:
+		rd.bo, rd.nano = binary.BigEndian, true
+	default:
+		return nil, ErrUnknownFormat
+	}
+	// Rest of the global header: version, thiszone, sigfigs, snaplen
+	// and network.
+	g := make([]byte, 20)
+	if _, err := io.ReadFull(r, g); err != nil {
+		return nil, ErrUnknownFormat
+	}
+	rd.linkType = int(rd.bo.Uint32(g[16:20]) & 0x0fffffff)
+	return rd, nil
+}
+
+// Next returns the next packet in the capture, or io.EOF at its end.
+func (rd *Reader) Next() (Packet, error) {
+	if rd.ng {
+		return rd.nextNG()
+	}
+	h := make([]byte, 16)
+	if _, err := io.ReadFull(rd.r, h); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return Packet{}, err
+		}
+		return Packet{}, io.EOF
+	}
+	sec := rd.bo.Uint32(h[0:4])
+	frac := rd.bo.Uint32(h[4:8])
+	incl := rd.bo.Uint32(h[8:12])
+	if incl > maxBlock {
+		return Packet{}, errRecordTooLong
+	}
+	data := make([]byte, incl)
+	if _, err := io.ReadFull(rd.r, data); err != nil {
+		return Packet{}, io.ErrUnexpectedEOF
+	}
+	nsec := int64(frac) * 1000
+	if rd.nano {
+		nsec = int64(frac)
+	}
+	return Packet{Time: time.Unix(int64(sec), nsec).UTC(), LinkType: rd.linkType, Data: data}, nil
+}
+
+// readSHB reads the Section Header Block whose type was just read.
+func (rd *Reader) readSHB() error {
+	h := make([]byte, 8)
+	if _, err := io.ReadFull(rd.r, h); err != nil {
+		return ErrUnknownFormat
+	}
+	switch {
+	case binary.LittleEndian.Uint32(h[4:8]) == byteOrderNG:
+		rd.bo = binary.LittleEndian
+	case binary.BigEndian.Uint32(h[4:8]) == byteOrderNG:
+		rd.bo = binary.BigEndian
+	default:
+		return ErrUnknownFormat
+	}
+	l := rd.bo.Uint32(h[0:4])
+	if l < 28 || l > maxBlock || l%4 != 0 {
+		return errBadBlock
+	}
+	// Skip the rest of the block: version, section length, options
+	// and trailing length.
+	if _, err := io.CopyN(ioutil.Discard, rd.r, int64(l-12)); err != nil {
+		return errBadBlock
+	}
+	// Interfaces are scoped to their section.
+	rd.ifaces = nil
+	return nil
+}
+
+// nextNG returns the next packet of a pcapng file.
+func (rd *Reader) nextNG() (Packet, error) {
+	for {
+		h := make([]byte, 8)
+		if _, err := io.ReadFull(rd.r, h); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return Packet{}, err
+			}
+			return Packet{}, io.EOF
+		}
+		if binary.BigEndian.Uint32(h[0:4]) == blockSHB {
+			// The byte order may change from one section to the next.
+			rd.r = io.MultiReader(bytes.NewReader(h[4:8]), rd.r)
+			if err := rd.readSHB(); err != nil {
+				return Packet{}, err
+			}
+			continue
+		}
+		typ, l := rd.bo.Uint32(h[0:4]), rd.bo.Uint32(h[4:8])
+		if l < 12 || l > maxBlock || l%4 != 0 {
+			return Packet{}, errBadBlock
+		}
+		body := make([]byte, l-8)
+		if _, err := io.ReadFull(rd.r, body); err != nil {
+			return Packet{}, io.ErrUnexpectedEOF
+		}
+		// Drop the trailing Block Total Length.
+		body = body[:len(body)-4]
+		switch typ {
+		case blockIDB:
+			if err := rd.addInterface(body); err != nil {
+				return Packet{}, err
+			}
+		case blockEPB:
+			if len(body) < 20 {
+				return Packet{}, errBadBlock
+			}
+			return rd.packet(body, 20, rd.bo.Uint32(body[0:4]), rd.bo.Uint32(body[4:8]), rd.bo.Uint32(body[8:12]), rd.bo.Uint32(body[12:16]))
+		case blockOPB:
+			if len(body) < 20 {
+				return Packet{}, errBadBlock
+			}
+			return rd.packet(body, 20, uint32(rd.bo.Uint16(body[0:2])), rd.bo.Uint32(body[4:8]), rd.bo.Uint32(body[8:12]), rd.bo.Uint32(body[12:16]))
+		case blockSPB:
+			if len(body) < 4 || len(rd.ifaces) == 0 {
+				return Packet{}, errBadBlock
+			}
+			n := rd.bo.Uint32(body[0:4])
+			if int(n) > len(body)-4 {
+				n = uint32(len(body) - 4)
+			}
+			return Packet{LinkType: rd.ifaces[0].linkType, Data: body[4 : 4+n]}, nil
+		}
+		// Other blocks carry nothing GCP related.
+	}
+}
+
+// packet builds a Packet from an Enhanced or obsolete Packet Block.
+func (rd *Reader) packet(body []byte, off int, id, tsHigh, tsLow, capLen uint32) (Packet, error) {
+	if len(body) < off || int(capLen) > len(body)-off {
+		return Packet{}, errBadBlock
+	}
+	if int(id) >= len(rd.ifaces) {
+		return Packet{}, errBadInterface
+	}
+	ifc := rd.ifaces[id]
+	ts := uint64(tsHigh)<<32 | uint64(tsLow)
+	sec := ts / ifc.tsres
+	nsec := (ts % ifc.tsres) * 1e9 / ifc.tsres
+	return Packet{
+		Time:     time.Unix(int64(sec), int64(nsec)).UTC(),
+		LinkType: ifc.linkType,
+		Data:     body[off : off+int(capLen)],
+	}, nil
+}
+
+// addInterface records the interface described by an Interface
+// Description Block.
+func (rd *Reader) addInterface(body []byte) error {
+	if len(body) < 8 {
+		return errBadBlock
+	}
+	ifc := iface{linkType: int(rd.bo.Uint16(body[0:2])), tsres: 1e6}
+	// Options: code (2 bytes), length (2 bytes), value padded to 32 bits.
+	for opts := body[8:]; len(opts) >= 4; {
+		code, l := rd.bo.Uint16(opts[0:2]), int(rd.bo.Uint16(opts[2:4]))
+		if code == 0 || 4+l > len(opts) {
+			break
+		}
+		// if_tsresol
+		if code == 9 && l >= 1 {
+			v := opts[4]
+			if v&0x80 == 0 && v > 19 || v&0x7f > 63 {
+				return fmt.Errorf("%v: if_tsresol %d", errBadBlock, v)
+			}
+			if v&0x80 == 0 {
+				ifc.tsres = uint64(math.Pow10(int(v)))
+			} else {
+				ifc.tsres = 1 << (v & 0x7f)
+			}
+		}
+		opts = opts[4+(l+3)&^3:]
+	}
+	rd.ifaces = append(rd.ifaces, ifc)
+	return nil
+}
@@ -0,0 +1,244 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/transport"
+)
+
+// pcapng option codes.
+const (
+	optEnd      = 0
+	optComment  = 1
+	optUserAppl = 4 // shb_userappl
+	optTSResol  = 9 // if_tsresol
+)
+
+// A Writer writes packets to a pcapng file. Its methods may be called
+// concurrently.
+type Writer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriter starts a pcapng file on w, with a single capturing interface
+// of type linkType and nanosecond timestamps.
+func NewWriter(w io.Writer, linkType int) (*Writer, error) {
+	wr := &Writer{w: w}
+	shb := []byte{0x4d, 0x3c, 0x2b, 0x1a, 1, 0, 0, 0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	shb = appendOption(shb, optUserAppl, []byte("gcp-rphy"))
+	shb = appendOption(shb, optEnd, nil)
+	if err := wr.block(blockSHB, shb); err != nil {
+		return nil, err
+	}
+	idb := make([]byte, 8)
+	binary.LittleEndian.PutUint16(idb[0:2], uint16(linkType))
+	idb = appendOption(idb, optTSResol, []byte{9})
+	idb = appendOption(idb, optEnd, nil)
+	if err := wr.block(blockIDB, idb); err != nil {
+		return nil, err
+	}
+	return wr, nil
+}
+
+// WritePacket writes data, captured at t, with an optional comment.
+func (w *Writer) WritePacket(t time.Time, data []byte, comment string) error {
+	b := make([]byte, 20, 20+len(data)+len(comment)+16)
+	ts := uint64(t.UnixNano())
+	binary.LittleEndian.PutUint32(b[4:8], uint32(ts>>32))
+	binary.LittleEndian.PutUint32(b[8:12], uint32(ts))
+	binary.LittleEndian.PutUint32(b[12:16], uint32(len(data)))
+	binary.LittleEndian.PutUint32(b[16:20], uint32(len(data)))
+	b = append(b, data...)
+	b = pad(b)
+	if comment != "" {
+		b = appendOption(b, optComment, []byte(comment))
+		b = appendOption(b, optEnd, nil)
+	}
+	return w.block(blockEPB, b)
+}
+
+// block writes a block of type typ, in little-endian byte order.
+func (w *Writer) block(typ uint32, body []byte) error {
+	body = pad(body)
+	b := make([]byte, 8, 12+len(body))
+	binary.LittleEndian.PutUint32(b[0:4], typ)
+	binary.LittleEndian.PutUint32(b[4:8], uint32(12+len(body)))
+	b = append(b, body...)
+	b = append(b, b[4:8]...)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err := w.w.Write(b)
+	return err
+}
+
+// appendOption appends a pcapng option to b.
+func appendOption(b []byte, code uint16, v []byte) []byte {
+	var h [4]byte
+	binary.LittleEndian.PutUint16(h[0:2], code)
+	binary.LittleEndian.PutUint16(h[2:4], uint16(len(v)))
+	return pad(append(append(b, h[:]...), v...))
+}
+
+// pad pads b to 32 bits.
+func pad(b []byte) []byte {
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// A Recorder writes the GCP encapsulation units exchanged over TCP to a
+// pcapng file, so a session can be opened in Wireshark. It synthesizes
+// Ethernet, IP and TCP headers for each unit, keeping the sequence and
+// acknowledgement numbers of each connection consistent, and annotates
+// each packet with a comment telling which end sent it.
+//
+// Recorder satisfies the transport.Recorder interface; set it as the
+// Recorder of a TCPEnd, Dialer, Core or RPD.
+type Recorder struct {
+	w *Writer
+
+	mu    sync.Mutex
+	flows map[string]uint32 // Next sequence number of each direction
+	err   error
+}
+
+// NewRecorder returns a Recorder writing a pcapng file to w.
+func NewRecorder(w io.Writer) (*Recorder, error) {
+	wr, err := NewWriter(w, LinkTypeEthernet)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{w: wr, flows: make(map[string]uint32)}, nil
+}
+
+// Err returns the first error writing the file, if any.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Record implements the transport.Recorder interface.
+func (r *Recorder) Record(dir transport.Direction, local, remote net.Addr, b []byte) {
+	src, dst := tcpAddr(local), tcpAddr(remote)
+	if dir == transport.Received {
+		src, dst = dst, src
+	}
+	comment := fmt.Sprintf("%s by %s: %s", dir, local, describe(b))
+
+	r.mu.Lock()
+	// Sequence numbers start at 1, as if after a handshake with an
+	// initial sequence number of 0.
+	fwd, rev := src.String()+">"+dst.String(), dst.String()+">"+src.String()
+	seq, ack := r.flows[fwd]+1, r.flows[rev]+1
+	r.flows[fwd] += uint32(len(b))
+	r.mu.Unlock()
+
+	err := r.w.WritePacket(time.Now(), frame(src, dst, seq, ack, b), comment)
+	if err != nil {
+		r.mu.Lock()
+		if r.err == nil {
+			r.err = err
+		}
+		r.mu.Unlock()
+	}
+}
+
+// describe summarizes the GCP unit in b.
+func describe(b []byte) string {
+	p, err := transport.UnMarshal(b)
+	if err != nil {
+		return err.Error()
+	}
+	if len(p.Msg) == 0 {
+		return fmt.Sprintf("Transaction ID %d, no GCP message", p.TranID)
+	}
+	return fmt.Sprintf("Transaction ID %d, %v", p.TranID, gcp.MessageID(p.Msg[0]))
+}
+
+// tcpAddr returns a as a TCP address, making up one for other kinds of
+// addresses.
+func tcpAddr(a net.Addr) *net.TCPAddr {
+	if t, ok := a.(*net.TCPAddr); ok && t.IP != nil {
+		return t
+	}
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: DefaultPort}
+}
+
+// frame returns an Ethernet frame carrying payload in a TCP segment from
+// src to dst.
+func frame(src, dst *net.TCPAddr, seq, ack uint32, payload []byte) []byte {
+	tcp := make([]byte, 20, 20+len(payload))
+	binary.BigEndian.PutUint16(tcp[0:2], uint16(src.Port))
+	binary.BigEndian.PutUint16(tcp[2:4], uint16(dst.Port))
+	binary.BigEndian.PutUint32(tcp[4:8], seq)
+	binary.BigEndian.PutUint32(tcp[8:12], ack)
+	tcp[12] = 5 << 4
+	tcp[13] = 0x18 // PSH, ACK
+	binary.BigEndian.PutUint16(tcp[14:16], 0xffff)
+	tcp = append(tcp, payload...)
+
+	var ip []byte
+	etype := uint16(etherTypeIPv4)
+	if s4, d4 := src.IP.To4(), dst.IP.To4(); s4 != nil && d4 != nil {
+		ip = make([]byte, 20, 20+len(tcp))
+		ip[0], ip[8], ip[9] = 0x45, 64, ipProtoTCP
+		binary.BigEndian.PutUint16(ip[2:4], uint16(20+len(tcp)))
+		ip[6] = 0x40 // Don't Fragment
+		copy(ip[12:16], s4)
+		copy(ip[16:20], d4)
+		binary.BigEndian.PutUint16(ip[10:12], checksum(ip, 0))
+		binary.BigEndian.PutUint16(tcp[16:18], checksum(tcp, pseudoHeader(s4, d4, len(tcp))))
+	} else {
+		etype = etherTypeIPv6
+		s16, d16 := src.IP.To16(), dst.IP.To16()
+		ip = make([]byte, 40, 40+len(tcp))
+		ip[0] = 6 << 4
+		binary.BigEndian.PutUint16(ip[4:6], uint16(len(tcp)))
+		ip[6], ip[7] = ipProtoTCP, 64
+		copy(ip[8:24], s16)
+		copy(ip[24:40], d16)
+		binary.BigEndian.PutUint16(tcp[16:18], checksum(tcp, pseudoHeader(s16, d16, len(tcp))))
+	}
+
+	// Locally administered MAC addresses derived from the IP addresses.
+	eth := make([]byte, 14, 14+len(ip)+len(tcp))
+	eth[0], eth[6] = 0x02, 0x02
+	copy(eth[2:6], dst.IP.To16()[12:16])
+	copy(eth[8:12], src.IP.To16()[12:16])
+	binary.BigEndian.PutUint16(eth[12:14], etype)
+	return append(append(eth, ip...), tcp...)
+}
+
+// pseudoHeader returns the sum of the TCP pseudo-header fields.
+func pseudoHeader(src, dst net.IP, l int) uint32 {
+	var sum uint32
+	for _, a := range [][]byte{src, dst} {
+		for i := 0; i < len(a); i += 2 {
+			sum += uint32(a[i])<<8 | uint32(a[i+1])
+		}
+	}
+	return sum + ipProtoTCP + uint32(l)
+}
+
+// checksum returns the Internet checksum of b, starting from sum.
+func checksum(b []byte, sum uint32) uint16 {
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+	return ^uint16(sum)
+}
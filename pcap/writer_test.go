@@ -0,0 +1,182 @@
+package pcap_test
+
+import (
+	"bytes"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/pcap"
+	"github.com/nleiva/gcp-rphy/transport"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.Write(p)
+}
+
+func (b *syncBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte(nil), b.b.Bytes()...)
+}
+
+func TestRecorder(t *testing.T) {
+	var file syncBuffer
+	rec, err := pcap.NewRecorder(&file)
+	if err != nil {
+		t.Fatalf("could not start recording: %v", err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	core := &transport.Core{
+		Recorder: rec,
+		Handler: transport.HandlerFunc(func(s *transport.Session, r *transport.Request) {
+			n, ok := r.Msg.Body.(*gcp.NotifyReq)
+			if !ok {
+				return
+			}
+			s.Reply(r, gcp.NewMessage(gcp.MessageIDNotifyRes, &gcp.NotifyRes{TransactionID: n.TransactionID}))
+		}),
+	}
+	go core.Serve(l)
+	defer core.Close()
+
+	s, err := transport.Dial(l.Addr().String(), nil)
+	if err != nil {
+		t.Fatalf("could not connect: %v", err)
+	}
+	defer s.Close()
+	m, err := gcp.ParseMessage(decode64(t, ntf))
+	if err != nil {
+		t.Fatalf("could not parse notify message: %v", err)
+	}
+	// The captured notification does not require a response.
+	m.Body.(*gcp.NotifyReq).Mode = 0
+	if _, err := s.Request(m, 2*time.Second); err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+
+	// The response may reach the RPD before the core records it.
+	dec := pcap.Decoder{Port: l.Addr().(*net.TCPAddr).Port}
+	var frames []*pcap.Frame
+	for deadline := time.Now().Add(2 * time.Second); len(frames) < 2 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		frames = frames[:0]
+		err := dec.Decode(bytes.NewReader(file.Bytes()), func(f *pcap.Frame) error {
+			frames = append(frames, f)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("could not decode the recording: %v", err)
+		}
+	}
+	if err := rec.Err(); err != nil {
+		t.Fatalf("recording failed: %v", err)
+	}
+	want := []struct {
+		id  gcp.MessageID
+		src net.Addr
+	}{
+		{id: gcp.MessageIDNotifyReq, src: s.LocalAddr()},
+		{id: gcp.MessageIDNotifyRes, src: s.RemoteAddr()},
+	}
+	if len(frames) != len(want) {
+		t.Fatalf("Frames got: %d, want: %d", len(frames), len(want))
+	}
+	for i, w := range want {
+		f := frames[i]
+		if f.Msg == nil || gcp.MessageID(f.Msg.MessageID) != w.id || f.Src.String() != w.src.String() {
+			t.Errorf("Frame %d got: %v from %v (%v), want: %v from %v", i, f.Msg, f.Src, f.Err, w.id, w.src)
+		}
+		if f.TCP.TranID == 0 {
+			t.Errorf("Frame %d lost its Transaction ID", i)
+		}
+	}
+	for _, c := range []string{"received by " + s.RemoteAddr().String(), "sent by " + s.RemoteAddr().String()} {
+		if !bytes.Contains(file.Bytes(), []byte(c)) {
+			t.Errorf("comment %q missing from the recording", c)
+		}
+	}
+}
+
+func TestRecorderBatch(t *testing.T) {
+	var file syncBuffer
+	rec, err := pcap.NewRecorder(&file)
+	if err != nil {
+		t.Fatalf("could not start recording: %v", err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	core := &transport.Core{
+		Recorder: rec,
+		Handler:  transport.HandlerFunc(func(*transport.Session, *transport.Request) {}),
+	}
+	go core.Serve(l)
+	defer core.Close()
+
+	s, err := transport.Dial(l.Addr().String(), nil)
+	if err != nil {
+		t.Fatalf("could not connect: %v", err)
+	}
+	defer s.Close()
+	bt := &transport.Batcher{Session: s}
+	for tid := uint16(1); tid <= 2; tid++ {
+		m := gcp.NewMessage(gcp.MessageIDNotifyReq, &gcp.NotifyReq{
+			TransactionID: tid,
+			EvntData:      gcp.GeneralNotification(tid, gcp.StartUpNotification),
+		})
+		if err := bt.Send(m); err != nil {
+			t.Fatalf("could not send notification %d: %v", tid, err)
+		}
+	}
+	if err := bt.Flush(); err != nil {
+		t.Fatalf("could not send the unit: %v", err)
+	}
+
+	dec := pcap.Decoder{Port: l.Addr().(*net.TCPAddr).Port}
+	var frames []*pcap.Frame
+	for deadline := time.Now().Add(2 * time.Second); len(frames) < 2 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		frames = frames[:0]
+		err := dec.Decode(bytes.NewReader(file.Bytes()), func(f *pcap.Frame) error {
+			frames = append(frames, f)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("could not decode the recording: %v", err)
+		}
+	}
+	if len(frames) != 2 {
+		t.Fatalf("Frames got: %d, want: 2", len(frames))
+	}
+	for i, f := range frames {
+		if f.Err != nil || f.Msg == nil || f.Msg.TransactionID() != uint16(i+1) || f.Index != i {
+			t.Errorf("Frame %d got: %+v, want: Notify %d at index %d", i, f, i+1, i)
+			continue
+		}
+		if f.TCP.TranID != frames[0].TCP.TranID {
+			t.Errorf("Frame %d got Transaction ID %d, want the unit's %d", i, f.TCP.TranID, frames[0].TCP.TranID)
+		}
+		if len(f.Raw) != 3+int(f.Msg.Lenght) {
+			t.Errorf("Frame %d got %d bytes, want: %d", i, len(f.Raw), 3+f.Msg.Lenght)
+		}
+		if f.Data == nil || f.Data.NTF == nil || f.Data.NTF.Sequence.SequenceNumber != strconv.Itoa(i+1) {
+			t.Errorf("Frame %d RCP data got: %+v", i, f.Data)
+		}
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
-	"log"
 	"net"
 	"strconv"
 	"time"
@@ -24,6 +23,8 @@ type RCP interface {
 	Len() uint16
 	Val() interface{}
 	IsComplex() bool
+	Kind() ValueKind
+	Enum() map[uint32]string
 	marshal() ([]byte, error)
 	unmarshal(b []byte) error
 	// The output of parseTLVs could change to adapt to requirements (type struct?).
@@ -33,6 +34,56 @@ type RCP interface {
 // Error messages
 var (
 	ErrUnexpectedEOF = errors.New("unexpected EOF")
+	ErrTruncatedTLV  = errors.New("truncated TLV")
+	errFound         = errors.New("found") // Stops FindTLV
+)
+
+// RCP Top Level TLV types.
+const (
+	TypeIRA uint8 = 1 // Identification and Resource Advertising
+	TypeREX uint8 = 2 // RCP Object Exchange
+	TypeNTF uint8 = 3 // Notify
+)
+
+// An Operation represents the value of an Operation TLV.
+type Operation uint8
+
+// RCP Operation values.
+const (
+	OpRead                  Operation = 1
+	OpWrite                 Operation = 2
+	OpDelete                Operation = 3
+	OpReadResponse          Operation = 4
+	OpWriteResponse         Operation = 5
+	OpDeleteResponse        Operation = 6
+	OpAllocateWrite         Operation = 7
+	OpAllocateWriteResponse Operation = 8
+)
+
+// A ResponseCode represents the value of a ResponseCode TLV.
+type ResponseCode uint8
+
+// RCP Response Codes.
+const (
+	RespNoError              ResponseCode = 0
+	RespGeneralError         ResponseCode = 1
+	RespResponseTooBig       ResponseCode = 2
+	RespAttributeNotFound    ResponseCode = 3
+	RespBadIndex             ResponseCode = 4
+	RespWriteToReadOnly      ResponseCode = 5
+	RespInconsistentValue    ResponseCode = 6
+	RespWrongLength          ResponseCode = 7
+	RespWrongValue           ResponseCode = 8
+	RespResourceUnavailable  ResponseCode = 9
+	RespAuthorizationFailure ResponseCode = 10
+	RespAttributeMissing     ResponseCode = 11
+	RespAllocationFailure    ResponseCode = 12
+	RespAllocationNoOwner    ResponseCode = 13
+	RespErrorProcessingUCD   ResponseCode = 14
+	RespErrorProcessingOCD   ResponseCode = 15
+	RespErrorProcessingDPD   ResponseCode = 16
+	RespSessionIdInUse       ResponseCode = 17
+	RespDoesNotExist         ResponseCode = 18
 )
 
 // Name returns the name of the TLV Type.
@@ -44,6 +95,14 @@ func (t *TLV) Len() uint16 { return t.Length }
 // Val returns the value the TLV carries.
 func (t *TLV) Val() interface{} { return t.Value }
 
+// Kind returns the encoding of the value of the TLV, opaque unless the
+// type of TLV declares another.
+func (t *TLV) Kind() ValueKind { return KindBytes }
+
+// Enum returns the names of the values of the TLV, if it is an
+// enumerated integer.
+func (t *TLV) Enum() map[uint32]string { return nil }
+
 // DataStr returns the Message Data Structure.
 func (t *TLV) DataStr() *GCP { return t.parentMsg }
 
@@ -97,6 +156,9 @@ func (t *TLV) parseTLVs(b []byte) ([]RCP, error) {
 		}
 
 		tlv := t.newTLV(b[i])
+		if tlv == nil {
+			return nil, errUnsupported("RCP Top Level", b[i])
+		}
 
 		// Unmarshal at the current offset, up to the expected length.
 		if err := tlv.unmarshal(b[i : i+3+l]); err != nil {
@@ -139,7 +201,6 @@ func (t *TLV) newTLV(b byte) RCP {
 		r.parentMsg.NTF = new(dSeq)
 		return r
 	default:
-		log.Printf("RCP Top Level TLV type: %d not supported", int(b))
 		return nil
 	}
 }
@@ -162,6 +223,9 @@ func (t *IRA) parseTLVs(b []byte) ([]RCP, error) {
 		}
 
 		tlv := t.newTLV(b[i])
+		if tlv == nil {
+			return nil, errUnsupported("IRA", b[i])
+		}
 
 		// Unmarshal at the current offset, up to the expected length.
 		if err := tlv.unmarshal(b[i : i+3+l]); err != nil {
@@ -197,7 +261,6 @@ func (t *IRA) newTLV(b byte) RCP {
 		r.parentMsg = t.parentMsg
 		return r
 	default:
-		log.Printf("IRA TLV type: %d not supported", int(b))
 		return nil
 	}
 }
@@ -220,6 +283,9 @@ func (t *REX) parseTLVs(b []byte) ([]RCP, error) {
 		}
 
 		tlv := t.newTLV(b[i])
+		if tlv == nil {
+			return nil, errUnsupported("REX", b[i])
+		}
 
 		// Unmarshal at the current offset, up to the expected length.
 		if err := tlv.unmarshal(b[i : i+3+l]); err != nil {
@@ -253,7 +319,6 @@ func (t *REX) newTLV(b byte) RCP {
 		r.parentMsg = t.parentMsg
 		return r
 	default:
-		log.Printf("REX TLV type: %d not supported", int(b))
 		return nil
 	}
 }
@@ -276,6 +341,9 @@ func (t *NTF) parseTLVs(b []byte) ([]RCP, error) {
 		}
 
 		tlv := t.newTLV(b[i])
+		if tlv == nil {
+			return nil, errUnsupported("NTF", b[i])
+		}
 
 		// Unmarshal at the current offset, up to the expected length.
 		if err := tlv.unmarshal(b[i : i+3+l]); err != nil {
@@ -309,7 +377,6 @@ func (t *NTF) newTLV(b byte) RCP {
 		r.parentMsg = t.parentMsg
 		return r
 	default:
-		log.Printf("NTF TLV type: %d not supported", int(b))
 		return nil
 	}
 }
@@ -338,6 +405,9 @@ func (t *Seq) parseTLVs(b []byte) ([]RCP, error) {
 		}
 
 		tlv := t.newTLV(b[i])
+		if tlv == nil {
+			return nil, errUnsupported("Sequence", b[i])
+		}
 
 		// Unmarshal at the current offset, up to the expected length.
 		if err := tlv.unmarshal(b[i : i+3+l]); err != nil {
@@ -381,36 +451,83 @@ func (t *Seq) newTLV(b byte) RCP {
 		return r
 	case 25:
 		r := new(RpdRed)
-		t.parentMsg.IRA.Sequence.RpdRedirect = new(RpdR)
-		r.parentMsg = t.parentMsg
+		r.parentMsg = t.view()
+		if r.parentMsg.IRA.Sequence.RpdRedirect == nil {
+			r.parentMsg.IRA.Sequence.RpdRedirect = new(RpdR)
+		}
 		t.RedIndex++
 		// IPAddress index for its slice.
 		r.IPindex = t.RedIndex
 		return r
+	case 16:
+		r := new(RfChan)
+		r.parentMsg = t.view()
+		seq := &r.parentMsg.REX.Sequence
+		seq.RfChannel = append(seq.RfChannel, RfCh{})
+		r.chanIndex = len(seq.RfChannel) - 1
+		return r
+	case 17:
+		r := new(RfPrt)
+		r.parentMsg = t.view()
+		seq := &r.parentMsg.REX.Sequence
+		seq.RfPort = append(seq.RfPort, RfPt{})
+		r.portIndex = len(seq.RfPort) - 1
+		return r
 	case 50:
 		r := new(RpdCap)
-		t.parentMsg.NTF.Sequence.RpdCapabilities = new(RpdC)
-		r.parentMsg = t.parentMsg
+		r.parentMsg = t.view()
+		if r.parentMsg.NTF.Sequence.RpdCapabilities == nil {
+			r.parentMsg.NTF.Sequence.RpdCapabilities = new(RpdC)
+		}
+		return r
+	case 60:
+		r := new(CcapCoreID)
+		r.parentMsg = t.view()
+		seq := &r.parentMsg.REX.Sequence
+		seq.CcapCoreIdentification = append(seq.CcapCoreIdentification, CcapID{})
+		r.coreIndex = len(seq.CcapCoreIdentification) - 1
 		return r
 	case 86:
 		r := new(GenrlNtf)
-		t.parentMsg.NTF.Sequence.GeneralNtf = new(GNtf)
-		r.parentMsg = t.parentMsg
+		r.parentMsg = t.view()
+		if r.parentMsg.NTF.Sequence.GeneralNtf == nil {
+			r.parentMsg.NTF.Sequence.GeneralNtf = new(GNtf)
+		}
 		return r
 	case 100:
 		r := new(RpdInfo)
-		t.parentMsg.REX.Sequence.RpdInfo = new(RpdI)
-		r.parentMsg = t.parentMsg
-		// IfEnet and IPAddress indexes for their slices.
-		r.Ifindex = -1
-		r.IPindex = -1
+		r.parentMsg = t.view()
+		info := r.parentMsg.REX.Sequence.RpdInfo
+		if info == nil {
+			info = new(RpdI)
+			r.parentMsg.REX.Sequence.RpdInfo = info
+		}
+		// IfEnet and IPAddress indexes for their slices, which the
+		// RpdInfo TLVs of a Sequence share.
+		r.Ifindex = len(info.IfEnet) - 1
+		r.IPindex = len(info.IPAddress) - 1
 		return r
 	default:
-		log.Printf("Sequence TLV type: %d not supported", int(b))
 		return nil
 	}
 }
 
+// view returns the message data structure as seen by the TLVs of the
+// Sequence that are decoded into a fixed Top Level TLV, like RpdInfo into
+// REX: all its Top Level TLVs are the one of the Sequence.
+func (t *Seq) view() *GCP {
+	var d *dSeq
+	switch t.index {
+	case 1:
+		d = t.parentMsg.IRA
+	case 2:
+		d = t.parentMsg.REX
+	case 3:
+		d = t.parentMsg.NTF
+	}
+	return &GCP{IRA: d, REX: d, NTF: d}
+}
+
 // A SeqNmr is a SequenceNumber TLV.
 type SeqNmr struct {
 	TLV
@@ -421,6 +538,9 @@ type SeqNmr struct {
 // Name returns the type name of a NTF Message TLV.
 func (t *SeqNmr) Name() string { return "SequenceNumber" }
 
+// Kind returns the encoding of the value of a SequenceNumber TLV.
+func (t *SeqNmr) Kind() ValueKind { return KindUint16 }
+
 // Val returns the value a SequenceNumber TLV carries.
 func (t *SeqNmr) Val() interface{} {
 	switch t.index {
@@ -434,6 +554,18 @@ func (t *SeqNmr) Val() interface{} {
 	return u16Val(t.Value)
 }
 
+// operationNames are the names of the values of an Operation TLV.
+var operationNames = map[uint32]string{
+	1: "Read",
+	2: "Write",
+	3: "Delete",
+	4: "ReadResponse",
+	5: "WriteResponse",
+	6: "DeleteResponse",
+	7: "AllocateWrite",
+	8: "AllocateWriteResponse",
+}
+
 // A Oper is a Operation TLV.
 type Oper struct {
 	TLV
@@ -444,31 +576,18 @@ type Oper struct {
 // Name returns the type name of a Operation TLV.
 func (t *Oper) Name() string { return "Operation" }
 
+// Kind returns the encoding of the value of a Operation TLV.
+func (t *Oper) Kind() ValueKind { return KindUint8 }
+
+// Enum returns the names of the values of a Operation TLV.
+func (t *Oper) Enum() map[uint32]string { return operationNames }
+
 // Val returns the value a Operation TLV carries.
 func (t *Oper) Val() interface{} {
 	if len(t.Value) != 1 {
 		return fmt.Errorf("unexpected lenght: %v, want: 1", len(t.Value))
 	}
-	var s string
-	switch int(t.Value[0]) {
-	case 1:
-		s = "Read"
-	case 2:
-		s = "Write"
-	case 3:
-		s = "Delete"
-	case 4:
-		s = "ReadResponse"
-	case 5:
-		s = "WriteResponse"
-	case 6:
-		s = "DeleteResponse"
-	case 7:
-		s = "AllocateWrite"
-	case 8:
-		s = "AllocateWriteResponse"
-	default:
-	}
+	s := operationNames[uint32(t.Value[0])]
 
 	switch t.index {
 	case 1:
@@ -482,6 +601,29 @@ func (t *Oper) Val() interface{} {
 	return s
 }
 
+// responseCodeNames are the names of the values of a ResponseCode TLV.
+var responseCodeNames = map[uint32]string{
+	0:  "NoError",
+	1:  "GeneralError",
+	2:  "ResponseTooBig",
+	3:  "AttributeNotFound",
+	4:  "BadIndex",
+	5:  "WriteToReadOnly",
+	6:  "InconsistentValue",
+	7:  "WrongLength",
+	8:  "WrongValue",
+	9:  "ResourceUnavailable",
+	10: "AuthorizationFailure",
+	11: "AttributeMissing",
+	12: "AllocationFailure",
+	13: "AllocationNoOwner",
+	14: "ErrorProcessingUCD",
+	15: "ErrorProcessingOCD",
+	16: "ErrorProcessingDPD",
+	17: "SessionIdInUse",
+	18: "DoesNotExist",
+}
+
 // A ResCode is an ResponseCode TLV.
 type ResCode struct {
 	TLV
@@ -492,52 +634,19 @@ type ResCode struct {
 // Name returns the type name of an ResponseCode TLV.
 func (t *ResCode) Name() string { return "ResponseCode" }
 
+// Kind returns the encoding of the value of an ResponseCode TLV.
+func (t *ResCode) Kind() ValueKind { return KindUint8 }
+
+// Enum returns the names of the values of an ResponseCode TLV.
+func (t *ResCode) Enum() map[uint32]string { return responseCodeNames }
+
 // Val returns the value an ResponseCode TLV carries.
 func (t *ResCode) Val() interface{} {
 	if len(t.Value) != 1 {
 		return fmt.Errorf("unexpected lenght: %v, want: 1", len(t.Value))
 	}
-	var s string
-	switch int(t.Value[0]) {
-	case 0:
-		s = "NoError"
-	case 1:
-		s = "GeneralError"
-	case 2:
-		s = "ResponseTooBig"
-	case 3:
-		s = "AttributeNotFound"
-	case 4:
-		s = "BadIndex"
-	case 5:
-		s = "WriteToReadOnly"
-	case 6:
-		s = "InconsistentValue"
-	case 7:
-		s = "WrongLength"
-	case 8:
-		s = "WrongValue"
-	case 9:
-		s = "ResourceUnavailable"
-	case 10:
-		s = "AuthorizationFailure"
-	case 11:
-		s = "AttributeMissing"
-	case 12:
-		s = "AllocationFailure"
-	case 13:
-		s = "AllocationNoOwner"
-	case 14:
-		s = "ErrorProcessingUCD"
-	case 15:
-		s = "ErrorProcessingOCD"
-	case 16:
-		s = "ErrorProcessingDPD"
-	case 17:
-		s = "SessionIdInUse"
-	case 18:
-		s = "DoesNotExist"
-	default:
+	s, ok := responseCodeNames[uint32(t.Value[0])]
+	if !ok {
 		s = "Unknown Notification"
 	}
 
@@ -558,6 +667,99 @@ func parseTLVs(b []byte) ([]RCP, error) {
 	return t.parseTLVs(b)
 }
 
+// EncodeTLV returns the encoding of a TLV of type typ whose value is the
+// concatenation of v. A Complex TLV is built by passing the encodings
+// of its sub-TLVs.
+func EncodeTLV(typ uint8, v ...[]byte) []byte {
+	var l int
+	for _, p := range v {
+		l += len(p)
+	}
+	b := make([]byte, 3, 3+l)
+	b[0] = typ
+	binary.BigEndian.PutUint16(b[1:3], uint16(l))
+	for _, p := range v {
+		b = append(b, p...)
+	}
+	return b
+}
+
+// EncodeSequence returns an RCP message of Top Level type top, carrying
+// a single Sequence TLV with the given sequence number, operation and
+// object TLVs.
+func EncodeSequence(top uint8, seq uint16, op Operation, tlvs ...[]byte) []byte {
+	sn := make([]byte, 2)
+	binary.BigEndian.PutUint16(sn, seq)
+	v := [][]byte{EncodeTLV(10, sn), EncodeTLV(11, []byte{byte(op)})}
+	return EncodeTLV(top, EncodeTLV(9, append(v, tlvs...)...))
+}
+
+// EachTLV calls fn with the type and value of each TLV in b, without
+// descending into Complex TLVs, and returns the first error fn returns.
+// A TLV that runs past the end of b fails with ErrTruncatedTLV.
+func EachTLV(b []byte, fn func(typ uint8, v []byte) error) error {
+	for len(b) > 0 {
+		if len(b) < 3 {
+			return ErrTruncatedTLV
+		}
+		l := 3 + int(binary.BigEndian.Uint16(b[1:3]))
+		if l > len(b) {
+			return ErrTruncatedTLV
+		}
+		if err := fn(b[0], b[3:l]); err != nil {
+			return err
+		}
+		b = b[l:]
+	}
+	return nil
+}
+
+// FindTLV returns the value of the first TLV of b at the path of types,
+// descending into the Complex TLVs along it, or nil if there is none.
+func FindTLV(b []byte, path ...uint8) ([]byte, error) {
+	var found []byte
+	err := EachTLV(b, func(typ uint8, v []byte) error {
+		if typ != path[0] {
+			return nil
+		}
+		if len(path) == 1 {
+			found = v
+			return errFound
+		}
+		sub, err := FindTLV(v, path[1:]...)
+		if err == nil && sub != nil {
+			found, err = sub, errFound
+		}
+		return err
+	})
+	if err == errFound {
+		err = nil
+	}
+	return found, err
+}
+
+// splitTLVs splits b into the TLVs it carries, without descending into
+// Complex TLVs. The values returned reference b.
+func splitTLVs(b []byte) ([]TLV, error) {
+	var tlvs []TLV
+	for i := 0; len(b[i:]) != 0; {
+		l, err := boundsChk(i, b)
+		if err != nil {
+			return nil, err
+		}
+		tlvs = append(tlvs, TLV{Type: b[i], Length: uint16(l), Value: b[i+3 : i+3+l]})
+		// Advance to the next TLV's type field.
+		i += (l + 3)
+	}
+	return tlvs, nil
+}
+
+// errUnsupported returns the error for a TLV of type typ, unknown to the
+// decoder, found in a parent TLV.
+func errUnsupported(parent string, typ byte) error {
+	return fmt.Errorf("%s TLV type: %d not supported", parent, typ)
+}
+
 func boundsChk(i int, b []byte) (int, error) {
 	// Three bytes: TLV type and TLV length.
 	if len(b[i:]) < 3 {
@@ -578,6 +780,17 @@ func stringVal(b []byte) string {
 	return string(b)
 }
 
+// enumName returns the name names gives the number s renders, or s if
+// there is none.
+func enumName(names map[uint32]string, s string) string {
+	if n, err := strconv.ParseUint(s, 10, 32); err == nil {
+		if name, ok := names[uint32(n)]; ok {
+			return name
+		}
+	}
+	return s
+}
+
 func u8Val(b []byte) string {
 	if len(b) != 1 {
 		return fmt.Sprintf("unexpected lenght: %v, want: 1", len(b))
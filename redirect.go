@@ -1,9 +1,82 @@
 package gcp
 
 import (
-	"log"
+	"encoding/binary"
+	"errors"
+	"net"
 )
 
+// Error messages
+var (
+	errNotRedirect = errors.New("not an RpdRedirect write")
+)
+
+// Redirect returns an IRA Write message redirecting the RPD to the CCAP
+// Cores at addrs, in order of preference.
+func Redirect(seq uint16, addrs ...net.IP) []byte {
+	var tlvs [][]byte
+	for _, a := range addrs {
+		ip := a.To4()
+		if ip == nil {
+			ip = a.To16()
+		}
+		tlvs = append(tlvs, EncodeTLV(25, EncodeTLV(1, ip)))
+	}
+	return EncodeSequence(TypeIRA, seq, OpWrite, tlvs...)
+}
+
+// ParseRedirect returns the sequence number and the CCAP Core addresses
+// carried by an IRA Write message holding RpdRedirect TLVs.
+func ParseRedirect(b []byte) (uint16, []net.IP, error) {
+	top, err := splitTLVs(b)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(top) != 1 || top[0].Type != TypeIRA {
+		return 0, nil, errNotRedirect
+	}
+	seqs, err := splitTLVs(top[0].Value)
+	if err != nil {
+		return 0, nil, err
+	}
+	var (
+		seq   uint16
+		op    Operation
+		addrs []net.IP
+	)
+	for _, s := range seqs {
+		if s.Type != 9 {
+			continue
+		}
+		tlvs, err := splitTLVs(s.Value)
+		if err != nil {
+			return 0, nil, err
+		}
+		for _, t := range tlvs {
+			switch {
+			case t.Type == 10 && len(t.Value) == 2:
+				seq = binary.BigEndian.Uint16(t.Value)
+			case t.Type == 11 && len(t.Value) == 1:
+				op = Operation(t.Value[0])
+			case t.Type == 25:
+				red, err := splitTLVs(t.Value)
+				if err != nil {
+					return 0, nil, err
+				}
+				for _, r := range red {
+					if r.Type == 1 && (len(r.Value) == 4 || len(r.Value) == 16) {
+						addrs = append(addrs, net.IP(append([]byte(nil), r.Value...)))
+					}
+				}
+			}
+		}
+	}
+	if op != OpWrite || len(addrs) == 0 {
+		return 0, nil, errNotRedirect
+	}
+	return seq, addrs, nil
+}
+
 // A RpdRed is a RpdRedirect TLV (Complex TLV).
 type RpdRed struct {
 	TLV
@@ -24,7 +97,6 @@ func (t *RpdRed) newTLV(b byte) RCP {
 		r.IPindex = t.IPindex
 		return r
 	default:
-		log.Printf("RpdRedirect TLV type: %d not supported", int(b))
 		return nil
 	}
 }
@@ -39,6 +111,9 @@ func (t *RpdRed) parseTLVs(b []byte) ([]RCP, error) {
 		}
 
 		tlv := t.newTLV(b[i])
+		if tlv == nil {
+			return nil, errUnsupported("RpdRedirect", b[i])
+		}
 
 		// Unmarshal at the current offset, up to the expected length.
 		if err := tlv.unmarshal(b[i : i+3+l]); err != nil {
@@ -73,6 +148,9 @@ type RedIPAdd struct {
 // Name returns the type name of aRedirectIpAddress TLV.
 func (t *RedIPAdd) Name() string { return "RedirectIpAddress" }
 
+// Kind returns the encoding of the value of a RedirectIpAddress TLV.
+func (t *RedIPAdd) Kind() ValueKind { return KindIP }
+
 // Val returns the value a RedirectIpAddress TLV carries.
 func (t *RedIPAdd) Val() interface{} {
 	s := ipVal(t.Value)
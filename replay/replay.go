@@ -0,0 +1,385 @@
+// Package replay re-enacts one side of a captured GCP conversation against
+// a live endpoint.
+//
+// Messages of the replayed side are sent as captured, except for the
+// fields that tie a response to its request: Transaction Identifiers and
+// RCP sequence numbers are rewritten to match the live requests being
+// answered. Messages of the other side are expected from the live
+// endpoint, in the captured order, and compared to the captured ones.
+package replay
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/pcap"
+	"github.com/nleiva/gcp-rphy/transport"
+)
+
+// defaultTimeout is how long a Replayer waits for a message from the
+// live endpoint.
+const defaultTimeout = 5 * time.Second
+
+// Error messages
+var (
+	ErrNoMessage  = errors.New("timed out waiting for the live endpoint")
+	ErrMismatch   = errors.New("live message differs from the captured one")
+	errUndecoded  = errors.New("captured message could not be decoded")
+	errNoResponse = errors.New("captured request has no live request to answer")
+)
+
+// Side is an end of a GCP conversation.
+type Side int
+
+// Ends of a GCP conversation.
+const (
+	RPD  Side = iota // The end that connects
+	Core             // The end listening on the GCP port
+)
+
+func (s Side) String() string {
+	if s == Core {
+		return "core"
+	}
+	return "rpd"
+}
+
+// A Conversation is the list of GCP messages exchanged over a TCP
+// connection.
+type Conversation struct {
+	RPD    *net.TCPAddr
+	Core   *net.TCPAddr
+	Frames []*pcap.Frame
+}
+
+// from returns the Side that sent f.
+func (c *Conversation) from(f *pcap.Frame) Side {
+	if f.Src.String() == c.Core.String() {
+		return Core
+	}
+	return RPD
+}
+
+// Conversations returns the GCP conversations in the capture read from r,
+// in the order of their first message. The core is the end using port,
+// pcap.DefaultPort if zero.
+func Conversations(r io.Reader, port int) ([]*Conversation, error) {
+	if port == 0 {
+		port = pcap.DefaultPort
+	}
+	var convs []*Conversation
+	index := make(map[string]*Conversation)
+	err := pcap.Decoder{Port: port}.Decode(r, func(f *pcap.Frame) error {
+		rpd, core := f.Src, f.Dst
+		if rpd.Port == port {
+			rpd, core = core, rpd
+		}
+		key := rpd.String() + ">" + core.String()
+		c := index[key]
+		if c == nil {
+			c = &Conversation{RPD: rpd, Core: core}
+			index[key] = c
+			convs = append(convs, c)
+		}
+		c.Frames = append(c.Frames, f)
+		return nil
+	})
+	return convs, err
+}
+
+// A Result is the outcome of replaying a captured message, or of
+// waiting for it from the live endpoint.
+type Result struct {
+	Captured *pcap.Frame // Message from the capture
+	Sent     bool        // Whether Captured was replayed, rather than expected
+	Live     []byte      // GCP message sent or received, nil if none
+	Diff     []string    // Differences between Captured and Live
+	Err      error
+}
+
+// A Replayer replays the messages of one Side of a Conversation. It is
+// the Handler of the Session to the live endpoint.
+type Replayer struct {
+	Side    Side
+	Timeout time.Duration // Time to wait for a message from the live endpoint, 5s if zero
+
+	in chan *transport.Request
+}
+
+// NewReplayer returns a Replayer for side.
+func NewReplayer(side Side) *Replayer {
+	return &Replayer{Side: side, in: make(chan *transport.Request, 64)}
+}
+
+// ServeGCP queues the messages from the live endpoint.
+func (rp *Replayer) ServeGCP(_ *transport.Session, r *transport.Request) {
+	rp.in <- r
+}
+
+func (rp *Replayer) timeout() time.Duration {
+	if rp.Timeout > 0 {
+		return rp.Timeout
+	}
+	return defaultTimeout
+}
+
+// Replay replays c over s, calling fn with the Result of each message of
+// the conversation. It stops at the first message that does not arrive
+// from the live endpoint in time.
+func (rp *Replayer) Replay(c *Conversation, s *transport.Session, fn func(*Result)) error {
+	// Live requests, by the Transaction Identifier of the captured
+	// request they stand for.
+	live := make(map[uint16]*transport.Request)
+	// Captured responses already compared.
+	done := make(map[*pcap.Frame]bool)
+	for i, f := range c.Frames {
+		if done[f] {
+			continue
+		}
+		res := &Result{Captured: f, Sent: c.from(f) == rp.Side}
+		switch {
+		case f.Msg == nil:
+			res.Err = fmt.Errorf("%v: %v", errUndecoded, f.Err)
+		case !res.Sent:
+			var r *transport.Request
+			select {
+			case r = <-rp.in:
+			case <-s.Done():
+				res.Err = transport.ErrSessionClosed
+			case <-time.After(rp.timeout()):
+				res.Err = ErrNoMessage
+			}
+			if r != nil {
+				live[f.TCP.TranID] = r
+				res.Live, res.Err = compare(f.Raw, r.Msg)
+				res.Diff = diff(f.Raw, res.Live)
+			}
+		case isResponse(f.Msg.MessageID):
+			b := clone(f.Raw)
+			r, ok := live[f.TCP.TranID]
+			if !ok {
+				res.Err = errNoResponse
+				break
+			}
+			if req, err := r.Msg.Marshal(); err == nil {
+				rewrite(b, req)
+			}
+			res.Live = b
+			res.Err = s.Reply(r, raw(b))
+		default:
+			b := clone(f.Raw)
+			res.Live = b
+			want := response(c.Frames[i+1:], f)
+			if want == nil {
+				res.Err = s.Send(raw(b))
+				break
+			}
+			r, err := s.Request(raw(b), rp.timeout())
+			if err == transport.ErrRequestTimeout || err == nil && r == nil {
+				err = ErrNoMessage
+			}
+			fn(res)
+			// The response is compared in its own Result.
+			done[want] = true
+			res = &Result{Captured: want, Err: err}
+			if err == nil {
+				res.Live, res.Err = compare(want.Raw, r.Msg)
+				res.Diff = diff(want.Raw, res.Live)
+			}
+		}
+		fn(res)
+		if res.Err == ErrNoMessage || res.Err == transport.ErrSessionClosed {
+			return res.Err
+		}
+	}
+	return nil
+}
+
+// response returns the captured response to req among frames, if any.
+func response(frames []*pcap.Frame, req *pcap.Frame) *pcap.Frame {
+	if req.TCP.TranID == 0 {
+		return nil
+	}
+	for _, f := range frames {
+		if f.Msg != nil && f.TCP.TranID == req.TCP.TranID && isResponse(f.Msg.MessageID) &&
+			f.Src.String() == req.Dst.String() {
+			return f
+		}
+	}
+	return nil
+}
+
+// compare returns the encoding of m and ErrMismatch if it differs from
+// the captured message b, other than in its Transaction ID and RCP
+// sequence numbers.
+func compare(b []byte, m *gcp.Message) ([]byte, error) {
+	l, err := m.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	if string(normalize(b)) != string(normalize(l)) {
+		return l, ErrMismatch
+	}
+	return l, nil
+}
+
+// diff returns the lines of the decoded messages that differ, prefixed
+// with "-" for the captured message b and "+" for the live message l.
+func diff(b, l []byte) []string {
+	if l == nil || string(normalize(b)) == string(normalize(l)) {
+		return nil
+	}
+	want, got := lines(normalize(b)), lines(normalize(l))
+	var d []string
+	for _, s := range want {
+		if !contains(got, s) {
+			d = append(d, "-"+s)
+		}
+	}
+	for _, s := range got {
+		if !contains(want, s) {
+			d = append(d, "+"+s)
+		}
+	}
+	return d
+}
+
+// lines decodes the GCP message b into lines of text.
+func lines(b []byte) []string {
+	m, err := gcp.ParseMessage(b)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	s, _ := m.Body.Process()
+	text := fmt.Sprintf("Message Identifier: %v", gcp.MessageID(m.MessageID)) + s
+	var ls []string
+	for _, l := range strings.Split(text, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			ls = append(ls, l)
+		}
+	}
+	return ls
+}
+
+func contains(ls []string, s string) bool {
+	for _, l := range ls {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Offsets of the GCP Transaction ID and of the RCP TLVs in a message.
+const (
+	tidOffset       = 3
+	notifyRCPOffset = 3 + 8
+	edsRCPOffset    = 3 + 12
+)
+
+// rcpOffset returns the offset of the RCP TLVs in the GCP message b, or
+// zero if it does not carry any.
+func rcpOffset(b []byte) int {
+	if len(b) == 0 {
+		return 0
+	}
+	switch gcp.MessageID(b[0]) {
+	case gcp.MessageIDNotifyReq:
+		return notifyRCPOffset
+	case gcp.MessageIDEDSReq, gcp.MessageIDEDSRes:
+		return edsRCPOffset
+	}
+	return 0
+}
+
+// seqNumbers returns the offsets of the RCP SequenceNumber values in the
+// GCP message b.
+func seqNumbers(b []byte) []int {
+	off := rcpOffset(b)
+	if off == 0 || off > len(b) {
+		return nil
+	}
+	var offs []int
+	// Top level TLVs (IRA, REX, NTF) contain Sequence TLVs (9), which
+	// contain a SequenceNumber TLV (10).
+	for _, top := range tlvs(b, off, len(b)) {
+		for _, seq := range tlvs(b, top.val, top.end) {
+			if seq.typ != 9 {
+				continue
+			}
+			for _, t := range tlvs(b, seq.val, seq.end) {
+				if t.typ == 10 && t.end-t.val == 2 {
+					offs = append(offs, t.val)
+				}
+			}
+		}
+	}
+	return offs
+}
+
+type tlv struct {
+	typ      uint8
+	val, end int
+}
+
+// tlvs splits b[start:end] into RCP TLVs, stopping at the first one that
+// does not fit.
+func tlvs(b []byte, start, end int) []tlv {
+	var ts []tlv
+	for i := start; i+3 <= end; {
+		l := int(binary.BigEndian.Uint16(b[i+1 : i+3]))
+		if i+3+l > end {
+			break
+		}
+		ts = append(ts, tlv{typ: b[i], val: i + 3, end: i + 3 + l})
+		i += 3 + l
+	}
+	return ts
+}
+
+// rewrite makes the captured response b answer the live request req, by
+// copying its Transaction ID and RCP sequence numbers.
+func rewrite(b, req []byte) {
+	if len(b) >= tidOffset+2 && len(req) >= tidOffset+2 {
+		copy(b[tidOffset:tidOffset+2], req[tidOffset:tidOffset+2])
+	}
+	bs, rs := seqNumbers(b), seqNumbers(req)
+	for i := 0; i < len(bs) && i < len(rs); i++ {
+		copy(b[bs[i]:bs[i]+2], req[rs[i]:rs[i]+2])
+	}
+}
+
+// normalize returns a copy of the GCP message b with its Transaction ID
+// and RCP sequence numbers cleared.
+func normalize(b []byte) []byte {
+	n := clone(b)
+	if len(n) >= tidOffset+2 {
+		n[tidOffset], n[tidOffset+1] = 0, 0
+	}
+	for _, o := range seqNumbers(n) {
+		n[o], n[o+1] = 0, 0
+	}
+	return n
+}
+
+// raw returns the GCP message b, sent as is.
+func raw(b []byte) *gcp.Message {
+	m := &gcp.Message{Body: &gcp.RawBody{}}
+	if len(b) >= 3 {
+		m.MessageID = b[0]
+		m.Lenght = binary.BigEndian.Uint16(b[1:3])
+		m.Body = &gcp.RawBody{Data: b[3:]}
+	}
+	return m
+}
+
+func clone(b []byte) []byte { return append([]byte(nil), b...) }
+
+// isResponse reports whether id identifies a normal or an error response.
+func isResponse(id uint8) bool { return id&1 == 1 }
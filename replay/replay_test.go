@@ -0,0 +1,178 @@
+package replay_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/pcap"
+	"github.com/nleiva/gcp-rphy/replay"
+	"github.com/nleiva/gcp-rphy/transport"
+)
+
+var ntf = "AgFqAAHAAQAAAAEDAV8JAVwKAAIAAQsAAQIyAUkTASUBAAVDaXNjbwIAAgAJAwAIUlBIWS1SUEQEAAag+ElvQxwFAAR2Ni40BgBvUHJpbWFyeTogVS1Cb290IDIwMTYuMDEgKEp1bCAzMSAyMDE3IC0gMDk6NTQ6NTEgKzA4MDApICo7R29sZGVuOiBVLUJvb3QgMjAxNi4wMSAoQXByIDEyIDIwMTcgLSAwOToxMzoyOCArMDgwMCk7BwADUlBECAADUlBECQALQ0FUMjEzM0UwQTUKAAIRPQsACEJDTTMxNjEwDAADVjExDQAIMDAwMDAwMDAOAAMxLjAPAAYxLjAuMTAQAAMxLjARAAASAAATAAgH4wQCEjIqBRQAEFJQRC1WNi00Lml0Yi5TU0EVABAgAQV4EAAREQAAAAAAAAJFFgABABgAHgEAAk5BAgAJKzAwMDAwMC4wAwAKKzAwMDAwMDAuMFYABAEAAQE="
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.Write(p)
+}
+
+func (b *syncBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte(nil), b.b.Bytes()...)
+}
+
+// startCore starts a core that answers a Notify with evntCode and then
+// reads the RpdInfo of the RPD with sequence number seq, reporting the
+// sequence number of the response on the returned channel.
+func startCore(t *testing.T, rec transport.Recorder, evntCode uint32, seq uint16) (*transport.Core, string, <-chan string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	seqs := make(chan string, 1)
+	c := &transport.Core{
+		Recorder: rec,
+		Handler: transport.HandlerFunc(func(s *transport.Session, r *transport.Request) {
+			n, ok := r.Msg.Body.(*gcp.NotifyReq)
+			if !ok {
+				return
+			}
+			s.Reply(r, gcp.NewMessage(gcp.MessageIDNotifyRes, &gcp.NotifyRes{TransactionID: n.TransactionID, EvntCode: evntCode}))
+			go func() {
+				req := gcp.NewMessage(gcp.MessageIDEDSReq, &gcp.EDSReq{
+					TransactionID: seq,
+					VendorID:      gcp.CableLabs,
+					DataStr:       gcp.EncodeSequence(gcp.TypeREX, seq, gcp.OpRead, gcp.EncodeTLV(100)),
+				})
+				res, err := s.Request(req, 2*time.Second)
+				if err != nil {
+					seqs <- err.Error()
+					return
+				}
+				_, g := res.Msg.Body.Process()
+				seqs <- g.REX.Sequence.SequenceNumber
+			}()
+		}),
+	}
+	go c.Serve(l)
+	return c, l.Addr().String(), seqs
+}
+
+// rpd answers reads of its RpdInfo.
+var rpd = transport.HandlerFunc(func(s *transport.Session, r *transport.Request) {
+	req, ok := r.Msg.Body.(*gcp.EDSReq)
+	if !ok {
+		return
+	}
+	_, g := req.Process()
+	seq, _ := strconv.Atoi(g.REX.Sequence.SequenceNumber)
+	res := &gcp.EDSRes{
+		TransactionID: req.TransactionID,
+		VendorID:      req.VendorID,
+		DataStr: gcp.EncodeSequence(gcp.TypeREX, uint16(seq), gcp.OpReadResponse,
+			gcp.EncodeTLV(19, []byte{byte(gcp.RespNoError)}), gcp.EncodeTLV(100)),
+	}
+	s.Reply(r, gcp.NewMessage(gcp.MessageIDEDSRes, res))
+})
+
+// record captures a conversation between rpd and a core.
+func record(t *testing.T) []*replay.Conversation {
+	t.Helper()
+	var file syncBuffer
+	rec, err := pcap.NewRecorder(&file)
+	if err != nil {
+		t.Fatalf("could not start recording: %v", err)
+	}
+	core, addr, seqs := startCore(t, rec, 0, 7)
+	defer core.Close()
+	s, err := transport.Dial(addr, rpd)
+	if err != nil {
+		t.Fatalf("could not connect: %v", err)
+	}
+	defer s.Close()
+	b, _ := base64.StdEncoding.DecodeString(ntf)
+	m, err := gcp.ParseMessage(b)
+	if err != nil {
+		t.Fatalf("could not parse notify message: %v", err)
+	}
+	// The captured notification does not require a response.
+	m.Body.(*gcp.NotifyReq).Mode = 0
+	if _, err := s.Request(m, 2*time.Second); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	if got := <-seqs; got != "7" {
+		t.Fatalf("Sequence Number got: %s, want: 7", got)
+	}
+
+	port := core.Sessions()[0].LocalAddr().(*net.TCPAddr).Port
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		convs, err := replay.Conversations(bytes.NewReader(file.Bytes()), port)
+		if err != nil {
+			t.Fatalf("could not read the recording: %v", err)
+		}
+		if len(convs) == 1 && len(convs[0].Frames) == 4 {
+			return convs
+		}
+	}
+	t.Fatalf("recording incomplete")
+	return nil
+}
+
+func TestReplay(t *testing.T) {
+	convs := record(t)
+	tt := []struct {
+		name     string
+		evntCode uint32
+		errs     []error
+	}{
+		{name: "Same core", errs: []error{nil, nil, nil, nil}},
+		{name: "Different response", evntCode: 1, errs: []error{nil, replay.ErrMismatch, nil, nil}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			// The live core uses other sequence numbers.
+			core, addr, seqs := startCore(t, nil, tc.evntCode, 8)
+			defer core.Close()
+			rp := replay.NewReplayer(replay.RPD)
+			s, err := transport.Dial(addr, rp)
+			if err != nil {
+				t.Fatalf("could not connect: %v", err)
+			}
+			defer s.Close()
+
+			var results []*replay.Result
+			if err := rp.Replay(convs[0], s, func(r *replay.Result) { results = append(results, r) }); err != nil {
+				t.Fatalf("Replay failed: %v", err)
+			}
+			if len(results) != len(tc.errs) {
+				t.Fatalf("Results got: %d, want: %d", len(results), len(tc.errs))
+			}
+			for i, r := range results {
+				if r.Err != tc.errs[i] {
+					t.Errorf("Result %d got: %v (%q), want: %v", i, r.Err, r.Diff, tc.errs[i])
+				}
+				if r.Err == replay.ErrMismatch && len(r.Diff) == 0 {
+					t.Errorf("Result %d has no differences", i)
+				}
+			}
+			if got := <-seqs; got != "8" {
+				t.Errorf("Sequence Number got: %s, want: 8", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,392 @@
+package gcp
+
+// A RfChan is a RfChannel TLV (Complex TLV).
+type RfChan struct {
+	TLV
+	// Index of the entry in the RfChannel slice
+	chanIndex int
+}
+
+// Name returns the type name of a RfChannel TLV.
+func (t *RfChan) Name() string { return "RfChannel" }
+
+// IsComplex returns whether a RfChannel TLV is Complex or not.
+func (t *RfChan) IsComplex() bool { return true }
+
+func (t *RfChan) newTLV(b byte) RCP {
+	switch int(b) {
+	case 12:
+		r := new(RfChanSel)
+		r.parentMsg = t.parentMsg
+		r.chanIndex = t.chanIndex
+		return r
+	default:
+		return nil
+	}
+}
+
+// parseTLVs parses RfChannel TLVs.
+func (t *RfChan) parseTLVs(b []byte) ([]RCP, error) {
+	var tlvs []RCP
+	for i := 0; len(b[i:]) != 0; {
+		l, err := boundsChk(i, b)
+		if err != nil {
+			return nil, err
+		}
+
+		tlv := t.newTLV(b[i])
+		if tlv == nil {
+			return nil, errUnsupported("RfChannel", b[i])
+		}
+
+		// Unmarshal at the current offset, up to the expected length.
+		if err := tlv.unmarshal(b[i : i+3+l]); err != nil {
+			return nil, err
+		}
+
+		switch {
+		case l > 3 && tlv.IsComplex():
+			rectlv, err := tlv.parseTLVs(b[i+3 : i+3+l])
+			if err != nil {
+				return nil, err
+			}
+			tlvs = append(tlvs, tlv)
+			tlvs = append(tlvs, rectlv...)
+		case l <= 3 || !tlv.IsComplex():
+			tlvs = append(tlvs, tlv)
+		}
+		// Advance to the next TLV's type field.
+		i += (l + 3)
+	}
+
+	return tlvs, nil
+
+}
+
+// A RfChanSel is a RfChannelSelector TLV (Complex TLV).
+type RfChanSel struct {
+	TLV
+	chanIndex int
+}
+
+// Name returns the type name of a RfChannelSelector TLV.
+func (t *RfChanSel) Name() string { return "RfChannelSelector" }
+
+// IsComplex returns whether a RfChannelSelector TLV is Complex or not.
+func (t *RfChanSel) IsComplex() bool { return true }
+
+func (t *RfChanSel) newTLV(b byte) RCP {
+	switch int(b) {
+	case 1:
+		r := new(ChanPortIdx)
+		r.parentMsg = t.parentMsg
+		r.chanIndex = t.chanIndex
+		return r
+	case 2:
+		r := new(RfChanType)
+		r.parentMsg = t.parentMsg
+		r.chanIndex = t.chanIndex
+		return r
+	case 3:
+		r := new(RfChanIdx)
+		r.parentMsg = t.parentMsg
+		r.chanIndex = t.chanIndex
+		return r
+	default:
+		return nil
+	}
+}
+
+// parseTLVs parses RfChannelSelector TLVs.
+func (t *RfChanSel) parseTLVs(b []byte) ([]RCP, error) {
+	var tlvs []RCP
+	for i := 0; len(b[i:]) != 0; {
+		l, err := boundsChk(i, b)
+		if err != nil {
+			return nil, err
+		}
+
+		tlv := t.newTLV(b[i])
+		if tlv == nil {
+			return nil, errUnsupported("RfChannelSelector", b[i])
+		}
+
+		// Unmarshal at the current offset, up to the expected length.
+		if err := tlv.unmarshal(b[i : i+3+l]); err != nil {
+			return nil, err
+		}
+
+		switch {
+		case l > 3 && tlv.IsComplex():
+			rectlv, err := tlv.parseTLVs(b[i+3 : i+3+l])
+			if err != nil {
+				return nil, err
+			}
+			tlvs = append(tlvs, tlv)
+			tlvs = append(tlvs, rectlv...)
+		case l <= 3 || !tlv.IsComplex():
+			tlvs = append(tlvs, tlv)
+		}
+		// Advance to the next TLV's type field.
+		i += (l + 3)
+	}
+
+	return tlvs, nil
+
+}
+
+// channel returns the RfChannelSelector of the RfChannel entry of index
+// i in m.
+func channel(m *GCP, i int) *RfChSel {
+	return &m.REX.Sequence.RfChannel[i].RfChannelSelector
+}
+
+// A ChanPortIdx is a RfPortIndex TLV of a RfChannelSelector.
+type ChanPortIdx struct {
+	TLV
+	chanIndex int
+}
+
+// Name returns the type name of a RfPortIndex TLV.
+func (t *ChanPortIdx) Name() string { return "RfPortIndex" }
+
+// Kind returns the encoding of the value of a RfPortIndex TLV.
+func (t *ChanPortIdx) Kind() ValueKind { return KindUint8 }
+
+// Val returns the value a RfPortIndex TLV carries.
+// The index of the RF port of the channel.
+func (t *ChanPortIdx) Val() interface{} {
+	s := u8Val(t.Value)
+	channel(t.parentMsg, t.chanIndex).RfPortIndex = s
+	return s
+}
+
+// rfChannelTypeNames are the names of the values of a RfChannelType TLV.
+var rfChannelTypeNames = map[uint32]string{
+	5: "DsScQam",
+}
+
+// A RfChanType is a RfChannelType TLV.
+type RfChanType struct {
+	TLV
+	chanIndex int
+}
+
+// Name returns the type name of a RfChannelType TLV.
+func (t *RfChanType) Name() string { return "RfChannelType" }
+
+// Kind returns the encoding of the value of a RfChannelType TLV.
+func (t *RfChanType) Kind() ValueKind { return KindUint8 }
+
+// Enum returns the names of the values of a RfChannelType TLV.
+func (t *RfChanType) Enum() map[uint32]string { return rfChannelTypeNames }
+
+// Val returns the value a RfChannelType TLV carries.
+func (t *RfChanType) Val() interface{} {
+	s := enumName(rfChannelTypeNames, u8Val(t.Value))
+	channel(t.parentMsg, t.chanIndex).RfChannelType = s
+	return s
+}
+
+// A RfChanIdx is a RfChannelIndex TLV.
+type RfChanIdx struct {
+	TLV
+	chanIndex int
+}
+
+// Name returns the type name of a RfChannelIndex TLV.
+func (t *RfChanIdx) Name() string { return "RfChannelIndex" }
+
+// Kind returns the encoding of the value of a RfChannelIndex TLV.
+func (t *RfChanIdx) Kind() ValueKind { return KindUint8 }
+
+// Val returns the value a RfChannelIndex TLV carries.
+// The index of the channel among those of its type on its RF port.
+func (t *RfChanIdx) Val() interface{} {
+	s := u8Val(t.Value)
+	channel(t.parentMsg, t.chanIndex).RfChannelIndex = s
+	return s
+}
+
+// A RfPrt is a RfPort TLV (Complex TLV).
+type RfPrt struct {
+	TLV
+	// Index of the entry in the RfPort slice
+	portIndex int
+}
+
+// Name returns the type name of a RfPort TLV.
+func (t *RfPrt) Name() string { return "RfPort" }
+
+// IsComplex returns whether a RfPort TLV is Complex or not.
+func (t *RfPrt) IsComplex() bool { return true }
+
+func (t *RfPrt) newTLV(b byte) RCP {
+	switch int(b) {
+	case 12:
+		r := new(RfPrtSel)
+		r.parentMsg = t.parentMsg
+		r.portIndex = t.portIndex
+		return r
+	default:
+		return nil
+	}
+}
+
+// parseTLVs parses RfPort TLVs.
+func (t *RfPrt) parseTLVs(b []byte) ([]RCP, error) {
+	var tlvs []RCP
+	for i := 0; len(b[i:]) != 0; {
+		l, err := boundsChk(i, b)
+		if err != nil {
+			return nil, err
+		}
+
+		tlv := t.newTLV(b[i])
+		if tlv == nil {
+			return nil, errUnsupported("RfPort", b[i])
+		}
+
+		// Unmarshal at the current offset, up to the expected length.
+		if err := tlv.unmarshal(b[i : i+3+l]); err != nil {
+			return nil, err
+		}
+
+		switch {
+		case l > 3 && tlv.IsComplex():
+			rectlv, err := tlv.parseTLVs(b[i+3 : i+3+l])
+			if err != nil {
+				return nil, err
+			}
+			tlvs = append(tlvs, tlv)
+			tlvs = append(tlvs, rectlv...)
+		case l <= 3 || !tlv.IsComplex():
+			tlvs = append(tlvs, tlv)
+		}
+		// Advance to the next TLV's type field.
+		i += (l + 3)
+	}
+
+	return tlvs, nil
+
+}
+
+// A RfPrtSel is a RfPortSelector TLV (Complex TLV).
+type RfPrtSel struct {
+	TLV
+	portIndex int
+}
+
+// Name returns the type name of a RfPortSelector TLV.
+func (t *RfPrtSel) Name() string { return "RfPortSelector" }
+
+// IsComplex returns whether a RfPortSelector TLV is Complex or not.
+func (t *RfPrtSel) IsComplex() bool { return true }
+
+func (t *RfPrtSel) newTLV(b byte) RCP {
+	switch int(b) {
+	case 1:
+		r := new(PrtPortIdx)
+		r.parentMsg = t.parentMsg
+		r.portIndex = t.portIndex
+		return r
+	case 2:
+		r := new(RfPrtType)
+		r.parentMsg = t.parentMsg
+		r.portIndex = t.portIndex
+		return r
+	default:
+		return nil
+	}
+}
+
+// parseTLVs parses RfPortSelector TLVs.
+func (t *RfPrtSel) parseTLVs(b []byte) ([]RCP, error) {
+	var tlvs []RCP
+	for i := 0; len(b[i:]) != 0; {
+		l, err := boundsChk(i, b)
+		if err != nil {
+			return nil, err
+		}
+
+		tlv := t.newTLV(b[i])
+		if tlv == nil {
+			return nil, errUnsupported("RfPortSelector", b[i])
+		}
+
+		// Unmarshal at the current offset, up to the expected length.
+		if err := tlv.unmarshal(b[i : i+3+l]); err != nil {
+			return nil, err
+		}
+
+		switch {
+		case l > 3 && tlv.IsComplex():
+			rectlv, err := tlv.parseTLVs(b[i+3 : i+3+l])
+			if err != nil {
+				return nil, err
+			}
+			tlvs = append(tlvs, tlv)
+			tlvs = append(tlvs, rectlv...)
+		case l <= 3 || !tlv.IsComplex():
+			tlvs = append(tlvs, tlv)
+		}
+		// Advance to the next TLV's type field.
+		i += (l + 3)
+	}
+
+	return tlvs, nil
+
+}
+
+// port returns the RfPortSelector of the RfPort entry of index i in m.
+func port(m *GCP, i int) *RfPtSel {
+	return &m.REX.Sequence.RfPort[i].RfPortSelector
+}
+
+// A PrtPortIdx is a RfPortIndex TLV of a RfPortSelector.
+type PrtPortIdx struct {
+	TLV
+	portIndex int
+}
+
+// Name returns the type name of a RfPortIndex TLV.
+func (t *PrtPortIdx) Name() string { return "RfPortIndex" }
+
+// Kind returns the encoding of the value of a RfPortIndex TLV.
+func (t *PrtPortIdx) Kind() ValueKind { return KindUint8 }
+
+// Val returns the value a RfPortIndex TLV carries.
+// The index of the RF port among those of its type.
+func (t *PrtPortIdx) Val() interface{} {
+	s := u8Val(t.Value)
+	port(t.parentMsg, t.portIndex).RfPortIndex = s
+	return s
+}
+
+// rfPortTypeNames are the names of the values of a RfPortType TLV.
+var rfPortTypeNames = map[uint32]string{
+	1: "DsRfPort",
+	2: "UsRfPort",
+}
+
+// A RfPrtType is a RfPortType TLV.
+type RfPrtType struct {
+	TLV
+	portIndex int
+}
+
+// Name returns the type name of a RfPortType TLV.
+func (t *RfPrtType) Name() string { return "RfPortType" }
+
+// Kind returns the encoding of the value of a RfPortType TLV.
+func (t *RfPrtType) Kind() ValueKind { return KindUint8 }
+
+// Enum returns the names of the values of a RfPortType TLV.
+func (t *RfPrtType) Enum() map[uint32]string { return rfPortTypeNames }
+
+// Val returns the value a RfPortType TLV carries.
+func (t *RfPrtType) Val() interface{} {
+	s := enumName(rfPortTypeNames, u8Val(t.Value))
+	port(t.parentMsg, t.portIndex).RfPortType = s
+	return s
+}
@@ -0,0 +1,224 @@
+package rpdsim
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nleiva/gcp-rphy/compose"
+	"github.com/nleiva/gcp-rphy/transport"
+)
+
+// A Fleet runs many simulated RPDs in one process, to load test a core.
+// Every RPD starts with a copy of Template, with its own MAC address and
+// serial number.
+type Fleet struct {
+	Core      string                           // Address (host:port) of the CCAP Core
+	Count     int                              // Number of RPDs
+	Template  *Store                           // RCP objects of the RPDs
+	MAC       net.HardwareAddr                 // Optional, MAC address of the first RPD, incremented for the rest
+	Serial    string                           // Optional, fmt format of the serial numbers given the RPD number from 1, like "SIM%07d"
+	Ramp      time.Duration                    // RPDs start at random times within Ramp
+	Jitter    time.Duration                    // Answers are delayed randomly up to Jitter
+	Strict    bool                             // Requests that violate the GCP specification get Error Responses
+	Schedules []Schedule                       // Notifications raised periodically by every RPD
+	OnEvent   func(rpd int, e transport.Event) // Optional, called when the association of an RPD changes state
+	Setup     func(rpd int, r *RPD)            // Optional, customizes each RPD before it starts
+
+	mu      sync.Mutex
+	rpds    []*RPD
+	up      int
+	inited  int
+	errs    int
+	failed  int
+	lastErr error
+	latency []time.Duration
+	closed  bool
+	quit    chan struct{}
+}
+
+// Stats are the aggregate statistics of a Fleet.
+type Stats struct {
+	RPDs        int // RPDs simulated
+	Up          int // RPDs connected to the core
+	Initialized int // RPDs the core sent a request to
+	Errors      int // Sessions that failed or were lost
+	// Percentiles of the time from an RPD connecting to its first answer
+	// to the core, zero before any RPD is initialized.
+	P50, P90, P99, Max time.Duration
+}
+
+func (s Stats) String() string {
+	r := func(d time.Duration) time.Duration { return d.Round(time.Microsecond) }
+	return fmt.Sprintf("RPDs %d, up %d, initialized %d, errors %d, init latency p50 %v p90 %v p99 %v max %v",
+		s.RPDs, s.Up, s.Initialized, s.Errors, r(s.P50), r(s.P90), r(s.P99), r(s.Max))
+}
+
+// Run starts the RPDs and waits for them until Close is called, or they
+// all fail to connect or reconnect, in which case it returns the last
+// error.
+func (f *Fleet) Run() error {
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return transport.ErrSessionClosed
+	}
+	f.quit = make(chan struct{})
+	f.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for i := 0; i < f.Count; i++ {
+		r, err := f.rpd(i)
+		if err != nil {
+			f.Close()
+			wg.Wait()
+			return err
+		}
+		var delay time.Duration
+		if f.Ramp > 0 {
+			delay = time.Duration(rand.Int63n(int64(f.Ramp)))
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			select {
+			case <-time.After(delay):
+			case <-f.quit:
+				return
+			}
+			f.run(i, r)
+		}(i)
+	}
+	wg.Wait()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case f.failed < f.Count:
+		return nil
+	case f.Count == 1:
+		return f.lastErr
+	}
+	return fmt.Errorf("all %d RPDs failed, the last one with: %v", f.Count, f.lastErr)
+}
+
+// rpd returns the i-th RPD of the fleet, numbered from 0.
+func (f *Fleet) rpd(i int) (*RPD, error) {
+	st := &Store{}
+	if f.Template != nil {
+		st = f.Template.Clone()
+	}
+	var vs []compose.PathValue
+	if len(f.MAC) == 6 {
+		vs = append(vs, compose.PathValue{Path: "RpdCapabilities.RpdIdentification.DeviceMacAddress", Value: addMAC(f.MAC, i).String()})
+	}
+	if f.Serial != "" {
+		vs = append(vs, compose.PathValue{Path: "RpdCapabilities.RpdIdentification.SerialNumber", Value: fmt.Sprintf(f.Serial, i+1)})
+	}
+	if len(vs) > 0 {
+		if err := st.Set(vs...); err != nil {
+			return nil, err
+		}
+	}
+	r := &RPD{Core: f.Core, Store: st, Schedules: f.Schedules, Jitter: f.Jitter, Strict: f.Strict}
+	if f.Setup != nil {
+		f.Setup(i, r)
+	}
+	f.mu.Lock()
+	f.rpds = append(f.rpds, r)
+	f.mu.Unlock()
+	return r, nil
+}
+
+// run runs r, the i-th RPD, keeping the statistics of its session.
+func (f *Fleet) run(i int, r *RPD) {
+	var (
+		once      sync.Once
+		start     = time.Now()
+		connected bool
+	)
+	r.served = func() {
+		once.Do(func() {
+			f.mu.Lock()
+			f.inited++
+			f.latency = append(f.latency, time.Since(start))
+			f.mu.Unlock()
+		})
+	}
+	r.OnEvent = func(e transport.Event) {
+		f.mu.Lock()
+		switch e.Type {
+		case transport.EventConnected, transport.EventReconnected, transport.EventHandover:
+			connected = true
+			f.up++
+		case transport.EventDisconnected:
+			f.up--
+			f.errs++
+		case transport.EventReconnectFailed:
+			f.errs++
+		}
+		f.mu.Unlock()
+		if f.OnEvent != nil {
+			f.OnEvent(i, e)
+		}
+	}
+	err := r.Run()
+	f.mu.Lock()
+	if err != nil && err != transport.ErrSessionClosed && !f.closed {
+		f.failed++
+		f.lastErr = err
+		if !connected {
+			// The first connection failed, which raises no event.
+			f.errs++
+		}
+	}
+	f.mu.Unlock()
+}
+
+// Stats returns the statistics of the fleet.
+func (f *Fleet) Stats() Stats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s := Stats{RPDs: len(f.rpds), Up: f.up, Initialized: f.inited, Errors: f.errs}
+	if n := len(f.latency); n > 0 {
+		l := append([]time.Duration(nil), f.latency...)
+		sort.Slice(l, func(i, j int) bool { return l[i] < l[j] })
+		at := func(p int) time.Duration { return l[(n*p+99)/100-1] }
+		s.P50, s.P90, s.P99, s.Max = at(50), at(90), at(99), l[n-1]
+	}
+	return s
+}
+
+// Each calls fn with every RPD started.
+func (f *Fleet) Each(fn func(r *RPD)) {
+	f.mu.Lock()
+	rpds := append([]*RPD(nil), f.rpds...)
+	f.mu.Unlock()
+	for _, r := range rpds {
+		fn(r)
+	}
+}
+
+// Close ends the associations of all RPDs with the core.
+func (f *Fleet) Close() error {
+	f.mu.Lock()
+	if !f.closed && f.quit != nil {
+		close(f.quit)
+	}
+	f.closed = true
+	f.mu.Unlock()
+	f.Each(func(r *RPD) { r.Close() })
+	return nil
+}
+
+// addMAC returns the MAC address mac plus n.
+func addMAC(mac net.HardwareAddr, n int) net.HardwareAddr {
+	b := make([]byte, 8)
+	copy(b[2:], mac)
+	v := binary.BigEndian.Uint64(b) + uint64(n)
+	binary.BigEndian.PutUint64(b, v)
+	return net.HardwareAddr(b[2:])
+}
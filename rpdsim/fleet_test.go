@@ -0,0 +1,182 @@
+package rpdsim_test
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/conform"
+	"github.com/nleiva/gcp-rphy/rpdsim"
+	"github.com/nleiva/gcp-rphy/transport"
+)
+
+func TestFleet(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		mu  sync.Mutex
+		ids = map[string]bool{}
+	)
+	c := &transport.Core{
+		Handler: transport.HandlerFunc(func(s *transport.Session, r *transport.Request) {
+			if _, ok := r.Msg.Body.(*gcp.NotifyReq); !ok {
+				return
+			}
+			m := gcp.NewMessage(gcp.MessageIDEDSReq, &gcp.EDSReq{
+				TransactionID: 1,
+				DataStr: gcp.EncodeSequence(gcp.TypeIRA, 1, gcp.OpRead,
+					gcp.EncodeTLV(50, gcp.EncodeTLV(19, gcp.EncodeTLV(4), gcp.EncodeTLV(9)))),
+			})
+			go func() {
+				res, err := s.Request(m, time.Second)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				ids[string(res.Msg.Body.(*gcp.EDSRes).DataStr)] = true
+				mu.Unlock()
+			}()
+		}),
+	}
+	go c.Serve(l)
+	defer c.Close()
+
+	const n = 20
+	f := &rpdsim.Fleet{
+		Core:     l.Addr().String(),
+		Count:    n,
+		Template: &rpdsim.Store{},
+		MAC:      net.HardwareAddr{0x02, 0, 0, 0, 0, 0xff},
+		Serial:   "SIM%04d",
+		Ramp:     50 * time.Millisecond,
+		Jitter:   5 * time.Millisecond,
+	}
+	done := make(chan error)
+	go func() { done <- f.Run() }()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		mu.Lock()
+		read := len(ids)
+		mu.Unlock()
+		if read == n {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	s := f.Stats()
+	if s.RPDs != n || s.Up != n || s.Initialized != n || s.Errors != 0 {
+		t.Errorf("Stats got: %v, want %d RPDs up and initialized", s, n)
+	}
+	if s.P50 <= 0 || s.P50 > s.P90 || s.P90 > s.P99 || s.P99 > s.Max {
+		t.Errorf("Stats got latencies: %v", s)
+	}
+
+	// The last RPD has the MAC address 02:00:00:00:01:12 and serial number SIM0020.
+	last := gcp.EncodeSequence(gcp.TypeIRA, 1, gcp.OpReadResponse,
+		gcp.EncodeTLV(19, []byte{byte(gcp.RespNoError)}),
+		gcp.EncodeTLV(50, gcp.EncodeTLV(19,
+			gcp.EncodeTLV(4, []byte{0x02, 0, 0, 0, 0x01, 0x12}),
+			gcp.EncodeTLV(9, []byte("SIM0020")))))
+	mu.Lock()
+	if len(ids) != n || !ids[string(last)] {
+		var got [][]byte
+		for id := range ids {
+			got = append(got, []byte(id))
+		}
+		t.Errorf("identities read: %x, want %d including %x", bytes.Join(got, []byte(" ")), n, last)
+	}
+	mu.Unlock()
+
+	f.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run got: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Run did not return after Close")
+	}
+}
+
+func TestRPDFailedRequest(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A write whose Top Level TLV is cut short, which the Store fails to
+	// process.
+	ds := gcp.EncodeSequence(gcp.TypeIRA, 7, gcp.OpWrite,
+		gcp.EncodeTLV(50, gcp.EncodeTLV(19, gcp.EncodeTLV(8, []byte("node-1")))))
+	res := make(chan []byte, 1)
+	c := &transport.Core{
+		Handler: transport.HandlerFunc(func(s *transport.Session, r *transport.Request) {
+			if _, ok := r.Msg.Body.(*gcp.NotifyReq); !ok {
+				return
+			}
+			m := gcp.NewMessage(gcp.MessageIDEDSReq, &gcp.EDSReq{TransactionID: 1, DataStr: ds[:len(ds)-1]})
+			go func() {
+				r, err := s.Request(m, time.Second)
+				if err != nil {
+					t.Error(err)
+					res <- nil
+					return
+				}
+				res <- r.Msg.Body.(*gcp.EDSRes).DataStr
+			}()
+		}),
+	}
+	go c.Serve(l)
+	defer c.Close()
+
+	r := &rpdsim.RPD{Core: l.Addr().String(), Store: &rpdsim.Store{}}
+	go r.Run()
+	defer r.Close()
+
+	want := gcp.EncodeSequence(gcp.TypeIRA, 7, gcp.OpWriteResponse,
+		gcp.EncodeTLV(19, []byte{byte(gcp.RespGeneralError)}))
+	select {
+	case got := <-res:
+		if !bytes.Equal(got, want) {
+			t.Errorf("response got: %x, want: %x", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no response to the request")
+	}
+}
+
+func TestRPDConform(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := &rpdsim.Store{}
+	if err := st.Load([]byte(config)); err != nil {
+		t.Fatal(err)
+	}
+	r := &rpdsim.RPD{Core: l.Addr().String(), Store: st, Strict: true}
+	go r.Run()
+	defer r.Close()
+
+	// The cases answered from the Store.
+	var cases []conform.Case
+	for _, c := range conform.Cases(conform.RPD) {
+		switch c.Name {
+		case "valid-request", "oversized-tlv", "unknown-tlv":
+			cases = append(cases, c)
+		}
+	}
+	ru := &conform.Runner{Timeout: time.Second, Wait: 5 * time.Second}
+	rep := ru.Accept(l, cases)
+	if rep.Failed() != 0 || len(rep.Results) != 3 {
+		var b strings.Builder
+		rep.Write(&b)
+		t.Errorf("report got:\n%s", &b)
+	}
+}
@@ -0,0 +1,240 @@
+// Package rpdsim simulates an RPD, so CCAP Cores can be tested without
+// hardware. A simulated RPD connects to its core, announces its start up,
+// answers the RCP reads and writes of the core from a Store loaded with
+// its identity, capabilities and interfaces, keeps the values written,
+// and raises notifications on a schedule or when asked to.
+package rpdsim
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/transport"
+)
+
+// Error messages
+var (
+	errNotFound = errors.New("attribute not found")
+	errSchedule = errors.New("schedule must be type@interval, like TimeOutNotification@30s")
+)
+
+// A Schedule raises a notification periodically.
+type Schedule struct {
+	Type  gcp.NotificationType
+	Every time.Duration
+}
+
+// ParseSchedule parses a schedule written as type@interval, where type
+// is a notification type name or number, like "TimeOutNotification@30s".
+func ParseSchedule(s string) (Schedule, error) {
+	i := strings.LastIndex(s, "@")
+	if i < 0 {
+		return Schedule{}, errSchedule
+	}
+	nt, err := ParseNotificationType(s[:i])
+	if err != nil {
+		return Schedule{}, err
+	}
+	d, err := time.ParseDuration(s[i+1:])
+	if err != nil || d <= 0 {
+		return Schedule{}, errSchedule
+	}
+	return Schedule{Type: nt, Every: d}, nil
+}
+
+// ParseNotificationType returns the notification type named or numbered
+// s.
+func ParseNotificationType(s string) (gcp.NotificationType, error) {
+	if ts := gcp.LookupTLV(gcp.TypeNTF, 9, 86, 1); ts != nil {
+		for v, name := range ts.Enum {
+			if strings.EqualFold(name, s) {
+				return gcp.NotificationType(v), nil
+			}
+		}
+	}
+	n, err := strconv.ParseUint(s, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("unknown notification type %q", s)
+	}
+	return gcp.NotificationType(n), nil
+}
+
+// An RPD is a simulated RPD.
+type RPD struct {
+	Core      string                // Address (host:port) of the CCAP Core
+	Store     *Store                // RCP objects of the RPD
+	Schedules []Schedule            // Notifications raised periodically
+	Jitter    time.Duration         // Answers are delayed randomly up to Jitter
+	Strict    bool                  // Requests that violate the GCP specification get Error Responses
+	OnEvent   func(transport.Event) // Optional, called when the association changes state
+	Recorder  transport.Recorder    // Optional, records the units exchanged
+	// Print, if set, prints the messages received from peer and the
+	// responses sent to it.
+	Print func(sent bool, peer string, tid *uint16, m *gcp.Message)
+
+	served func() // Called before answering a request, for Fleet
+
+	mu     sync.Mutex
+	rpd    *transport.RPD
+	closed bool
+}
+
+// Run connects the RPD to its core and answers it until Close is called,
+// or reconnecting to the core fails.
+func (r *RPD) Run() error {
+	var h transport.Handler = transport.HandlerFunc(r.serve)
+	if r.Strict {
+		h = transport.StrictHandler(h)
+	}
+	t := &transport.RPD{
+		Core:     r.Core,
+		Handler:  h,
+		OnEvent:  r.OnEvent,
+		Recorder: r.Recorder,
+	}
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return transport.ErrSessionClosed
+	}
+	r.rpd = t
+	r.mu.Unlock()
+
+	quit := make(chan struct{})
+	defer close(quit)
+	for _, s := range r.Schedules {
+		go r.every(s, quit)
+	}
+	return t.Run()
+}
+
+// every raises the notification of s periodically until quit is closed.
+// Notifications due while the RPD is not connected are skipped.
+func (r *RPD) every(s Schedule, quit chan struct{}) {
+	tick := time.NewTicker(s.Every)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			r.Notify(s.Type)
+		case <-quit:
+			return
+		}
+	}
+}
+
+// Notify sends a GeneralNotification of type nt to the core.
+func (r *RPD) Notify(nt gcp.NotificationType) error {
+	r.mu.Lock()
+	t := r.rpd
+	r.mu.Unlock()
+	if t == nil {
+		return transport.ErrSessionClosed
+	}
+	return t.Notify(nt)
+}
+
+// Close ends the association with the core.
+func (r *RPD) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	t := r.rpd
+	r.mu.Unlock()
+	if t != nil {
+		return t.Close()
+	}
+	return nil
+}
+
+// serve answers the EDS Requests of the core from the Store.
+func (r *RPD) serve(s *transport.Session, q *transport.Request) {
+	peer := s.RemoteAddr().String()
+	if r.Print != nil {
+		r.Print(false, peer, &q.TranID, q.Msg)
+	}
+	req, ok := q.Msg.Body.(*gcp.EDSReq)
+	if !ok {
+		return
+	}
+	ds, err := r.Store.Exchange(req.DataStr)
+	if err != nil {
+		ds = failed(req.DataStr)
+	}
+	m := gcp.NewMessage(gcp.MessageIDEDSRes, &gcp.EDSRes{
+		TransactionID: req.TransactionID,
+		Mode:          req.Mode,
+		Port:          req.Port,
+		Channel:       req.Channel,
+		VendorID:      req.VendorID,
+		VendorIdx:     req.VendorIdx,
+		DataStr:       ds,
+	})
+	if r.Jitter > 0 {
+		time.Sleep(time.Duration(rand.Int63n(int64(r.Jitter))))
+	}
+	if r.Print != nil {
+		r.Print(true, peer, &q.TranID, m)
+	}
+	if r.served != nil {
+		r.served()
+	}
+	s.Reply(q, m)
+}
+
+// failed returns the Data Structures answering b, which the Store could
+// not process, with a GeneralError. The response carries the Top Level
+// TLV, sequence number and operation of the first Sequence in b, as far
+// as they can be read.
+func failed(b []byte) []byte {
+	top, seq, op := gcp.TypeREX, uint16(0), gcp.OpRead
+	if len(b) > 0 {
+		top = b[0]
+	}
+	found := false
+	eachReadable(value(b), func(t uint8, v []byte) {
+		if t != 9 || found {
+			return
+		}
+		found = true
+		eachReadable(v, func(t uint8, v []byte) {
+			switch {
+			case t == 10 && len(v) == 2:
+				seq = binary.BigEndian.Uint16(v)
+			case t == 11 && len(v) == 1:
+				op = gcp.Operation(v[0])
+			}
+		})
+	})
+	return gcp.EncodeSequence(top, seq, response(op),
+		gcp.EncodeTLV(19, []byte{byte(gcp.RespGeneralError)}))
+}
+
+// eachReadable calls fn with the type and value of each TLV in b, the
+// last one cut short if b is.
+func eachReadable(b []byte, fn func(t uint8, v []byte)) {
+	for len(b) >= 3 {
+		v := value(b)
+		fn(b[0], v)
+		b = b[3+len(v):]
+	}
+}
+
+// value returns the value of the TLV at the start of b, cut short if b
+// is.
+func value(b []byte) []byte {
+	if len(b) < 3 {
+		return nil
+	}
+	l := 3 + int(binary.BigEndian.Uint16(b[1:3]))
+	if l > len(b) {
+		l = len(b)
+	}
+	return b[3:l]
+}
@@ -0,0 +1,301 @@
+package rpdsim
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/compose"
+)
+
+// tables are the RCP objects with many entries, by their types below the
+// Sequence TLV, with the number of leading TLVs that index the entries.
+var tables = map[string]int{
+	"100.8":  1, // IfEnet: EnetPortIndex
+	"100.15": 2, // IpAddress: AddrType, IpAddress
+}
+
+// A Store keeps the RCP objects of a simulated RPD, as a tree of TLVs
+// below the Sequence TLV. The zero value is an empty store.
+type Store struct {
+	mu   sync.Mutex
+	root node
+}
+
+type node struct {
+	typ  uint8
+	val  []byte
+	kids []*node
+}
+
+// child returns the child of n of type t, adding it if create is set.
+func (n *node) child(t uint8, create bool) *node {
+	for _, c := range n.kids {
+		if c.typ == t {
+			return c
+		}
+	}
+	if !create {
+		return nil
+	}
+	c := &node{typ: t}
+	n.kids = append(n.kids, c)
+	return c
+}
+
+// entry returns the table entry of n of type t indexed by key, adding it
+// if create is set.
+func (n *node) entry(t uint8, k int, key []byte, create bool) *node {
+	for _, c := range n.kids {
+		if c.typ == t && bytes.Equal(c.key(k), key) {
+			return c
+		}
+	}
+	if !create {
+		return nil
+	}
+	c := &node{typ: t}
+	n.kids = append(n.kids, c)
+	return c
+}
+
+// key returns the first k children of n, which index a table entry.
+func (n *node) key(k int) []byte {
+	var b []byte
+	for i := 0; i < k && i < len(n.kids); i++ {
+		b = append(b, n.kids[i].encode()...)
+	}
+	return b
+}
+
+// encode returns the TLV n, with its children if it has any.
+func (n *node) encode() []byte {
+	if len(n.kids) == 0 {
+		return gcp.EncodeTLV(n.typ, n.val)
+	}
+	var v [][]byte
+	for _, c := range n.kids {
+		v = append(v, c.encode())
+	}
+	return gcp.EncodeTLV(n.typ, v...)
+}
+
+// Load writes the RCP objects described by the JSON or YAML document doc,
+// as documented by compose.TLVs.
+func (st *Store) Load(doc []byte) error {
+	b, err := compose.TLVs(doc, gcp.TypeREX)
+	if err != nil {
+		return err
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.write(&st.root, b, []uint8{gcp.TypeREX, 9})
+}
+
+// Set writes the values of vs.
+func (st *Store) Set(vs ...compose.PathValue) error {
+	b, err := compose.Sequence(gcp.TypeREX, 0, gcp.OpWrite, vs...)
+	if err != nil {
+		return err
+	}
+	_, err = st.Exchange(b)
+	return err
+}
+
+// Exchange applies the RCP operations in b, the Data Structures of an
+// EDS Request, and returns the Data Structures of the response. Reads
+// and writes are answered with a Response Code: WrongLength for TLVs
+// that run past their parent, AttributeNotFound for reads of TLVs never
+// written. Other operations get a GeneralError.
+func (st *Store) Exchange(b []byte) ([]byte, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	var res []byte
+	err := gcp.EachTLV(b, func(top uint8, v []byte) error {
+		return gcp.EachTLV(v, func(t uint8, v []byte) error {
+			if t != 9 {
+				return nil
+			}
+			var (
+				seq  uint16
+				op   gcp.Operation
+				tlvs []byte
+			)
+			err := gcp.EachTLV(v, func(t uint8, v []byte) error {
+				switch {
+				case t == 10 && len(v) == 2:
+					seq = binary.BigEndian.Uint16(v)
+				case t == 11 && len(v) == 1:
+					op = gcp.Operation(v[0])
+				default:
+					tlvs = append(tlvs, gcp.EncodeTLV(t, v)...)
+				}
+				return nil
+			})
+			path := []uint8{top, 9}
+			code, data := gcp.RespNoError, []byte(nil)
+			if err == nil {
+				switch op {
+				case gcp.OpRead:
+					data, err = st.read(&st.root, tlvs, path)
+				case gcp.OpWrite:
+					err = st.write(&st.root, tlvs, path)
+				default:
+					code = gcp.RespGeneralError
+				}
+			}
+			switch err {
+			case nil:
+			case gcp.ErrTruncatedTLV:
+				code, data = gcp.RespWrongLength, nil
+			case errNotFound:
+				code, data = gcp.RespAttributeNotFound, nil
+			default:
+				code, data = gcp.RespGeneralError, nil
+			}
+			res = append(res, gcp.EncodeSequence(top, seq, response(op),
+				gcp.EncodeTLV(19, []byte{byte(code)}), data)...)
+			return nil
+		})
+	})
+	return res, err
+}
+
+// response returns the operation answering op, or op if it is not a
+// request.
+func response(op gcp.Operation) gcp.Operation {
+	switch op {
+	case gcp.OpRead:
+		return gcp.OpReadResponse
+	case gcp.OpWrite:
+		return gcp.OpWriteResponse
+	case gcp.OpDelete:
+		return gcp.OpDeleteResponse
+	case gcp.OpAllocateWrite:
+		return gcp.OpAllocateWriteResponse
+	}
+	return op
+}
+
+// read returns the TLVs in b, below n at the schema path, with the values
+// stored. Empty Complex TLVs are read whole, and table entries whose
+// index is left out are all read. A TLV never written, or a table entry
+// not found by its index, fails the read with errNotFound.
+func (st *Store) read(n *node, b []byte, path []uint8) ([]byte, error) {
+	var out []byte
+	err := gcp.EachTLV(b, func(t uint8, v []byte) error {
+		p := append(path[:len(path):len(path)], t)
+		k, ok := tables[relPath(p)]
+		if !ok {
+			c := n.child(t, false)
+			if c == nil {
+				return errNotFound
+			}
+			if len(v) == 0 || len(c.kids) == 0 {
+				out = append(out, c.encode()...)
+				return nil
+			}
+			sub, err := st.read(c, v, p)
+			if len(sub) > 0 {
+				out = append(out, gcp.EncodeTLV(t, sub)...)
+			}
+			return err
+		}
+
+		key, rest, indexed := splitKey(v, k, p)
+		found := false
+		for _, c := range n.kids {
+			if c.typ != t || indexed && !bytes.Equal(c.key(k), key) {
+				continue
+			}
+			found = true
+			if len(rest) == 0 {
+				out = append(out, c.encode()...)
+				continue
+			}
+			sub, err := st.read(c, rest, p)
+			if err != nil {
+				return err
+			}
+			out = append(out, gcp.EncodeTLV(t, c.key(k), sub)...)
+		}
+		if indexed && !found {
+			return errNotFound
+		}
+		return nil
+	})
+	return out, err
+}
+
+// write stores the values of the TLVs in b, below n at the schema path.
+func (st *Store) write(n *node, b []byte, path []uint8) error {
+	return gcp.EachTLV(b, func(t uint8, v []byte) error {
+		p := append(path[:len(path):len(path)], t)
+		s := gcp.LookupTLV(p...)
+		if s == nil || s.Kind != gcp.KindComplex {
+			c := n.child(t, true)
+			c.val = append([]byte(nil), v...)
+			return nil
+		}
+		k, ok := tables[relPath(p)]
+		if !ok {
+			return st.write(n.child(t, true), v, p)
+		}
+		key, _, indexed := splitKey(v, k, p)
+		if !indexed {
+			return fmt.Errorf("%s entry without its index", s.Name)
+		}
+		return st.write(n.entry(t, k, key, true), v, p)
+	})
+}
+
+// splitKey splits v, the value of an entry of a table at path indexed by
+// its first k TLVs, into those TLVs and the rest. It reports whether v
+// starts with the index TLVs.
+func splitKey(v []byte, k int, path []uint8) (key, rest []byte, ok bool) {
+	s := gcp.LookupTLV(path...)
+	if s == nil || len(s.Sub) < k {
+		return nil, v, false
+	}
+	rest = v
+	for i := 0; i < k; i++ {
+		if len(rest) < 3 || rest[0] != s.Sub[i].Type {
+			return nil, v, false
+		}
+		l := 3 + int(binary.BigEndian.Uint16(rest[1:3]))
+		if l > len(rest) {
+			return nil, v, false
+		}
+		rest = rest[l:]
+	}
+	return v[:len(v)-len(rest)], rest, true
+}
+
+// relPath returns the types of path below the Sequence TLV, dot
+// separated.
+func relPath(path []uint8) string {
+	ts := make([]string, 0, len(path))
+	for _, t := range path[2:] {
+		ts = append(ts, strconv.Itoa(int(t)))
+	}
+	return strings.Join(ts, ".")
+}
+
+// Clone returns a copy of the store.
+func (st *Store) Clone() *Store {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return &Store{root: *st.root.clone()}
+}
+
+func (n *node) clone() *node {
+	c := &node{typ: n.typ, val: n.val, kids: make([]*node, len(n.kids))}
+	for i, k := range n.kids {
+		c.kids[i] = k.clone()
+	}
+	return c
+}
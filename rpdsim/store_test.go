@@ -0,0 +1,129 @@
+package rpdsim_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/rpdsim"
+)
+
+const config = `
+RpdCapabilities:
+  RpdIdentification:
+    VendorName: acme
+RpdInfo:
+  IfEnet:
+    - EnetPortIndex: 1
+      Name: eth0
+    - EnetPortIndex: 2
+      Name: eth1
+`
+
+var (
+	eth0 = gcp.EncodeTLV(8, gcp.EncodeTLV(1, []byte{1}), gcp.EncodeTLV(2, []byte("eth0")))
+	eth1 = gcp.EncodeTLV(8, gcp.EncodeTLV(1, []byte{2}), gcp.EncodeTLV(2, []byte("eth1")))
+	ok   = gcp.EncodeTLV(19, []byte{byte(gcp.RespNoError)})
+)
+
+func TestStore(t *testing.T) {
+	st := &rpdsim.Store{}
+	if err := st.Load([]byte(config)); err != nil {
+		t.Fatal(err)
+	}
+	tt := []struct {
+		name string
+		req  []byte
+		want []byte
+	}{
+		{
+			name: "read whole object",
+			req:  gcp.EncodeSequence(gcp.TypeREX, 1, gcp.OpRead, gcp.EncodeTLV(100)),
+			want: gcp.EncodeSequence(gcp.TypeREX, 1, gcp.OpReadResponse, ok, gcp.EncodeTLV(100, eth0, eth1)),
+		},
+		{
+			name: "read over IRA",
+			req:  gcp.EncodeSequence(gcp.TypeIRA, 2, gcp.OpRead, gcp.EncodeTLV(50, gcp.EncodeTLV(19, gcp.EncodeTLV(1)))),
+			want: gcp.EncodeSequence(gcp.TypeIRA, 2, gcp.OpReadResponse, ok,
+				gcp.EncodeTLV(50, gcp.EncodeTLV(19, gcp.EncodeTLV(1, []byte("acme"))))),
+		},
+		{
+			name: "write a table entry",
+			req: gcp.EncodeSequence(gcp.TypeREX, 3, gcp.OpWrite,
+				gcp.EncodeTLV(100, gcp.EncodeTLV(8, gcp.EncodeTLV(1, []byte{2}), gcp.EncodeTLV(2, []byte("north"))))),
+			want: gcp.EncodeSequence(gcp.TypeREX, 3, gcp.OpWriteResponse, ok),
+		},
+		{
+			name: "read a table entry",
+			req: gcp.EncodeSequence(gcp.TypeREX, 4, gcp.OpRead,
+				gcp.EncodeTLV(100, gcp.EncodeTLV(8, gcp.EncodeTLV(1, []byte{2}), gcp.EncodeTLV(2)))),
+			want: gcp.EncodeSequence(gcp.TypeREX, 4, gcp.OpReadResponse, ok,
+				gcp.EncodeTLV(100, gcp.EncodeTLV(8, gcp.EncodeTLV(1, []byte{2}), gcp.EncodeTLV(2, []byte("north"))))),
+		},
+		{
+			name: "read a field of every entry",
+			req: gcp.EncodeSequence(gcp.TypeREX, 5, gcp.OpRead,
+				gcp.EncodeTLV(100, gcp.EncodeTLV(8, gcp.EncodeTLV(2)))),
+			want: gcp.EncodeSequence(gcp.TypeREX, 5, gcp.OpReadResponse, ok,
+				gcp.EncodeTLV(100,
+					gcp.EncodeTLV(8, gcp.EncodeTLV(1, []byte{1}), gcp.EncodeTLV(2, []byte("eth0"))),
+					gcp.EncodeTLV(8, gcp.EncodeTLV(1, []byte{2}), gcp.EncodeTLV(2, []byte("north"))))),
+		},
+		{
+			name: "write a table entry without its index",
+			req: gcp.EncodeSequence(gcp.TypeREX, 6, gcp.OpWrite,
+				gcp.EncodeTLV(100, gcp.EncodeTLV(8, gcp.EncodeTLV(2, []byte("eth9"))))),
+			want: gcp.EncodeSequence(gcp.TypeREX, 6, gcp.OpWriteResponse,
+				gcp.EncodeTLV(19, []byte{byte(gcp.RespGeneralError)})),
+		},
+		{
+			name: "read an attribute never written",
+			req: gcp.EncodeSequence(gcp.TypeIRA, 8, gcp.OpRead,
+				gcp.EncodeTLV(50, gcp.EncodeTLV(19, gcp.EncodeTLV(1), gcp.EncodeTLV(8)))),
+			want: gcp.EncodeSequence(gcp.TypeIRA, 8, gcp.OpReadResponse,
+				gcp.EncodeTLV(19, []byte{byte(gcp.RespAttributeNotFound)})),
+		},
+		{
+			name: "read a missing table entry",
+			req: gcp.EncodeSequence(gcp.TypeREX, 9, gcp.OpRead,
+				gcp.EncodeTLV(100, gcp.EncodeTLV(8, gcp.EncodeTLV(1, []byte{3})))),
+			want: gcp.EncodeSequence(gcp.TypeREX, 9, gcp.OpReadResponse,
+				gcp.EncodeTLV(19, []byte{byte(gcp.RespAttributeNotFound)})),
+		},
+		{
+			name: "read a TLV longer than its parent",
+			req:  gcp.EncodeSequence(gcp.TypeIRA, 10, gcp.OpRead, []byte{50, 0, 0xff, 19, 0, 0}),
+			want: gcp.EncodeSequence(gcp.TypeIRA, 10, gcp.OpReadResponse,
+				gcp.EncodeTLV(19, []byte{byte(gcp.RespWrongLength)})),
+		},
+		{
+			name: "delete",
+			req:  gcp.EncodeSequence(gcp.TypeREX, 7, gcp.OpDelete, gcp.EncodeTLV(100)),
+			want: gcp.EncodeSequence(gcp.TypeREX, 7, gcp.OpDeleteResponse,
+				gcp.EncodeTLV(19, []byte{byte(gcp.RespGeneralError)})),
+		},
+	}
+	for _, tc := range tt {
+		got, err := st.Exchange(tc.req)
+		if err != nil {
+			t.Errorf("%s failed: %v", tc.name, err)
+			continue
+		}
+		if !bytes.Equal(got, tc.want) {
+			t.Errorf("%s got: %x, want: %x", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestParseSchedule(t *testing.T) {
+	s, err := rpdsim.ParseSchedule("timeoutnotification@30s")
+	if err != nil || s.Type != gcp.TimeOutNotification || s.Every != 30*time.Second {
+		t.Errorf("ParseSchedule got: %v, %v", s, err)
+	}
+	for _, in := range []string{"TimeOutNotification", "Bogus@1s", "4@never", "4@0s"} {
+		if _, err := rpdsim.ParseSchedule(in); err == nil {
+			t.Errorf("ParseSchedule(%q) got no error", in)
+		}
+	}
+}
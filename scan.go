@@ -0,0 +1,192 @@
+package gcp
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// maxScanDepth bounds the nesting of the TLVs a Scanner descends into.
+const maxScanDepth = 16
+
+// A Scanner walks the RCP TLVs of a message, like the RCP field of a
+// message body, depth first, descending into the Complex TLVs known to
+// the Schema. Unlike ParseMessage, it neither copies the TLVs nor builds
+// a tree of them: values reference the buffer scanned, so a Scanner
+// reused with Reset allocates nothing. Callers pick the TLVs they care
+// about by their paths, and Skip the Complex TLVs they do not.
+//
+//	s := gcp.NewScanner(b)
+//	for s.Next() {
+//		if s.Match("3.9.86.1") {
+//			// Notification type
+//		}
+//	}
+//	if err := s.Err(); err != nil {
+//		// Malformed TLV
+//	}
+type Scanner struct {
+	buf     []byte
+	next    int // Offset of the next TLV header
+	start   int // Offset of the current TLV header
+	val     []byte
+	cur     *TLVSchema
+	descend bool
+	depth   int // Nesting level of the current TLV, 1 for top level TLVs
+	path    [maxScanDepth]uint8
+	ends    [maxScanDepth]int        // Offset where each level ends
+	parents [maxScanDepth]*TLVSchema // Schema of the TLV holding each level
+	err     error
+}
+
+// NewScanner returns a Scanner for the RCP TLVs in b.
+func NewScanner(b []byte) *Scanner {
+	s := new(Scanner)
+	s.Reset(b)
+	return s
+}
+
+// Reset makes s scan the RCP TLVs in b from the start.
+func (s *Scanner) Reset(b []byte) {
+	Schema()
+	*s = Scanner{buf: b}
+	s.ends[0] = len(b)
+	s.parents[0] = schemaRoot
+}
+
+// Next advances to the next TLV, descending into the current one if it
+// is Complex and not skipped. It returns false when there are no more
+// TLVs or a malformed one was found, see Err.
+func (s *Scanner) Next() bool {
+	if s.err != nil {
+		return false
+	}
+	if s.descend && s.depth < maxScanDepth {
+		s.ends[s.depth] = s.next
+		s.parents[s.depth] = s.cur
+		s.next = s.start + 3
+	} else if s.depth > 0 {
+		s.depth--
+	}
+	for s.depth > 0 && s.next == s.ends[s.depth] {
+		s.depth--
+	}
+	end := s.ends[s.depth]
+	if s.next == end {
+		s.cur, s.val, s.descend = nil, nil, false
+		return false
+	}
+	if end-s.next < 3 {
+		return s.fail(fmt.Errorf("TLV header needs 3 bytes, %d left. Index: %d", end-s.next, s.next))
+	}
+	l := int(binary.BigEndian.Uint16(s.buf[s.next+1 : s.next+3]))
+	if s.next+3+l > end {
+		return s.fail(fmt.Errorf("TLV length %d exceeds the %d bytes left. Index: %d, Type: %v", l, end-s.next-3, s.next, s.buf[s.next]))
+	}
+	typ := s.buf[s.next]
+	s.start = s.next
+	s.next += 3 + l
+	s.val = s.buf[s.start+3 : s.next]
+	s.path[s.depth] = typ
+	s.cur = s.parents[s.depth].Child(typ)
+	s.descend = s.cur != nil && s.cur.Kind == KindComplex
+	s.depth++
+	return true
+}
+
+func (s *Scanner) fail(err error) bool {
+	s.err = err
+	s.cur, s.val, s.descend = nil, nil, false
+	return false
+}
+
+// Skip makes Next move past the TLVs nested in the current one.
+func (s *Scanner) Skip() { s.descend = false }
+
+// Err returns the error that stopped the scan, or nil if the TLVs were
+// well formed.
+func (s *Scanner) Err() error { return s.err }
+
+// Type returns the type of the current TLV.
+func (s *Scanner) Type() uint8 {
+	if s.depth == 0 {
+		return 0
+	}
+	return s.path[s.depth-1]
+}
+
+// Depth returns the nesting level of the current TLV, 1 for top level
+// TLVs.
+func (s *Scanner) Depth() int { return s.depth }
+
+// Path returns the types of the TLVs leading to the current one,
+// starting from the top level and ending with its own. It is only valid
+// until the next call to Next.
+func (s *Scanner) Path() []uint8 { return s.path[:s.depth] }
+
+// Offset returns the offset of the current TLV in the buffer scanned.
+func (s *Scanner) Offset() int { return s.start }
+
+// Value returns the value of the current TLV, which references the
+// buffer scanned. The value of a Complex TLV is the encoding of its
+// nested TLVs.
+func (s *Scanner) Value() []byte { return s.val }
+
+// Schema returns the schema of the current TLV, or nil if it is unknown
+// to the decoder. Unknown TLVs are not descended into.
+func (s *Scanner) Schema() *TLVSchema { return s.cur }
+
+// Name returns the name of the current TLV, or an empty string if it is
+// unknown to the decoder.
+func (s *Scanner) Name() string {
+	if s.cur == nil {
+		return ""
+	}
+	return s.cur.Name
+}
+
+// Uint returns the value of the current TLV as a big endian unsigned
+// integer, and whether it is one to eight bytes long.
+func (s *Scanner) Uint() (uint64, bool) {
+	if len(s.val) == 0 || len(s.val) > 8 {
+		return 0, false
+	}
+	var u uint64
+	for _, b := range s.val {
+		u = u<<8 | uint64(b)
+	}
+	return u, true
+}
+
+// Match reports whether the path of the current TLV is path: the types
+// of the TLVs leading to it in decimal, dot separated, like "2.9.50.19.1"
+// for the VendorName of a REX message. A "*" matches any type.
+func (s *Scanner) Match(path string) bool {
+	i := 0
+	for d := 0; d < s.depth; d++ {
+		if i >= len(path) {
+			return false
+		}
+		if d > 0 {
+			if path[i] != '.' {
+				return false
+			}
+			i++
+		}
+		if i < len(path) && path[i] == '*' {
+			i++
+			continue
+		}
+		n, j := 0, i
+		for ; j < len(path) && path[j] >= '0' && path[j] <= '9'; j++ {
+			n = n*10 + int(path[j]-'0')
+			if n > 0xff {
+				return false
+			}
+		}
+		if j == i || uint8(n) != s.path[d] {
+			return false
+		}
+		i = j
+	}
+	return i == len(path)
+}
@@ -0,0 +1,196 @@
+package gcp_test
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"testing"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/hexdump"
+)
+
+// rcpField returns the GCP message b64 and the offset of its RCP field.
+func rcpField(t *testing.T, b64 string) ([]byte, int) {
+	t.Helper()
+	b, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		t.Fatalf("could not decode message: %v", err)
+	}
+	for _, f := range gcp.BodyLayout(gcp.MessageID(b[0])) {
+		if f.RCP {
+			return b, 3 + f.Offset
+		}
+	}
+	t.Fatalf("%v has no RCP field", gcp.MessageID(b[0]))
+	return nil, 0
+}
+
+func TestScanner(t *testing.T) {
+	tt := []struct {
+		name    string
+		message string
+	}{
+		{name: "Notify", message: ntf},
+		{name: "RCP Object Exchange", message: rex},
+		{name: "Identification and Resource Advertising", message: ira},
+	}
+	for _, tc := range tt {
+		b, off := rcpField(t, tc.message)
+		// The Scanner walks the TLVs hexdump annotates.
+		var want []hexdump.Field
+		for _, f := range hexdump.Fields(b) {
+			if f.Depth > 0 {
+				want = append(want, f)
+			}
+		}
+		s := gcp.NewScanner(b[off:])
+		var n int
+		for ; s.Next(); n++ {
+			if n >= len(want) {
+				t.Fatalf("%s: got more than %d TLVs", tc.name, len(want))
+			}
+			w := want[n]
+			if got := pathString(s.Path()); got != w.Path || s.Depth() != w.Depth || off+s.Offset() != w.Offset {
+				t.Errorf("%s: TLV %d got: %s at depth %d, offset %d, want: %s at depth %d, offset %d",
+					tc.name, n, got, s.Depth(), off+s.Offset(), w.Path, w.Depth, w.Offset)
+			}
+			if name := s.Name(); name != w.Name && !(name == "" && w.Err == hexdump.ErrUnknownTLV) {
+				t.Errorf("%s: TLV %s got: %q, want: %q", tc.name, w.Path, name, w.Name)
+			}
+		}
+		if err := s.Err(); err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		}
+		if n != len(want) {
+			t.Errorf("%s: TLVs got: %d, want: %d", tc.name, n, len(want))
+		}
+	}
+}
+
+func pathString(p []uint8) string {
+	var s []string
+	for _, t := range p {
+		s = append(s, strconv.Itoa(int(t)))
+	}
+	return strings.Join(s, ".")
+}
+
+func TestScannerMatch(t *testing.T) {
+	b, off := rcpField(t, ntf)
+	var s gcp.Scanner
+	s.Reset(b[off:])
+	var top int
+	var seq uint64
+	for s.Next() {
+		switch {
+		case s.Depth() == 1:
+			top++
+		case s.Match("*.9.10"):
+			seq, _ = s.Uint()
+		case s.Match("3.9.50"):
+			// Not interested in the capabilities.
+			s.Skip()
+		case s.Depth() > 3 && s.Path()[2] == 50:
+			t.Errorf("TLV %v not skipped", s.Path())
+		}
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if top != 1 || seq != 1 {
+		t.Errorf("got: %d top level TLVs, SequenceNumber %d, want: 1 top level TLV, SequenceNumber 1", top, seq)
+	}
+
+	tt := []struct {
+		path string
+		want bool
+	}{
+		{path: "3.9.10", want: true},
+		{path: "*.*.*", want: true},
+		{path: "3.9", want: false},
+		{path: "3.9.10.1", want: false},
+		{path: "3.9.11", want: false},
+		{path: "3.9.266", want: false},
+		{path: "3..10", want: false},
+		{path: "", want: false},
+	}
+	s.Reset(b[off:])
+	for s.Next() && !s.Match("3.9.10") {
+	}
+	for _, tc := range tt {
+		if got := s.Match(tc.path); got != tc.want {
+			t.Errorf("Match(%q) got: %v, want: %v", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestScannerErr(t *testing.T) {
+	tt := []struct {
+		name string
+		b    []byte
+		tlvs int
+	}{
+		{name: "Truncated header", b: gcp.EncodeTLV(3, gcp.EncodeTLV(9), []byte{10, 0}), tlvs: 2},
+		{name: "Length overflow", b: gcp.EncodeTLV(3, gcp.EncodeTLV(9, []byte{10, 0, 5, 0})), tlvs: 2},
+		{name: "Trailing bytes", b: append(gcp.EncodeTLV(1), 1), tlvs: 1},
+	}
+	for _, tc := range tt {
+		s := gcp.NewScanner(tc.b)
+		var n int
+		for s.Next() {
+			n++
+		}
+		if s.Err() == nil {
+			t.Errorf("%s: got no error", tc.name)
+		}
+		if n != tc.tlvs {
+			t.Errorf("%s: TLVs got: %d, want: %d", tc.name, n, tc.tlvs)
+		}
+	}
+}
+
+func TestFindTLV(t *testing.T) {
+	ds := append(gcp.EncodeSequence(gcp.TypeIRA, 1, gcp.OpReadResponse,
+		gcp.EncodeTLV(19, []byte{0}),
+		gcp.EncodeTLV(50, gcp.EncodeTLV(19, gcp.EncodeTLV(1, []byte("acme"))))),
+		gcp.EncodeTLV(2)...)
+	tt := []struct {
+		name string
+		b    []byte
+		path []uint8
+		want string
+		err  error
+	}{
+		{name: "Leaf", b: ds, path: []uint8{1, 9, 50, 19, 1}, want: "acme"},
+		{name: "Past a sibling", b: ds, path: []uint8{1, 9, 50}, want: string(gcp.EncodeTLV(19, gcp.EncodeTLV(1, []byte("acme"))))},
+		{name: "Missing", b: ds, path: []uint8{1, 9, 60}},
+		{name: "Truncated before", b: []byte{1, 0, 5, 0}, path: []uint8{1}, err: gcp.ErrTruncatedTLV},
+		{name: "Truncated after", b: append(gcp.EncodeTLV(1, []byte{7}), 2, 0), path: []uint8{1}, want: "\x07"},
+	}
+	for _, tc := range tt {
+		got, err := gcp.FindTLV(tc.b, tc.path...)
+		if err != tc.err {
+			t.Errorf("%s: error got: %v, want: %v", tc.name, err, tc.err)
+		}
+		if string(got) != tc.want {
+			t.Errorf("%s got: %x, want: %x", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestScannerAllocs(t *testing.T) {
+	b, off := rcpField(t, rex)
+	var s gcp.Scanner
+	allocs := testing.AllocsPerRun(100, func() {
+		s.Reset(b[off:])
+		for s.Next() {
+			if s.Match("2.9.100.8.9") {
+				s.Uint()
+			}
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("allocations got: %v, want: 0", allocs)
+	}
+}
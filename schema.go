@@ -0,0 +1,226 @@
+package gcp
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// A ValueKind is the encoding of an RCP TLV value.
+type ValueKind int
+
+// RCP TLV value encodings.
+const (
+	KindBytes       ValueKind = iota // Opaque octets
+	KindComplex                      // Nested TLVs
+	KindString                       // Printable string, 0-255 bytes
+	KindUint8                        // Unsigned byte, possibly enumerated
+	KindUint16                       // Unsigned short
+	KindUint32                       // Unsigned int
+	KindTimeTicks                    // Hundredths of a second, 4 bytes
+	KindMAC                          // MAC address, 6 bytes
+	KindIP                           // IPv4 or IPv6 address, 4 or 16 bytes
+	KindDateAndTime                  // DateAndTime from RFC 2579, 8 or 11 bytes
+)
+
+var kindNames = map[ValueKind]string{
+	KindBytes:       "bytes",
+	KindComplex:     "complex",
+	KindString:      "string",
+	KindUint8:       "uint8",
+	KindUint16:      "uint16",
+	KindUint32:      "uint32",
+	KindTimeTicks:   "timeticks",
+	KindMAC:         "mac",
+	KindIP:          "ip",
+	KindDateAndTime: "dateandtime",
+}
+
+func (k ValueKind) String() string {
+	if n, ok := kindNames[k]; ok {
+		return n
+	}
+	return fmt.Sprintf("ValueKind(%d)", int(k))
+}
+
+// Size returns the length of the values of kind k, or zero if it varies.
+func (k ValueKind) Size() int {
+	switch k {
+	case KindUint8:
+		return 1
+	case KindUint16:
+		return 2
+	case KindUint32, KindTimeTicks:
+		return 4
+	case KindMAC:
+		return 6
+	}
+	return 0
+}
+
+// A TLVSchema describes an RCP TLV known to the decoder.
+type TLVSchema struct {
+	Type uint8
+	Name string
+	Kind ValueKind
+	Enum map[uint32]string // Names of the values of an enumerated integer TLV
+	Sub  []*TLVSchema      // TLVs nested in a KindComplex TLV, ordered by Type
+}
+
+// Child returns the schema of the TLV of type typ nested in s, or nil if
+// there is none.
+func (s *TLVSchema) Child(typ uint8) *TLVSchema {
+	for _, c := range s.Sub {
+		if c.Type == typ {
+			return c
+		}
+	}
+	return nil
+}
+
+var (
+	schemaOnce sync.Once
+	schemaRoot *TLVSchema
+)
+
+// Schema returns the schema of the top level RCP TLVs (IRA, REX and NTF)
+// and, through their Sub fields, of every TLV nested in them.
+//
+// The schema is derived from the decoder, by feeding it every TLV type at
+// every level, so it always describes what ParseMessage decodes. Names,
+// value kinds and enumerations are those the TLV types declare. A TLV
+// nested in several others, like Sequence, is described once and shared.
+// The schema must not be modified.
+func Schema() []*TLVSchema {
+	schemaOnce.Do(func() {
+		b := &schemaBuilder{nodes: make(map[schemaKey]*TLVSchema)}
+		schemaRoot = &TLVSchema{Kind: KindComplex}
+		b.visit(nil, schemaRoot)
+	})
+	return schemaRoot.Sub
+}
+
+// LookupTLV returns the schema of the TLV at path, the types of the TLVs
+// leading to it starting from the top level, or nil if it is unknown.
+func LookupTLV(path ...uint8) *TLVSchema {
+	Schema()
+	s := schemaRoot
+	for _, t := range path {
+		if s = s.Child(t); s == nil {
+			return nil
+		}
+	}
+	return s
+}
+
+// maxSchemaDepth bounds the nesting of TLVs explored by schemaBuilder.
+const maxSchemaDepth = 16
+
+type schemaKey struct {
+	typ  uint8
+	impl reflect.Type
+}
+
+// A schemaBuilder explores the TLVs known to the decoder.
+type schemaBuilder struct {
+	nodes map[schemaKey]*TLVSchema
+}
+
+// visit adds to s, the TLV at path, the TLVs the decoder accepts in it.
+func (b *schemaBuilder) visit(path []uint8, s *TLVSchema) {
+	if len(path) >= maxSchemaDepth {
+		return
+	}
+	for c := 0; c < 256; c++ {
+		typ := uint8(c)
+		r := probeTLV(path, typ)
+		if r == nil {
+			continue
+		}
+		k := schemaKey{typ: typ, impl: reflect.TypeOf(r)}
+		n, ok := b.nodes[k]
+		if !ok {
+			n = &TLVSchema{Type: typ, Name: r.Name(), Kind: r.Kind(), Enum: r.Enum()}
+			if r.IsComplex() {
+				n.Kind = KindComplex
+			}
+			b.nodes[k] = n
+		}
+		if s.Child(typ) == nil {
+			s.Sub = append(s.Sub, n)
+			sort.Slice(s.Sub, func(i, j int) bool { return s.Sub[i].Type < s.Sub[j].Type })
+		}
+		if n.Kind == KindComplex {
+			b.visit(append(path[:len(path):len(path)], typ), n)
+		}
+	}
+}
+
+// probeTLV decodes a TLV of type typ nested in path. It returns nil if
+// the decoder does not support it there.
+func probeTLV(path []uint8, typ uint8) (r RCP) {
+	defer func() {
+		if recover() != nil {
+			r = nil
+		}
+	}()
+	b := EncodeTLV(typ, []byte{1})
+	for i := len(path) - 1; i >= 0; i-- {
+		b = EncodeTLV(path[i], b)
+	}
+	var t TLV
+	tlvs, err := t.parseTLVs(b)
+	if err != nil || len(tlvs) < len(path)+1 {
+		return nil
+	}
+	return tlvs[len(tlvs)-1]
+}
+
+// A BodyField describes a field of a GCP message body.
+type BodyField struct {
+	Name   string // Name of the field in the type of the message body
+	Offset int    // Offset from the start of the body
+	Size   int    // Size in bytes, zero for the field that takes the rest of the body
+	RCP    bool   // Whether the field carries RCP TLVs
+}
+
+// bodyTypes are the message body types of the messages the library
+// encodes.
+var bodyTypes = map[MessageID]reflect.Type{
+	MessageIDNotifyReq: reflect.TypeOf(NotifyReq{}),
+	MessageIDNotifyRes: reflect.TypeOf(NotifyRes{}),
+	MessageIDNotifyErr: reflect.TypeOf(NotifyErr{}),
+	MessageIDGDMReq:    reflect.TypeOf(DMReq{}),
+	MessageIDEDSReq:    reflect.TypeOf(EDSReq{}),
+	MessageIDEDSRes:    reflect.TypeOf(EDSRes{}),
+}
+
+// BodyLayout returns the fields of the body of messages with id, in wire
+// order, or nil if the library has no type for it.
+func BodyLayout(id MessageID) []BodyField {
+	t, ok := bodyTypes[id]
+	if !ok {
+		return nil
+	}
+	var fs []BodyField
+	off := 0
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		f := BodyField{Name: sf.Name, Offset: off}
+		switch sf.Type.Kind() {
+		case reflect.Uint8:
+			f.Size = 1
+		case reflect.Uint16:
+			f.Size = 2
+		case reflect.Uint32:
+			f.Size = 4
+		case reflect.Slice:
+			// Variable length fields carry the RCP data structures.
+			f.RCP = true
+		}
+		off += f.Size
+		fs = append(fs, f)
+	}
+	return fs
+}
@@ -0,0 +1,62 @@
+package gcp_test
+
+import (
+	"testing"
+
+	gcp "github.com/nleiva/gcp-rphy"
+)
+
+func TestSchema(t *testing.T) {
+	tt := []struct {
+		name string
+		path []uint8
+		want string
+		kind gcp.ValueKind
+		enum map[uint32]string
+	}{
+		{name: "Top level", path: []uint8{3}, want: "Notify", kind: gcp.KindComplex},
+		{name: "Sequence Number", path: []uint8{2, 9, 10}, want: "SequenceNumber", kind: gcp.KindUint16},
+		{name: "Operation", path: []uint8{1, 9, 11}, want: "Operation", kind: gcp.KindUint8,
+			enum: map[uint32]string{1: "Read", 4: "ReadResponse"}},
+		{name: "Vendor Name", path: []uint8{3, 9, 50, 19, 1}, want: "VendorName", kind: gcp.KindString},
+		{name: "MAC Address", path: []uint8{3, 9, 50, 19, 4}, want: "DeviceMacAddress", kind: gcp.KindMAC},
+		{name: "Last Update", path: []uint8{3, 9, 50, 19, 19}, want: "CurrentSwImageLastUpdate", kind: gcp.KindDateAndTime},
+		{name: "Redirect", path: []uint8{1, 9, 25, 1}, want: "RedirectIpAddress", kind: gcp.KindIP},
+		{name: "List entry", path: []uint8{2, 9, 100, 8, 8}, want: "AdminStatus", kind: gcp.KindUint8,
+			enum: map[uint32]string{1: "up", 2: "down"}},
+		{name: "Wide enum", path: []uint8{2, 9, 100, 15, 1}, want: "AddrType", kind: gcp.KindUint32,
+			enum: map[uint32]string{1: "ipv4", 2: "ipv6"}},
+		{name: "Time ticks", path: []uint8{2, 9, 100, 8, 10}, want: "LastChange", kind: gcp.KindTimeTicks},
+		{name: "Truth value", path: []uint8{2, 9, 100, 8, 14}, want: "ConnectorPresent", kind: gcp.KindUint8,
+			enum: map[uint32]string{1: "true", 2: "false"}},
+		{name: "Core identification", path: []uint8{2, 9, 60, 4}, want: "IsPrincipal", kind: gcp.KindUint8,
+			enum: map[uint32]string{0: "false", 1: "true"}},
+		{name: "Core name", path: []uint8{2, 9, 60, 5}, want: "CoreName", kind: gcp.KindString},
+		{name: "RF channel", path: []uint8{2, 9, 16, 12, 2}, want: "RfChannelType", kind: gcp.KindUint8,
+			enum: map[uint32]string{5: "DsScQam"}},
+		{name: "RF port", path: []uint8{2, 9, 17, 12, 2}, want: "RfPortType", kind: gcp.KindUint8,
+			enum: map[uint32]string{1: "DsRfPort"}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s := gcp.LookupTLV(tc.path...)
+			if s == nil {
+				t.Fatalf("TLV %v not found", tc.path)
+			}
+			if s.Name != tc.want || s.Kind != tc.kind {
+				t.Errorf("TLV %v got: %s (%v), want: %s (%v)", tc.path, s.Name, s.Kind, tc.want, tc.kind)
+			}
+			for v, n := range tc.enum {
+				if s.Enum[v] != n {
+					t.Errorf("value %d got: %q, want: %q", v, s.Enum[v], n)
+				}
+			}
+		})
+	}
+	if s := gcp.LookupTLV(2, 9, 200); s != nil {
+		t.Errorf("unknown TLV got: %s", s.Name)
+	}
+	if got, want := len(gcp.Schema()), 3; got != want {
+		t.Errorf("top level TLVs got: %d, want: %d", got, want)
+	}
+}
@@ -0,0 +1,208 @@
+package script
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/hexdump"
+)
+
+// A field is a TLV of a message below its Sequence, identified by the
+// types and names of the TLVs leading to it.
+type field struct {
+	types []string
+	names []string
+	value string
+}
+
+// fields returns the TLVs below the Sequence of m.
+func fields(m *gcp.Message) ([]field, error) {
+	b, err := m.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	var fs []field
+	var names []string
+	for _, f := range hexdump.Fields(b) {
+		if f.Path == "" || len(names) < f.Depth-1 {
+			continue
+		}
+		// The top level TLV has depth 1, the Sequence 2.
+		names = append(names[:f.Depth-1], f.Name)
+		types := strings.Split(f.Path, ".")
+		if f.Depth < 3 || len(types) != f.Depth {
+			continue
+		}
+		fs = append(fs, field{
+			types: types[2:],
+			names: append([]string(nil), names[2:]...),
+			value: f.Value,
+		})
+	}
+	return fs, nil
+}
+
+// at returns the values of the TLVs of fs at path.
+func at(fs []field, path string) (values []string, found bool) {
+	keys := strings.Split(path, ".")
+	for _, f := range fs {
+		if len(f.types) != len(keys) {
+			continue
+		}
+		match := true
+		for i, k := range keys {
+			if k != f.types[i] && !strings.EqualFold(k, f.names[i]) {
+				match = false
+				break
+			}
+		}
+		if match {
+			values = append(values, f.value)
+			found = true
+		}
+	}
+	return values, found
+}
+
+// equal reports whether got, a value rendered by the hexdump package,
+// is want. Strings are compared unquoted, and enumerated values by name
+// or number.
+func equal(got, want string) bool {
+	if got == want {
+		return true
+	}
+	if s, err := strconv.Unquote(got); err == nil && strings.HasPrefix(got, `"`) {
+		return s == want
+	}
+	if i := strings.LastIndex(got, " ("); i > 0 && strings.HasSuffix(got, ")") {
+		return strings.EqualFold(got[:i], want) || got[i+2:len(got)-1] == want
+	}
+	return false
+}
+
+// notifies reports whether m is a Notify Request with a
+// GeneralNotification of the type named or numbered typ.
+func notifies(m *gcp.Message, typ string) bool {
+	if m.MessageID != uint8(gcp.MessageIDNotifyReq) {
+		return false
+	}
+	fs, err := fields(m)
+	if err != nil {
+		return false
+	}
+	vs, _ := at(fs, "86.1")
+	for _, v := range vs {
+		if equal(v, typ) {
+			return true
+		}
+	}
+	return false
+}
+
+// The comparison operators of expect.
+const (
+	opEqual    = "=="
+	opNotEqual = "!="
+	opMatch    = "~"
+)
+
+// checkExpect validates the syntax of an expect step.
+func checkExpect(st step) error {
+	args := st.args
+	if len(args) < 2 {
+		return fmt.Errorf("usage: expect message name | match regexp | path [op value]")
+	}
+	switch {
+	case args[1] == "message" && len(args) > 2:
+	case args[1] == "match" && len(args) > 2:
+		_, err := regexp.Compile(rest(st.text, 2))
+		return err
+	case len(args) == 2:
+	case args[2] == opEqual || args[2] == opNotEqual:
+		if len(args) < 4 {
+			return fmt.Errorf("missing value to compare %s with", args[1])
+		}
+	case args[2] == opMatch:
+		if len(args) < 4 {
+			return fmt.Errorf("missing regexp to match %s with", args[1])
+		}
+		_, err := regexp.Compile(unquote(rest(st.text, 3)))
+		return err
+	default:
+		return fmt.Errorf("unknown operator %q, want ==, != or ~", args[2])
+	}
+	return nil
+}
+
+// expect checks the expectation of st against m.
+func expect(st step, m *gcp.Message) error {
+	args := st.args
+	id := gcp.MessageID(m.MessageID)
+	switch args[1] {
+	case "message":
+		want := rest(st.text, 2)
+		if !strings.EqualFold(id.String(), want) && strconv.Itoa(int(m.MessageID)) != want {
+			return fmt.Errorf("got: %s, want: %s", id, want)
+		}
+		return nil
+	case "match":
+		re := regexp.MustCompile(rest(st.text, 2))
+		if t := text(m); !re.MatchString(t) {
+			return fmt.Errorf("%s does not match:\n%s", id, t)
+		}
+		return nil
+	}
+
+	fs, err := fields(m)
+	if err != nil {
+		return err
+	}
+	path := args[1]
+	values, found := at(fs, path)
+	if !found {
+		return fmt.Errorf("%s not found in %s", path, id)
+	}
+	if len(args) == 2 {
+		return nil
+	}
+	want := unquote(rest(st.text, 3))
+	var ok bool
+	switch args[2] {
+	case opEqual:
+		for _, v := range values {
+			ok = ok || equal(v, want)
+		}
+	case opNotEqual:
+		ok = true
+		for _, v := range values {
+			ok = ok && !equal(v, want)
+		}
+	case opMatch:
+		re := regexp.MustCompile(want)
+		for _, v := range values {
+			ok = ok || re.MatchString(v) || re.MatchString(unquote(v))
+		}
+	}
+	if !ok {
+		return fmt.Errorf("%s is %s, want %s %s", path, strings.Join(values, ", "), args[2], want)
+	}
+	return nil
+}
+
+// text returns m decoded, or its annotated dump if it cannot be decoded.
+func text(m *gcp.Message) (t string) {
+	b, err := m.Marshal()
+	if err != nil {
+		return err.Error()
+	}
+	defer func() {
+		if recover() != nil {
+			t = hexdump.Message(b)
+		}
+	}()
+	t, _ = m.Body.Process()
+	return t
+}
@@ -0,0 +1,505 @@
+// Package script runs scripted GCP exchanges and reports which tests
+// passed, so lab regression suites can be written without Go.
+//
+// A script is a list of commands, one per line. Commands before the
+// first test set the session up; each test runs until one of its
+// commands fails:
+//
+//	# RPD bring-up.
+//	accept :8190 30s
+//	timeout 5s
+//
+//	test "Start up"
+//	wait notify StartUpNotification 10s
+//
+//	test "Identification"
+//	read RpdCapabilities.RpdIdentification
+//	expect message EDS Response
+//	expect ResponseCode == NoError
+//	expect RpdCapabilities.RpdIdentification.VendorName == gcp-rphy
+//	expect RpdCapabilities.RpdIdentification.DeviceMacAddress ~ ^02:
+//
+// The commands are:
+//
+//	connect address          connect to an RPD or a core
+//	accept address [wait]    wait, one minute by default, for an RPD to connect
+//	test name                start a test
+//	read, write, notify,     as in the shell package; send reads files
+//	send, timeout, format    relative to the script
+//	wait notify type wait    wait for a GeneralNotification of a type
+//	wait message name wait   wait for a message, like Notify Request
+//	sleep wait               pause
+//	expect message name      check the Message ID of the last message
+//	expect match regexp      check the decoded last message matches regexp
+//	expect path              check the last message has the TLV at path
+//	expect path op value     check the values of the TLV at path, where op
+//	                         is ==, != or ~ (matches a regular expression)
+//
+// The last message is the response to the last request, or the message
+// the last wait received. TLV paths are names or types below a Sequence,
+// separated by dots. Values are compared as decoded, with strings
+// unquoted, and enumerated values by name or number.
+package script
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/shell"
+	"github.com/nleiva/gcp-rphy/transport"
+)
+
+// Error messages
+var (
+	errNoSession   = errors.New("not connected, use connect or accept first")
+	errNoMessage   = errors.New("no message to check")
+	errSetupFailed = errors.New("not run, the setup failed")
+)
+
+const (
+	// defaultAccept is how long accept waits for an RPD by default.
+	defaultAccept = time.Minute
+	// maxPending is the number of RPDs that can connect before being
+	// accepted. Others are disconnected.
+	maxPending = 8
+)
+
+// A step is a command of a script.
+type step struct {
+	line int
+	text string
+	args []string
+}
+
+// A test is a named list of steps.
+type test struct {
+	name  string
+	steps []step
+}
+
+// A Result is the outcome of a test.
+type Result struct {
+	Name     string
+	Err      error // Why the test failed, nil if it passed
+	Duration time.Duration
+}
+
+// A Report is the outcome of the tests of a script.
+type Report struct {
+	Script  string
+	Results []Result
+}
+
+// Failed returns the number of tests that failed.
+func (r *Report) Failed() int {
+	var n int
+	for _, res := range r.Results {
+		if res.Err != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// Write writes the report to w, a line per test followed by a summary.
+func (r *Report) Write(w io.Writer) error {
+	var b strings.Builder
+	for _, res := range r.Results {
+		status := "PASS"
+		if res.Err != nil {
+			status = "FAIL"
+		}
+		fmt.Fprintf(&b, "--- %s: %s (%.2fs)\n", status, res.Name, res.Duration.Seconds())
+		if res.Err != nil {
+			fmt.Fprintf(&b, "    %v\n", res.Err)
+		}
+	}
+	if n := r.Failed(); n > 0 {
+		fmt.Fprintf(&b, "FAIL\t%s\t%d of %d tests failed\n", r.Script, n, len(r.Results))
+	} else {
+		fmt.Fprintf(&b, "ok\t%s\t%d tests passed\n", r.Script, len(r.Results))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// A Runner runs scripts.
+type Runner struct {
+	// Print, if set, prints the messages sent to and received from peer.
+	// The encapsulation Transaction ID of messages sent is nil.
+	Print   func(sent bool, peer string, tid *uint16, m *gcp.Message)
+	Format  func(name string) error // Optional, changes the output format of Print
+	Timeout time.Duration           // Time to wait for responses, 5s if zero
+}
+
+// RunFile runs the script in the file name.
+func (ru *Runner) RunFile(name string) (*Report, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ru.run(name, filepath.Dir(name), f)
+}
+
+// Run runs the script read from r, called name in the report. Files are
+// sent from the current directory.
+func (ru *Runner) Run(name string, r io.Reader) (*Report, error) {
+	return ru.run(name, "", r)
+}
+
+func (ru *Runner) run(name, dir string, r io.Reader) (*Report, error) {
+	tests, err := parse(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%v", name, err)
+	}
+	x := &execution{runner: ru, name: name, dir: dir, inbox: newInbox(), timeout: ru.Timeout}
+	defer x.close()
+	rep := &Report{Script: name}
+	for i, t := range tests {
+		start := time.Now()
+		err := x.test(t)
+		if i == 0 {
+			// Setup.
+			if err == nil {
+				continue
+			}
+			rep.Results = append(rep.Results, Result{Name: "setup", Err: err, Duration: time.Since(start)})
+			for _, t := range tests[1:] {
+				rep.Results = append(rep.Results, Result{Name: t.name, Err: errSetupFailed})
+			}
+			break
+		}
+		rep.Results = append(rep.Results, Result{Name: t.name, Err: err, Duration: time.Since(start)})
+	}
+	return rep, nil
+}
+
+// parse parses a script into its tests, the first one being the setup.
+func parse(r io.Reader) ([]*test, error) {
+	tests := []*test{{name: "setup"}}
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		text := strings.TrimSpace(s.Text())
+		args := strings.Fields(text)
+		if len(args) == 0 || strings.HasPrefix(args[0], "#") {
+			continue
+		}
+		st := step{line: n, text: text, args: args}
+		if err := check(st); err != nil {
+			return nil, fmt.Errorf("%d: %v", n, err)
+		}
+		if args[0] == "test" {
+			tests = append(tests, &test{name: unquote(rest(text, 1))})
+			continue
+		}
+		t := tests[len(tests)-1]
+		t.steps = append(t.steps, st)
+	}
+	return tests, s.Err()
+}
+
+// check validates the syntax of a step.
+func check(st step) error {
+	args := st.args
+	usage := func(u string) error { return fmt.Errorf("usage: %s", u) }
+	switch args[0] {
+	case "connect":
+		if len(args) != 2 {
+			return usage("connect address")
+		}
+	case "accept":
+		if len(args) < 2 || len(args) > 3 {
+			return usage("accept address [wait]")
+		}
+		if len(args) == 3 {
+			return checkDuration(args[2])
+		}
+	case "test":
+		if len(args) < 2 {
+			return usage("test name")
+		}
+	case "wait":
+		if len(args) < 4 || args[1] != "notify" && args[1] != "message" {
+			return usage("wait notify|message name wait")
+		}
+		return checkDuration(args[len(args)-1])
+	case "sleep":
+		if len(args) != 2 {
+			return usage("sleep wait")
+		}
+		return checkDuration(args[1])
+	case "expect":
+		return checkExpect(st)
+	case "timeout":
+		if len(args) != 2 {
+			return usage("timeout duration")
+		}
+		return checkDuration(args[1])
+	case "read", "write", "notify", "send", "format":
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	return nil
+}
+
+func checkDuration(s string) error {
+	_, err := time.ParseDuration(s)
+	return err
+}
+
+// rest returns text without its first n words.
+func rest(text string, n int) string {
+	for i := 0; i < n; i++ {
+		text = strings.TrimLeft(text, " \t")
+		j := strings.IndexAny(text, " \t")
+		if j < 0 {
+			return ""
+		}
+		text = text[j:]
+	}
+	return strings.TrimSpace(text)
+}
+
+// unquote removes the double quotes around s, if any.
+func unquote(s string) string {
+	if u, err := strconv.Unquote(s); err == nil && strings.HasPrefix(s, `"`) {
+		return u
+	}
+	return s
+}
+
+// An execution is the state of a script being run.
+type execution struct {
+	runner *Runner
+	name   string
+	dir    string
+	sh     *shell.Shell
+	inbox  *inbox
+	last   *gcp.Message
+
+	// timeout is set by timeout commands run before there is a shell.
+	timeout time.Duration
+
+	core     *transport.Core
+	accepted chan *transport.Session
+}
+
+// test runs the steps of t until one fails.
+func (x *execution) test(t *test) error {
+	for _, st := range t.steps {
+		if err := x.step(st); err != nil {
+			return fmt.Errorf("%s:%d: %s: %v", x.name, st.line, st.text, err)
+		}
+	}
+	return nil
+}
+
+func (x *execution) step(st step) error {
+	args := st.args
+	switch args[0] {
+	case "connect":
+		s, err := transport.Dial(args[1], x.handler())
+		if err != nil {
+			return err
+		}
+		x.setSession(s)
+	case "accept":
+		wait := defaultAccept
+		if len(args) == 3 {
+			wait, _ = time.ParseDuration(args[2])
+		}
+		return x.accept(args[1], wait)
+	case "sleep":
+		d, _ := time.ParseDuration(args[1])
+		time.Sleep(d)
+	case "wait":
+		return x.wait(args)
+	case "expect":
+		if x.last == nil {
+			return errNoMessage
+		}
+		return expect(st, x.last)
+	case "send":
+		if x.sh == nil {
+			return errNoSession
+		}
+		file := rest(st.text, 1)
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(x.dir, file)
+		}
+		return x.sh.Exec("send " + file)
+	case "timeout":
+		d, err := time.ParseDuration(args[1])
+		if err != nil {
+			return err
+		}
+		x.timeout = d
+		if x.sh != nil {
+			x.sh.Timeout = d
+		}
+	default:
+		if x.sh == nil {
+			return errNoSession
+		}
+		return x.sh.Exec(st.text)
+	}
+	return nil
+}
+
+// handler returns the Handler of the sessions of the script, which
+// keeps the messages received for wait.
+func (x *execution) handler() transport.Handler {
+	return transport.HandlerFunc(func(s *transport.Session, r *transport.Request) {
+		if x.runner.Print != nil {
+			x.runner.Print(false, s.RemoteAddr().String(), &r.TranID, r.Msg)
+		}
+		x.inbox.put(r.Msg)
+	})
+}
+
+// setSession makes s the session of the shell running the commands.
+func (x *execution) setSession(s *transport.Session) {
+	if x.sh != nil {
+		x.sh.Session.Close()
+	}
+	x.sh = &shell.Shell{
+		Session: s,
+		Print: func(sent bool, tid *uint16, m *gcp.Message) {
+			if !sent {
+				x.last = m
+			}
+			if x.runner.Print != nil {
+				x.runner.Print(sent, s.RemoteAddr().String(), tid, m)
+			}
+		},
+		Format:  x.runner.Format,
+		Out:     ioutil.Discard,
+		Timeout: x.timeout,
+	}
+}
+
+// accept waits for an RPD to connect to addr.
+func (x *execution) accept(addr string, wait time.Duration) error {
+	if x.core == nil {
+		x.accepted = make(chan *transport.Session, maxPending)
+		c := &transport.Core{Handler: x.handler()}
+		c.OnEvent = func(e transport.Event) {
+			if e.Type != transport.EventConnected {
+				return
+			}
+			for _, s := range c.Sessions() {
+				if s.RemoteAddr().String() != e.Addr {
+					continue
+				}
+				select {
+				case x.accepted <- s:
+				default:
+					s.Close()
+				}
+			}
+		}
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return err
+		}
+		go c.Serve(l)
+		x.core = c
+	}
+	select {
+	case s := <-x.accepted:
+		x.setSession(s)
+		return nil
+	case <-time.After(wait):
+		return fmt.Errorf("no RPD connected within %v", wait)
+	}
+}
+
+// wait waits for a message or a notification.
+func (x *execution) wait(args []string) error {
+	d, _ := time.ParseDuration(args[len(args)-1])
+	what := strings.Join(args[2:len(args)-1], " ")
+	var match func(m *gcp.Message) bool
+	switch args[1] {
+	case "notify":
+		match = func(m *gcp.Message) bool { return notifies(m, what) }
+	case "message":
+		match = func(m *gcp.Message) bool {
+			return strings.EqualFold(gcp.MessageID(m.MessageID).String(), what) || strconv.Itoa(int(m.MessageID)) == what
+		}
+	}
+	m := x.inbox.take(match, d)
+	if m == nil {
+		if args[1] == "notify" {
+			return fmt.Errorf("no %s notification within %v", what, d)
+		}
+		return fmt.Errorf("no %s message within %v", what, d)
+	}
+	x.last = m
+	return nil
+}
+
+func (x *execution) close() {
+	if x.sh != nil {
+		x.sh.Session.Close()
+	}
+	if x.core != nil {
+		x.core.Close()
+	}
+}
+
+// An inbox keeps the messages received outside of requests until a wait
+// takes them.
+type inbox struct {
+	mu     sync.Mutex
+	msgs   []*gcp.Message
+	signal chan struct{}
+}
+
+func newInbox() *inbox {
+	return &inbox{signal: make(chan struct{}, 1)}
+}
+
+func (b *inbox) put(m *gcp.Message) {
+	b.mu.Lock()
+	b.msgs = append(b.msgs, m)
+	b.mu.Unlock()
+	select {
+	case b.signal <- struct{}{}:
+	default:
+	}
+}
+
+// take removes and returns the first message that matches, waiting up to
+// timeout for it. It returns nil if none arrived.
+func (b *inbox) take(match func(*gcp.Message) bool, timeout time.Duration) *gcp.Message {
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	for {
+		b.mu.Lock()
+		for i, m := range b.msgs {
+			if match(m) {
+				b.msgs = append(b.msgs[:i], b.msgs[i+1:]...)
+				b.mu.Unlock()
+				return m
+			}
+		}
+		b.mu.Unlock()
+		select {
+		case <-b.signal:
+		case <-t.C:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package script_test
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/script"
+	"github.com/nleiva/gcp-rphy/transport"
+)
+
+// serve starts a core that notifies the sessions connected of its start
+// up and answers EDS Requests with the vendor name. It returns the core
+// address and a function that stops it.
+func serve(t *testing.T) (string, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &transport.Core{
+		Handler: transport.HandlerFunc(func(s *transport.Session, r *transport.Request) {
+			req, ok := r.Msg.Body.(*gcp.EDSReq)
+			if !ok {
+				return
+			}
+			s.Reply(r, gcp.NewMessage(gcp.MessageIDEDSRes, &gcp.EDSRes{
+				TransactionID: req.TransactionID,
+				DataStr: gcp.EncodeSequence(gcp.TypeREX, req.TransactionID, gcp.OpReadResponse,
+					gcp.EncodeTLV(19, []byte{byte(gcp.RespNoError)}),
+					gcp.EncodeTLV(50, gcp.EncodeTLV(19, gcp.EncodeTLV(1, []byte("acme"))))),
+			}))
+		}),
+	}
+	c.OnEvent = func(e transport.Event) {
+		if e.Type != transport.EventConnected {
+			return
+		}
+		for _, s := range c.Sessions() {
+			s.Send(gcp.NewMessage(gcp.MessageIDNotifyReq, &gcp.NotifyReq{
+				TransactionID: 1,
+				EvntData:      gcp.GeneralNotification(1, gcp.StartUpNotification),
+			}))
+		}
+	}
+	go c.Serve(l)
+	return l.Addr().String(), func() { c.Close() }
+}
+
+func TestRun(t *testing.T) {
+	addr, stop := serve(t)
+	defer stop()
+	src := fmt.Sprintf(`# Bring-up
+connect %s
+timeout 2s
+
+test "Start up"
+wait notify StartUpNotification 2s
+expect message Notify Request
+
+test Identification
+read RpdCapabilities.RpdIdentification
+expect message EDS Response
+expect ResponseCode == NoError
+expect 50.19.1 == acme
+expect RpdCapabilities.RpdIdentification.VendorName ~ ^ac
+expect match acme
+
+test "Wrong vendor"
+read 50.19.1
+expect RpdCapabilities.RpdIdentification.VendorName != acme
+expect ResponseCode == NoError
+
+test "No notification"
+wait notify FatalError 100ms
+`, addr)
+	ru := &script.Runner{Timeout: time.Second}
+	rep, err := ru.Run("bring-up.gcp", strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		name string
+		err  string
+	}{
+		{"Start up", ""},
+		{"Identification", ""},
+		{"Wrong vendor", `bring-up.gcp:19: expect RpdCapabilities.RpdIdentification.VendorName != acme: RpdCapabilities.RpdIdentification.VendorName is "acme", want != acme`},
+		{"No notification", "bring-up.gcp:23: wait notify FatalError 100ms: no FatalError notification within 100ms"},
+	}
+	if len(rep.Results) != len(want) {
+		t.Fatalf("got %d results: %v, want: %d", len(rep.Results), rep.Results, len(want))
+	}
+	for i, w := range want {
+		r := rep.Results[i]
+		var got string
+		if r.Err != nil {
+			got = r.Err.Error()
+		}
+		if r.Name != w.name || got != w.err {
+			t.Errorf("result %d got: %q, %q, want: %q, %q", i, r.Name, got, w.name, w.err)
+		}
+	}
+	if rep.Failed() != 2 {
+		t.Errorf("Failed got: %d, want: 2", rep.Failed())
+	}
+	var out bytes.Buffer
+	rep.Write(&out)
+	if !strings.HasSuffix(out.String(), "FAIL\tbring-up.gcp\t2 of 4 tests failed\n") {
+		t.Errorf("Write got:\n%s", out.String())
+	}
+}
+
+func TestRunSetupFailed(t *testing.T) {
+	ru := &script.Runner{}
+	rep, err := ru.Run("s", strings.NewReader("read 50.19\ntest one\nsleep 1ms\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.Results) != 2 || rep.Results[0].Name != "setup" || rep.Failed() != 2 {
+		t.Errorf("got: %v, want a failed setup and test", rep.Results)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for src, want := range map[string]string{
+		"reed 50":                 `s:1: unknown command "reed"`,
+		"\nwait notify X":         "s:2: usage: wait notify|message name wait",
+		"sleep":                   "s:1: usage: sleep wait",
+		"expect 50.19.1 = acme":   `s:1: unknown operator "=", want ==, != or ~`,
+		"expect match (":          "s:1: error parsing regexp: missing closing ): `(`",
+		"expect VendorName ==":    "s:1: missing value to compare VendorName with",
+		"accept :8190 1m forever": "s:1: usage: accept address [wait]",
+	} {
+		_, err := (&script.Runner{}).Run("s", strings.NewReader(src))
+		if err == nil || err.Error() != want {
+			t.Errorf("Run(%q) got: %v, want: %s", src, err, want)
+		}
+	}
+}
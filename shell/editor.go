@@ -0,0 +1,239 @@
+package shell
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Keys read by the editor.
+const (
+	keyCtrlA     = 1
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyBackspace = 8
+	keyTab       = 9
+	keyLF        = 10
+	keyCtrlK     = 11
+	keyCR        = 13
+	keyCtrlU     = 21
+	keyEscape    = 27
+	keyDelete    = 127
+)
+
+// An editor reads lines typed on a terminal in raw mode, with cursor
+// movement, a history browsed with the arrow keys and tab completion.
+type editor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	prompt   string
+	complete func(line string) []string
+	history  []string
+
+	mu     sync.Mutex
+	line   []rune
+	pos    int
+	active bool // a line is being edited
+}
+
+func newEditor(in io.Reader, out io.Writer, prompt string, complete func(string) []string) *editor {
+	return &editor{in: bufio.NewReader(in), out: out, prompt: prompt, complete: complete}
+}
+
+// readLine returns the next line typed, or io.EOF if Ctrl-D is typed on
+// an empty line.
+func (e *editor) readLine() (string, error) {
+	e.mu.Lock()
+	e.line, e.pos, e.active = nil, 0, true
+	e.redraw()
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		e.active = false
+		e.mu.Unlock()
+	}()
+
+	hist := len(e.history)
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		e.mu.Lock()
+		switch r {
+		case keyCR, keyLF:
+			line := string(e.line)
+			fmt.Fprint(e.out, "\n")
+			if strings.TrimSpace(line) != "" {
+				e.history = append(e.history, line)
+			}
+			e.mu.Unlock()
+			return line, nil
+		case keyCtrlD:
+			if len(e.line) == 0 {
+				fmt.Fprint(e.out, "\n")
+				e.mu.Unlock()
+				return "", io.EOF
+			}
+			e.erase(e.pos)
+		case keyCtrlC:
+			fmt.Fprint(e.out, "^C\n")
+			e.line, e.pos = nil, 0
+			hist = len(e.history)
+		case keyBackspace, keyDelete:
+			if e.pos > 0 {
+				e.pos--
+				e.erase(e.pos)
+			}
+		case keyCtrlA:
+			e.pos = 0
+		case keyCtrlE:
+			e.pos = len(e.line)
+		case keyCtrlK:
+			e.line = e.line[:e.pos]
+		case keyCtrlU:
+			e.line, e.pos = append([]rune(nil), e.line[e.pos:]...), 0
+		case keyTab:
+			e.tab()
+		case keyEscape:
+			e.escape(&hist)
+		default:
+			if r >= ' ' {
+				e.line = append(e.line[:e.pos], append([]rune{r}, e.line[e.pos:]...)...)
+				e.pos++
+			}
+		}
+		e.redraw()
+		e.mu.Unlock()
+	}
+}
+
+// escape handles the escape sequence of the arrow, home, end and delete
+// keys.
+func (e *editor) escape(hist *int) {
+	b, err := e.in.ReadByte()
+	if err != nil || b != '[' && b != 'O' {
+		return
+	}
+	k, err := e.in.ReadByte()
+	if err != nil {
+		return
+	}
+	switch k {
+	case 'A':
+		if *hist > 0 {
+			*hist--
+			e.setLine(e.history[*hist])
+		}
+	case 'B':
+		switch {
+		case *hist < len(e.history)-1:
+			*hist++
+			e.setLine(e.history[*hist])
+		case *hist == len(e.history)-1:
+			*hist++
+			e.setLine("")
+		}
+	case 'C':
+		if e.pos < len(e.line) {
+			e.pos++
+		}
+	case 'D':
+		if e.pos > 0 {
+			e.pos--
+		}
+	case 'H':
+		e.pos = 0
+	case 'F':
+		e.pos = len(e.line)
+	case '3':
+		if t, _ := e.in.ReadByte(); t == '~' && e.pos < len(e.line) {
+			e.erase(e.pos)
+		}
+	}
+}
+
+func (e *editor) setLine(s string) {
+	e.line = []rune(s)
+	e.pos = len(e.line)
+}
+
+// erase removes the character at i.
+func (e *editor) erase(i int) {
+	if i < len(e.line) {
+		e.line = append(e.line[:i], e.line[i+1:]...)
+	}
+}
+
+// tab completes the word before the cursor. A single completion replaces
+// it, several extend it with the prefix they share, or are listed when
+// they share no more than the word.
+func (e *editor) tab() {
+	if e.complete == nil {
+		return
+	}
+	before := string(e.line[:e.pos])
+	cs := e.complete(before)
+	if len(cs) == 0 {
+		return
+	}
+	word := before[strings.LastIndex(before, " ")+1:]
+	c := cs[0]
+	if len(cs) == 1 {
+		if !strings.HasSuffix(c, ".") && !strings.HasSuffix(c, "/") {
+			c += " "
+		}
+	} else {
+		for _, o := range cs[1:] {
+			c = commonPrefix(c, o)
+		}
+		if len(c) <= len(word) {
+			// List the last element of paths.
+			dir := word[:strings.LastIndex(word, ".")+1]
+			names := make([]string, len(cs))
+			for i, c := range cs {
+				names[i] = strings.TrimPrefix(c, dir)
+			}
+			fmt.Fprintf(e.out, "\n%s\n", strings.Join(names, "  "))
+			return
+		}
+	}
+	rest := e.line[e.pos:]
+	e.line = append([]rune(before[:len(before)-len(word)]+c), rest...)
+	e.pos = len(e.line) - len(rest)
+}
+
+// commonPrefix returns the longest prefix of a and b, ignoring case.
+func commonPrefix(a, b string) string {
+	i := 0
+	for i < len(a) && i < len(b) && strings.EqualFold(a[i:i+1], b[i:i+1]) {
+		i++
+	}
+	return a[:i]
+}
+
+// redraw prints the prompt and the line, and moves the cursor back to
+// its position.
+func (e *editor) redraw() {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", e.prompt, string(e.line))
+	if n := len(e.line) - e.pos; n > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", n)
+	}
+}
+
+// interrupt runs fn, which prints to the terminal, without mixing its
+// output with the line being edited.
+func (e *editor) interrupt(fn func()) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.active {
+		fn()
+		return
+	}
+	fmt.Fprint(e.out, "\r\x1b[K")
+	fn()
+	e.redraw()
+}
@@ -0,0 +1,443 @@
+// Package shell implements an interactive shell over a GCP session, for
+// bring-up debugging. It reads and writes RCP objects, raises
+// notifications and sends messages described in documents, with tab
+// completion of TLV names from the RCP schema and a command history:
+//
+//	gcp> read RpdCapabilities.RpdIdentification.VendorName
+//	gcp> read 50.19
+//	gcp> write RpdCapabilities.RpdIdentification.DeviceAlias = node 1
+//	gcp> notify StartUpNotification
+//	gcp> send read-rpdinfo.yaml
+//
+// Paths are TLV names or types below a Sequence separated by dots, as in
+// the compose package, which also encodes the values written. A value
+// written runs to the end of the line, or is quoted to write several:
+//
+//	gcp> write 60.5 = "ccap 1" 60.3 = 10.0.0.1
+package shell
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/compose"
+	"github.com/nleiva/gcp-rphy/transport"
+)
+
+// Error messages
+var (
+	errUnknownCommand = errors.New("unknown command, type help for the list of commands")
+	errNoFormat       = errors.New("the output format cannot be changed")
+)
+
+// Prompt is printed before each command read from a terminal.
+const Prompt = "gcp> "
+
+// A command is a shell command, described for help.
+type command struct {
+	name  string
+	args  string
+	about string
+}
+
+var commands = []command{
+	{"read", "path...", "read the RCP objects at the paths"},
+	{"write", "path=value...", "write values, quoted or to the end of the line, to the RCP objects at the paths"},
+	{"notify", "type", "send a GeneralNotification of a type, by name or number"},
+	{"send", "file", "send the message described in a YAML or JSON file"},
+	{"format", "text|json|hex", "change the output format"},
+	{"timeout", "duration", "change the time to wait for responses, like 10s"},
+	{"history", "", "list the commands typed"},
+	{"help", "", "list the commands"},
+	{"quit", "", "end the session"},
+}
+
+// A Shell runs commands over a GCP session.
+type Shell struct {
+	Session *transport.Session
+	// Print prints the messages sent and received. The encapsulation
+	// Transaction ID of messages sent is not known and nil.
+	Print   func(sent bool, tid *uint16, m *gcp.Message)
+	Format  func(name string) error // Optional, changes the output format of Print
+	Out     io.Writer               // Output of commands, os.Stdout if nil
+	Timeout time.Duration           // Time to wait for responses, 5s if zero
+
+	mu  sync.Mutex
+	seq uint16
+	ed  *editor
+}
+
+func (sh *Shell) out() io.Writer {
+	if sh.Out == nil {
+		return os.Stdout
+	}
+	return sh.Out
+}
+
+// Run reads and runs commands from in until quit is typed or the input
+// ends. When in is a terminal, lines can be edited, completed with tab
+// and recalled with the arrow keys.
+func (sh *Shell) Run(in *os.File) error {
+	restore, err := makeRaw(int(in.Fd()))
+	if err != nil {
+		return sh.run(bufio.NewScanner(in))
+	}
+	defer restore()
+	ed := newEditor(in, sh.out(), Prompt, Complete)
+	sh.mu.Lock()
+	sh.ed = ed
+	sh.mu.Unlock()
+	for {
+		line, err := ed.readLine()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := sh.Exec(line); err == io.EOF {
+			return nil
+		} else if err != nil {
+			fmt.Fprintf(sh.out(), "error: %v\n", err)
+		}
+	}
+}
+
+// run runs the commands read a line at a time from s.
+func (sh *Shell) run(s *bufio.Scanner) error {
+	for s.Scan() {
+		if err := sh.Exec(s.Text()); err == io.EOF {
+			return nil
+		} else if err != nil {
+			fmt.Fprintf(sh.out(), "error: %v\n", err)
+		}
+	}
+	return s.Err()
+}
+
+// Received prints r, a message received outside of a request, without
+// mixing it with the command being typed. It is meant to be called by
+// the Handler of the Session.
+func (sh *Shell) Received(r *transport.Request) {
+	sh.mu.Lock()
+	ed := sh.ed
+	sh.mu.Unlock()
+	show := func() { sh.Print(false, &r.TranID, r.Msg) }
+	if ed == nil {
+		show()
+		return
+	}
+	ed.interrupt(show)
+}
+
+// Exec runs the command line. It returns io.EOF for quit.
+func (sh *Shell) Exec(line string) error {
+	args := strings.Fields(line)
+	if len(args) == 0 || strings.HasPrefix(args[0], "#") {
+		return nil
+	}
+	switch args[0] {
+	case "read":
+		if len(args) == 1 {
+			return usage("read")
+		}
+		vs := make([]compose.PathValue, 0, len(args)-1)
+		for _, a := range args[1:] {
+			vs = append(vs, compose.PathValue{Path: a})
+		}
+		return sh.rcp(gcp.OpRead, vs)
+	case "write":
+		vs, ok := assignments(strings.TrimSpace(line)[len(args[0]):])
+		if !ok {
+			return usage("write")
+		}
+		return sh.rcp(gcp.OpWrite, vs)
+	case "notify":
+		return sh.notify(args[1:])
+	case "send":
+		return sh.send(args[1:])
+	case "format":
+		if len(args) != 2 {
+			return usage("format")
+		}
+		if sh.Format == nil {
+			return errNoFormat
+		}
+		return sh.Format(args[1])
+	case "timeout":
+		if len(args) != 2 {
+			return usage("timeout")
+		}
+		d, err := time.ParseDuration(args[1])
+		if err != nil {
+			return err
+		}
+		sh.Timeout = d
+	case "history":
+		sh.mu.Lock()
+		defer sh.mu.Unlock()
+		if sh.ed != nil {
+			for i, l := range sh.ed.history {
+				fmt.Fprintf(sh.out(), "%4d  %s\n", i+1, l)
+			}
+		}
+	case "help", "?":
+		for _, c := range commands {
+			fmt.Fprintf(sh.out(), "  %-28s %s\n", strings.TrimSpace(c.name+" "+c.args), c.about)
+		}
+	case "quit", "exit":
+		return io.EOF
+	default:
+		return errUnknownCommand
+	}
+	return nil
+}
+
+// assignments parses the "path = value" assignments of a write command.
+// Each value is quoted, or runs to the end of s.
+func assignments(s string) ([]compose.PathValue, bool) {
+	var vs []compose.PathValue
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		i := strings.Index(s, "=")
+		if i < 0 {
+			return nil, false
+		}
+		v := compose.PathValue{Path: strings.TrimSpace(s[:i])}
+		if v.Path == "" || strings.ContainsAny(v.Path, " \t") {
+			return nil, false
+		}
+		s = strings.TrimSpace(s[i+1:])
+		if strings.HasPrefix(s, `"`) {
+			q, err := strconv.QuotedPrefix(s)
+			if err != nil {
+				return nil, false
+			}
+			v.Value, _ = strconv.Unquote(q)
+			s = s[len(q):]
+		} else {
+			v.Value, s = s, ""
+		}
+		vs = append(vs, v)
+	}
+	return vs, len(vs) > 0
+}
+
+func usage(name string) error {
+	for _, c := range commands {
+		if c.name == name {
+			return fmt.Errorf("usage: %s %s", c.name, c.args)
+		}
+	}
+	return errUnknownCommand
+}
+
+// next returns the next sequence number, also used as Transaction ID.
+func (sh *Shell) next() uint16 {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	sh.seq++
+	return sh.seq
+}
+
+// rcp runs the RCP operation op on the paths, and values, of vs.
+func (sh *Shell) rcp(op gcp.Operation, vs []compose.PathValue) error {
+	seq := sh.next()
+	ds, err := compose.Sequence(gcp.TypeREX, seq, op, vs...)
+	if err != nil {
+		return err
+	}
+	return sh.request(gcp.NewMessage(gcp.MessageIDEDSReq, &gcp.EDSReq{
+		TransactionID: seq,
+		VendorID:      gcp.CableLabs,
+		DataStr:       ds,
+	}))
+}
+
+// notify sends a GeneralNotification.
+func (sh *Shell) notify(args []string) error {
+	if len(args) != 1 {
+		return usage("notify")
+	}
+	nt, ok := notificationType(args[0])
+	if !ok {
+		return fmt.Errorf("unknown notification type %q", args[0])
+	}
+	seq := sh.next()
+	m := gcp.NewMessage(gcp.MessageIDNotifyReq, &gcp.NotifyReq{
+		TransactionID: seq,
+		EvntData:      gcp.GeneralNotification(seq, nt),
+	})
+	sh.Print(true, nil, m)
+	return sh.Session.Send(m)
+}
+
+// notificationType returns the notification type named or numbered s.
+func notificationType(s string) (gcp.NotificationType, bool) {
+	for v, name := range notificationTypes() {
+		if strings.EqualFold(name, s) {
+			return gcp.NotificationType(v), true
+		}
+	}
+	n, err := strconv.ParseUint(s, 10, 8)
+	return gcp.NotificationType(n), err == nil
+}
+
+func notificationTypes() map[uint32]string {
+	if s := gcp.LookupTLV(gcp.TypeNTF, 9, 86, 1); s != nil {
+		return s.Enum
+	}
+	return nil
+}
+
+// send sends the message described in a document.
+func (sh *Shell) send(args []string) error {
+	if len(args) != 1 {
+		return usage("send")
+	}
+	doc, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	m, err := compose.Message(doc)
+	if err != nil {
+		return err
+	}
+	// Even Message IDs identify requests.
+	if m.MessageID&1 == 0 {
+		return sh.request(m)
+	}
+	sh.Print(true, nil, m)
+	return sh.Session.Send(m)
+}
+
+// request sends m and prints its response.
+func (sh *Shell) request(m *gcp.Message) error {
+	timeout := sh.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	sh.Print(true, nil, m)
+	r, err := sh.Session.Request(m, timeout)
+	if err != nil || r == nil {
+		return err
+	}
+	sh.Print(false, &r.TranID, r.Msg)
+	return nil
+}
+
+// Complete returns the completions of the last word of line: command
+// names, TLV paths, notification types, output formats or file names.
+func Complete(line string) []string {
+	word := line[strings.LastIndex(line, " ")+1:]
+	args := strings.Fields(line)
+	if len(args) == 0 || len(args) == 1 && word != "" {
+		var cs []string
+		for _, c := range commands {
+			if strings.HasPrefix(c.name, word) {
+				cs = append(cs, c.name)
+			}
+		}
+		return cs
+	}
+	switch args[0] {
+	case "read":
+		return completePath(word)
+	case "write":
+		if strings.Contains(word, "=") || strings.HasSuffix(strings.TrimSpace(line), "=") {
+			return nil
+		}
+		return completePath(word)
+	case "notify":
+		var cs []string
+		for _, name := range notificationTypes() {
+			if hasPrefixFold(name, word) {
+				cs = append(cs, name)
+			}
+		}
+		sort.Strings(cs)
+		return cs
+	case "format":
+		return matching([]string{"text", "json", "hex"}, word)
+	case "send":
+		ms, _ := filepath.Glob(word + "*")
+		for i, m := range ms {
+			if fi, err := os.Stat(m); err == nil && fi.IsDir() {
+				ms[i] += string(filepath.Separator)
+			}
+		}
+		return ms
+	}
+	return nil
+}
+
+// completePath returns the TLV paths that complete word. Paths to Complex
+// TLVs end with a dot.
+func completePath(word string) []string {
+	s := gcp.LookupTLV(gcp.TypeREX, 9)
+	if s == nil {
+		return nil
+	}
+	keys := strings.Split(word, ".")
+	ss := s.Sub
+	for _, k := range keys[:len(keys)-1] {
+		var c *gcp.TLVSchema
+		for _, s := range ss {
+			if strings.EqualFold(s.Name, k) || strconv.Itoa(int(s.Type)) == k {
+				c = s
+			}
+		}
+		if c == nil {
+			return nil
+		}
+		ss = c.Sub
+	}
+	prefix, last := strings.Join(keys[:len(keys)-1], "."), keys[len(keys)-1]
+	if prefix != "" {
+		prefix += "."
+	}
+	var cs []string
+	for _, s := range ss {
+		if len(keys) == 1 && (s.Type == 10 || s.Type == 11 || s.Type == 19) {
+			// Sequence Number, Operation and Response Code are set
+			// by the shell.
+			continue
+		}
+		name := s.Name
+		if !hasPrefixFold(name, last) {
+			name = strconv.Itoa(int(s.Type))
+			if !strings.HasPrefix(name, last) {
+				continue
+			}
+		}
+		if s.Kind == gcp.KindComplex {
+			name += "."
+		}
+		cs = append(cs, prefix+name)
+	}
+	return cs
+}
+
+func matching(names []string, word string) []string {
+	var cs []string
+	for _, n := range names {
+		if strings.HasPrefix(n, word) {
+			cs = append(cs, n)
+		}
+	}
+	return cs
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
@@ -0,0 +1,149 @@
+package shell_test
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/shell"
+	"github.com/nleiva/gcp-rphy/transport"
+)
+
+func TestComplete(t *testing.T) {
+	tt := []struct {
+		line string
+		want []string
+	}{
+		{line: "re", want: []string{"read"}},
+		{line: "read RpdCap", want: []string{"RpdCapabilities."}},
+		{line: "read rpdcapabilities.RpdIdentification.Vendor",
+			want: []string{"rpdcapabilities.RpdIdentification.VendorName", "rpdcapabilities.RpdIdentification.VendorId"}},
+		{line: "read 50.19.2", want: []string{"50.19.2", "50.19.20", "50.19.21", "50.19.22"}},
+		{line: "write RpdCapabilities.RpdIdentification.DeviceAlias = ", want: nil},
+		{line: "notify StartUp", want: []string{"StartUpNotification"}},
+		{line: "format j", want: []string{"json"}},
+	}
+	for _, tc := range tt {
+		if got := shell.Complete(tc.line); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Complete(%q) got: %q, want: %q", tc.line, got, tc.want)
+		}
+	}
+}
+
+// serve starts a core answering EDS Requests with a read response
+// carrying the vendor name, and returns a session connected to it and a
+// function that stops both.
+func serve(t *testing.T) (*transport.Session, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &transport.Core{
+		Handler: transport.HandlerFunc(func(s *transport.Session, r *transport.Request) {
+			req, ok := r.Msg.Body.(*gcp.EDSReq)
+			if !ok {
+				return
+			}
+			s.Reply(r, gcp.NewMessage(gcp.MessageIDEDSRes, &gcp.EDSRes{
+				TransactionID: req.TransactionID,
+				DataStr: gcp.EncodeSequence(gcp.TypeREX, 1, gcp.OpReadResponse,
+					gcp.EncodeTLV(50, gcp.EncodeTLV(19, gcp.EncodeTLV(1, []byte("acme"))))),
+			}))
+		}),
+	}
+	go c.Serve(l)
+	s, err := transport.Dial(l.Addr().String(), nil)
+	if err != nil {
+		c.Close()
+		t.Fatal(err)
+	}
+	return s, func() {
+		s.Close()
+		c.Close()
+	}
+}
+
+func TestExec(t *testing.T) {
+	s, stop := serve(t)
+	defer stop()
+	var sent, received []*gcp.Message
+	var out bytes.Buffer
+	sh := &shell.Shell{
+		Session: s,
+		Print: func(s bool, _ *uint16, m *gcp.Message) {
+			if s {
+				sent = append(sent, m)
+			} else {
+				received = append(received, m)
+			}
+		},
+		Out:     &out,
+		Timeout: time.Second,
+	}
+
+	if err := sh.Exec("write RpdCapabilities.RpdIdentification.DeviceAlias = node-1"); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	want := gcp.EncodeSequence(gcp.TypeREX, 1, gcp.OpWrite,
+		gcp.EncodeTLV(50, gcp.EncodeTLV(19, gcp.EncodeTLV(8, []byte("node-1")))))
+	if got := sent[0].Body.(*gcp.EDSReq).DataStr; !bytes.Equal(got, want) {
+		t.Errorf("write sent: %x, want: %x", got, want)
+	}
+	if len(received) != 1 || received[0].MessageID != uint8(gcp.MessageIDEDSRes) {
+		t.Errorf("write received: %v, want an EDS Response", received)
+	}
+
+	if err := sh.Exec("read 50.19"); err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	want = gcp.EncodeSequence(gcp.TypeREX, 2, gcp.OpRead, gcp.EncodeTLV(50, gcp.EncodeTLV(19)))
+	if got := sent[1].Body.(*gcp.EDSReq).DataStr; !bytes.Equal(got, want) {
+		t.Errorf("read sent: %x, want: %x", got, want)
+	}
+
+	if err := sh.Exec("notify StartUpNotification"); err != nil {
+		t.Fatalf("notify failed: %v", err)
+	}
+	if got, want := sent[2].Body.(*gcp.NotifyReq).EvntData, gcp.GeneralNotification(3, gcp.StartUpNotification); !bytes.Equal(got, want) {
+		t.Errorf("notify sent: %x, want: %x", got, want)
+	}
+
+	// Values run to the end of the line, or are quoted.
+	if err := sh.Exec("write RpdCapabilities.RpdIdentification.DeviceAlias = node 1"); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	want = gcp.EncodeSequence(gcp.TypeREX, 4, gcp.OpWrite,
+		gcp.EncodeTLV(50, gcp.EncodeTLV(19, gcp.EncodeTLV(8, []byte("node 1")))))
+	if got := sent[3].Body.(*gcp.EDSReq).DataStr; !bytes.Equal(got, want) {
+		t.Errorf("write sent: %x, want: %x", got, want)
+	}
+	if err := sh.Exec(`write 60.5 = "ccap = 1" 60.3 = 10.0.0.1`); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	want = gcp.EncodeSequence(gcp.TypeREX, 5, gcp.OpWrite,
+		gcp.EncodeTLV(60, gcp.EncodeTLV(5, []byte("ccap = 1")), gcp.EncodeTLV(3, []byte{10, 0, 0, 1})))
+	if got := sent[4].Body.(*gcp.EDSReq).DataStr; !bytes.Equal(got, want) {
+		t.Errorf("write sent: %x, want: %x", got, want)
+	}
+
+	for line, err := range map[string]string{
+		"reed 50":            "unknown command, type help for the list of commands",
+		"write 50.19.8":      "usage: write path=value...",
+		`write 60.5 = "ccap`: "usage: write path=value...",
+		"read 50.19.Foo":     "50.19.Foo: unknown key",
+		"notify Bogus":       `unknown notification type "Bogus"`,
+		"format text":        "the output format cannot be changed",
+		"timeout a long one": "usage: timeout duration",
+	} {
+		if got := sh.Exec(line); got == nil || got.Error() != err {
+			t.Errorf("Exec(%q) got: %v, want: %s", line, got, err)
+		}
+	}
+	if err := sh.Exec("help"); err != nil || !strings.Contains(out.String(), "notify type") {
+		t.Errorf("help got: %v, %q", err, out.String())
+	}
+}
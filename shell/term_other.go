@@ -0,0 +1,12 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package shell
+
+import "errors"
+
+// makeRaw is not supported on this platform, so commands are read a line
+// at a time, without completion nor history.
+func makeRaw(fd int) (func(), error) {
+	return nil, errors.New("terminal raw mode not supported")
+}
@@ -0,0 +1,117 @@
+package transport
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+)
+
+// DefaultBatchSize is the length of the units a Batcher sends by default,
+// which fit in a single TCP segment over Ethernet.
+const DefaultBatchSize = 1460
+
+// maxUnitSize is the length of the longest TCP encapsulation unit.
+const maxUnitSize = 6 + 0xffff
+
+// A Batcher packs the GCP messages sent over a Session into as few TCP
+// encapsulation units as it can, each up to Size bytes long, to save on
+// segments when sending many small messages like notifications. A
+// message that does not fit in a unit of Size bytes is sent alone.
+//
+// Messages are held until their unit is full, Delay expires or Flush is
+// called. Like those sent with Send, their responses are delivered to
+// the Handler of the Session. A Batcher is safe for concurrent use.
+type Batcher struct {
+	Session *Session
+	Size    int           // Maximum length of a unit, DefaultBatchSize if zero
+	Delay   time.Duration // Longest time a message is held, zero holds it until Flush
+
+	mu    sync.Mutex
+	buf   []byte
+	keys  []waitKey
+	timer *time.Timer
+	err   error // Error of the last flush on Delay
+}
+
+// Send adds m to the unit being packed, sending the unit first if m does
+// not fit in it. It returns the error of the last unit sent, which may
+// be an earlier one flushed on Delay.
+func (b *Batcher) Send(m *gcp.Message) error {
+	p, err := m.Marshal()
+	if err != nil {
+		return fmt.Errorf("failed to marshall a message: %v", err)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.err; err != nil {
+		b.err = nil
+		return err
+	}
+	if len(b.buf) > 0 && 7+len(b.buf)+len(p) > b.size() {
+		if err := b.flush(); err != nil {
+			return err
+		}
+	}
+	if k, ok := b.Session.await(m); ok {
+		b.keys = append(b.keys, k)
+	}
+	b.buf = append(b.buf, p...)
+	if 7+len(b.buf) >= b.size() {
+		return b.flush()
+	}
+	if b.Delay > 0 && b.timer == nil {
+		b.timer = time.AfterFunc(b.Delay, b.expire)
+	}
+	return nil
+}
+
+// Flush sends the messages held, if any.
+func (b *Batcher) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.err; err != nil {
+		b.err = nil
+		return err
+	}
+	return b.flush()
+}
+
+// expire flushes the messages held once Delay expires.
+func (b *Batcher) expire() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.flush(); err != nil {
+		b.err = err
+	}
+}
+
+// flush sends the messages held in a single unit.
+func (b *Batcher) flush() error {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(b.buf) == 0 {
+		return nil
+	}
+	err := b.Session.writeUnit(0, 0, b.buf)
+	if err != nil {
+		for _, k := range b.keys {
+			b.Session.answered(k)
+		}
+	}
+	b.buf, b.keys = b.buf[:0], b.keys[:0]
+	return err
+}
+
+func (b *Batcher) size() int {
+	switch {
+	case b.Size == 0:
+		return DefaultBatchSize
+	case b.Size > maxUnitSize:
+		return maxUnitSize
+	}
+	return b.Size
+}
@@ -0,0 +1,86 @@
+package transport_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/transport"
+)
+
+// unitCounter counts the units a Core receives.
+type unitCounter chan []byte
+
+func (c unitCounter) Record(dir transport.Direction, local, remote net.Addr, b []byte) {
+	if dir == transport.Received {
+		c <- b
+	}
+}
+
+func TestBatcher(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	units := make(unitCounter, 10)
+	ntfs := make(chan uint16, 10)
+	core := &transport.Core{
+		Recorder: units,
+		Handler: transport.HandlerFunc(func(s *transport.Session, r *transport.Request) {
+			ntfs <- r.Msg.TransactionID()
+		}),
+	}
+	go core.Serve(l)
+	defer core.Close()
+
+	s, err := transport.Dial(l.Addr().String(), nil)
+	if err != nil {
+		t.Fatalf("could not connect: %v", err)
+	}
+	defer s.Close()
+
+	notify := func(tid uint16) *gcp.Message {
+		return gcp.NewMessage(gcp.MessageIDNotifyReq, &gcp.NotifyReq{
+			TransactionID: tid,
+			EvntData:      gcp.GeneralNotification(tid, gcp.StartUpNotification),
+		})
+	}
+	b, _ := notify(1).Marshal()
+	// Two notifications fill a unit.
+	bt := &transport.Batcher{Session: s, Size: 7 + 2*len(b), Delay: 100 * time.Millisecond}
+	for tid := uint16(1); tid <= 5; tid++ {
+		if err := bt.Send(notify(tid)); err != nil {
+			t.Fatalf("could not send notification %d: %v", tid, err)
+		}
+	}
+	for tid := uint16(1); tid <= 5; tid++ {
+		select {
+		case got := <-ntfs:
+			if got != tid {
+				t.Errorf("notification got: %d, want: %d", got, tid)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("notification %d not received", tid)
+		}
+	}
+	for i, want := range []int{2, 2, 1} {
+		u := <-units
+		ms, err := gcp.ParseMessages(u[7:])
+		if err != nil || len(ms) != want {
+			t.Errorf("unit %d got: %d messages (%v), want: %d", i, len(ms), err, want)
+		}
+	}
+
+	if err := bt.Send(notify(6)); err != nil {
+		t.Fatalf("could not send notification 6: %v", err)
+	}
+	if err := bt.Flush(); err != nil {
+		t.Fatalf("could not flush: %v", err)
+	}
+	select {
+	case <-ntfs:
+	case <-time.After(50 * time.Millisecond):
+		t.Errorf("notification 6 not received before Delay")
+	}
+}
@@ -0,0 +1,200 @@
+package transport
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+)
+
+// defaultTimeout is how long a Core waits for an RPD to answer a request.
+const defaultTimeout = 5 * time.Second
+
+// A Core is the CCAP Core end of GCP. It accepts sessions from RPDs and
+// serves the messages they send with Handler.
+type Core struct {
+	Addr     string        // TCP address to listen on, ":8190" if empty
+	Handler  Handler       // Handler for messages received from RPDs
+	OnEvent  func(Event)   // Optional, called when a session starts or ends, or a request goes unanswered
+	Timeout  time.Duration // Time to wait for a response, 5s if zero
+	Liveness Liveness      // Dead RPD detection for each session
+	Recorder Recorder      // Optional, records the units of every session
+
+	// TLSConfig secures sessions with TLS when set. With CheckIdentity,
+	// the messages of an RPD are held until it reports, in a Notify
+	// Request or an EDS Response, an RpdIdentification that matches its
+	// certificate, see VerifyIdentity. Sessions whose RPD reports another
+	// identity are closed.
+	TLSConfig     *tls.Config
+	CheckIdentity bool
+
+	mu       sync.Mutex
+	ln       net.Listener
+	sessions map[*Session]struct{}
+	seq      uint16
+	closed   bool
+}
+
+// ListenAndServe listens on c.Addr and serves RPD sessions.
+func (c *Core) ListenAndServe() error {
+	addr := c.Addr
+	if addr == "" {
+		addr = ":8190"
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to start the server: %v", err)
+	}
+	return c.Serve(l)
+}
+
+// Serve accepts RPD sessions on l until the Core is closed.
+func (c *Core) Serve(l net.Listener) error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		l.Close()
+		return ErrSessionClosed
+	}
+	c.ln = l
+	if c.sessions == nil {
+		c.sessions = make(map[*Session]struct{})
+	}
+	c.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			c.mu.Lock()
+			closed := c.closed
+			c.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return fmt.Errorf("failed to accept the connection: %v", err)
+		}
+		go c.accept(conn)
+	}
+}
+
+// accept starts a session over conn and forgets it once it ends.
+func (c *Core) accept(conn net.Conn) {
+	c.Liveness.tune(conn)
+	var id *identityCheck
+	if c.TLSConfig != nil {
+		tc := tls.Server(conn, c.TLSConfig)
+		timeout := c.Timeout
+		if timeout == 0 {
+			timeout = defaultTimeout
+		}
+		if err := handshake(tc, timeout); err != nil {
+			log.Printf("failed to accept the connection: %v", err)
+			return
+		}
+		conn = tc
+		if c.CheckIdentity {
+			certs := tc.ConnectionState().PeerCertificates
+			if len(certs) == 0 {
+				log.Printf("failed to accept the connection: %v", errNoPeerCert)
+				tc.Close()
+				return
+			}
+			id = &identityCheck{cert: certs[0]}
+		}
+	}
+	s := newSession(conn, c.Handler, c.Liveness)
+	s.identity = id
+	s.recorder = c.Recorder
+	s.onEvent = c.emit
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		conn.Close()
+		return
+	}
+	c.sessions[s] = struct{}{}
+	c.mu.Unlock()
+	s.start()
+	c.emit(Event{Type: EventConnected, Addr: s.RemoteAddr().String()})
+
+	<-s.Done()
+	c.mu.Lock()
+	delete(c.sessions, s)
+	c.mu.Unlock()
+	c.emit(Event{Type: EventDisconnected, Addr: s.RemoteAddr().String(), Err: s.Err()})
+}
+
+func (c *Core) emit(e Event) {
+	if c.OnEvent != nil {
+		c.OnEvent(e)
+	}
+}
+
+// Sessions returns the active RPD sessions.
+func (c *Core) Sessions() []*Session {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ss := make([]*Session, 0, len(c.sessions))
+	for s := range c.sessions {
+		ss = append(ss, s)
+	}
+	return ss
+}
+
+// Handover hands the RPD on session s over to the CCAP Core at addr.
+// The RPD acknowledges the request, closes the session and connects to
+// the new core keeping its configuration.
+func (c *Core) Handover(s *Session, addr net.IP) error {
+	c.mu.Lock()
+	c.seq++
+	seq := c.seq
+	c.mu.Unlock()
+
+	req := &gcp.EDSReq{
+		TransactionID: seq,
+		VendorID:      gcp.CableLabs,
+		DataStr:       gcp.Redirect(seq, addr),
+	}
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+	r, err := s.Request(gcp.NewMessage(gcp.MessageIDEDSReq, req), timeout)
+	if err != nil {
+		return fmt.Errorf("handover to %v failed: %v", addr, err)
+	}
+	if _, ok := r.Msg.Body.(*gcp.EDSRes); !ok {
+		return fmt.Errorf("handover to %v failed: unexpected response, Message ID: %d", addr, r.Msg.MessageID)
+	}
+	_, g := r.Msg.Body.Process()
+	if g == nil || g.IRA == nil || g.IRA.Sequence.ResponseCode != "NoError" {
+		return errors.New("handover rejected by the RPD")
+	}
+	return nil
+}
+
+// Close stops accepting sessions and ends the active ones.
+func (c *Core) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	ln := c.ln
+	ss := make([]*Session, 0, len(c.sessions))
+	for s := range c.sessions {
+		ss = append(ss, s)
+	}
+	c.mu.Unlock()
+
+	var err error
+	if ln != nil {
+		err = ln.Close()
+	}
+	for _, s := range ss {
+		s.Close()
+	}
+	return err
+}
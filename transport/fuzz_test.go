@@ -0,0 +1,67 @@
+package transport_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/nleiva/gcp-rphy/transport"
+)
+
+// seedUnits adds the TCP encapsulation of the test messages to the corpus
+// of f.
+func seedUnits(f *testing.F) {
+	for _, s := range []string{ntf, dm} {
+		m, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			f.Fatal(err)
+		}
+		b, _ := transport.TCPmessage{TranID: 1, ProtID: 1, Len: uint16(1 + len(m)), Msg: m}.Marshal()
+		f.Add(b)
+	}
+}
+
+func FuzzUnMarshal(f *testing.F) {
+	seedUnits(f)
+	f.Fuzz(func(t *testing.T, b []byte) {
+		p, err := transport.UnMarshal(b)
+		if err != nil {
+			return
+		}
+		got, err := p.Marshal()
+		if err != nil {
+			t.Fatalf("Marshal of an unmarshaled unit got: %v", err)
+		}
+		if !bytes.Equal(got, b) {
+			t.Fatalf("Marshal got: %x, want %x", got, b)
+		}
+	})
+}
+
+func FuzzReadMessage(f *testing.F) {
+	seedUnits(f)
+	f.Fuzz(func(t *testing.T, b []byte) {
+		p, err := transport.ReadMessage(bytes.NewReader(b))
+		if err != nil {
+			return
+		}
+		if int(p.Len) != 1+len(p.Msg) {
+			t.Fatalf("Len got: %d, want %d for a %d-byte message", p.Len, 1+len(p.Msg), len(p.Msg))
+		}
+	})
+}
+
+func FuzzUnMarshalL2TP(f *testing.F) {
+	m, _ := base64.StdEncoding.DecodeString(dm)
+	b, _ := transport.EncapsulateL2TP(20, 1, m).Marshal()
+	f.Add(b)
+	f.Fuzz(func(t *testing.T, b []byte) {
+		p, err := transport.UnMarshalL2TP(b)
+		if err != nil {
+			return
+		}
+		if _, err := p.Marshal(); err != nil {
+			t.Fatalf("Marshal of an unmarshaled message got: %v", err)
+		}
+	})
+}
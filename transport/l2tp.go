@@ -0,0 +1,338 @@
+package transport
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+)
+
+// L2TPv3 parameters used to carry GCP messages. Each GCP message travels
+// in a CableLabs vendor-specific AVP of an L2TPv3 control message.
+const (
+	L2TPHeaderLen   = 12   // Control message header over UDP
+	L2TPMaxAVPValue = 1017 // 10 bits AVP Length minus the 6 bytes AVP header
+
+	l2tpVersion     = 3
+	l2tpAttrMsgType = 0  // Message Type AVP (IETF)
+	l2tpAttrConnID  = 61 // Assigned Control Connection ID AVP (IETF)
+)
+
+// Error messages
+var (
+	ErrAVPTooLong = errors.New("AVP value too long")
+	ErrL2TPConfig = errors.New("L2TPv3 message type and GCP attribute type must be set")
+	errL2TPHeader = errors.New("not an L2TPv3 control message")
+	errL2TPAVPLen = errors.New("AVP length is inconsistent with the message")
+)
+
+// An AVP is an L2TPv3 Attribute Value Pair (RFC 3931, section 5.1).
+type AVP struct {
+	Mandatory bool   // M bit
+	Hidden    bool   // H bit
+	VendorID  uint16 // Vendor ID: 2 bytes
+	Type      uint16 // Attribute Type: 2 bytes
+	Value     []byte // Attribute Value: N bytes
+}
+
+// Marshal encodes an AVP.
+func (a AVP) Marshal() ([]byte, error) {
+	if len(a.Value) > L2TPMaxAVPValue {
+		return nil, ErrAVPTooLong
+	}
+	l := 6 + len(a.Value)
+	b := make([]byte, l)
+	binary.BigEndian.PutUint16(b[:2], uint16(l))
+	if a.Mandatory {
+		b[0] |= 0x80
+	}
+	if a.Hidden {
+		b[0] |= 0x40
+	}
+	binary.BigEndian.PutUint16(b[2:4], a.VendorID)
+	binary.BigEndian.PutUint16(b[4:6], a.Type)
+	copy(b[6:], a.Value)
+	return b, nil
+}
+
+// parseAVPs decodes the AVPs in b.
+func parseAVPs(b []byte) ([]AVP, error) {
+	var avps []AVP
+	for i := 0; len(b[i:]) != 0; {
+		if len(b[i:]) < 6 {
+			return nil, errL2TPAVPLen
+		}
+		l := int(binary.BigEndian.Uint16(b[i:i+2]) & 0x03ff)
+		if l < 6 || l > len(b[i:]) {
+			return nil, errL2TPAVPLen
+		}
+		a := AVP{
+			Mandatory: b[i]&0x80 != 0,
+			Hidden:    b[i]&0x40 != 0,
+			VendorID:  binary.BigEndian.Uint16(b[i+2 : i+4]),
+			Type:      binary.BigEndian.Uint16(b[i+4 : i+6]),
+			Value:     make([]byte, l-6),
+		}
+		copy(a.Value, b[i+6:i+l])
+		avps = append(avps, a)
+		i += l
+	}
+	return avps, nil
+}
+
+// L2TPmessage represents an L2TPv3 control message over UDP. A message
+// without AVPs is a Zero-Length Body (ZLB) acknowledgement.
+type L2TPmessage struct {
+	ConnID uint32 // Control Connection ID: 4 bytes
+	Ns     uint16 // Sequence number of this message: 2 bytes
+	Nr     uint16 // Next sequence number expected from the peer: 2 bytes
+	AVPs   []AVP
+}
+
+// Marshal encodes an L2TPv3 control message.
+func (p L2TPmessage) Marshal() ([]byte, error) {
+	b := make([]byte, L2TPHeaderLen)
+	for _, a := range p.AVPs {
+		ab, err := a.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, ab...)
+	}
+	// T=1 (control), L=1 (length present), S=1 (sequence present).
+	binary.BigEndian.PutUint16(b[:2], 0xc800|l2tpVersion)
+	binary.BigEndian.PutUint16(b[2:4], uint16(len(b)))
+	binary.BigEndian.PutUint32(b[4:8], p.ConnID)
+	binary.BigEndian.PutUint16(b[8:10], p.Ns)
+	binary.BigEndian.PutUint16(b[10:12], p.Nr)
+	return b, nil
+}
+
+// UnMarshalL2TP decodes an L2TPv3 control message.
+func UnMarshalL2TP(b []byte) (L2TPmessage, error) {
+	if len(b) < L2TPHeaderLen {
+		return L2TPmessage{}, gcp.ErrMessageTooShort
+	}
+	if binary.BigEndian.Uint16(b[:2])&0xc80f != 0xc800|l2tpVersion {
+		return L2TPmessage{}, errL2TPHeader
+	}
+	l := int(binary.BigEndian.Uint16(b[2:4]))
+	if l < L2TPHeaderLen || l > len(b) {
+		return L2TPmessage{}, errL2TPAVPLen
+	}
+	avps, err := parseAVPs(b[L2TPHeaderLen:l])
+	if err != nil {
+		return L2TPmessage{}, err
+	}
+	return L2TPmessage{
+		ConnID: binary.BigEndian.Uint32(b[4:8]),
+		Ns:     binary.BigEndian.Uint16(b[8:10]),
+		Nr:     binary.BigEndian.Uint16(b[10:12]),
+		AVPs:   avps,
+	}, nil
+}
+
+// EncapsulateL2TP returns a control message of type msgType carrying
+// each of msgs in a GCP AVP of type attr.
+func EncapsulateL2TP(msgType, attr uint16, msgs ...[]byte) L2TPmessage {
+	p := L2TPmessage{AVPs: []AVP{{
+		Mandatory: true,
+		VendorID:  0, // IETF
+		Type:      l2tpAttrMsgType,
+		Value:     []byte{byte(msgType >> 8), byte(msgType)},
+	}}}
+	for _, m := range msgs {
+		p.AVPs = append(p.AVPs, AVP{VendorID: uint16(gcp.CableLabs), Type: attr, Value: m})
+	}
+	return p
+}
+
+// GCP returns the GCP messages carried in AVPs of type attr.
+func (p L2TPmessage) GCP(attr uint16) [][]byte {
+	var msgs [][]byte
+	for _, a := range p.AVPs {
+		if a.VendorID == uint16(gcp.CableLabs) && a.Type == attr {
+			msgs = append(msgs, a.Value)
+		}
+	}
+	return msgs
+}
+
+// L2TPEnd is a minimal stand-in for an L2TPv3 control channel over UDP
+// that satisfies the Transport interface. It carries GCP messages in
+// AVPs, without any of the tunnel or session management of a real
+// L2TPv3 stack.
+//
+// The R-PHY specifications assign no control message type or AVP to GCP,
+// so MsgType and Attr must be set to the values the peer uses.
+//
+// Like a control channel, it delivers messages reliably and in order:
+// each message has a sequence number (Ns) and is retransmitted until
+// the peer acknowledges it with a Zero-Length Body message whose Nr
+// covers it.
+type L2TPEnd struct {
+	Host          string
+	Port          string
+	ConnID        uint32                       // Control Connection ID of the peer, for peers that do not assign theirs
+	LocalConnID   uint32                       // Own Control Connection ID, assigned to the peer in every message if set
+	MsgType       uint16                       // Message type of control messages carrying GCP
+	Attr          uint16                       // Attribute type of GCP AVPs
+	Retries       int                          // Retransmissions of an unacknowledged message
+	RetryInterval time.Duration                // Time to wait for an acknowledgement, DefaultRetryInterval if zero
+	Handler       func(net.Addr, *gcp.Message) // Called for each message received, messages are printed if nil
+
+	mu   sync.Mutex
+	conn net.Conn
+	ns   uint16
+}
+
+// Receive listens for new GCP/L2TPv3 messages.
+func (e *L2TPEnd) Receive() error {
+	c, err := net.ListenPacket("udp", ":"+e.Port)
+	if err != nil {
+		return fmt.Errorf("failed to start the server: %v", err)
+	}
+	defer c.Close()
+	return e.Serve(c)
+}
+
+// Serve reads GCP/L2TPv3 messages from c until it is closed.
+func (e *L2TPEnd) Serve(c net.PacketConn) error {
+	if e.MsgType == 0 || e.Attr == 0 {
+		return ErrL2TPConfig
+	}
+	// Next sequence number expected from each peer.
+	nr := make(map[string]uint16)
+	buf := make([]byte, 65535)
+	for {
+		n, addr, err := c.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		p, err := UnMarshalL2TP(buf[:n])
+		if err != nil {
+			log.Printf("failed unmarshaling L2TPv3 message from %s: %v", addr, err)
+			continue
+		}
+		if len(p.AVPs) == 0 {
+			// Stray acknowledgement.
+			continue
+		}
+		peer := addr.String()
+		next := nr[peer]
+		// Ns ahead of the expected one means a message was lost, wait
+		// for its retransmission.
+		if int16(p.Ns-next) > 0 {
+			continue
+		}
+		deliver := p.Ns == next
+		if deliver {
+			next++
+			nr[peer] = next
+		}
+		ack, _ := L2TPmessage{ConnID: e.peerConnID(p), Ns: 0, Nr: next}.Marshal()
+		if _, err := c.WriteTo(ack, addr); err != nil {
+			log.Printf("failed to acknowledge a message: %v", err)
+		}
+		if !deliver {
+			continue
+		}
+		for _, b := range p.GCP(e.Attr) {
+			m, err := gcp.ParseMessage(b)
+			if err != nil {
+				log.Printf("could not parse GCP message: %s\n", err.Error())
+				continue
+			}
+			if e.Handler != nil {
+				e.Handler(addr, m)
+				continue
+			}
+			output, _ := m.Body.Process()
+			fmt.Printf("Incoming Message (Length: %d) ->\n  Message Identifier: %v\n  Length: %v\n  Body: %s\n",
+				len(b), m.MessageID, m.Lenght, output)
+		}
+	}
+}
+
+// peerConnID returns the Control Connection ID the sender of p assigned
+// itself, or the configured one if it assigned none.
+func (e *L2TPEnd) peerConnID(p L2TPmessage) uint32 {
+	for _, a := range p.AVPs {
+		if a.VendorID == 0 && a.Type == l2tpAttrConnID && len(a.Value) == 4 {
+			return binary.BigEndian.Uint32(a.Value)
+		}
+	}
+	return e.ConnID
+}
+
+// Send transmits a GCP/L2TPv3 message.
+func (e *L2TPEnd) Send(b []byte) error {
+	if e.MsgType == 0 || e.Attr == 0 {
+		return ErrL2TPConfig
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.conn == nil {
+		c, err := net.Dial("udp", net.JoinHostPort(e.Host, e.Port))
+		if err != nil {
+			return fmt.Errorf("could not establish a connection: %v", err)
+		}
+		e.conn = c
+	}
+	p := EncapsulateL2TP(e.MsgType, e.Attr, b)
+	p.ConnID, p.Ns = e.ConnID, e.ns
+	if e.LocalConnID != 0 {
+		id := make([]byte, 4)
+		binary.BigEndian.PutUint32(id, e.LocalConnID)
+		p.AVPs = append(p.AVPs, AVP{Mandatory: true, Type: l2tpAttrConnID, Value: id})
+	}
+	t, err := p.Marshal()
+	if err != nil {
+		return fmt.Errorf("failed to marshall a message: %v", err)
+	}
+
+	interval := e.RetryInterval
+	if interval == 0 {
+		interval = DefaultRetryInterval
+	}
+	ack := make([]byte, 65535)
+	for i := 0; i <= e.Retries; i++ {
+		if _, err := e.conn.Write(t); err != nil {
+			return fmt.Errorf("failed to send a message: %v", err)
+		}
+		e.conn.SetReadDeadline(time.Now().Add(interval))
+		for {
+			n, err := e.conn.Read(ack)
+			if err != nil {
+				break
+			}
+			// The acknowledgement covers this message once Nr moves past it.
+			a, err := UnMarshalL2TP(ack[:n])
+			if err != nil || e.LocalConnID != 0 && a.ConnID != e.LocalConnID {
+				continue
+			}
+			if int16(a.Nr-e.ns) > 0 {
+				e.ns++
+				return nil
+			}
+		}
+	}
+	return ErrNoAck
+}
+
+// Close releases the connection used by Send.
+func (e *L2TPEnd) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.conn == nil {
+		return nil
+	}
+	err := e.conn.Close()
+	e.conn = nil
+	return err
+}
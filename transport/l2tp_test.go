@@ -0,0 +1,117 @@
+package transport_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net"
+	"testing"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/transport"
+)
+
+// L2TPv3 message and attribute types carrying GCP in the tests.
+const (
+	gcpMsgType = 100
+	gcpAttr    = 100
+)
+
+func TestL2TPMarshal(t *testing.T) {
+	data, err := base64.StdEncoding.DecodeString(ntf)
+	if err != nil {
+		t.Fatalf("could not decode base64 notify message: %v", err)
+	}
+	p := transport.EncapsulateL2TP(gcpMsgType, gcpAttr, data, data)
+	p.ConnID, p.Ns, p.Nr = 0x01020304, 7, 9
+	b, err := p.Marshal()
+	if err != nil {
+		t.Fatalf("could not marshal L2TPv3 message: %v", err)
+	}
+	// T, L and S bits set, version 3.
+	if !bytes.Equal(b[:2], []byte{0xc8, 0x03}) {
+		t.Fatalf("Flags and Version got: %x, want: c803", b[:2])
+	}
+	q, err := transport.UnMarshalL2TP(b)
+	if err != nil {
+		t.Fatalf("could not unmarshal L2TPv3 message: %v", err)
+	}
+	if q.ConnID != p.ConnID || q.Ns != p.Ns || q.Nr != p.Nr {
+		t.Fatalf("Header got: %d/%d/%d, want: %d/%d/%d", q.ConnID, q.Ns, q.Nr, p.ConnID, p.Ns, p.Nr)
+	}
+	msgs := q.GCP(gcpAttr)
+	if len(msgs) != 2 || !bytes.Equal(msgs[0], data) || !bytes.Equal(msgs[1], data) {
+		t.Fatalf("GCP messages got: %d, want: 2 copies of the notify message", len(msgs))
+	}
+	if _, err := transport.EncapsulateL2TP(1, 1, make([]byte, transport.L2TPMaxAVPValue+1)).Marshal(); err != transport.ErrAVPTooLong {
+		t.Fatalf("Marshal of an oversized AVP got: %v, want: %v", err, transport.ErrAVPTooLong)
+	}
+	// Truncated AVP.
+	if _, err := transport.UnMarshalL2TP(b[:len(b)-1]); err == nil {
+		t.Fatalf("UnMarshalL2TP of a truncated message succeeded")
+	}
+}
+
+func TestL2TPSendReceive(t *testing.T) {
+	data, err := base64.StdEncoding.DecodeString(dm)
+	if err != nil {
+		t.Fatalf("could not decode base64 message: %v", err)
+	}
+	tt := []struct {
+		name       string
+		dropReads  int
+		dropWrites int
+		connID     uint32 // Control Connection ID the client assigns itself
+	}{
+		{name: "Reliable"},
+		{name: "Assigned connection ID", connID: 0x0a0b0c0d},
+		{name: "Lost message", dropReads: 1},
+		{name: "Lost acknowledgement", dropWrites: 1},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("could not listen: %v", err)
+			}
+			defer pc.Close()
+			msgs := make(chan *gcp.Message, 10)
+			server := &transport.L2TPEnd{MsgType: gcpMsgType, Attr: gcpAttr, Handler: func(_ net.Addr, m *gcp.Message) { msgs <- m }}
+			go server.Serve(&lossyConn{PacketConn: pc, dropReads: tc.dropReads, dropWrites: tc.dropWrites})
+
+			host, port, _ := net.SplitHostPort(pc.LocalAddr().String())
+			client := &transport.L2TPEnd{Host: host, Port: port, MsgType: gcpMsgType, Attr: gcpAttr,
+				ConnID: 1, LocalConnID: tc.connID, Retries: 3, RetryInterval: 50 * time.Millisecond}
+			defer client.Close()
+			// Two messages, delivered once each and in order.
+			for i := 0; i < 2; i++ {
+				if err := client.Send(data); err != nil {
+					t.Fatalf("could not send message %d: %v", i, err)
+				}
+			}
+			for i := 0; i < 2; i++ {
+				select {
+				case m := <-msgs:
+					b, _ := m.Marshal()
+					if !bytes.Equal(b, data) {
+						t.Fatalf("Message got: %v, want: %v", b, data)
+					}
+				case <-time.After(time.Second):
+					t.Fatalf("timed out waiting for message %d", i)
+				}
+			}
+			select {
+			case <-msgs:
+				t.Fatalf("duplicate message delivered")
+			case <-time.After(200 * time.Millisecond):
+			}
+		})
+	}
+}
+
+func TestL2TPConfig(t *testing.T) {
+	e := &transport.L2TPEnd{Host: "127.0.0.1", Port: "1701"}
+	if err := e.Send([]byte{}); err != transport.ErrL2TPConfig {
+		t.Fatalf("Send got: %v, want: %v", err, transport.ErrL2TPConfig)
+	}
+}
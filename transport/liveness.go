@@ -0,0 +1,118 @@
+package transport
+
+import (
+	"net"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+)
+
+// A ProbeType selects the message a Session sends to check that its
+// peer is alive.
+type ProbeType int
+
+// Keepalive probe types.
+const (
+	// ProbeNotify sends a GCP Notify Request without Event Data. Sessions
+	// answer these with a Notify Response without involving their Handler.
+	ProbeNotify ProbeType = iota
+	// ProbeRead sends an RCP REX Read of the RPD's SerialNumber. It
+	// relies on the peer's Handler answering RCP reads.
+	ProbeRead
+)
+
+// Liveness configures how a Session detects a dead peer.
+//
+// A Session declares its peer dead when nothing is received from it for
+// Timeout. To keep a healthy but quiet session from crossing that
+// threshold, the Session sends a probe after every Interval without
+// traffic from the peer. When the peer is declared dead the Session
+// ends with ErrPeerTimeout.
+type Liveness struct {
+	Interval     time.Duration // Idle time before probing the peer, zero disables probes
+	Timeout      time.Duration // Idle time before declaring the peer dead, 3 Intervals if zero
+	Probe        ProbeType     // Message used to probe the peer
+	TCPKeepAlive time.Duration // TCP keepalive period, zero keeps Go's default, negative disables them
+	WriteTimeout time.Duration // Maximum time to write a message, zero means no limit
+
+	// ResponseTimeout is the time the peer has to answer the requests
+	// sent with Send whose Mode requires a response, before the Session
+	// raises an EventNoResponse. Zero disables the check.
+	ResponseTimeout time.Duration
+}
+
+// timeout returns how long the peer may stay silent, or zero if the
+// Session waits for it forever.
+func (lv Liveness) timeout() time.Duration {
+	if lv.Timeout > 0 {
+		return lv.Timeout
+	}
+	return 3 * lv.Interval
+}
+
+// tune applies the TCP keepalive settings to c.
+func (lv Liveness) tune(c net.Conn) {
+	tc, ok := c.(*net.TCPConn)
+	if !ok || lv.TCPKeepAlive == 0 {
+		return
+	}
+	if lv.TCPKeepAlive < 0 {
+		tc.SetKeepAlive(false)
+		return
+	}
+	tc.SetKeepAlive(true)
+	tc.SetKeepAlivePeriod(lv.TCPKeepAlive)
+}
+
+// keepalive probes the peer whenever it has been quiet for an Interval.
+func (s *Session) keepalive() {
+	t := time.NewTicker(s.liveness.Interval)
+	defer t.Stop()
+	var seq uint16
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-t.C:
+			s.mu.Lock()
+			idle := time.Since(s.lastRx)
+			s.mu.Unlock()
+			if idle < s.liveness.Interval {
+				continue
+			}
+			seq++
+			// Whether the probe is answered or not, the read deadline
+			// decides when the peer is dead.
+			go s.Request(s.liveness.probe(seq), s.liveness.Interval)
+		}
+	}
+}
+
+// probe returns the keepalive message with sequence number seq.
+func (lv Liveness) probe(seq uint16) *gcp.Message {
+	if lv.Probe == ProbeRead {
+		req := &gcp.EDSReq{
+			TransactionID: seq,
+			VendorID:      gcp.CableLabs,
+			DataStr: gcp.EncodeSequence(gcp.TypeREX, seq, gcp.OpRead,
+				gcp.EncodeTLV(50, gcp.EncodeTLV(19, gcp.EncodeTLV(9)))),
+		}
+		return gcp.NewMessage(gcp.MessageIDEDSReq, req)
+	}
+	return gcp.NewMessage(gcp.MessageIDNotifyReq, &gcp.NotifyReq{TransactionID: seq})
+}
+
+// answerProbe responds to a Notify keepalive probe.
+func (s *Session) answerProbe(r *Request) bool {
+	n, ok := r.Msg.Body.(*gcp.NotifyReq)
+	if !ok || r.TranID == 0 || len(n.EvntData) != 0 {
+		return false
+	}
+	res := &gcp.NotifyRes{
+		TransactionID: n.TransactionID,
+		Mode:          n.Mode,
+		EvntCode:      n.EvntCode,
+	}
+	s.Reply(r, gcp.NewMessage(gcp.MessageIDNotifyRes, res))
+	return true
+}
@@ -0,0 +1,105 @@
+package transport_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/transport"
+)
+
+// blackHole accepts a connection and reports the notifications it
+// reads, without ever answering.
+func blackHole(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	ntfs := make(chan string, 10)
+	go func() {
+		defer l.Close()
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		for {
+			pkt, err := transport.ReadMessage(c)
+			if err != nil {
+				return
+			}
+			m, err := gcp.ParseMessage(pkt.Msg)
+			if err != nil {
+				continue
+			}
+			_, g := m.Body.Process()
+			if g != nil && g.NTF != nil && g.NTF.Sequence.GeneralNtf != nil {
+				ntfs <- g.NTF.Sequence.GeneralNtf.NotificationType
+			}
+		}
+	}()
+	return l.Addr().String(), ntfs
+}
+
+func TestLivenessTimeout(t *testing.T) {
+	addr, ntfs := blackHole(t)
+	events := make(chan transport.Event, 10)
+	rpd := &transport.RPD{
+		Core:             addr,
+		Liveness:         transport.Liveness{Interval: 50 * time.Millisecond, Timeout: 200 * time.Millisecond},
+		ReconnectTimeout: 100 * time.Millisecond,
+		OnEvent:          func(e transport.Event) { events <- e },
+	}
+	go rpd.Run()
+	defer rpd.Close()
+
+	expectNotification(t, ntfs, "StartUpNotification")
+	expectNotification(t, ntfs, "TimeOutNotification")
+	if e := <-events; e.Type != transport.EventConnected {
+		t.Fatalf("Event got: %v, want: %v", e.Type, transport.EventConnected)
+	}
+	e := <-events
+	if e.Type != transport.EventDisconnected || e.Err != transport.ErrPeerTimeout {
+		t.Fatalf("Event got: %v (%v), want: %v (%v)", e.Type, e.Err, transport.EventDisconnected, transport.ErrPeerTimeout)
+	}
+}
+
+func TestLivenessKeepalive(t *testing.T) {
+	for _, probe := range []transport.ProbeType{transport.ProbeNotify, transport.ProbeRead} {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("could not listen: %v", err)
+		}
+		core := &transport.Core{
+			// Answer RCP reads, so they can be used as probes.
+			Handler: transport.HandlerFunc(func(s *transport.Session, r *transport.Request) {
+				if req, ok := r.Msg.Body.(*gcp.EDSReq); ok {
+					s.Reply(r, gcp.NewMessage(gcp.MessageIDEDSRes, &gcp.EDSRes{TransactionID: req.TransactionID}))
+				}
+			}),
+			Liveness: transport.Liveness{Interval: 50 * time.Millisecond},
+		}
+		go core.Serve(l)
+
+		events := make(chan transport.Event, 10)
+		rpd := &transport.RPD{
+			Core:     l.Addr().String(),
+			Liveness: transport.Liveness{Interval: 50 * time.Millisecond, Timeout: 200 * time.Millisecond, Probe: probe},
+			OnEvent:  func(e transport.Event) { events <- e },
+		}
+		go rpd.Run()
+
+		if e := <-events; e.Type != transport.EventConnected {
+			t.Fatalf("Event got: %v, want: %v", e.Type, transport.EventConnected)
+		}
+		select {
+		case e := <-events:
+			t.Fatalf("unexpected event with probe %d: %v (%v)", probe, e.Type, e.Err)
+		case <-time.After(600 * time.Millisecond):
+		}
+		rpd.Close()
+		core.Close()
+	}
+}
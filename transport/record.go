@@ -0,0 +1,38 @@
+package transport
+
+import (
+	"net"
+)
+
+// Direction is the direction of a GCP encapsulation unit, as seen from
+// the local end.
+type Direction int
+
+// Directions of a GCP encapsulation unit.
+const (
+	Received Direction = iota
+	Sent
+)
+
+func (d Direction) String() string {
+	if d == Sent {
+		return "sent"
+	}
+	return "received"
+}
+
+// A Recorder records the GCP encapsulation units exchanged over TCP,
+// for instance to a capture file with pcap.Recorder. Record is called
+// with the encoded unit b once it has been written to, or read from,
+// the connection between local and remote. It may be called
+// concurrently for different connections.
+type Recorder interface {
+	Record(dir Direction, local, remote net.Addr, b []byte)
+}
+
+// record hands a unit exchanged over c to r, if any.
+func record(r Recorder, dir Direction, c net.Conn, b []byte) {
+	if r != nil {
+		r.Record(dir, c.LocalAddr(), c.RemoteAddr(), b)
+	}
+}
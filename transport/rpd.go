@@ -0,0 +1,237 @@
+package transport
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+)
+
+// Default RPD reconnect timers.
+const (
+	DefaultReconnectInterval = 1 * time.Second
+	DefaultReconnectTimeout  = 60 * time.Second
+)
+
+// An RPD is the RPD end of GCP. It keeps a single association with its
+// principal CCAP Core across connection losses and core handovers.
+//
+// When Liveness is configured and the core stays silent for longer
+// than its timeout, the RPD raises a TimeOutNotification, drops the
+// session and reports it with an EventDisconnected carrying
+// ErrPeerTimeout, before starting the reconnect procedure.
+//
+// When the session to the core is lost, the RPD retries the same core
+// every ReconnectInterval until ReconnectTimeout expires. When the core
+// hands the RPD over with an RpdRedirect write, the RPD acknowledges it
+// and moves to the new core. In both cases the configuration state kept
+// by Handler is preserved, and the RPD announces itself with a
+// ReconnectNotification or a HandoverNotification instead of the
+// StartUpNotification sent on the first connection.
+type RPD struct {
+	Core    string      // Address (host:port) of the principal CCAP Core
+	Port    string      // GCP port of the cores the RPD is handed over to, "8190" if empty
+	Handler Handler     // Handler for messages received from the core
+	OnEvent func(Event) // Optional, called when the association changes state
+
+	Liveness  Liveness    // Dead core detection
+	TLSConfig *tls.Config // Secures sessions with TLS when set
+	Recorder  Recorder    // Optional, records the units of every session
+
+	ReconnectInterval time.Duration // Time between reconnect attempts
+	ReconnectTimeout  time.Duration // Time to give up reconnecting
+
+	mu     sync.Mutex
+	sess   *Session
+	seq    uint16
+	tid    uint16
+	next   string
+	closed bool
+	quit   chan struct{}
+}
+
+// Run connects the RPD to its principal core and keeps it associated
+// until Close is called, or reconnecting to a core fails.
+func (r *RPD) Run() error {
+	r.mu.Lock()
+	r.quit = make(chan struct{})
+	r.mu.Unlock()
+
+	addr := r.Core
+	s, err := r.connect(addr)
+	if err != nil {
+		return err
+	}
+	nt, ev := gcp.StartUpNotification, EventConnected
+	for {
+		r.emit(Event{Type: ev, Addr: addr})
+		if err := r.Notify(nt); err != nil {
+			s.Close()
+		}
+		<-s.Done()
+
+		r.mu.Lock()
+		closed, next := r.closed, r.next
+		r.next = ""
+		r.mu.Unlock()
+		switch {
+		case closed:
+			return nil
+		case next != "":
+			addr = next
+			nt, ev = gcp.HandoverNotification, EventHandover
+		default:
+			r.emit(Event{Type: EventDisconnected, Addr: addr, Err: s.Err()})
+			nt, ev = gcp.ReconnectNotification, EventReconnected
+		}
+		s, err = r.reconnect(addr)
+		if err != nil {
+			r.emit(Event{Type: EventReconnectFailed, Addr: addr, Err: err})
+			return err
+		}
+	}
+}
+
+// connect establishes a session with the core at addr.
+func (r *RPD) connect(addr string) (*Session, error) {
+	d := Dialer{Liveness: r.Liveness, TLSConfig: r.TLSConfig, Recorder: r.Recorder, OnEvent: r.emit}
+	s, err := d.dial(addr, HandlerFunc(r.serve), r.timedOut)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		s.Close()
+		return nil, ErrSessionClosed
+	}
+	r.sess = s
+	return s, nil
+}
+
+// reconnect tries to establish a session with the core at addr until
+// the reconnect timeout expires.
+func (r *RPD) reconnect(addr string) (*Session, error) {
+	interval, timeout := r.ReconnectInterval, r.ReconnectTimeout
+	if interval == 0 {
+		interval = DefaultReconnectInterval
+	}
+	if timeout == 0 {
+		timeout = DefaultReconnectTimeout
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		s, err := r.connect(addr)
+		if err == nil || err == ErrSessionClosed {
+			return s, err
+		}
+		if time.Now().Add(interval).After(deadline) {
+			return nil, fmt.Errorf("could not reconnect to %s: %v", addr, err)
+		}
+		select {
+		case <-time.After(interval):
+		case <-r.quit:
+			return nil, ErrSessionClosed
+		}
+	}
+}
+
+func (r *RPD) emit(e Event) {
+	if r.OnEvent != nil {
+		r.OnEvent(e)
+	}
+}
+
+// Session returns the current session with the core.
+func (r *RPD) Session() *Session {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sess
+}
+
+// Notify sends a GeneralNotification of type nt to the core.
+func (r *RPD) Notify(nt gcp.NotificationType) error {
+	r.mu.Lock()
+	s := r.sess
+	r.mu.Unlock()
+	if s == nil {
+		return ErrSessionClosed
+	}
+	return r.notify(s, nt)
+}
+
+// timedOut raises a TimeOutNotification on a session whose core has
+// stopped answering. Delivery is best effort.
+func (r *RPD) timedOut(s *Session) {
+	s.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	r.notify(s, gcp.TimeOutNotification)
+}
+
+func (r *RPD) notify(s *Session, nt gcp.NotificationType) error {
+	r.mu.Lock()
+	r.seq++
+	r.tid++
+	seq, tid := r.seq, r.tid
+	r.mu.Unlock()
+	req := &gcp.NotifyReq{
+		TransactionID: tid,
+		EvntData:      gcp.GeneralNotification(seq, nt),
+	}
+	return s.Send(gcp.NewMessage(gcp.MessageIDNotifyReq, req))
+}
+
+// Close ends the association with the core.
+func (r *RPD) Close() error {
+	r.mu.Lock()
+	if !r.closed && r.quit != nil {
+		close(r.quit)
+	}
+	r.closed = true
+	s := r.sess
+	r.mu.Unlock()
+	if s != nil {
+		return s.Close()
+	}
+	return nil
+}
+
+// serve handles the messages received from the core, taking care of
+// handover requests before passing the rest to the RPD's Handler.
+func (r *RPD) serve(s *Session, q *Request) {
+	if req, ok := q.Msg.Body.(*gcp.EDSReq); ok {
+		if seq, addrs, err := gcp.ParseRedirect(req.DataStr); err == nil {
+			r.handover(s, q, req, seq, addrs[0])
+			return
+		}
+	}
+	if r.Handler != nil {
+		r.Handler.ServeGCP(s, q)
+	}
+}
+
+// handover acknowledges a handover request and ends the session, so
+// Run moves to the new core.
+func (r *RPD) handover(s *Session, q *Request, req *gcp.EDSReq, seq uint16, addr net.IP) {
+	res := &gcp.EDSRes{
+		TransactionID: req.TransactionID,
+		Mode:          req.Mode,
+		Port:          req.Port,
+		Channel:       req.Channel,
+		VendorID:      req.VendorID,
+		VendorIdx:     req.VendorIdx,
+		DataStr: gcp.EncodeSequence(gcp.TypeIRA, seq, gcp.OpWriteResponse,
+			gcp.EncodeTLV(19, []byte{byte(gcp.RespNoError)})),
+	}
+	port := r.Port
+	if port == "" {
+		port = "8190"
+	}
+	r.mu.Lock()
+	r.next = net.JoinHostPort(addr.String(), port)
+	r.mu.Unlock()
+	s.Reply(q, gcp.NewMessage(gcp.MessageIDEDSRes, res))
+	s.Close()
+}
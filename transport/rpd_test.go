@@ -0,0 +1,121 @@
+package transport_test
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/transport"
+)
+
+// startCore starts a Core on addr reporting the notifications it
+// receives on the returned channel.
+func startCore(t *testing.T, addr string) (*transport.Core, string, <-chan string) {
+	t.Helper()
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("could not listen on %s: %v", addr, err)
+	}
+	ntfs := make(chan string, 10)
+	c := &transport.Core{
+		Handler: transport.HandlerFunc(func(s *transport.Session, r *transport.Request) {
+			if _, ok := r.Msg.Body.(*gcp.NotifyReq); !ok {
+				return
+			}
+			_, g := r.Msg.Body.Process()
+			if g != nil && g.NTF != nil && g.NTF.Sequence.GeneralNtf != nil {
+				ntfs <- g.NTF.Sequence.GeneralNtf.NotificationType
+			}
+		}),
+	}
+	go c.Serve(l)
+	return c, l.Addr().String(), ntfs
+}
+
+func expectNotification(t *testing.T, ntfs <-chan string, want string) {
+	t.Helper()
+	select {
+	case got := <-ntfs:
+		if got != want {
+			t.Fatalf("Notification Type got: %v, want: %s", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for %s", want)
+	}
+}
+
+func TestRPDReconnect(t *testing.T) {
+	core, addr, ntfs := startCore(t, "127.0.0.1:0")
+	events := make(chan transport.Event, 10)
+	rpd := &transport.RPD{
+		Core:              addr,
+		ReconnectInterval: 50 * time.Millisecond,
+		ReconnectTimeout:  5 * time.Second,
+		OnEvent:           func(e transport.Event) { events <- e },
+	}
+	go rpd.Run()
+	defer rpd.Close()
+
+	expectNotification(t, ntfs, "StartUpNotification")
+
+	// Kill the core mid-session and bring it back on the same address.
+	core.Close()
+	core, _, ntfs = startCore(t, addr)
+	defer core.Close()
+
+	expectNotification(t, ntfs, "ReconnectNotification")
+	for _, want := range []transport.EventType{transport.EventConnected, transport.EventDisconnected, transport.EventReconnected} {
+		if e := <-events; e.Type != want {
+			t.Fatalf("Event got: %v, want: %v", e.Type, want)
+		}
+	}
+}
+
+func TestRPDHandover(t *testing.T) {
+	core1, addr1, ntfs1 := startCore(t, "127.0.0.1:0")
+	defer core1.Close()
+	core2, addr2, ntfs2 := startCore(t, "127.0.0.1:0")
+	defer core2.Close()
+
+	_, port, _ := net.SplitHostPort(addr2)
+	var writes int32
+	events := make(chan transport.Event, 10)
+	rpd := &transport.RPD{
+		Core: addr1,
+		Port: port,
+		Handler: transport.HandlerFunc(func(s *transport.Session, r *transport.Request) {
+			atomic.AddInt32(&writes, 1)
+		}),
+		OnEvent: func(e transport.Event) { events <- e },
+	}
+	go rpd.Run()
+	defer rpd.Close()
+
+	expectNotification(t, ntfs1, "StartUpNotification")
+	sessions := core1.Sessions()
+	if len(sessions) != 1 {
+		t.Fatalf("Sessions got: %d, want: 1", len(sessions))
+	}
+	// Configuration state held by the RPD's Handler.
+	err := sessions[0].Send(gcp.NewMessage(gcp.MessageIDEDSReq, &gcp.EDSReq{
+		DataStr: gcp.EncodeSequence(gcp.TypeREX, 1, gcp.OpWrite),
+	}))
+	if err != nil {
+		t.Fatalf("could not send a write: %v", err)
+	}
+	if err := core1.Handover(sessions[0], net.ParseIP("127.0.0.1")); err != nil {
+		t.Fatalf("could not hand the RPD over: %v", err)
+	}
+
+	expectNotification(t, ntfs2, "HandoverNotification")
+	for _, want := range []transport.EventType{transport.EventConnected, transport.EventHandover} {
+		if e := <-events; e.Type != want {
+			t.Fatalf("Event got: %v, want: %v", e.Type, want)
+		}
+	}
+	if n := atomic.LoadInt32(&writes); n != 1 {
+		t.Fatalf("configuration writes got: %d, want: 1", n)
+	}
+}
@@ -0,0 +1,495 @@
+package transport
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+)
+
+// Error messages
+var (
+	ErrSessionClosed  = errors.New("session closed")
+	ErrRequestTimeout = errors.New("request timed out")
+	ErrPeerTimeout    = errors.New("peer timed out")
+)
+
+// An EventType identifies a change in the state of a GCP association.
+type EventType int
+
+// Event types.
+const (
+	EventConnected       EventType = iota + 1 // A session was established
+	EventDisconnected                         // A session was lost
+	EventReconnected                          // The RPD reconnected to its core
+	EventHandover                             // The RPD moved to a new core
+	EventReconnectFailed                      // The RPD gave up reconnecting
+	EventNoResponse                           // A request that required a response got none
+)
+
+var eventNames = map[EventType]string{
+	EventConnected:       "Connected",
+	EventDisconnected:    "Disconnected",
+	EventReconnected:     "Reconnected",
+	EventHandover:        "Handover",
+	EventReconnectFailed: "ReconnectFailed",
+	EventNoResponse:      "NoResponse",
+}
+
+func (e EventType) String() string {
+	if s, ok := eventNames[e]; ok {
+		return s
+	}
+	return fmt.Sprintf("EventType(%d)", int(e))
+}
+
+// An Event reports a change in the state of a GCP association to the
+// application.
+type Event struct {
+	Type EventType
+	Addr string       // Address of the peer
+	Err  error        // Cause of a disconnection or failure, if any
+	Msg  *gcp.Message // Request left unanswered, for EventNoResponse
+}
+
+// A Request is a GCP message received over a Session, along with the
+// fields of its TCP encapsulation needed to answer it.
+type Request struct {
+	TranID uint16 // Transaction Identifier of the encapsulation
+	UnitID uint8  // Unit Identifier of the encapsulation
+	Msg    *gcp.Message
+}
+
+// A Handler responds to GCP messages received over a Session.
+//
+// ServeGCP is called from the goroutine reading the Session, one
+// message at a time. It should not block waiting on a Request issued
+// over the same Session.
+type Handler interface {
+	ServeGCP(s *Session, r *Request)
+}
+
+// The HandlerFunc type is an adapter to allow the use of ordinary
+// functions as GCP handlers.
+type HandlerFunc func(s *Session, r *Request)
+
+// ServeGCP calls f(s, r).
+func (f HandlerFunc) ServeGCP(s *Session, r *Request) { f(s, r) }
+
+// StrictHandler returns a Handler that answers the requests that violate
+// the GCP specification with the Error Response Validate calls for, and
+// passes the other messages to h. Requests addressed to a unit other than
+// the device itself, Unit Identifier 0, are answered with IllegalAddr.
+func StrictHandler(h Handler) Handler {
+	return HandlerFunc(func(s *Session, r *Request) {
+		if !isResponse(r.Msg.MessageID) {
+			if r.UnitID != 0 {
+				s.Reply(r, gcp.ErrorResponse(r.Msg, gcp.IllegalAddr))
+				return
+			}
+			for _, v := range r.Msg.Validate() {
+				if v.Code != gcp.MsgSuccess {
+					s.Reply(r, gcp.ErrorResponse(r.Msg, v.Code))
+					return
+				}
+			}
+		}
+		h.ServeGCP(s, r)
+	})
+}
+
+// A Session is a GCP association over an established TCP connection.
+// Messages received are delivered to its Handler, except for responses
+// to requests sent with Request, which are returned to the caller.
+//
+// A Session honors the Mode of the requests it receives: the Normal
+// Responses to requests that do not require one are not sent.
+type Session struct {
+	conn      net.Conn
+	handler   Handler
+	liveness  Liveness
+	recorder  Recorder
+	onTimeout func(*Session)
+	onEvent   func(Event)
+	identity  *identityCheck // Holds messages until the RPD identity is verified, if set
+
+	// wmu serializes writes to conn.
+	wmu sync.Mutex
+
+	mu      sync.Mutex
+	tid     uint16
+	pending map[uint16]chan *Request
+	waiting map[waitKey]*time.Timer
+	lastRx  time.Time
+	closed  bool
+	err     error
+	done    chan struct{}
+}
+
+// A Dialer contains options for connecting to a GCP endpoint.
+type Dialer struct {
+	Timeout   time.Duration // Maximum time to establish the connection
+	Liveness  Liveness      // Dead peer detection for the Session
+	TLSConfig *tls.Config   // Secures the connection with TLS when set
+	Recorder  Recorder      // Optional, records the units sent and received
+	OnEvent   func(Event)   // Optional, called when a request sent goes unanswered
+}
+
+// Dial connects to the GCP endpoint at addr and starts a Session whose
+// incoming messages are served by h.
+func Dial(addr string, h Handler) (*Session, error) {
+	var d Dialer
+	return d.Dial(addr, h)
+}
+
+// Dial connects to the GCP endpoint at addr and starts a Session whose
+// incoming messages are served by h.
+func (d *Dialer) Dial(addr string, h Handler) (*Session, error) {
+	return d.dial(addr, h, nil)
+}
+
+// dial is Dial with a hook called when the peer is declared dead.
+func (d *Dialer) dial(addr string, h Handler, onTimeout func(*Session)) (*Session, error) {
+	nd := net.Dialer{Timeout: d.Timeout}
+	c, err := nd.Dial("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup a connection: %v", err)
+	}
+	d.Liveness.tune(c)
+	if d.TLSConfig != nil {
+		cfg := d.TLSConfig
+		if cfg.ServerName == "" {
+			cfg = cfg.Clone()
+			cfg.ServerName, _, _ = net.SplitHostPort(addr)
+		}
+		tc := tls.Client(c, cfg)
+		if err := handshake(tc, d.Timeout); err != nil {
+			return nil, err
+		}
+		c = tc
+	}
+	s := newSession(c, h, d.Liveness)
+	s.recorder = d.Recorder
+	s.onTimeout = onTimeout
+	s.onEvent = d.OnEvent
+	s.start()
+	return s, nil
+}
+
+// newSession returns a Session over c. The Session is not served until
+// start is called.
+func newSession(c net.Conn, h Handler, lv Liveness) *Session {
+	return &Session{
+		conn:     c,
+		handler:  h,
+		liveness: lv,
+		lastRx:   time.Now(),
+		pending:  make(map[uint16]chan *Request),
+		waiting:  make(map[waitKey]*time.Timer),
+		done:     make(chan struct{}),
+	}
+}
+
+// handshake runs the TLS handshake on c, closing c if it fails.
+func handshake(c *tls.Conn, timeout time.Duration) error {
+	if timeout > 0 {
+		c.SetDeadline(time.Now().Add(timeout))
+	}
+	if err := c.Handshake(); err != nil {
+		c.Close()
+		return fmt.Errorf("TLS handshake failed: %v", err)
+	}
+	c.SetDeadline(time.Time{})
+	return nil
+}
+
+// start serves the Session and, when configured, probes the peer.
+func (s *Session) start() {
+	go s.serve()
+	if s.liveness.Interval > 0 {
+		go s.keepalive()
+	}
+}
+
+// LocalAddr returns the local network address of the Session.
+func (s *Session) LocalAddr() net.Addr { return s.conn.LocalAddr() }
+
+// RemoteAddr returns the network address of the peer.
+func (s *Session) RemoteAddr() net.Addr { return s.conn.RemoteAddr() }
+
+// Done returns a channel that is closed when the Session ends.
+func (s *Session) Done() <-chan struct{} { return s.done }
+
+// Err returns the reason the Session ended, or nil while it is active.
+func (s *Session) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close ends the Session.
+func (s *Session) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	return s.conn.Close()
+}
+
+// Send transmits m without waiting for a response. The response, if
+// any, is delivered to the Handler. When the Liveness of the Session
+// sets a ResponseTimeout and m is a request whose Mode requires a
+// response, an EventNoResponse is raised if none arrives in time.
+func (s *Session) Send(m *gcp.Message) error {
+	k, ok := s.await(m)
+	if err := s.write(0, 0, m); err != nil {
+		if ok {
+			s.answered(k)
+		}
+		return err
+	}
+	return nil
+}
+
+// Reply transmits m as the response to r. A Normal Response is dropped
+// when the Mode of r says it is not required; Error Responses are
+// always sent.
+func (s *Session) Reply(r *Request, m *gcp.Message) error {
+	if isResponse(m.MessageID) && m.MessageID < 128 {
+		if mode, ok := r.Msg.Mode(); ok && !mode.ResponseRequired() {
+			return nil
+		}
+	}
+	return s.write(r.TranID, r.UnitID, m)
+}
+
+// Request transmits m and waits up to timeout for the peer's response.
+// When the Mode of m does not require a Normal Response, only an Error
+// Response is expected, and Request returns a nil Request and error if
+// none arrives within timeout.
+func (s *Session) Request(m *gcp.Message, timeout time.Duration) (*Request, error) {
+	s.mu.Lock()
+	if s.err != nil {
+		s.mu.Unlock()
+		return nil, ErrSessionClosed
+	}
+	// A Transaction Identifier of 0 means to ignore this field.
+	s.tid++
+	if s.tid == 0 {
+		s.tid++
+	}
+	tid := s.tid
+	ch := make(chan *Request, 1)
+	s.pending[tid] = ch
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, tid)
+		s.mu.Unlock()
+	}()
+
+	if err := s.write(tid, 0, m); err != nil {
+		return nil, err
+	}
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case r := <-ch:
+		return r, nil
+	case <-s.done:
+		return nil, ErrSessionClosed
+	case <-t.C:
+		if mode, ok := m.Mode(); ok && !mode.ResponseRequired() {
+			return nil, nil
+		}
+		return nil, ErrRequestTimeout
+	}
+}
+
+// A waitKey identifies a request sent with Send by its Message ID and
+// the Transaction ID of its body, which its responses echo.
+type waitKey struct {
+	id  uint8
+	tid uint16
+}
+
+// await starts waiting for the response to m, if m requires one and
+// the Session checks for them.
+func (s *Session) await(m *gcp.Message) (waitKey, bool) {
+	d := s.liveness.ResponseTimeout
+	if d <= 0 || isResponse(m.MessageID) {
+		return waitKey{}, false
+	}
+	if mode, ok := m.Mode(); !ok || !mode.ResponseRequired() {
+		return waitKey{}, false
+	}
+	k := waitKey{id: m.MessageID, tid: m.TransactionID()}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.waiting[k]; ok {
+		t.Stop()
+	}
+	var t *time.Timer
+	t = time.AfterFunc(d, func() {
+		s.mu.Lock()
+		ok := s.waiting[k] == t && s.err == nil
+		if ok {
+			delete(s.waiting, k)
+		}
+		s.mu.Unlock()
+		if ok && s.onEvent != nil {
+			s.onEvent(Event{Type: EventNoResponse, Addr: s.RemoteAddr().String(), Msg: m})
+		}
+	})
+	s.waiting[k] = t
+	return k, true
+}
+
+// answered stops waiting for the response to the request k.
+func (s *Session) answered(k waitKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.waiting[k]; ok {
+		t.Stop()
+		delete(s.waiting, k)
+	}
+}
+
+// responds stops waiting for the request sent with Send that r answers,
+// if any.
+func (s *Session) responds(r *Request) {
+	id := r.Msg.MessageID
+	if !isResponse(id) {
+		return
+	}
+	if id >= 128 {
+		id -= 128
+	}
+	s.answered(waitKey{id: id - 1, tid: r.Msg.TransactionID()})
+}
+
+func (s *Session) write(tid uint16, unit uint8, m *gcp.Message) error {
+	b, err := m.Marshal()
+	if err != nil {
+		return fmt.Errorf("failed to marshall a message: %v", err)
+	}
+	return s.writeUnit(tid, unit, b)
+}
+
+// writeUnit transmits b, one or more encoded GCP messages, in a single
+// encapsulation unit.
+func (s *Session) writeUnit(tid uint16, unit uint8, b []byte) error {
+	t, err := encapsulate(tid, unit, b).Marshal()
+	if err != nil {
+		return fmt.Errorf("failed to marshall a message: %v", err)
+	}
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	if d := s.liveness.WriteTimeout; d > 0 {
+		s.conn.SetWriteDeadline(time.Now().Add(d))
+	}
+	if _, err := s.conn.Write(t); err != nil {
+		return fmt.Errorf("failed to send a message: %v", err)
+	}
+	record(s.recorder, Sent, s.conn, t)
+	return nil
+}
+
+// serve reads messages from the connection until it fails.
+func (s *Session) serve() {
+	var err error
+	timeout := s.liveness.timeout()
+	for {
+		if timeout > 0 {
+			s.conn.SetReadDeadline(time.Now().Add(timeout))
+		}
+		var pkt TCPmessage
+		pkt, err = ReadMessage(s.conn)
+		if err != nil {
+			break
+		}
+		s.mu.Lock()
+		s.lastRx = time.Now()
+		s.mu.Unlock()
+		if s.recorder != nil {
+			b, _ := pkt.Marshal()
+			record(s.recorder, Received, s.conn, b)
+		}
+		ms, perr := messages(pkt.Msg)
+		if perr != nil {
+			log.Printf("could not parse GCP message: %s\n", perr.Error())
+		}
+		for _, m := range ms {
+			s.dispatch(&Request{TranID: pkt.TranID, UnitID: pkt.UnitID, Msg: m})
+		}
+	}
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		err = ErrPeerTimeout
+		if s.onTimeout != nil {
+			s.onTimeout(s)
+		}
+	}
+	s.conn.Close()
+	s.mu.Lock()
+	if s.closed {
+		err = ErrSessionClosed
+	}
+	s.err = err
+	for k, t := range s.waiting {
+		t.Stop()
+		delete(s.waiting, k)
+	}
+	s.mu.Unlock()
+	close(s.done)
+}
+
+// dispatch handles r, or holds it until the RPD identity is verified.
+func (s *Session) dispatch(r *Request) {
+	if s.identity != nil {
+		for _, r := range s.identity.admit(s, r) {
+			s.handle(r)
+		}
+		return
+	}
+	s.handle(r)
+}
+
+// handle hands r to a pending Request, the keepalive prober or the
+// Handler.
+func (s *Session) handle(r *Request) {
+	s.responds(r)
+	if s.deliver(r) || s.answerProbe(r) {
+		return
+	}
+	if s.handler != nil {
+		s.handler.ServeGCP(s, r)
+	}
+}
+
+// deliver hands r to a pending Request, if r answers one. A Request
+// takes the first response only: the messages of a batched unit share
+// its Transaction ID, and later ones go to the Handler.
+func (s *Session) deliver(r *Request) bool {
+	if r.TranID == 0 || !isResponse(r.Msg.MessageID) {
+		return false
+	}
+	s.mu.Lock()
+	ch, ok := s.pending[r.TranID]
+	delete(s.pending, r.TranID)
+	s.mu.Unlock()
+	if ok {
+		select {
+		case ch <- r:
+		default:
+		}
+	}
+	return ok
+}
+
+// isResponse reports whether id identifies a normal or an error response.
+func isResponse(id uint8) bool { return id&1 == 1 }
@@ -0,0 +1,341 @@
+package transport_test
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/transport"
+)
+
+func TestStrictHandler(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	served := make(chan struct{}, 10)
+	core := &transport.Core{
+		Handler: transport.StrictHandler(transport.HandlerFunc(func(s *transport.Session, r *transport.Request) {
+			served <- struct{}{}
+			s.Reply(r, gcp.NewMessage(gcp.MessageIDNotifyRes, &gcp.NotifyRes{TransactionID: 7}))
+		})),
+	}
+	go core.Serve(l)
+	defer core.Close()
+
+	s, err := transport.Dial(l.Addr().String(), nil)
+	if err != nil {
+		t.Fatalf("could not connect: %v", err)
+	}
+	defer s.Close()
+
+	tt := []struct {
+		name string
+		mode gcp.Mode
+		want gcp.MessageID
+	}{
+		{name: "Valid", mode: 0, want: gcp.MessageIDNotifyRes},
+		{name: "Reserved Mode bits", mode: 0x01, want: gcp.MessageIDNotifyErr},
+	}
+	for _, tc := range tt {
+		req := gcp.NewMessage(gcp.MessageIDNotifyReq, &gcp.NotifyReq{
+			TransactionID: 7,
+			Mode:          tc.mode,
+			EvntData:      gcp.GeneralNotification(1, gcp.StartUpNotification),
+		})
+		r, err := s.Request(req, time.Second)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got := gcp.MessageID(r.Msg.MessageID); got != tc.want {
+			t.Fatalf("%s: response got: %v, want: %v", tc.name, got, tc.want)
+		}
+		if tc.want != gcp.MessageIDNotifyErr {
+			continue
+		}
+		b, _ := r.Msg.Body.Marshal()
+		if got := gcp.RtrnCode(b[2]); got != gcp.IllegalMode {
+			t.Errorf("%s: Return Code got: %v, want: %v", tc.name, got, gcp.IllegalMode)
+		}
+	}
+
+	// A request to another unit, sent over a connection of its own.
+	c, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("could not connect: %v", err)
+	}
+	defer c.Close()
+	req, _ := gcp.NewMessage(gcp.MessageIDNotifyReq, &gcp.NotifyReq{
+		TransactionID: 7,
+		EvntData:      gcp.GeneralNotification(1, gcp.StartUpNotification),
+	}).Marshal()
+	u, _ := transport.TCPmessage{TranID: 9, ProtID: 1, Len: uint16(1 + len(req)), UnitID: 3, Msg: req}.Marshal()
+	if _, err := c.Write(u); err != nil {
+		t.Fatalf("could not send: %v", err)
+	}
+	c.SetReadDeadline(time.Now().Add(time.Second))
+	res, err := transport.ReadMessage(c)
+	if err != nil {
+		t.Fatalf("Unit ID: %v", err)
+	}
+	if res.UnitID != 3 || gcp.MessageID(res.Msg[0]) != gcp.MessageIDNotifyErr || gcp.RtrnCode(res.Msg[5]) != gcp.IllegalAddr {
+		t.Errorf("Unit ID: response got: %+v, want: an IllegalAddr Notify Error to unit 3", res)
+	}
+
+	if got := len(served); got != 1 {
+		t.Errorf("requests served got: %d, want: 1", got)
+	}
+}
+
+func TestModeNoResponse(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	served := make(chan struct{}, 10)
+	core := &transport.Core{
+		Handler: transport.HandlerFunc(func(s *transport.Session, r *transport.Request) {
+			served <- struct{}{}
+			s.Reply(r, gcp.NewMessage(gcp.MessageIDNotifyRes, &gcp.NotifyRes{TransactionID: 7}))
+		}),
+	}
+	go core.Serve(l)
+	defer core.Close()
+
+	s, err := transport.Dial(l.Addr().String(), nil)
+	if err != nil {
+		t.Fatalf("could not connect: %v", err)
+	}
+	defer s.Close()
+
+	tt := []struct {
+		name string
+		mode gcp.Mode
+		want bool
+	}{
+		{name: "Response required", mode: 0, want: true},
+		{name: "No response required", mode: gcp.ModeNoResponse, want: false},
+	}
+	for _, tc := range tt {
+		req := gcp.NewMessage(gcp.MessageIDNotifyReq, &gcp.NotifyReq{
+			TransactionID: 7,
+			Mode:          tc.mode,
+			EvntData:      gcp.GeneralNotification(1, gcp.StartUpNotification),
+		})
+		r, err := s.Request(req, 200*time.Millisecond)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got := r != nil; got != tc.want {
+			t.Errorf("%s: response got: %v, want: %v", tc.name, got, tc.want)
+		}
+	}
+	if got := len(served); got != 2 {
+		t.Errorf("requests served got: %d, want: 2", got)
+	}
+}
+
+func TestResponseTimeout(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	events := make(chan transport.Event, 10)
+	core := &transport.Core{
+		Liveness: transport.Liveness{ResponseTimeout: 100 * time.Millisecond},
+		OnEvent:  func(e transport.Event) { events <- e },
+	}
+	go core.Serve(l)
+	defer core.Close()
+
+	// The RPD only answers the notification with Transaction ID 1.
+	s, err := transport.Dial(l.Addr().String(), transport.HandlerFunc(func(s *transport.Session, r *transport.Request) {
+		if r.Msg.TransactionID() == 1 {
+			s.Reply(r, gcp.NewMessage(gcp.MessageIDNotifyRes, &gcp.NotifyRes{TransactionID: 1}))
+		}
+	}))
+	if err != nil {
+		t.Fatalf("could not connect: %v", err)
+	}
+	defer s.Close()
+	if e := <-events; e.Type != transport.EventConnected {
+		t.Fatalf("event got: %v, want: %v", e.Type, transport.EventConnected)
+	}
+
+	rpd := core.Sessions()[0]
+	for _, n := range []struct {
+		tid  uint16
+		mode gcp.Mode
+	}{{1, 0}, {2, 0}, {3, gcp.ModeNoResponse}} {
+		m := gcp.NewMessage(gcp.MessageIDNotifyReq, &gcp.NotifyReq{
+			TransactionID: n.tid,
+			Mode:          n.mode,
+			EvntData:      gcp.GeneralNotification(n.tid, gcp.StartUpNotification),
+		})
+		if err := rpd.Send(m); err != nil {
+			t.Fatalf("could not send: %v", err)
+		}
+	}
+
+	select {
+	case e := <-events:
+		if e.Type != transport.EventNoResponse {
+			t.Fatalf("event got: %v, want: %v", e.Type, transport.EventNoResponse)
+		}
+		if got := e.Msg.TransactionID(); got != 2 {
+			t.Errorf("unanswered Transaction ID got: %d, want: 2", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no EventNoResponse")
+	}
+	select {
+	case e := <-events:
+		t.Errorf("unexpected event: %v", e.Type)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestBatchedResponses(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	defer l.Close()
+	// The peer answers each request with two responses in one unit.
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		for {
+			u, err := transport.ReadMessage(c)
+			if err != nil {
+				return
+			}
+			var b []byte
+			for i := 0; i < 2; i++ {
+				m, _ := gcp.NewMessage(gcp.MessageIDNotifyRes, &gcp.NotifyRes{TransactionID: 7}).Marshal()
+				b = append(b, m...)
+			}
+			res, _ := transport.TCPmessage{TranID: u.TranID, ProtID: 1, Len: uint16(1 + len(b)), Msg: b}.Marshal()
+			c.Write(res)
+		}
+	}()
+
+	extra := make(chan struct{}, 10)
+	s, err := transport.Dial(l.Addr().String(), transport.HandlerFunc(func(*transport.Session, *transport.Request) {
+		extra <- struct{}{}
+	}))
+	if err != nil {
+		t.Fatalf("could not connect: %v", err)
+	}
+	defer s.Close()
+	req := gcp.NewMessage(gcp.MessageIDNotifyReq, &gcp.NotifyReq{
+		TransactionID: 7,
+		EvntData:      gcp.GeneralNotification(1, gcp.StartUpNotification),
+	})
+	for i := 0; i < 3; i++ {
+		if _, err := s.Request(req, time.Second); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		select {
+		case <-extra:
+		case <-time.After(time.Second):
+			t.Fatalf("second responses handled got: %d, want: 3", i)
+		}
+	}
+}
+
+func TestTruncatedUnit(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	served := make(chan uint16, 10)
+	core := &transport.Core{
+		Handler: transport.StrictHandler(transport.HandlerFunc(func(s *transport.Session, r *transport.Request) {
+			served <- r.Msg.TransactionID()
+			s.Reply(r, gcp.NewMessage(gcp.MessageIDNotifyRes, &gcp.NotifyRes{TransactionID: r.Msg.TransactionID()}))
+		})),
+	}
+	go core.Serve(l)
+	defer core.Close()
+
+	c, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("could not connect: %v", err)
+	}
+	defer c.Close()
+	// A valid request followed by the first bytes of another.
+	req, _ := gcp.NewMessage(gcp.MessageIDNotifyReq, &gcp.NotifyReq{
+		TransactionID: 7,
+		EvntData:      gcp.GeneralNotification(1, gcp.StartUpNotification),
+	}).Marshal()
+	b := append(append([]byte(nil), req...), req[:5]...)
+	u, _ := transport.TCPmessage{TranID: 9, ProtID: 1, Len: uint16(1 + len(b)), Msg: b}.Marshal()
+	if _, err := c.Write(u); err != nil {
+		t.Fatalf("could not send: %v", err)
+	}
+	c.SetReadDeadline(time.Now().Add(time.Second))
+	res, err := transport.ReadMessage(c)
+	if err != nil {
+		t.Fatalf("no response: %v", err)
+	}
+	if got := gcp.MessageID(res.Msg[0]); got != gcp.MessageIDNotifyRes {
+		t.Errorf("response got: %v, want: %v", got, gcp.MessageIDNotifyRes)
+	}
+	select {
+	case tid := <-served:
+		if tid != 7 {
+			t.Errorf("Transaction ID served got: %d, want: 7", tid)
+		}
+	default:
+		t.Errorf("the valid request was not served")
+	}
+}
+
+func TestShortMessageLength(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	core := &transport.Core{
+		Handler: transport.StrictHandler(transport.HandlerFunc(func(s *transport.Session, r *transport.Request) {
+			s.Reply(r, gcp.NewMessage(gcp.MessageIDNotifyRes, &gcp.NotifyRes{TransactionID: r.Msg.TransactionID()}))
+		})),
+	}
+	go core.Serve(l)
+	defer core.Close()
+
+	c, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("could not connect: %v", err)
+	}
+	defer c.Close()
+	// The Message Length leaves out the last bytes of the Event Data.
+	req, _ := gcp.NewMessage(gcp.MessageIDNotifyReq, &gcp.NotifyReq{
+		TransactionID: 7,
+		EvntData:      gcp.GeneralNotification(1, gcp.StartUpNotification),
+	}).Marshal()
+	binary.BigEndian.PutUint16(req[1:3], uint16(len(req)-3-3))
+	u, _ := transport.TCPmessage{TranID: 9, ProtID: 1, Len: uint16(1 + len(req)), Msg: req}.Marshal()
+	if _, err := c.Write(u); err != nil {
+		t.Fatalf("could not send: %v", err)
+	}
+	c.SetReadDeadline(time.Now().Add(time.Second))
+	res, err := transport.ReadMessage(c)
+	if err != nil {
+		t.Fatalf("no response: %v", err)
+	}
+	if got := gcp.MessageID(res.Msg[0]); got != gcp.MessageIDNotifyErr {
+		t.Fatalf("response got: %v, want: %v", got, gcp.MessageIDNotifyErr)
+	}
+	if got := gcp.RtrnCode(res.Msg[5]); got != gcp.IllegalMsgLen {
+		t.Errorf("Return Code got: %v, want: %v", got, gcp.IllegalMsgLen)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"io"
 	"log"
 	"net"
+	"time"
 
 	gcp "github.com/nleiva/gcp-rphy"
 )
@@ -63,10 +64,91 @@ func UnMarshal(b []byte) (TCPmessage, error) {
 	return p, nil
 }
 
+// ReadMessage reads a single GCP TCP encapsulation unit from r, using its
+// Length field to find where the unit ends.
+func ReadMessage(r io.Reader) (TCPmessage, error) {
+	h := make([]byte, 6)
+	if _, err := io.ReadFull(r, h); err != nil {
+		return TCPmessage{}, err
+	}
+	l := int(binary.BigEndian.Uint16(h[4:6]))
+	if l < 1 {
+		return TCPmessage{}, gcp.ErrMessageTooShort
+	}
+	b := make([]byte, 6+l)
+	copy(b, h)
+	if _, err := io.ReadFull(r, b[6:]); err != nil {
+		return TCPmessage{}, err
+	}
+	return UnMarshal(b)
+}
+
+// messages parses the GCP messages in the Message Field b of a unit.
+// Bytes left that do not start a request are taken as the end of the
+// last message, which is parsed again up to the end of b, so that a
+// Handler validating it can answer with the Error Response its Message
+// Length calls for. Along with an error, it returns the messages parsed
+// before it.
+func messages(b []byte) ([]*gcp.Message, error) {
+	ms, err := gcp.ParseMessages(b)
+	if err == nil {
+		return ms, nil
+	}
+	var off, last int
+	for _, m := range ms {
+		last, off = off, off+3+int(m.Lenght)
+	}
+	if len(ms) > 0 && startsRequest(b[off:]) {
+		return ms, err
+	}
+	if len(ms) > 0 {
+		ms, off = ms[:len(ms)-1], last
+	}
+	if len(b[off:]) < 3 {
+		return ms, err
+	}
+	// ParseMessage stops at the Message Length: parse the rest of the
+	// field as the body, and keep the Message Length it was sent with.
+	whole := append([]byte(nil), b[off:]...)
+	binary.BigEndian.PutUint16(whole[1:3], uint16(len(whole)-3))
+	m, perr := gcp.ParseMessage(whole)
+	if perr != nil {
+		return ms, perr
+	}
+	m.Lenght = binary.BigEndian.Uint16(b[off+1 : off+3])
+	return append(ms, m), nil
+}
+
+// startsRequest reports whether b starts with the Message ID of a GCP
+// request.
+func startsRequest(b []byte) bool {
+	if len(b) == 0 {
+		return false
+	}
+	switch gcp.MessageID(b[0]) {
+	case gcp.MessageIDNotifyReq, gcp.MessageIDGDMReq, gcp.MessageIDEDSReq, gcp.MessageIDEDRReq, gcp.MessageIDMWRReq:
+		return true
+	}
+	return false
+}
+
+// encapsulate returns the TCP encapsulation of GCP message b.
+func encapsulate(tid uint16, unit uint8, b []byte) TCPmessage {
+	return TCPmessage{
+		TranID: tid,
+		ProtID: 1,
+		Len:    uint16(1 + len(b)),
+		UnitID: unit,
+		Msg:    b,
+	}
+}
+
 // TCPEnd is a TCP endpoint that satisfies the Transport interface.
 type TCPEnd struct {
-	Host string
-	Port string
+	Host     string
+	Port     string
+	Timeout  time.Duration // Idle time before dropping a received connection, zero means no limit
+	Recorder Recorder      // Optional, records the units sent and received
 }
 
 func (e TCPEnd) listen() (net.Listener, error) {
@@ -91,7 +173,7 @@ func (e TCPEnd) Receive() error {
 			log.Printf("failed to accept the connection: %v", err)
 			continue
 		}
-		go handleMessage(c)
+		go handleMessage(c, e.Timeout, e.Recorder)
 	}
 }
 
@@ -124,6 +206,7 @@ func (e TCPEnd) Send(b []byte) error {
 	if err != nil {
 		return fmt.Errorf("failed to send a message: %v", err)
 	}
+	record(e.Recorder, Sent, conn, t)
 	return nil
 }
 
@@ -139,14 +222,21 @@ func (e TCPEnd) CraftPkt(b []byte) (TCPmessage, error) {
 	return m, nil
 }
 
-func handleMessage(c net.Conn) {
+func handleMessage(c net.Conn, timeout time.Duration, rec Recorder) {
 	fmt.Printf("Serving %s\n", c.RemoteAddr().String())
 	// TODO, do NOT hardcode the MTU.
 	MTU := 1500
 	defer c.Close()
 	for {
 		buf := make([]byte, MTU)
+		if timeout > 0 {
+			c.SetReadDeadline(time.Now().Add(timeout))
+		}
 		n, err := c.Read(buf)
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			log.Printf("peer timed out: %s\n", c.RemoteAddr().String())
+			return
+		}
 		switch {
 		case err == io.EOF:
 			log.Printf("end of the transmission: %s\n", err.Error())
@@ -155,19 +245,21 @@ func handleMessage(c net.Conn) {
 			log.Printf("failed reading response: %s\n", err.Error())
 			continue
 		default:
+			record(rec, Received, c, buf[:n])
 			pkt, err := UnMarshal(buf[:n])
 			if err != nil {
 				log.Printf("failed unmarshaling TCP message: %s\n", err.Error())
 				continue
 			}
-			m, err := gcp.ParseMessage(pkt.Msg)
+			ms, err := messages(pkt.Msg)
 			if err != nil {
 				log.Printf("could not parse GCP message: %s\n", err.Error())
-				continue
 			}
-			output, _ := m.Body.Process()
-			fmt.Printf("Incoming Message (Length: %d) ->\n  Message Identifier: %v\n  Length: %v\n  Body: %s\n",
-				n, m.MessageID, m.Lenght, output)
+			for _, m := range ms {
+				output, _ := m.Body.Process()
+				fmt.Printf("Incoming Message (Length: %d) ->\n  Message Identifier: %v\n  Length: %v\n  Body: %s\n",
+					n, m.MessageID, m.Lenght, output)
+			}
 		}
 	}
 }
@@ -0,0 +1,264 @@
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+)
+
+// A CipherPolicy selects the TLS versions and cipher suites allowed on
+// a GCP control channel.
+type CipherPolicy int
+
+// TLS cipher policies.
+const (
+	// PolicyDefault accepts TLS 1.2 or later with Go's default suites.
+	PolicyDefault CipherPolicy = iota
+	// PolicyStrict accepts TLS 1.2 with forward secret AEAD suites only,
+	// or TLS 1.3.
+	PolicyStrict
+	// PolicyTLS13 accepts TLS 1.3 only.
+	PolicyTLS13
+)
+
+// strictSuites are the TLS 1.2 cipher suites allowed by PolicyStrict.
+var strictSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+}
+
+// Error messages
+var (
+	ErrIdentityMismatch = errors.New("certificate does not match the RPD identity")
+	errNoPeerCert       = errors.New("no peer certificate")
+	errUnverified       = errors.New("RPD identity not reported")
+)
+
+// TLSEnd is a TLS endpoint that satisfies the Transport interface.
+//
+// Both ends authenticate each other: each presents Certificate and
+// requires a peer certificate issued by one of CAs. When Identity is
+// set, the peer certificate must also identify that RPD, see
+// VerifyIdentity.
+type TLSEnd struct {
+	Host         string
+	Port         string
+	Certificate  tls.Certificate // Own certificate chain and private key
+	CAs          *x509.CertPool  // CAs trusted to issue peer certificates
+	Policy       CipherPolicy    // Allowed TLS versions and cipher suites
+	CipherSuites []uint16        // TLS 1.2 cipher suites, overriding those of Policy
+	Identity     *gcp.RpdIden    // Expected identity of the peer RPD, if any
+	Timeout      time.Duration   // Idle time before dropping a received connection, zero means no limit
+}
+
+// config returns the TLS settings shared by both ends.
+func (e TLSEnd) config() *tls.Config {
+	c := &tls.Config{
+		Certificates: []tls.Certificate{e.Certificate},
+		MinVersion:   tls.VersionTLS12,
+	}
+	switch e.Policy {
+	case PolicyStrict:
+		c.CipherSuites = strictSuites
+	case PolicyTLS13:
+		c.MinVersion = tls.VersionTLS13
+	}
+	if e.CipherSuites != nil {
+		c.CipherSuites = e.CipherSuites
+	}
+	if e.Identity != nil {
+		id := *e.Identity
+		c.VerifyPeerCertificate = func(_ [][]byte, chains [][]*x509.Certificate) error {
+			if len(chains) == 0 || len(chains[0]) == 0 {
+				return errNoPeerCert
+			}
+			return VerifyIdentity(chains[0][0], id)
+		}
+	}
+	return c
+}
+
+// ClientConfig returns the TLS configuration for connecting to a GCP
+// server with the endpoint's settings.
+func (e TLSEnd) ClientConfig() *tls.Config {
+	c := e.config()
+	c.RootCAs = e.CAs
+	c.ServerName = e.Host
+	return c
+}
+
+// ServerConfig returns the TLS configuration for accepting GCP
+// connections with the endpoint's settings.
+func (e TLSEnd) ServerConfig() *tls.Config {
+	c := e.config()
+	c.ClientCAs = e.CAs
+	c.ClientAuth = tls.RequireAndVerifyClientCert
+	return c
+}
+
+// Receive listens for new GCP/TLS messages.
+func (e TLSEnd) Receive() error {
+	listener, err := tls.Listen("tcp", ":"+e.Port, e.ServerConfig())
+	if err != nil {
+		return fmt.Errorf("failed to start the server: %v", err)
+	}
+	defer listener.Close()
+	for {
+		c, err := listener.Accept()
+		if err != nil {
+			log.Printf("failed to accept the connection: %v", err)
+			continue
+		}
+		go handleMessage(c, e.Timeout, nil)
+	}
+}
+
+// Send transmits a GCP/TLS message.
+func (e TLSEnd) Send(b []byte) error {
+	addr := net.JoinHostPort(e.Host, e.Port)
+	conn, err := tls.Dial("tcp", addr, e.ClientConfig())
+	if err != nil {
+		return fmt.Errorf("could not establish a connection: %v", err)
+	}
+	defer conn.Close()
+	t, err := encapsulate(0, 0, b).Marshal()
+	if err != nil {
+		return fmt.Errorf("failed to marshall a message: %v", err)
+	}
+	_, err = conn.Write(t)
+	if err != nil {
+		return fmt.Errorf("failed to send a message: %v", err)
+	}
+	return nil
+}
+
+// VerifyIdentity checks that cert was issued to the RPD described by id.
+// The SerialNumber of id must match the serial number attribute of the
+// certificate subject, and its DeviceMacAddress the subject's common
+// name, written with or without separators. Empty fields of id are not
+// checked.
+func VerifyIdentity(cert *x509.Certificate, id gcp.RpdIden) error {
+	if id.SerialNumber != "" && cert.Subject.SerialNumber != id.SerialNumber {
+		return fmt.Errorf("%v: serial number %q, want: %q", ErrIdentityMismatch, cert.Subject.SerialNumber, id.SerialNumber)
+	}
+	if id.DeviceMacAddress != "" && normMAC(cert.Subject.CommonName) != normMAC(id.DeviceMacAddress) {
+		return fmt.Errorf("%v: MAC address %q, want: %q", ErrIdentityMismatch, cert.Subject.CommonName, id.DeviceMacAddress)
+	}
+	return nil
+}
+
+// normMAC strips the separators of a MAC address and lowers its case.
+func normMAC(s string) string {
+	r := strings.NewReplacer(":", "", "-", "", ".", "")
+	return strings.ToLower(r.Replace(s))
+}
+
+// PeerCertificate returns the certificate presented by the peer, or nil
+// if the Session does not run over TLS.
+func (s *Session) PeerCertificate() *x509.Certificate {
+	c, ok := s.conn.(*tls.Conn)
+	if !ok {
+		return nil
+	}
+	certs := c.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil
+	}
+	return certs[0]
+}
+
+// maxHeld is the number of messages an identityCheck holds before
+// giving up on the RPD.
+const maxHeld = 64
+
+// An identityCheck holds the messages received from an RPD until it
+// reports, in a Notify Request or an EDS Response, an RpdIdentification
+// that matches the certificate it presented. An RpdIdentification
+// without the serial number or MAC address of the certificate fails it.
+type identityCheck struct {
+	cert     *x509.Certificate
+	verified bool
+	held     []*Request
+}
+
+// admit returns the messages of s ready to be dispatched once r is
+// received, closing s if the RPD identity does not match its certificate
+// or takes too long to be reported.
+func (c *identityCheck) admit(s *Session, r *Request) []*Request {
+	if c.verified {
+		return []*Request{r}
+	}
+	id, ok := reportedIdentity(r.Msg)
+	if !ok {
+		if len(c.held) == maxHeld {
+			log.Printf("dropping %s: %v", s.RemoteAddr().String(), errUnverified)
+			s.Close()
+			return nil
+		}
+		c.held = append(c.held, r)
+		return nil
+	}
+	err := VerifyIdentity(c.cert, id)
+	if err == nil && !identifies(c.cert, id) {
+		err = errUnverified
+	}
+	if err != nil {
+		log.Printf("dropping %s: %v", s.RemoteAddr().String(), err)
+		s.Close()
+		return nil
+	}
+	rs := append(c.held, r)
+	c.verified, c.held = true, nil
+	return rs
+}
+
+// identifies reports whether id ties the RPD to cert: it must carry the
+// serial number and MAC address the certificate names, and at least one
+// of them, as VerifyIdentity does not check empty fields.
+func identifies(cert *x509.Certificate, id gcp.RpdIden) bool {
+	switch {
+	case id.SerialNumber == "" && id.DeviceMacAddress == "":
+		return false
+	case cert.Subject.SerialNumber != "" && id.SerialNumber == "":
+		return false
+	case cert.Subject.CommonName != "" && id.DeviceMacAddress == "":
+		return false
+	}
+	return true
+}
+
+// reportedIdentity returns the RpdIdentification m carries, if it is a
+// Notify Request or an EDS Response that reports one.
+func reportedIdentity(m *gcp.Message) (gcp.RpdIden, bool) {
+	var id gcp.RpdIden
+	switch gcp.MessageID(m.MessageID) {
+	case gcp.MessageIDNotifyReq, gcp.MessageIDEDSRes:
+	default:
+		return id, false
+	}
+	t, err := m.Tree()
+	if err != nil {
+		return id, false
+	}
+	if _, err := t.Get("RpdCapabilities/RpdIdentification"); err != nil {
+		return id, false
+	}
+	if n, err := t.Get("RpdCapabilities/RpdIdentification/SerialNumber"); err == nil {
+		id.SerialNumber = n.String()
+	}
+	if n, err := t.Get("RpdCapabilities/RpdIdentification/DeviceMacAddress"); err == nil {
+		id.DeviceMacAddress = n.String()
+	}
+	return id, true
+}
@@ -0,0 +1,319 @@
+package transport_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/transport"
+)
+
+// pki holds locally generated certificates for testing.
+type pki struct {
+	ca   *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newPKI(t *testing.T) *pki {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("could not generate a key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "GCP Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("could not create the CA certificate: %v", err)
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("could not parse the CA certificate: %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	return &pki{ca: ca, key: key, pool: pool}
+}
+
+// issue returns a certificate for subject, valid for loopback.
+func (p *pki) issue(t *testing.T, subject pkix.Name) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("could not generate a key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      subject,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, p.ca, &key.PublicKey, p.key)
+	if err != nil {
+		t.Fatalf("could not create a certificate: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// The identity of the RPD that sent the ntf test message.
+var rpdSubject = pkix.Name{CommonName: "a0:f8:49:6f:43:1c", SerialNumber: "CAT2133E0A5"}
+
+// startTLSCore starts a Core with end's settings, reporting session
+// events on the returned channel.
+func startTLSCore(t *testing.T, end transport.TLSEnd) (*transport.Core, string, <-chan transport.Event) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	events := make(chan transport.Event, 10)
+	c := &transport.Core{
+		TLSConfig:     end.ServerConfig(),
+		CheckIdentity: true,
+		OnEvent:       func(e transport.Event) { events <- e },
+	}
+	go c.Serve(l)
+	return c, l.Addr().String(), events
+}
+
+func expectEvent(t *testing.T, events <-chan transport.Event, want transport.EventType) {
+	t.Helper()
+	select {
+	case e := <-events:
+		if e.Type != want {
+			t.Fatalf("Event got: %v (%v), want: %v", e.Type, e.Err, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for %v", want)
+	}
+}
+
+func TestTLSSession(t *testing.T) {
+	p := newPKI(t)
+	core, addr, events := startTLSCore(t, transport.TLSEnd{
+		Certificate: p.issue(t, pkix.Name{CommonName: "core"}),
+		CAs:         p.pool,
+		Policy:      transport.PolicyStrict,
+	})
+	defer core.Close()
+
+	tt := []struct {
+		name    string
+		subject pkix.Name
+		want    transport.EventType
+	}{
+		{name: "Matching identity", subject: rpdSubject, want: transport.EventConnected},
+		{name: "Serial number mismatch", subject: pkix.Name{CommonName: rpdSubject.CommonName, SerialNumber: "CAT0000X0X0"}, want: transport.EventDisconnected},
+		{name: "MAC address mismatch", subject: pkix.Name{CommonName: "a0f8.496f.4300", SerialNumber: rpdSubject.SerialNumber}, want: transport.EventDisconnected},
+	}
+	data, err := base64.StdEncoding.DecodeString(ntf)
+	if err != nil {
+		t.Fatalf("could not decode base64 notify message: %v", err)
+	}
+	m, err := gcp.ParseMessage(data)
+	if err != nil {
+		t.Fatalf("could not parse notify message: %v", err)
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			d := transport.Dialer{TLSConfig: transport.TLSEnd{
+				Host:        "127.0.0.1",
+				Certificate: p.issue(t, tc.subject),
+				CAs:         p.pool,
+			}.ClientConfig()}
+			s, err := d.Dial(addr, nil)
+			if err != nil {
+				t.Fatalf("could not connect: %v", err)
+			}
+			defer s.Close()
+			expectEvent(t, events, transport.EventConnected)
+
+			// The RPD reports its RpdIdentification on start up.
+			if err := s.Send(m); err != nil {
+				t.Fatalf("could not send notify message: %v", err)
+			}
+			select {
+			case e := <-events:
+				if tc.want == transport.EventConnected {
+					t.Fatalf("unexpected event: %v (%v)", e.Type, e.Err)
+				}
+				if e.Type != tc.want {
+					t.Fatalf("Event got: %v, want: %v", e.Type, tc.want)
+				}
+			case <-time.After(300 * time.Millisecond):
+				if tc.want != transport.EventConnected {
+					t.Fatalf("timed out waiting for %v", tc.want)
+				}
+			}
+			s.Close()
+			if tc.want == transport.EventConnected {
+				expectEvent(t, events, transport.EventDisconnected)
+			}
+		})
+	}
+}
+
+func TestTLSIdentityEDS(t *testing.T) {
+	p := newPKI(t)
+	end := transport.TLSEnd{
+		Certificate: p.issue(t, pkix.Name{CommonName: "core"}),
+		CAs:         p.pool,
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	events := make(chan transport.Event, 10)
+	served := make(chan uint8, 10)
+	core := &transport.Core{
+		TLSConfig:     end.ServerConfig(),
+		CheckIdentity: true,
+		Handler:       transport.HandlerFunc(func(s *transport.Session, r *transport.Request) { served <- r.Msg.MessageID }),
+		OnEvent:       func(e transport.Event) { events <- e },
+	}
+	go core.Serve(l)
+	defer core.Close()
+
+	// The RPD never notifies: it sends an EDS Request, then reports its
+	// identity in an EDS Response.
+	req := gcp.NewMessage(gcp.MessageIDEDSReq, &gcp.EDSReq{TransactionID: 1,
+		DataStr: gcp.EncodeSequence(gcp.TypeREX, 1, gcp.OpRead, gcp.EncodeTLV(100))})
+	mac, _ := net.ParseMAC(rpdSubject.CommonName)
+	res := gcp.NewMessage(gcp.MessageIDEDSRes, &gcp.EDSRes{TransactionID: 2,
+		DataStr: gcp.EncodeSequence(gcp.TypeREX, 1, gcp.OpReadResponse,
+			gcp.EncodeTLV(50, gcp.EncodeTLV(19, gcp.EncodeTLV(4, mac), gcp.EncodeTLV(9, []byte(rpdSubject.SerialNumber)))))})
+
+	empty := gcp.NewMessage(gcp.MessageIDEDSRes, &gcp.EDSRes{TransactionID: 2,
+		DataStr: gcp.EncodeSequence(gcp.TypeREX, 1, gcp.OpReadResponse, gcp.EncodeTLV(50, gcp.EncodeTLV(19)))})
+
+	tt := []struct {
+		name    string
+		subject pkix.Name
+		res     *gcp.Message // Response reporting the identity
+		want    []uint8      // Messages served
+	}{
+		{name: "Matching identity", subject: rpdSubject, res: res, want: []uint8{6, 7}},
+		{name: "Serial number mismatch", subject: pkix.Name{CommonName: rpdSubject.CommonName, SerialNumber: "CAT0000X0X0"}, res: res},
+		{name: "Empty identity", subject: rpdSubject, res: empty},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			d := transport.Dialer{TLSConfig: transport.TLSEnd{
+				Host:        "127.0.0.1",
+				Certificate: p.issue(t, tc.subject),
+				CAs:         p.pool,
+			}.ClientConfig()}
+			s, err := d.Dial(l.Addr().String(), nil)
+			if err != nil {
+				t.Fatalf("could not connect: %v", err)
+			}
+			defer s.Close()
+			expectEvent(t, events, transport.EventConnected)
+
+			if err := s.Send(req); err != nil {
+				t.Fatalf("could not send the EDS Request: %v", err)
+			}
+			select {
+			case id := <-served:
+				t.Fatalf("message %d served before the RPD identity was verified", id)
+			case <-time.After(100 * time.Millisecond):
+			}
+			if err := s.Send(tc.res); err != nil {
+				t.Fatalf("could not send the EDS Response: %v", err)
+			}
+			for _, want := range tc.want {
+				select {
+				case id := <-served:
+					if id != want {
+						t.Fatalf("Served got: %d, want: %d", id, want)
+					}
+				case <-time.After(2 * time.Second):
+					t.Fatalf("timed out waiting for message %d", want)
+				}
+			}
+			if tc.want != nil {
+				s.Close()
+			}
+			expectEvent(t, events, transport.EventDisconnected)
+			select {
+			case id := <-served:
+				t.Fatalf("unexpected message %d served", id)
+			default:
+			}
+		})
+	}
+}
+
+func TestTLSMutualAuth(t *testing.T) {
+	p, other := newPKI(t), newPKI(t)
+	core, addr, events := startTLSCore(t, transport.TLSEnd{
+		Certificate: p.issue(t, pkix.Name{CommonName: "core"}),
+		CAs:         p.pool,
+		Identity:    &gcp.RpdIden{SerialNumber: rpdSubject.SerialNumber},
+		Policy:      transport.PolicyTLS13,
+	})
+	defer core.Close()
+
+	tt := []struct {
+		name string
+		cert tls.Certificate
+	}{
+		{name: "No client certificate"},
+		{name: "Untrusted issuer", cert: other.issue(t, rpdSubject)},
+		{name: "Unexpected identity", cert: p.issue(t, pkix.Name{CommonName: rpdSubject.CommonName, SerialNumber: "CAT0000X0X0"})},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			end := transport.TLSEnd{Host: "127.0.0.1", CAs: p.pool, Certificate: tc.cert}
+			d := transport.Dialer{TLSConfig: end.ClientConfig()}
+			if s, err := d.Dial(addr, nil); err == nil {
+				defer s.Close()
+			}
+			select {
+			case e := <-events:
+				t.Fatalf("unexpected event: %v", e.Type)
+			case <-time.After(200 * time.Millisecond):
+			}
+		})
+	}
+}
+
+func TestVerifyIdentity(t *testing.T) {
+	cert := &x509.Certificate{Subject: rpdSubject}
+	tt := []struct {
+		id  gcp.RpdIden
+		err bool
+	}{
+		{id: gcp.RpdIden{}},
+		{id: gcp.RpdIden{SerialNumber: "CAT2133E0A5", DeviceMacAddress: "a0:f8:49:6f:43:1c"}},
+		{id: gcp.RpdIden{DeviceMacAddress: "A0-F8-49-6F-43-1C"}},
+		{id: gcp.RpdIden{DeviceMacAddress: "a0:f8:49:6f:43:1d"}, err: true},
+		{id: gcp.RpdIden{SerialNumber: "CAT2133E0A6"}, err: true},
+	}
+	for _, tc := range tt {
+		err := transport.VerifyIdentity(cert, tc.id)
+		if (err != nil) != tc.err {
+			t.Errorf("VerifyIdentity(%+v) got: %v, want error: %v", tc.id, err, tc.err)
+		}
+	}
+}
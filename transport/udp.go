@@ -0,0 +1,204 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync/atomic"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+)
+
+// Defaults for UDPEnd.
+const (
+	// DefaultMaxDatagram fits a GCP/UDP datagram in a 1500 bytes MTU
+	// IPv4 packet.
+	DefaultMaxDatagram     = 1472
+	DefaultRetryInterval   = 200 * time.Millisecond
+	DefaultDuplicateWindow = 10 * time.Second
+)
+
+// Error messages
+var (
+	ErrDatagramTooLarge = errors.New("datagram too large")
+	ErrNoAck            = errors.New("message not acknowledged")
+)
+
+// udpTranID is the last Transaction Identifier used by a UDPEnd.
+var udpTranID uint32
+
+// UDPEnd is a UDP endpoint that satisfies the Transport interface. It
+// uses the same encapsulation as TCPEnd, one unit per datagram.
+//
+// GCP over UDP is meant for messages that do not require reliability,
+// such as telemetry notifications. When Retries is set, Send asks the
+// receiver to acknowledge the message by giving it a non-zero
+// Transaction Identifier, and retransmits it until acknowledged. An
+// acknowledgement is an encapsulation unit carrying the Transaction
+// Identifier of the message it acknowledges and no GCP message. The
+// receiver delivers retransmitted copies of a message only once.
+type UDPEnd struct {
+	Host            string
+	Port            string
+	MaxDatagram     int                          // Largest datagram sent or accepted, DefaultMaxDatagram if zero
+	Retries         int                          // Retransmissions of an unacknowledged message, zero disables acknowledgements
+	RetryInterval   time.Duration                // Time to wait for an acknowledgement, DefaultRetryInterval if zero
+	DuplicateWindow time.Duration                // Time a received Transaction Identifier is remembered, DefaultDuplicateWindow if zero
+	Handler         func(net.Addr, *gcp.Message) // Called for each message received, messages are printed if nil
+}
+
+func (e UDPEnd) maxDatagram() int {
+	if e.MaxDatagram > 0 {
+		return e.MaxDatagram
+	}
+	return DefaultMaxDatagram
+}
+
+// Receive listens for new GCP/UDP messages.
+func (e UDPEnd) Receive() error {
+	c, err := net.ListenPacket("udp", ":"+e.Port)
+	if err != nil {
+		return fmt.Errorf("failed to start the server: %v", err)
+	}
+	defer c.Close()
+	return e.Serve(c)
+}
+
+// Serve reads GCP/UDP messages from c until it is closed.
+func (e UDPEnd) Serve(c net.PacketConn) error {
+	window := e.DuplicateWindow
+	if window == 0 {
+		window = DefaultDuplicateWindow
+	}
+	seen := newDedup(window)
+	max := e.maxDatagram()
+	// One extra byte tells oversized datagrams apart.
+	buf := make([]byte, max+1)
+	for {
+		n, addr, err := c.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		if n > max {
+			log.Printf("dropping datagram from %s: %v (%d bytes)", addr, ErrDatagramTooLarge, n)
+			continue
+		}
+		pkt, err := UnMarshal(buf[:n])
+		if err != nil || int(pkt.Len) != n-6 {
+			log.Printf("failed unmarshaling UDP message from %s", addr)
+			continue
+		}
+		if len(pkt.Msg) == 0 {
+			// Stray acknowledgement.
+			continue
+		}
+		if pkt.TranID != 0 {
+			ack, _ := encapsulate(pkt.TranID, pkt.UnitID, nil).Marshal()
+			if _, err := c.WriteTo(ack, addr); err != nil {
+				log.Printf("failed to acknowledge a message: %v", err)
+			}
+			if seen.check(addr.String(), pkt.TranID) {
+				continue
+			}
+		}
+		ms, err := messages(pkt.Msg)
+		if err != nil {
+			log.Printf("could not parse GCP message: %s\n", err.Error())
+		}
+		for _, m := range ms {
+			if e.Handler != nil {
+				e.Handler(addr, m)
+				continue
+			}
+			output, _ := m.Body.Process()
+			fmt.Printf("Incoming Message (Length: %d) ->\n  Message Identifier: %v\n  Length: %v\n  Body: %s\n",
+				n, m.MessageID, m.Lenght, output)
+		}
+	}
+}
+
+// Send transmits a GCP/UDP message.
+func (e UDPEnd) Send(b []byte) error {
+	var tid uint16
+	if e.Retries > 0 {
+		// A Transaction Identifier of 0 means to ignore this field.
+		for tid == 0 {
+			tid = uint16(atomic.AddUint32(&udpTranID, 1))
+		}
+	}
+	t, err := encapsulate(tid, 0, b).Marshal()
+	if err != nil {
+		return fmt.Errorf("failed to marshall a message: %v", err)
+	}
+	if len(t) > e.maxDatagram() {
+		return fmt.Errorf("could not send a message: %v (%d bytes)", ErrDatagramTooLarge, len(t))
+	}
+	conn, err := net.Dial("udp", net.JoinHostPort(e.Host, e.Port))
+	if err != nil {
+		return fmt.Errorf("could not establish a connection: %v", err)
+	}
+	defer conn.Close()
+
+	interval := e.RetryInterval
+	if interval == 0 {
+		interval = DefaultRetryInterval
+	}
+	ack := make([]byte, e.maxDatagram())
+	for i := 0; i <= e.Retries; i++ {
+		if _, err := conn.Write(t); err != nil {
+			return fmt.Errorf("failed to send a message: %v", err)
+		}
+		if tid == 0 {
+			return nil
+		}
+		conn.SetReadDeadline(time.Now().Add(interval))
+		for {
+			n, err := conn.Read(ack)
+			if err != nil {
+				break
+			}
+			if p, err := UnMarshal(ack[:n]); err == nil && p.TranID == tid {
+				return nil
+			}
+		}
+	}
+	return ErrNoAck
+}
+
+// A dedup remembers the Transaction Identifiers received from each peer
+// for a time window.
+type dedup struct {
+	window time.Duration
+	seen   map[dedupKey]time.Time
+}
+
+type dedupKey struct {
+	addr string
+	tid  uint16
+}
+
+func newDedup(window time.Duration) *dedup {
+	return &dedup{window: window, seen: make(map[dedupKey]time.Time)}
+}
+
+// check records tid from addr, reporting whether it was already seen
+// within the window.
+func (d *dedup) check(addr string, tid uint16) bool {
+	now := time.Now()
+	k := dedupKey{addr: addr, tid: tid}
+	if t, ok := d.seen[k]; ok && now.Sub(t) < d.window {
+		return true
+	}
+	d.seen[k] = now
+	// Forget expired entries once in a while.
+	if len(d.seen)%1024 == 0 {
+		for k, t := range d.seen {
+			if now.Sub(t) >= d.window {
+				delete(d.seen, k)
+			}
+		}
+	}
+	return false
+}
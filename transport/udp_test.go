@@ -0,0 +1,124 @@
+package transport_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+	"github.com/nleiva/gcp-rphy/transport"
+)
+
+// Pre-generated GCP Device Management (GDM) Request message for testing
+var dm = "BAAIAAEAAAAAAAA="
+
+// lossyConn drops the first datagrams read from or written to it.
+type lossyConn struct {
+	net.PacketConn
+	mu          sync.Mutex
+	dropReads   int
+	dropWrites  int
+	reads, acks int
+}
+
+func (c *lossyConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	for {
+		n, addr, err := c.PacketConn.ReadFrom(b)
+		c.mu.Lock()
+		c.reads++
+		drop := c.reads <= c.dropReads
+		c.mu.Unlock()
+		if err != nil || !drop {
+			return n, addr, err
+		}
+	}
+}
+
+func (c *lossyConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	c.mu.Lock()
+	c.acks++
+	drop := c.acks <= c.dropWrites
+	c.mu.Unlock()
+	if drop {
+		return len(b), nil
+	}
+	return c.PacketConn.WriteTo(b, addr)
+}
+
+func TestUDPSendReceive(t *testing.T) {
+	data, err := base64.StdEncoding.DecodeString(dm)
+	if err != nil {
+		t.Fatalf("could not decode base64 message: %v", err)
+	}
+	tt := []struct {
+		name       string
+		retries    int
+		dropReads  int
+		dropWrites int
+	}{
+		{name: "Unreliable"},
+		{name: "Reliable", retries: 3},
+		{name: "Lost message", retries: 3, dropReads: 1},
+		{name: "Lost acknowledgement", retries: 3, dropWrites: 1},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("could not listen: %v", err)
+			}
+			defer pc.Close()
+			msgs := make(chan *gcp.Message, 10)
+			server := transport.UDPEnd{Handler: func(_ net.Addr, m *gcp.Message) { msgs <- m }}
+			go server.Serve(&lossyConn{PacketConn: pc, dropReads: tc.dropReads, dropWrites: tc.dropWrites})
+
+			host, port, _ := net.SplitHostPort(pc.LocalAddr().String())
+			client := transport.UDPEnd{
+				Host:          host,
+				Port:          port,
+				Retries:       tc.retries,
+				RetryInterval: 50 * time.Millisecond,
+			}
+			if err := client.Send(data); err != nil {
+				t.Fatalf("could not send message: %v", err)
+			}
+			select {
+			case m := <-msgs:
+				b, _ := m.Marshal()
+				if !bytes.Equal(b, data) {
+					t.Fatalf("Message got: %v, want: %v", b, data)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("timed out waiting for the message")
+			}
+			// Retransmitted copies are suppressed.
+			select {
+			case <-msgs:
+				t.Fatalf("duplicate message delivered")
+			case <-time.After(200 * time.Millisecond):
+			}
+		})
+	}
+}
+
+func TestUDPLimits(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	defer pc.Close()
+	host, port, _ := net.SplitHostPort(pc.LocalAddr().String())
+
+	// Nobody acknowledges messages sent to pc.
+	client := transport.UDPEnd{Host: host, Port: port, Retries: 2, RetryInterval: 20 * time.Millisecond}
+	if err := client.Send([]byte{2, 0, 8, 0, 1, 0, 0, 0, 0, 0, 0}); err != transport.ErrNoAck {
+		t.Fatalf("Send got: %v, want: %v", err, transport.ErrNoAck)
+	}
+	client.MaxDatagram = 64
+	if err := client.Send(make([]byte, 64)); err == nil {
+		t.Fatalf("Send of an oversized datagram succeeded")
+	}
+}
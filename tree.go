@@ -0,0 +1,388 @@
+package gcp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error messages
+var (
+	ErrNoRCP    = errors.New("message carries no RCP TLVs")
+	ErrNotFound = errors.New("no TLV matches the query")
+)
+
+// A Tree is a decoded RCP message, whose TLVs can be queried by path
+// with Get and Find.
+//
+// A query is a list of steps separated by "/" or ".", each naming a TLV
+// by type number or by name, ignoring case, spaces and punctuation, or
+// "*" for any TLV. A step may be followed by a filter in brackets: a
+// zero based index among the TLVs the step matches, like IfEnet[0], or a
+// nested TLV and its value, like IfEnet[EnetPortIndex=1]. Values are
+// compared as rendered by Node.String or, for integers, as numbers.
+//
+// Queries start at the top level TLVs, like "REX/Sequence/RpdInfo" or
+// "2.9.100", or at the Sequences, where RCP objects are, when the first
+// step matches no top level TLV, like "RpdInfo" or "100".
+type Tree struct {
+	TLVs []*Node // Top level TLVs
+}
+
+// A Node is a TLV of a Tree.
+//
+// The Value of a TLV known to the decoder is typed according to its
+// Kind: uint8, uint16 or uint32 for integers, time.Duration for time
+// ticks, string, net.HardwareAddr, net.IP, time.Time for dates, and
+// []byte for opaque values. It is nil for Complex TLVs, and the raw bytes
+// for unknown TLVs and values whose length does not match their kind.
+type Node struct {
+	Type  uint8
+	Name  string      // Empty if the TLV is unknown to the decoder
+	Path  string      // Types of the TLVs leading to this one, dot separated, like "2.9.50.19.1"
+	Kind  ValueKind   // KindBytes if the TLV is unknown to the decoder
+	Value interface{} // Typed value
+	Raw   []byte      // Encoded value
+	Sub   []*Node     // TLVs nested in a Complex TLV
+	enum  map[uint32]string
+}
+
+// DecodeTree decodes the RCP TLVs in b. For malformed TLVs, it returns
+// the tree decoded up to them along with the error.
+func DecodeTree(b []byte) (*Tree, error) {
+	b = append([]byte(nil), b...)
+	t := new(Tree)
+	var stack []*Node
+	s := NewScanner(b)
+	for s.Next() {
+		n := newNode(s)
+		stack = stack[:s.Depth()-1]
+		if len(stack) == 0 {
+			t.TLVs = append(t.TLVs, n)
+		} else {
+			p := stack[len(stack)-1]
+			p.Sub = append(p.Sub, n)
+		}
+		stack = append(stack, n)
+	}
+	return t, s.Err()
+}
+
+// Tree decodes the RCP TLVs of the body of p.
+func (p *Message) Tree() (*Tree, error) {
+	for _, f := range BodyLayout(MessageID(p.MessageID)) {
+		if !f.RCP {
+			continue
+		}
+		b, err := p.Body.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		if len(b) < f.Offset {
+			return nil, ErrMessageTooShort
+		}
+		return DecodeTree(b[f.Offset:])
+	}
+	return nil, ErrNoRCP
+}
+
+// newNode returns the node of the current TLV of s.
+func newNode(s *Scanner) *Node {
+	p := make([]string, s.Depth())
+	for i, t := range s.Path() {
+		p[i] = strconv.Itoa(int(t))
+	}
+	n := &Node{Type: s.Type(), Path: strings.Join(p, "."), Raw: s.Value(), Value: s.Value()}
+	sc := s.Schema()
+	if sc == nil {
+		return n
+	}
+	n.Name, n.Kind, n.enum = sc.Name, sc.Kind, sc.Enum
+	if v, ok := typedValue(sc.Kind, n.Raw); ok {
+		n.Value = v
+	}
+	return n
+}
+
+// typedValue returns v, a value of kind k, as the Go type a Node holds,
+// and whether its length matches its kind.
+func typedValue(k ValueKind, v []byte) (interface{}, bool) {
+	if n := k.Size(); n > 0 && len(v) != n {
+		return nil, false
+	}
+	switch k {
+	case KindComplex:
+		return nil, true
+	case KindUint8:
+		return v[0], true
+	case KindUint16:
+		return binary.BigEndian.Uint16(v), true
+	case KindUint32:
+		return binary.BigEndian.Uint32(v), true
+	case KindTimeTicks:
+		return time.Duration(binary.BigEndian.Uint32(v)) * 10 * time.Millisecond, true
+	case KindString:
+		return string(v), true
+	case KindMAC:
+		return net.HardwareAddr(v), true
+	case KindIP:
+		if len(v) != 4 && len(v) != 16 {
+			return nil, false
+		}
+		return net.IP(v), true
+	case KindDateAndTime:
+		if len(v) != 8 && len(v) != 11 {
+			return nil, false
+		}
+		loc := time.UTC
+		if len(v) == 11 {
+			off := (int(v[9])*60 + int(v[10])) * 60
+			if v[8] == '-' {
+				off = -off
+			}
+			loc = time.FixedZone("", off)
+		}
+		return time.Date(int(binary.BigEndian.Uint16(v[0:2])), time.Month(v[2]), int(v[3]),
+			int(v[4]), int(v[5]), int(v[6]), int(v[7])*int(100*time.Millisecond), loc), true
+	}
+	return v, true
+}
+
+// Uint returns the value of n if it is an integer.
+func (n *Node) Uint() (uint32, bool) {
+	switch v := n.Value.(type) {
+	case uint8:
+		return uint32(v), true
+	case uint16:
+		return uint32(v), true
+	case uint32:
+		return v, true
+	}
+	return 0, false
+}
+
+// String renders the value of n: enumerated integers by name, time
+// ticks in seconds, dates like "2019-04-02 18:50:42.5 +08:00" and raw
+// bytes as 0x followed by hexadecimal digits. Complex TLVs render empty.
+func (n *Node) String() string {
+	if u, ok := n.Uint(); ok {
+		if name, ok := n.enum[u]; ok {
+			return name
+		}
+		return strconv.FormatUint(uint64(u), 10)
+	}
+	switch v := n.Value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Duration:
+		t := uint64(v / (10 * time.Millisecond))
+		return fmt.Sprintf("%d.%02ds", t/100, t%100)
+	case time.Time:
+		if v.Location() == time.UTC {
+			return v.Format("2006-01-02 15:04:05.0")
+		}
+		return v.Format("2006-01-02 15:04:05.0 -07:00")
+	case []byte:
+		return fmt.Sprintf("0x%x", v)
+	}
+	return fmt.Sprint(n.Value)
+}
+
+// Get returns the first TLV of t that matches query, or ErrNotFound.
+func (t *Tree) Get(query string) (*Node, error) {
+	ns, err := t.Find(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(ns) == 0 {
+		return nil, ErrNotFound
+	}
+	return ns[0], nil
+}
+
+// Find returns the TLVs of t that match query, in message order.
+func (t *Tree) Find(query string) ([]*Node, error) {
+	steps, err := parseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	root := []*Node{{Sub: t.TLVs}}
+	if len(steps[0].match(t.TLVs)) == 0 {
+		// Start at the Sequences.
+		root = nil
+		for _, n := range t.TLVs {
+			for _, s := range n.Sub {
+				if s.Type == 9 {
+					root = append(root, s)
+				}
+			}
+		}
+	}
+	for _, st := range steps {
+		var next []*Node
+		for _, n := range root {
+			next = append(next, st.match(n.Sub)...)
+		}
+		root = next
+	}
+	return root, nil
+}
+
+// A step is a step of a query.
+type step struct {
+	tlv   selector
+	index int      // Zero based index of the TLV among those matched, -1 for any
+	key   selector // Nested TLV the TLVs must have, if any
+	val   string   // Value of the nested TLV
+}
+
+// A selector names a TLV by type or normalized name.
+type selector struct {
+	typ  int // -1 when named
+	name string
+	any  bool
+	set  bool
+}
+
+func (s selector) matches(n *Node) bool {
+	switch {
+	case s.any:
+		return true
+	case s.typ >= 0:
+		return int(n.Type) == s.typ
+	}
+	return n.Name != "" && normName(n.Name) == s.name
+}
+
+// match returns the nodes of ns st matches.
+func (st step) match(ns []*Node) []*Node {
+	var m []*Node
+	for _, n := range ns {
+		if st.tlv.matches(n) && st.filter(n) {
+			m = append(m, n)
+		}
+	}
+	if st.index < 0 {
+		return m
+	}
+	if st.index >= len(m) {
+		return nil
+	}
+	return m[st.index : st.index+1]
+}
+
+// filter reports whether n has the nested TLV and value st requires.
+func (st step) filter(n *Node) bool {
+	if !st.key.set {
+		return true
+	}
+	for _, c := range n.Sub {
+		if !st.key.matches(c) {
+			continue
+		}
+		if strings.EqualFold(c.String(), st.val) {
+			return true
+		}
+		if u, ok := c.Uint(); ok && strconv.FormatUint(uint64(u), 10) == st.val {
+			return true
+		}
+	}
+	return false
+}
+
+// parseQuery returns the steps of query.
+func parseQuery(query string) ([]step, error) {
+	var (
+		steps []step
+		start int
+		depth int
+	)
+	for i := 0; i <= len(query); i++ {
+		if i < len(query) {
+			switch c := query[i]; {
+			case c == '[':
+				depth++
+				continue
+			case c == ']':
+				depth--
+				continue
+			case depth > 0 || c != '/' && c != '.':
+				continue
+			}
+		}
+		st, err := parseStep(query[start:i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid query %q: %v", query, err)
+		}
+		steps = append(steps, st)
+		start = i + 1
+	}
+	return steps, nil
+}
+
+// parseStep parses a step of a query, like IfEnet[EnetPortIndex=1].
+func parseStep(s string) (step, error) {
+	st := step{index: -1}
+	name := s
+	if i := strings.IndexByte(s, '['); i >= 0 {
+		if !strings.HasSuffix(s, "]") {
+			return st, fmt.Errorf("unterminated filter in %q", s)
+		}
+		name = s[:i]
+		f := s[i+1 : len(s)-1]
+		if k := strings.IndexByte(f, '='); k >= 0 {
+			key, err := parseSelector(f[:k])
+			if err != nil {
+				return st, err
+			}
+			st.key, st.val = key, strings.TrimSpace(f[k+1:])
+		} else {
+			n, err := strconv.Atoi(strings.TrimSpace(f))
+			if err != nil || n < 0 {
+				return st, fmt.Errorf("invalid index %q", f)
+			}
+			st.index = n
+		}
+	}
+	sel, err := parseSelector(name)
+	st.tlv = sel
+	return st, err
+}
+
+// parseSelector parses a TLV type, name or "*".
+func parseSelector(s string) (selector, error) {
+	s = strings.TrimSpace(s)
+	sel := selector{typ: -1, set: true}
+	switch {
+	case s == "*":
+		sel.any = true
+	case s == "":
+		return sel, errors.New("empty step")
+	case s[0] >= '0' && s[0] <= '9':
+		n, err := strconv.ParseUint(s, 10, 8)
+		if err != nil {
+			return sel, fmt.Errorf("invalid TLV type %q", s)
+		}
+		sel.typ = int(n)
+	default:
+		sel.name = normName(s)
+	}
+	return sel, nil
+}
+
+// normName returns s in lower case without spaces or punctuation.
+func normName(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
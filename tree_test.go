@@ -0,0 +1,156 @@
+package gcp_test
+
+import (
+	"encoding/base64"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	gcp "github.com/nleiva/gcp-rphy"
+)
+
+// tree returns the Tree of the GCP message b64.
+func tree(t *testing.T, b64 string) *gcp.Tree {
+	t.Helper()
+	b, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		t.Fatalf("could not decode message: %v", err)
+	}
+	m, err := gcp.ParseMessage(b)
+	if err != nil {
+		t.Fatalf("could not parse message: %v", err)
+	}
+	tr, err := m.Tree()
+	if err != nil {
+		t.Fatalf("could not decode the RCP TLVs: %v", err)
+	}
+	return tr
+}
+
+func TestTreeGet(t *testing.T) {
+	n, r := tree(t, ntf), tree(t, rex)
+	tt := []struct {
+		name  string
+		tree  *gcp.Tree
+		query string
+		path  string
+		value interface{}
+		str   string
+	}{
+		{name: "By type", tree: n, query: "50.19.1",
+			path: "3.9.50.19.1", value: "Cisco", str: "Cisco"},
+		{name: "By name", tree: n, query: "RpdCapabilities/RpdIdentification/DeviceMacAddress",
+			path: "3.9.50.19.4", value: net.HardwareAddr{0xa0, 0xf8, 0x49, 0x6f, 0x43, 0x1c}, str: "a0:f8:49:6f:43:1c"},
+		{name: "From the top level", tree: n, query: "Notify/Sequence/Sequence Number",
+			path: "3.9.10", value: uint16(1), str: "1"},
+		{name: "Enumerated", tree: n, query: "86.1",
+			path: "3.9.86.1", value: uint8(gcp.StartUpNotification), str: "StartUpNotification"},
+		{name: "Date", tree: n, query: "50.19.CurrentSwImageLastUpdate",
+			path: "3.9.50.19.19", value: time.Date(2019, 4, 2, 18, 50, 42, 5e8, time.UTC), str: "2019-04-02 18:50:42.5"},
+		{name: "Address", tree: n, query: "50/19/21",
+			path: "3.9.50.19.21", value: net.ParseIP("2001:578:1000:1111::245"), str: "2001:578:1000:1111::245"},
+		{name: "Filtered entry", tree: r, query: "RpdInfo/IfEnet[EnetPortIndex=1]/OperStatus",
+			path: "2.9.100.8.9", value: uint8(1), str: "up"},
+		{name: "Filtered by name", tree: r, query: "RpdInfo/IfEnet[OperStatus=lowerLayerDown]/Name",
+			path: "2.9.100.8.2", value: "vbh1", str: "vbh1"},
+		{name: "Filtered by address", tree: r, query: "100.15[IpAddress=192.168.1.1].3",
+			path: "2.9.100.15.3", value: uint8(3), str: "3"},
+		{name: "Indexed entry", tree: r, query: "RpdInfo/IfEnet[1]/LastChange",
+			path: "2.9.100.8.10", value: 137550 * time.Millisecond, str: "137.55s"},
+		{name: "Any type", tree: r, query: "*.*.100.8[0].6",
+			path: "2.9.100.8.6", value: uint32(1500), str: "1500"},
+	}
+	for _, tc := range tt {
+		got, err := tc.tree.Get(tc.query)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if got.Path != tc.path || !reflect.DeepEqual(got.Value, tc.value) || got.String() != tc.str {
+			t.Errorf("%s: got: %s %#v (%s), want: %s %#v (%s)", tc.name, got.Path, got.Value, got, tc.path, tc.value, tc.str)
+		}
+	}
+}
+
+func TestTreeFind(t *testing.T) {
+	r := tree(t, rex)
+	tt := []struct {
+		query string
+		want  []string
+	}{
+		{query: "RpdInfo/IfEnet/Name", want: []string{"vbh1", "vbh0"}},
+		{query: "RpdInfo/IpAddress[EnetPortIndex=7]/IpAddress", want: []string{"127.0.0.1", "::1"}},
+		{query: "RpdInfo/IpAddress[AddrType=1]/2", want: []string{"10.0.1.254", "127.0.0.1", "192.168.1.1"}},
+		{query: "RpdInfo/IfEnet[2]/Name"},
+		{query: "RpdCapabilities"},
+	}
+	for _, tc := range tt {
+		ns, err := r.Find(tc.query)
+		if err != nil {
+			t.Errorf("%s: %v", tc.query, err)
+			continue
+		}
+		var got []string
+		for _, n := range ns {
+			got = append(got, n.String())
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got: %q, want: %q", tc.query, got, tc.want)
+		}
+	}
+	if _, err := r.Get("RpdCapabilities"); err != gcp.ErrNotFound {
+		t.Errorf("Get of a missing TLV got: %v, want: %v", err, gcp.ErrNotFound)
+	}
+	for _, q := range []string{"", "RpdInfo/", "RpdInfo[0", "RpdInfo[-1]", "RpdInfo/256", "IfEnet[=1]"} {
+		if _, err := r.Find(q); err == nil {
+			t.Errorf("Find(%q) got no error", q)
+		}
+	}
+}
+
+func TestTreeUnknown(t *testing.T) {
+	b := append(gcp.EncodeSequence(gcp.TypeREX, 1, gcp.OpRead,
+		gcp.EncodeTLV(200, []byte{1, 2}),
+		gcp.EncodeTLV(100, gcp.EncodeTLV(8, gcp.EncodeTLV(1, []byte{1, 2}))),
+	), gcp.EncodeTLV(4, gcp.EncodeTLV(1))...)
+	tr, err := gcp.DecodeTree(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tt := []struct {
+		query string
+		path  string
+		name  string
+		str   string
+	}{
+		{query: "200", path: "2.9.200", str: "0x0102"},
+		// Values whose length does not match their kind are left raw.
+		{query: "100.8.EnetPortIndex", path: "2.9.100.8.1", name: "EnetPortIndex", str: "0x0102"},
+		// Unknown TLVs are not descended into.
+		{query: "4", path: "4", str: "0x010000"},
+	}
+	for _, tc := range tt {
+		n, err := tr.Get(tc.query)
+		if err != nil {
+			t.Errorf("%s: %v", tc.query, err)
+			continue
+		}
+		if n.Path != tc.path || n.Name != tc.name || n.String() != tc.str || len(n.Sub) != 0 {
+			t.Errorf("%s: got: %s %q %s, want: %s %q %s", tc.query, n.Path, n.Name, n, tc.path, tc.name, tc.str)
+		}
+		if _, ok := n.Value.([]byte); !ok {
+			t.Errorf("%s: Value got: %T, want: []byte", tc.query, n.Value)
+		}
+	}
+
+	// A malformed TLV ends the tree.
+	tr, err = gcp.DecodeTree(b[:len(b)-1])
+	if err == nil || len(tr.TLVs) != 1 {
+		t.Errorf("truncated TLVs got: %d top level TLVs, %v, want: 1 and an error", len(tr.TLVs), err)
+	}
+	m := gcp.NewMessage(gcp.MessageIDGDMReq, &gcp.DMReq{})
+	if _, err := m.Tree(); err != gcp.ErrNoRCP {
+		t.Errorf("Tree of a message without RCP TLVs got: %v, want: %v", err, gcp.ErrNoRCP)
+	}
+}
@@ -0,0 +1,437 @@
+package gcp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A Rule is a requirement of the GCP and RCP specifications on messages.
+type Rule int
+
+// Rules checked by Validate.
+const (
+	RuleLength   Rule = iota + 1 // Length fields match the bytes they measure
+	RuleReserved                 // Reserved bits are zero
+	RuleRange                    // Values are within their range
+	RuleMissing                  // Mandatory fields and TLVs are present
+)
+
+var ruleNames = map[Rule]string{
+	RuleLength:   "Length",
+	RuleReserved: "Reserved",
+	RuleRange:    "Range",
+	RuleMissing:  "Missing",
+}
+
+func (r Rule) String() string {
+	if n, ok := ruleNames[r]; ok {
+		return n
+	}
+	return fmt.Sprintf("Rule(%d)", int(r))
+}
+
+// A Violation is a departure of a message from a Rule.
+type Violation struct {
+	Rule   Rule
+	Offset int    // Offset in the message of the field at fault, -1 in a decoded data structure
+	Path   string // Types of the RCP TLVs leading to the field, dot separated, empty outside them
+	Name   string // Name of the field
+	Detail string // What is wrong with the field
+	// Code is the Return Code of the Error Response a request with the
+	// violation calls for, or MsgSuccess if the request is answered with
+	// a Normal Response, as violations in RCP TLVs are, with a
+	// ResponseCode.
+	Code RtrnCode
+}
+
+func (v Violation) String() string {
+	s := v.Name
+	if v.Path != "" {
+		s = v.Path + " " + s
+	}
+	if v.Offset >= 0 {
+		s = fmt.Sprintf("offset %d: %s", v.Offset, s)
+	}
+	return s + ": " + v.Detail
+}
+
+// maxStringLen is the length of the longest RCP string.
+const maxStringLen = 255
+
+// openEnums are the RCP TLVs with enumerated values the decoder names
+// only some of, by the types of the TLVs leading to them below the Top
+// Level TLV. Their values are not range checked.
+var openEnums = map[string]bool{
+	"9.16.12.2":  true, // RfChannelType
+	"9.100.8.4":  true, // Type (IANAifType)
+	"9.100.15.1": true, // AddrType (InetAddressType)
+}
+
+// valueRange returns the range of the values of the RCP TLV of types
+// path and schema s, from the lowest to the highest it names, or false
+// if its values are not range checked.
+func valueRange(path []uint8, s *TLVSchema) (lo, hi uint32, ok bool) {
+	if len(s.Enum) == 0 || s.Kind.Size() == 0 || openEnums[joinTypes(path[1:])] {
+		return 0, 0, false
+	}
+	lo = ^uint32(0)
+	for n := range s.Enum {
+		if n < lo {
+			lo = n
+		}
+		if n > hi {
+			hi = n
+		}
+	}
+	return lo, hi, true
+}
+
+// Validate checks m against the GCP and RCP specifications, and returns
+// the violations found, in the order of the bytes at fault.
+func (m *Message) Validate() []Violation {
+	b, err := m.Marshal()
+	if err != nil {
+		return []Violation{{Rule: RuleLength, Offset: 3, Name: "Message Body", Detail: err.Error(), Code: IllegalMsgLen}}
+	}
+	var v validator
+	v.message(b)
+	return v.vs
+}
+
+// A validator collects the violations of a message.
+type validator struct {
+	vs []Violation
+}
+
+func (v *validator) add(r Rule, off int, path []uint8, name string, code RtrnCode, format string, a ...interface{}) {
+	v.vs = append(v.vs, Violation{
+		Rule:   r,
+		Offset: off,
+		Path:   joinTypes(path),
+		Name:   name,
+		Detail: fmt.Sprintf(format, a...),
+		Code:   code,
+	})
+}
+
+// message checks b, a marshaled GCP message.
+func (v *validator) message(b []byte) {
+	id := MessageID(b[0])
+	body := b[3:]
+	if l := int(binary.BigEndian.Uint16(b[1:3])); l != len(body) {
+		v.add(RuleLength, 1, nil, "Message Length", IllegalMsgLen, "%d, but the body has %d bytes", l, len(body))
+	}
+	if id >= 128 {
+		// Error Responses, also to unsupported messages, are identified
+		// as the Normal Response with the most significant bit set, and
+		// carry a Transaction ID and a Return Code.
+		if id&1 == 0 {
+			v.add(RuleRange, 0, nil, "Message ID", MsgSuccess, "Error Response identifier %d is even", id)
+		}
+		if len(body) != 3 {
+			v.add(RuleLength, 3, nil, "Message Body", IllegalMsgLen, "%d bytes, want: 3", len(body))
+			return
+		}
+		if _, ok := rtrnCodeNames[RtrnCode(body[2])]; !ok {
+			v.add(RuleRange, 5, nil, "Return Code", MsgSuccess, "unknown Return Code %d", body[2])
+		}
+		return
+	}
+	if _, ok := messageNames[id]; !ok {
+		v.add(RuleRange, 0, nil, "Message ID", UnsupportedMsg, "unknown Message Identifier %d", id)
+		return
+	}
+	fs := BodyLayout(id)
+	if fs == nil {
+		return
+	}
+	fixed, rcp := 0, false
+	for _, f := range fs {
+		fixed += f.Size
+		rcp = rcp || f.RCP
+	}
+	switch {
+	case len(body) < fixed:
+		v.add(RuleLength, 3, nil, "Message Body", IllegalMsgLen, "%d bytes, want at least %d", len(body), fixed)
+		return
+	case len(body) > fixed && !rcp:
+		v.add(RuleLength, 3+fixed, nil, "Message Body", IllegalMsgLen, "%d bytes beyond its fields", len(body)-fixed)
+	}
+	for _, f := range fs {
+		off := 3 + f.Offset
+		switch f.Name {
+		case "Mode":
+			if r := Mode(b[off]) & ModeReserved; r != 0 {
+				v.add(RuleReserved, off, nil, "Mode", IllegalMode, "reserved bits %#02x set", uint8(r))
+			}
+		case "VendorID":
+			if binary.BigEndian.Uint32(b[off:]) == 0 {
+				v.add(RuleRange, off, nil, "Vendor ID", IllegalVendorID, "reserved Vendor ID 0")
+			}
+		}
+		if f.RCP {
+			v.tlvs(b, off, len(b), nil, schemaOf(nil), 0)
+		}
+	}
+}
+
+// schemaOf returns the schema of the TLV at path, or of the Top Level
+// TLVs if path is empty.
+func schemaOf(path []uint8) *TLVSchema {
+	Schema()
+	if len(path) == 0 {
+		return schemaRoot
+	}
+	return LookupTLV(path...)
+}
+
+// tlvs checks the TLVs of b from off to end, nested in the TLVs of types
+// path, whose schema is s, and in a Sequence with operation op.
+func (v *validator) tlvs(b []byte, off, end int, path []uint8, s *TLVSchema, op Operation) {
+	for i := off; i < end; {
+		if end-i < 3 {
+			v.add(RuleLength, i, path, "TLV", MsgSuccess, "%d bytes left, want at least 3", end-i)
+			return
+		}
+		p := append(path[:len(path):len(path)], b[i])
+		l := int(binary.BigEndian.Uint16(b[i+1 : i+3]))
+		c := s.Child(b[i])
+		name := "TLV"
+		if c != nil {
+			name = c.Name
+		}
+		if i+3+l > end {
+			v.add(RuleLength, i+1, p, name, MsgSuccess, "length %d exceeds the %d bytes left", l, end-i-3)
+			return
+		}
+		switch {
+		case c == nil && len(path) == 0:
+			v.add(RuleRange, i, p, name, MsgSuccess, "unknown Top Level TLV type %d", b[i])
+		case c == nil:
+			// Attributes unknown to the decoder are the receiver's to
+			// report, with a ResponseCode.
+		case c.Kind == KindComplex:
+			if len(path) == 1 && b[i] == 9 {
+				op = v.sequence(b, i, i+3+l, p)
+			}
+			v.tlvs(b, i+3, i+3+l, p, c, op)
+		default:
+			v.value(b[i+3:i+3+l], i, p, c, op)
+		}
+		if len(path) == 0 && c != nil && !hasType(b[i+3:i+3+l], 9) {
+			v.add(RuleMissing, i, p, name, MsgSuccess, "no Sequence TLV")
+		}
+		i += 3 + l
+	}
+}
+
+// sequence checks that the Sequence TLV at off, up to end, carries the
+// TLVs its operation requires, and returns the operation.
+func (v *validator) sequence(b []byte, off, end int, path []uint8) Operation {
+	types := make(map[uint8][]byte)
+	objects := 0
+	for i := off + 3; i+3 <= end; {
+		switch b[i] {
+		case 10, 11, 19:
+		default:
+			objects++
+		}
+		l := int(binary.BigEndian.Uint16(b[i+1 : i+3]))
+		if i+3+l > end {
+			// Reported by tlvs.
+			break
+		}
+		types[b[i]] = b[i+3 : i+3+l]
+		i += 3 + l
+	}
+	if _, ok := types[10]; !ok {
+		v.add(RuleMissing, off, path, "Sequence", MsgSuccess, "no SequenceNumber TLV")
+	}
+	ov, ok := types[11]
+	if !ok {
+		v.add(RuleMissing, off, path, "Sequence", MsgSuccess, "no Operation TLV")
+		return 0
+	}
+	if len(ov) != 1 {
+		return 0
+	}
+	op := Operation(ov[0])
+	switch op {
+	case OpRead, OpWrite, OpDelete, OpAllocateWrite:
+		if objects == 0 {
+			v.add(RuleMissing, off, path, "Sequence", MsgSuccess, "no objects to operate on")
+		}
+	case OpReadResponse, OpWriteResponse, OpDeleteResponse, OpAllocateWriteResponse:
+		if _, ok := types[19]; !ok {
+			v.add(RuleMissing, off, path, "Sequence", MsgSuccess, "no ResponseCode TLV in a response")
+		}
+	}
+	return op
+}
+
+// value checks the value b of the TLV at off, of types path and schema
+// s, in a Sequence with operation op.
+func (v *validator) value(b []byte, off int, path []uint8, s *TLVSchema, op Operation) {
+	if len(b) == 0 && (op == OpRead || op == OpDelete) {
+		// Reads and deletes name the attributes without a value.
+		return
+	}
+	want := ""
+	switch s.Kind {
+	case KindIP:
+		if len(b) != 4 && len(b) != 16 {
+			want = "4 or 16"
+		}
+	case KindDateAndTime:
+		if len(b) != 8 && len(b) != 11 {
+			want = "8 or 11"
+		}
+	case KindString:
+		if len(b) > maxStringLen {
+			want = "at most " + strconv.Itoa(maxStringLen)
+		}
+	default:
+		if n := s.Kind.Size(); n != 0 && len(b) != n {
+			want = strconv.Itoa(n)
+		}
+	}
+	if want != "" {
+		v.add(RuleLength, off+1, path, s.Name, MsgSuccess, "%d bytes, want: %s", len(b), want)
+		return
+	}
+	lo, hi, ok := valueRange(path, s)
+	if !ok {
+		return
+	}
+	var n uint32
+	for _, c := range b {
+		n = n<<8 | uint32(c)
+	}
+	if n < lo || n > hi {
+		v.add(RuleRange, off+3, path, s.Name, MsgSuccess, "value %d, want %d to %d", n, lo, hi)
+	}
+}
+
+// hasType reports whether the TLVs of b include one of type typ.
+func hasType(b []byte, typ uint8) bool {
+	for i := 0; i+3 <= len(b); i += 3 + int(binary.BigEndian.Uint16(b[i+1:i+3])) {
+		if b[i] == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// joinTypes returns the TLV types of path, dot separated.
+func joinTypes(path []uint8) string {
+	s := make([]string, len(path))
+	for i, t := range path {
+		s[i] = strconv.Itoa(int(t))
+	}
+	return strings.Join(s, ".")
+}
+
+// Validate checks the Sequences of the data structure g, as decoded by
+// Process, for the TLVs their operations require, and for enumerated
+// values out of range or that could not be decoded. Violations have an
+// Offset of -1.
+func (g *GCP) Validate() []Violation {
+	var v validator
+	for _, d := range []struct {
+		typ uint8
+		seq *dSeq
+	}{{TypeIRA, g.IRA}, {TypeREX, g.REX}, {TypeNTF, g.NTF}} {
+		if d.seq == nil {
+			continue
+		}
+		s := &d.seq.Sequence
+		path := []uint8{d.typ, 9}
+		if s.SequenceNumber == "" {
+			v.add(RuleMissing, -1, path, "Sequence", MsgSuccess, "no SequenceNumber")
+		}
+		objects := s.RpdCapabilities != nil || s.RpdRedirect != nil || s.GeneralNtf != nil || s.RpdInfo != nil ||
+			s.RfChannel != nil || s.RfPort != nil || s.CcapCoreIdentification != nil
+		switch s.Operation {
+		case "":
+			v.add(RuleMissing, -1, path, "Sequence", MsgSuccess, "no known Operation")
+		case "Read", "Write", "Delete", "AllocateWrite":
+			if !objects {
+				v.add(RuleMissing, -1, path, "Sequence", MsgSuccess, "no objects to operate on")
+			}
+		default:
+			if s.ResponseCode == "" {
+				v.add(RuleMissing, -1, path, "Sequence", MsgSuccess, "no ResponseCode in a response")
+			}
+		}
+		v.enums(path, enumValue{19, s.ResponseCode})
+		if n := s.GeneralNtf; n != nil {
+			v.enums(append(path, 86), enumValue{1, n.NotificationType})
+		}
+		if i := s.RpdInfo; i != nil {
+			for _, e := range i.IfEnet {
+				v.enums(append(path, 100, 8),
+					enumValue{4, e.Type}, enumValue{8, e.AdminStatus}, enumValue{9, e.OperStatus},
+					enumValue{12, e.LinkUpDownTrapEnable}, enumValue{13, e.PromiscuousMode})
+			}
+			for _, a := range i.IPAddress {
+				v.enums(append(path, 100, 15),
+					enumValue{1, a.AddrType}, enumValue{4, a.Type}, enumValue{6, a.Origin}, enumValue{7, a.Status})
+			}
+		}
+		for _, c := range s.RfChannel {
+			v.enums(append(path, 16, 12), enumValue{2, c.RfChannelSelector.RfChannelType})
+		}
+		for _, p := range s.RfPort {
+			v.enums(append(path, 17, 12), enumValue{2, p.RfPortSelector.RfPortType})
+		}
+		for _, c := range s.CcapCoreIdentification {
+			v.enums(append(path, 60),
+				enumValue{4, c.IsPrincipal}, enumValue{7, c.CoreMode},
+				enumValue{8, c.InitialConfigurationComplete}, enumValue{9, c.MoveToOperational})
+		}
+	}
+	return v.vs
+}
+
+// An enumValue is the value of an enumerated RCP TLV of type typ, as
+// decoded by Process: the name of the value, or the value if it has no
+// name.
+type enumValue struct {
+	typ uint8
+	s   string
+}
+
+// enums checks the enumerated values es of the TLVs nested in the TLV of
+// types path.
+func (v *validator) enums(path []uint8, es ...enumValue) {
+	for _, e := range es {
+		p := append(path[:len(path):len(path)], e.typ)
+		s := LookupTLV(p...)
+		if e.s == "" || s == nil || isName(s.Enum, e.s) {
+			continue
+		}
+		n, err := strconv.ParseUint(e.s, 10, 32)
+		switch {
+		case strings.HasPrefix(e.s, "unexpected lenght"):
+			v.add(RuleLength, -1, p, s.Name, MsgSuccess, "%s", e.s)
+		case err != nil:
+			v.add(RuleRange, -1, p, s.Name, MsgSuccess, "unknown value")
+		default:
+			if lo, hi, ok := valueRange(p, s); ok && (uint32(n) < lo || uint32(n) > hi) {
+				v.add(RuleRange, -1, p, s.Name, MsgSuccess, "value %d, want %d to %d", n, lo, hi)
+			}
+		}
+	}
+}
+
+// isName reports whether s is one of names.
+func isName(names map[uint32]string, s string) bool {
+	for _, n := range names {
+		if n == s {
+			return true
+		}
+	}
+	return false
+}
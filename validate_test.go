@@ -0,0 +1,114 @@
+package gcp_test
+
+import (
+	"encoding/base64"
+	"testing"
+
+	gcp "github.com/nleiva/gcp-rphy"
+)
+
+func TestValidate(t *testing.T) {
+	eds := func(ds []byte) *gcp.Message {
+		return gcp.NewMessage(gcp.MessageIDEDSReq, &gcp.EDSReq{TransactionID: 1, VendorID: gcp.CableLabs, DataStr: ds})
+	}
+	read := eds(gcp.EncodeSequence(gcp.TypeIRA, 1, gcp.OpRead, gcp.EncodeTLV(50, gcp.EncodeTLV(19, gcp.EncodeTLV(1)))))
+	body := func(m *gcp.Message) []byte { b, _ := m.Body.Marshal(); return b }
+	raw := func(id uint8, b []byte) *gcp.Message {
+		return &gcp.Message{MessageID: id, Lenght: uint16(len(b)), Body: &gcp.RawBody{Data: b}}
+	}
+
+	tt := []struct {
+		name string
+		msg  *gcp.Message
+		want []gcp.Violation // Only the Rule, Offset, Path, Name and Code are compared
+	}{
+		{name: "Valid read", msg: read},
+		{name: "Message Length", msg: &gcp.Message{MessageID: read.MessageID, Lenght: read.Lenght + 1, Body: read.Body},
+			want: []gcp.Violation{{Rule: gcp.RuleLength, Offset: 1, Name: "Message Length", Code: gcp.IllegalMsgLen}}},
+		{name: "Unknown Message ID", msg: raw(100, body(read)),
+			want: []gcp.Violation{{Rule: gcp.RuleRange, Offset: 0, Name: "Message ID", Code: gcp.UnsupportedMsg}}},
+		{name: "Truncated body", msg: raw(6, body(read)[:5]),
+			want: []gcp.Violation{{Rule: gcp.RuleLength, Offset: 3, Name: "Message Body", Code: gcp.IllegalMsgLen}}},
+		{name: "Reserved Mode bits", msg: gcp.NewMessage(gcp.MessageIDNotifyRes, &gcp.NotifyRes{Mode: 0x81}),
+			want: []gcp.Violation{{Rule: gcp.RuleReserved, Offset: 5, Name: "Mode", Code: gcp.IllegalMode}}},
+		{name: "Vendor ID", msg: gcp.NewMessage(gcp.MessageIDEDSReq, &gcp.EDSReq{DataStr: body(read)[12:]}),
+			want: []gcp.Violation{{Rule: gcp.RuleRange, Offset: 10, Name: "Vendor ID", Code: gcp.IllegalVendorID}}},
+		{name: "Error Response", msg: gcp.ErrorResponse(read, 42),
+			want: []gcp.Violation{{Rule: gcp.RuleRange, Offset: 5, Name: "Return Code"}}},
+		{name: "Overflowing TLV", msg: eds(gcp.EncodeSequence(gcp.TypeIRA, 1, gcp.OpRead, []byte{50, 0, 9, 19, 0, 0})),
+			want: []gcp.Violation{{Rule: gcp.RuleLength, Offset: 31, Path: "1.9.50", Name: "RpdCapabilities"}}},
+		{name: "Value length", msg: eds(gcp.EncodeSequence(gcp.TypeREX, 1, gcp.OpWrite, gcp.EncodeTLV(50, gcp.EncodeTLV(19, gcp.EncodeTLV(4, []byte{1, 2}))))),
+			want: []gcp.Violation{{Rule: gcp.RuleLength, Offset: 37, Path: "2.9.50.19.4", Name: "DeviceMacAddress"}}},
+		{name: "Value range", msg: eds(gcp.EncodeSequence(gcp.TypeNTF, 1, gcp.Operation(9), gcp.EncodeTLV(86, gcp.EncodeTLV(1, []byte{0})))),
+			want: []gcp.Violation{
+				{Rule: gcp.RuleRange, Offset: 29, Path: "3.9.11", Name: "Operation"},
+				{Rule: gcp.RuleRange, Offset: 36, Path: "3.9.86.1", Name: "NotificationType"},
+			}},
+		{name: "Enumerated value", msg: eds(gcp.EncodeSequence(gcp.TypeREX, 1, gcp.OpWrite, gcp.EncodeTLV(60, gcp.EncodeTLV(1, []byte{0}), gcp.EncodeTLV(7, []byte{9})))),
+			want: []gcp.Violation{{Rule: gcp.RuleRange, Offset: 40, Path: "2.9.60.7", Name: "CoreMode"}}},
+		{name: "Missing objects", msg: eds(gcp.EncodeSequence(gcp.TypeIRA, 1, gcp.OpRead)),
+			want: []gcp.Violation{{Rule: gcp.RuleMissing, Offset: 18, Path: "1.9", Name: "Sequence"}}},
+		{name: "Missing ResponseCode", msg: eds(gcp.EncodeSequence(gcp.TypeIRA, 1, gcp.OpReadResponse, gcp.EncodeTLV(50))),
+			want: []gcp.Violation{{Rule: gcp.RuleMissing, Offset: 18, Path: "1.9", Name: "Sequence"}}},
+		{name: "Missing Sequence", msg: eds(gcp.EncodeTLV(gcp.TypeREX)),
+			want: []gcp.Violation{{Rule: gcp.RuleMissing, Offset: 15, Path: "2", Name: "REX"}}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.msg.Validate()
+			if len(got) != len(tc.want) {
+				t.Fatalf("Validate got: %v, want %d violations", got, len(tc.want))
+			}
+			for i, v := range got {
+				w := tc.want[i]
+				if v.Rule != w.Rule || v.Offset != w.Offset || v.Path != w.Path || v.Name != w.Name || v.Code != w.Code {
+					t.Errorf("violation %d got: %+v, want: %+v", i, v, w)
+				}
+				if v.Detail == "" {
+					t.Errorf("violation %d has no detail: %v", i, v)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateDataStructure(t *testing.T) {
+	for _, s := range []string{ntf, rex, ira} {
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := gcp.ParseMessage(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if vs := m.Validate(); len(vs) != 0 {
+			t.Errorf("Validate of a captured message got: %v", vs)
+		}
+		_, g := m.Body.Process()
+		if vs := g.Validate(); len(vs) != 0 {
+			t.Errorf("Validate of its data structure got: %v", vs)
+		}
+	}
+
+	m := gcp.NewMessage(gcp.MessageIDEDSRes, &gcp.EDSRes{
+		DataStr: gcp.EncodeSequence(gcp.TypeREX, 1, gcp.OpReadResponse, gcp.EncodeTLV(19, []byte{99})),
+	})
+	_, g := m.Body.Process()
+	vs := g.Validate()
+	if len(vs) != 1 || vs[0].Rule != gcp.RuleRange || vs[0].Path != "2.9.19" || vs[0].Offset != -1 {
+		t.Errorf("Validate of an unknown ResponseCode got: %v", vs)
+	}
+	if got, want := vs[0].String(), "2.9.19 ResponseCode: unknown value"; len(vs) == 1 && got != want {
+		t.Errorf("String got: %q, want: %q", got, want)
+	}
+
+	m = gcp.NewMessage(gcp.MessageIDNotifyReq, &gcp.NotifyReq{
+		EvntData: gcp.EncodeSequence(gcp.TypeNTF, 1, gcp.OpWrite, gcp.EncodeTLV(86, gcp.EncodeTLV(1, []byte{0}))),
+	})
+	_, g = m.Body.Process()
+	vs = g.Validate()
+	if len(vs) != 1 || vs[0].Rule != gcp.RuleRange || vs[0].Path != "3.9.86.1" || vs[0].Detail != "value 0, want 1 to 11" {
+		t.Errorf("Validate of a NotificationType out of range got: %v", vs)
+	}
+}